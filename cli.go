@@ -0,0 +1,246 @@
+//cli.go
+
+package main
+
+import (
+	"SimpleGit/config"
+	"SimpleGit/database"
+	"SimpleGit/handlers"
+	"SimpleGit/models"
+	"SimpleGit/services"
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"gorm.io/gorm"
+)
+
+// errUsage is returned by commands called with the wrong arguments; the
+// usage of the command is printed instead of the error.
+var errUsage = errors.New("usage")
+
+// errServerRunning is returned by lockDataDir while the server runs.
+var errServerRunning = errors.New("the server is running")
+
+// lockFileName is the file in the data directory the server locks while it
+// runs.
+const lockFileName = "simplegit.lock"
+
+// command is a subcommand of the server binary. Commands act directly on
+// the database and the repository directory. Users and SSH keys live only
+// in the database, so their commands also work while the server runs. The
+// server keeps the repositories in memory, so commands changing them are
+// stopped: they refuse to run while the server holds the lock on the data
+// directory, and hold it themselves so the server cannot start meanwhile.
+type command struct {
+	name    string // The words selecting the command, such as "user create"
+	args    string // The arguments, for the usage
+	summary string
+	run     func(args []string) error
+	stopped bool // Only runs while the server is stopped
+}
+
+var commands = []command{
+	{"serve", "", "Run the HTTP and SSH servers (the default)", nil, false},
+	{"user create", "[--admin] [--password <password>] <username> <email>", "Create a user", userCreate, false},
+	{"user delete", "<username>", "Delete a user, their keys, tokens and memberships", userDelete, false},
+	{"user list", "", "List the users", userList, false},
+	{"user set-admin", "<username> <true|false>", "Grant or revoke site admin rights", userSetAdmin, false},
+	{"user reset-password", "[--password <password>] <username>", "Set a new password", userResetPassword, false},
+	{"repo create", "[--description <text>] <owner/name>", "Create an empty repository", repoCreate, true},
+	{"repo delete", "[--purge] <owner/name>", "Delete a repository; --purge skips the restore window", repoDelete, true},
+	{"repo list", "", "List the repositories", repoList, false},
+	{"repo import", "[--description <text>] <owner/name> <url|bundle>", "Create a repository from a git URL or a bundle file", repoImport, true},
+	{"key add", "[--name <name>] <username> <key|file|->", "Add an SSH public key to a user", keyAdd, false},
+	{"key list", "<username>", "List the SSH keys of a user", keyList, false},
+	{"backup", "", "Write a backup set to the backup directory", runBackup, false},
+	{"restore", "<backup set>", "Restore a backup set into an empty installation", runRestore, true},
+	{"doctor", "[--full]", "Check the configuration, the database and the repositories", runDoctor, false},
+}
+
+// run runs the command selected by args and returns the exit status.
+// Without arguments it runs the server.
+func run(args []string) int {
+	if len(args) == 0 || args[0] == "serve" {
+		config.Init()
+		return serve()
+	}
+	if args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		usage(os.Stdout, "")
+		return 0
+	}
+
+	cmd, rest := findCommand(args)
+	if cmd == nil {
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n\n", strings.Join(args, " "))
+		usage(os.Stderr, args[0])
+		return 2
+	}
+
+	// Commands report what they did themselves
+	log.SetOutput(io.Discard)
+	if err := config.Load(); err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		return 1
+	}
+	// Development mode logs every query on standard output
+	config.GlobalConfig.DevMode = false
+
+	if cmd.stopped {
+		unlock, err := lockDataDir()
+		if errors.Is(err, errServerRunning) {
+			fmt.Fprintf(os.Stderr, "Error: %v; stop it before running %s, or use the web interface or the API\n", err, cmd.name)
+			return 1
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			return 1
+		}
+		defer unlock()
+	}
+
+	switch err := cmd.run(rest); {
+	case errors.Is(err, errUsage):
+		fmt.Fprintf(os.Stderr, "Usage: %s %s %s\n", programName(), cmd.name, cmd.args)
+		return 2
+	case err != nil:
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		return 1
+	}
+	return 0
+}
+
+// findCommand returns the command named by the first words of args and the
+// remaining arguments.
+func findCommand(args []string) (*command, []string) {
+	for i := range commands {
+		words := strings.Fields(commands[i].name)
+		if commands[i].run == nil || len(args) < len(words) {
+			continue
+		}
+		if strings.Join(args[:len(words)], " ") == commands[i].name {
+			return &commands[i], args[len(words):]
+		}
+	}
+	return nil, nil
+}
+
+// usage lists the commands starting with the word prefix, or all of them
+// if none does.
+func usage(w io.Writer, prefix string) {
+	var list []command
+	for _, cmd := range commands {
+		if strings.HasPrefix(cmd.name+" ", prefix+" ") {
+			list = append(list, cmd)
+		}
+	}
+	if len(list) == 0 {
+		list = commands
+	}
+
+	fmt.Fprintf(w, "Usage: %s [command]\n\nCommands:\n", programName())
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, cmd := range list {
+		fmt.Fprintf(tw, "  %s %s\t%s\n", cmd.name, cmd.args, cmd.summary)
+	}
+	tw.Flush()
+}
+
+func programName() string {
+	return filepath.Base(os.Args[0])
+}
+
+// parseFlags parses the flags of a command, which may come before, after or
+// between its arguments, and returns the arguments. It fails with errUsage
+// unless there are exactly n arguments.
+func parseFlags(fs *flag.FlagSet, args []string, n int) ([]string, error) {
+	fs.SetOutput(io.Discard)
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			if !errors.Is(err, flag.ErrHelp) {
+				fmt.Fprintln(os.Stderr, "Error:", err)
+			}
+			return nil, errUsage
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			break
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+	if len(positional) != n {
+		return nil, errUsage
+	}
+	return positional, nil
+}
+
+// openDB opens the database of the installation.
+func openDB() (*gorm.DB, error) {
+	return database.InitDB(config.GlobalConfig.DataDir)
+}
+
+// openServer loads the installation like the server does, without serving
+// it, for the commands that change repositories.
+func openServer() (*handlers.Server, *models.UserService, error) {
+	db, err := openDB()
+	if err != nil {
+		return nil, nil, err
+	}
+	server, userService, err := newServer(db)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := server.MigrateFlatRepositories(); err != nil {
+		return nil, nil, err
+	}
+	if err := server.ScanRepositories(); err != nil {
+		return nil, nil, err
+	}
+	return server, userService, nil
+}
+
+// newServer creates a server on db that has not loaded the repositories.
+func newServer(db *gorm.DB) (*handlers.Server, *models.UserService, error) {
+	userService := models.NewUserService(db, []byte(config.GlobalConfig.JWTSecret))
+
+	server, err := handlers.NewServer(config.GlobalConfig.RepoPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	server.LFS, err = services.NewLocalLFSStore(lfsDir())
+	if err != nil {
+		return nil, nil, err
+	}
+	server.SetDB(db)
+	server.SetUserService(userService)
+	return server, userService, nil
+}
+
+// readPassword returns password, or reads it from the first line of
+// standard input if it is empty.
+func readPassword(password string) (string, error) {
+	if password == "" {
+		if info, err := os.Stdin.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+			fmt.Fprint(os.Stderr, "Password: ")
+		}
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			return "", fmt.Errorf("no password given")
+		}
+		password = strings.TrimRight(line, "\r\n")
+	}
+	if password == "" {
+		return "", fmt.Errorf("the password cannot be empty")
+	}
+	return password, nil
+}
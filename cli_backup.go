@@ -0,0 +1,60 @@
+//cli_backup.go
+
+package main
+
+import (
+	"SimpleGit/config"
+	"SimpleGit/services"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gorm.io/gorm"
+)
+
+func newBackups(db *gorm.DB) *services.Backups {
+	return services.NewBackups(db, config.GlobalConfig.RepoPath, lfsDir(), config.GlobalConfig.SSHKeyPath, config.GlobalConfig.BackupDir, config.GlobalConfig.BackupKeep)
+}
+
+// lfsDir is the directory of the local LFS store.
+func lfsDir() string {
+	return filepath.Join(config.GlobalConfig.DataDir, "lfs")
+}
+
+// runBackup writes a backup set and prints its path.
+func runBackup(args []string) error {
+	if _, err := parseFlags(flag.NewFlagSet("backup", flag.ContinueOnError), args, 0); err != nil {
+		return err
+	}
+
+	db, err := openDB()
+	if err != nil {
+		return err
+	}
+	path, err := newBackups(db).Run()
+	if err != nil {
+		return err
+	}
+	fmt.Println(path)
+	return nil
+}
+
+// runRestore rebuilds the repositories and the database from a backup set,
+// given as a path or the name of a set in the backup directory.
+func runRestore(args []string) error {
+	args, err := parseFlags(flag.NewFlagSet("restore", flag.ContinueOnError), args, 1)
+	if err != nil {
+		return err
+	}
+
+	dir := args[0]
+	if _, err := os.Stat(dir); os.IsNotExist(err) && !filepath.IsAbs(dir) {
+		dir = filepath.Join(config.GlobalConfig.BackupDir, dir)
+	}
+	if err := services.RestoreBackup(dir, config.GlobalConfig.RepoPath, lfsDir(), config.GlobalConfig.DBPath, config.GlobalConfig.SSHKeyPath); err != nil {
+		return err
+	}
+	fmt.Printf("Restored %s\n", dir)
+	return nil
+}
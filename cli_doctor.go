@@ -0,0 +1,249 @@
+//cli_doctor.go
+
+package main
+
+import (
+	"SimpleGit/config"
+	"SimpleGit/models"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+	"gorm.io/gorm"
+)
+
+// deletedOwner is the directory deleted repositories are kept in until
+// they are purged.
+const deletedOwner = ".deleted"
+
+// doctor prints the result of each check and counts the failures.
+type doctor struct {
+	failures int
+}
+
+func (d *doctor) ok(format string, args ...interface{}) {
+	fmt.Printf("OK    %s\n", fmt.Sprintf(format, args...))
+}
+
+func (d *doctor) warn(format string, args ...interface{}) {
+	fmt.Printf("WARN  %s\n", fmt.Sprintf(format, args...))
+}
+
+func (d *doctor) fail(format string, args ...interface{}) {
+	d.failures++
+	fmt.Printf("FAIL  %s\n", fmt.Sprintf(format, args...))
+}
+
+// runDoctor checks that the installation can be served: the directories,
+// git, the database, the SSH host key and every repository. It fails if
+// any check failed; warnings point at things worth cleaning up.
+func runDoctor(args []string) error {
+	fs := flag.NewFlagSet("doctor", flag.ContinueOnError)
+	full := fs.Bool("full", false, "")
+	if _, err := parseFlags(fs, args, 0); err != nil {
+		return err
+	}
+
+	d := &doctor{}
+	d.checkDirectories()
+	d.checkGit()
+	d.checkHostKey()
+
+	db, err := openDB()
+	if err != nil {
+		d.fail("Database %s: %v", config.GlobalConfig.DBPath, err)
+	} else {
+		d.checkDatabase(db)
+		d.checkRepositories(db, *full)
+	}
+
+	if d.failures > 0 {
+		return fmt.Errorf("%d checks failed", d.failures)
+	}
+	return nil
+}
+
+// checkDirectories checks that the server can write where it keeps its data.
+func (d *doctor) checkDirectories() {
+	dirs := []struct{ name, path string }{
+		{"Data directory", config.GlobalConfig.DataDir},
+		{"Repository directory", config.GlobalConfig.RepoPath},
+		{"Backup directory", config.GlobalConfig.BackupDir},
+	}
+	for _, dir := range dirs {
+		if err := os.MkdirAll(dir.path, 0755); err != nil {
+			d.fail("%s %s: %v", dir.name, dir.path, err)
+			continue
+		}
+		f, err := os.CreateTemp(dir.path, ".doctor-*")
+		if err != nil {
+			d.fail("%s %s is not writable: %v", dir.name, dir.path, err)
+			continue
+		}
+		f.Close()
+		os.Remove(f.Name())
+		d.ok("%s %s", dir.name, dir.path)
+	}
+}
+
+func (d *doctor) checkGit() {
+	version, err := models.CheckGitVersion()
+	if err != nil {
+		d.fail("%v", err)
+		return
+	}
+	d.ok("git %s", version)
+}
+
+func (d *doctor) checkHostKey() {
+	path := config.GlobalConfig.SSHKeyPath
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		d.warn("SSH host key %s does not exist; the server creates it when it starts", path)
+		return
+	}
+	if err != nil {
+		d.fail("SSH host key %s: %v", path, err)
+		return
+	}
+	key, err := ssh.ParsePrivateKey(data)
+	if err != nil {
+		d.fail("SSH host key %s cannot be read: %v", path, err)
+		return
+	}
+	d.ok("SSH host key %s (%s)", path, ssh.FingerprintSHA256(key.PublicKey()))
+}
+
+// checkDatabase checks the integrity of the database and that somebody
+// can administer the server.
+func (d *doctor) checkDatabase(db *gorm.DB) {
+	var result string
+	if err := db.Raw("PRAGMA integrity_check").Scan(&result).Error; err != nil {
+		d.fail("Database %s: %v", config.GlobalConfig.DBPath, err)
+		return
+	}
+	if result != "ok" {
+		d.fail("Database %s is corrupt: %s", config.GlobalConfig.DBPath, result)
+		return
+	}
+	d.ok("Database %s", config.GlobalConfig.DBPath)
+
+	users := models.NewUserService(db, nil)
+	admins, err := users.GetAdminCount()
+	switch {
+	case err != nil:
+		d.fail("Users cannot be read: %v", err)
+	case admins == 0:
+		d.warn("There is no admin; create one with the setup token or with user create --admin")
+	default:
+		d.ok("Admins: %d", admins)
+	}
+}
+
+// checkRepositories checks every repository on disk and that the database
+// only refers to repositories that exist.
+func (d *doctor) checkRepositories(db *gorm.DB, full bool) {
+	server, _, err := newServer(db)
+	if err != nil {
+		d.fail("Repositories cannot be loaded: %v", err)
+		return
+	}
+	if err := server.ScanRepositories(); err != nil {
+		d.fail("Repositories cannot be loaded: %v", err)
+		return
+	}
+
+	repos := server.Repositories()
+	names := make([]string, 0, len(repos))
+	for name := range repos {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	broken := 0
+	for _, name := range names {
+		if err := repos[name].Check(full); err != nil {
+			d.fail("Repository %s: %v", name, err)
+			broken++
+		}
+	}
+	if broken == 0 {
+		d.ok("Repositories: %d", len(names))
+	}
+
+	d.checkFlatRepositories()
+	d.checkDeletedRepositories(db)
+	d.checkRecords(db, repos)
+}
+
+// checkFlatRepositories finds repositories left in the flat layout of
+// earlier versions.
+func (d *doctor) checkFlatRepositories() {
+	entries, err := os.ReadDir(config.GlobalConfig.RepoPath)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		if entry.IsDir() && strings.HasSuffix(entry.Name(), ".git") {
+			d.warn("Repository %s has no owner; the server moves it when it starts", entry.Name())
+		}
+	}
+}
+
+// checkDeletedRepositories checks that every deleted repository can still
+// be restored and that no directory outlived its deletion.
+func (d *doctor) checkDeletedRepositories(db *gorm.DB) {
+	deleted, err := models.NewDeletedRepoService(db).List()
+	if err != nil {
+		d.fail("Deleted repositories cannot be read: %v", err)
+		return
+	}
+
+	known := make(map[string]bool)
+	for _, repo := range deleted {
+		known[repo.ID+".git"] = true
+		if _, err := os.Stat(models.RepoDir(config.GlobalConfig.RepoPath, repo.TrashName())); err != nil {
+			d.fail("Deleted repository %s cannot be restored: its directory is missing", repo.Name)
+		}
+	}
+
+	entries, err := os.ReadDir(filepath.Join(config.GlobalConfig.RepoPath, deletedOwner))
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		if !known[entry.Name()] {
+			d.warn("%s is not a deleted repository the server knows of", filepath.Join(deletedOwner, entry.Name()))
+		}
+	}
+}
+
+// checkRecords finds issues, pull requests, CI jobs and imports of
+// repositories that no longer exist.
+func (d *doctor) checkRecords(db *gorm.DB, repos map[string]*models.Repository) {
+	missing := make(map[string]bool)
+	for _, model := range []interface{}{&models.Issue{}, &models.PullRequest{}, &models.CIJob{}, &models.RepoImport{}} {
+		var names []string
+		if err := db.Model(model).Distinct().Pluck("repo_name", &names).Error; err != nil {
+			d.fail("Records cannot be read: %v", err)
+			return
+		}
+		for _, name := range names {
+			if _, ok := repos[name]; !ok && !strings.HasPrefix(name, deletedOwner+"/") {
+				missing[name] = true
+			}
+		}
+	}
+
+	names := make([]string, 0, len(missing))
+	for name := range missing {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		d.warn("The database has records of %s, which does not exist", name)
+	}
+}
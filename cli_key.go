@@ -0,0 +1,113 @@
+//cli_key.go
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// keyAdd adds an SSH public key to a user. The key is given in the
+// authorized_keys format, as the path of a file holding it, or as "-" to
+// read it from standard input. The name defaults to the key's comment.
+func keyAdd(args []string) error {
+	fs := flag.NewFlagSet("key add", flag.ContinueOnError)
+	name := fs.String("name", "", "")
+	args, err := parseFlags(fs, args, 2)
+	if err != nil {
+		return err
+	}
+
+	publicKey, err := readPublicKey(args[1])
+	if err != nil {
+		return err
+	}
+	key, comment, _, _, err := ssh.ParseAuthorizedKey([]byte(publicKey))
+	if err != nil {
+		return fmt.Errorf("not an SSH public key: %w", err)
+	}
+	if *name == "" {
+		*name = comment
+	}
+	if *name == "" {
+		return fmt.Errorf("the key has no comment; name it with --name")
+	}
+
+	users, err := openUsers()
+	if err != nil {
+		return err
+	}
+	user, err := users.GetUserByUsername(args[0])
+	if err != nil {
+		return fmt.Errorf("user %s not found", args[0])
+	}
+	existing, err := users.GetUserSSHKeys(user.ID)
+	if err != nil {
+		return err
+	}
+	for _, k := range existing {
+		if parsed, _, _, _, err := ssh.ParseAuthorizedKey([]byte(k.PublicKey)); err == nil && ssh.FingerprintSHA256(parsed) == ssh.FingerprintSHA256(key) {
+			return fmt.Errorf("%s already has this key as %s", user.Username, k.Name)
+		}
+	}
+	if _, err := users.AddSSHKey(user.ID, *name, publicKey); err != nil {
+		return err
+	}
+	fmt.Printf("Added key %s (%s) to %s\n", *name, ssh.FingerprintSHA256(key), user.Username)
+	return nil
+}
+
+// readPublicKey returns the key given on the command line.
+func readPublicKey(arg string) (string, error) {
+	var data []byte
+	var err error
+	switch {
+	case arg == "-":
+		data, err = io.ReadAll(os.Stdin)
+	case strings.HasPrefix(arg, "ssh-") || strings.HasPrefix(arg, "ecdsa-") || strings.HasPrefix(arg, "sk-"):
+		data = []byte(arg)
+	default:
+		data, err = os.ReadFile(arg)
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+func keyList(args []string) error {
+	args, err := parseFlags(flag.NewFlagSet("key list", flag.ContinueOnError), args, 1)
+	if err != nil {
+		return err
+	}
+
+	users, err := openUsers()
+	if err != nil {
+		return err
+	}
+	user, err := users.GetUserByUsername(args[0])
+	if err != nil {
+		return fmt.Errorf("user %s not found", args[0])
+	}
+	keys, err := users.GetUserSSHKeys(user.ID)
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tTYPE\tFINGERPRINT\tADDED")
+	for _, k := range keys {
+		keyType, fingerprint := "?", "invalid key"
+		if parsed, _, _, _, err := ssh.ParseAuthorizedKey([]byte(k.PublicKey)); err == nil {
+			keyType, fingerprint = parsed.Type(), ssh.FingerprintSHA256(parsed)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", k.Name, keyType, fingerprint, k.CreatedAt.Format("2006-01-02"))
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,183 @@
+//cli_repo.go
+
+package main
+
+import (
+	"SimpleGit/config"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// cliActor is who repository changes made on the command line are
+// recorded for.
+const cliActor = "command line"
+
+// splitRepoName splits <owner>/<name>.
+func splitRepoName(full string) (string, string, error) {
+	owner, name, ok := strings.Cut(strings.TrimSuffix(full, ".git"), "/")
+	if !ok || owner == "" || name == "" {
+		return "", "", fmt.Errorf("repositories are named <owner>/<name>")
+	}
+	return owner, name, nil
+}
+
+func repoCreate(args []string) error {
+	fs := flag.NewFlagSet("repo create", flag.ContinueOnError)
+	description := fs.String("description", "", "")
+	args, err := parseFlags(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	owner, name, err := splitRepoName(args[0])
+	if err != nil {
+		return err
+	}
+
+	server, _, err := openServer()
+	if err != nil {
+		return err
+	}
+	repo, err := server.CreateRepository(owner, name, *description)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Created repository %s\n", repo.Name)
+	return nil
+}
+
+// repoDelete deletes a repository. It can be restored from the admin pages
+// until the retention period is over, unless --purge is given.
+func repoDelete(args []string) error {
+	fs := flag.NewFlagSet("repo delete", flag.ContinueOnError)
+	purge := fs.Bool("purge", false, "")
+	args, err := parseFlags(fs, args, 1)
+	if err != nil {
+		return err
+	}
+
+	server, _, err := openServer()
+	if err != nil {
+		return err
+	}
+	name := strings.TrimSuffix(args[0], ".git")
+	if err := server.DeleteRepository(name, cliActor, *purge); err != nil {
+		return err
+	}
+	if *purge {
+		fmt.Printf("Deleted repository %s for good\n", name)
+	} else {
+		fmt.Printf("Deleted repository %s; it can be restored for %d days\n", name, config.GlobalConfig.DeletedRepoRetentionDays)
+	}
+	return nil
+}
+
+func repoList(args []string) error {
+	if _, err := parseFlags(flag.NewFlagSet("repo list", flag.ContinueOnError), args, 0); err != nil {
+		return err
+	}
+
+	server, _, err := openServer()
+	if err != nil {
+		return err
+	}
+	repos := server.Repositories()
+	names := make([]string, 0, len(repos))
+	for name := range repos {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tARCHIVED\tFORK OF\tDESCRIPTION")
+	for _, name := range names {
+		repo := repos[name]
+		fmt.Fprintf(tw, "%s\t%v\t%s\t%s\n", repo.Name, repo.Archived, repo.Parent, repo.Description)
+	}
+	return tw.Flush()
+}
+
+// repoImport creates a repository and imports a git URL or a bundle file
+// into it, showing git's progress. The repository is removed again if the
+// import fails.
+func repoImport(args []string) error {
+	fs := flag.NewFlagSet("repo import", flag.ContinueOnError)
+	description := fs.String("description", "", "")
+	args, err := parseFlags(fs, args, 2)
+	if err != nil {
+		return err
+	}
+	owner, name, err := splitRepoName(args[0])
+	if err != nil {
+		return err
+	}
+
+	server, _, err := openServer()
+	if err != nil {
+		return err
+	}
+	repo, err := server.CreateRepository(owner, name, *description)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	progress := newProgressWriter(os.Stderr)
+	err = server.ImportRepository(ctx, repo, args[1], cliActor, progress)
+	progress.finish()
+	if err != nil {
+		if delErr := server.DeleteRepository(repo.Name, cliActor, true); delErr != nil {
+			fmt.Fprintf(os.Stderr, "Failed to remove %s: %v\n", repo.Name, delErr)
+		}
+		return err
+	}
+	fmt.Printf("Imported repository %s\n", repo.Name)
+	return nil
+}
+
+// progressWriter shows the progress lines of git on a terminal, each
+// replacing the previous one until a step is done. Elsewhere only the
+// completed steps are shown.
+type progressWriter struct {
+	out      *os.File
+	terminal bool
+	pending  bool // A line is shown that the next one replaces
+}
+
+func newProgressWriter(out *os.File) *progressWriter {
+	info, err := out.Stat()
+	return &progressWriter{out: out, terminal: err == nil && info.Mode()&os.ModeCharDevice != 0}
+}
+
+func (p *progressWriter) Write(data []byte) (int, error) {
+	line := strings.TrimSpace(string(data))
+	if !strings.Contains(line, "%") {
+		return len(data), nil
+	}
+
+	done := strings.HasSuffix(line, "done.")
+	switch {
+	case p.terminal && done:
+		fmt.Fprintf(p.out, "\r\033[K%s\n", line)
+	case p.terminal:
+		fmt.Fprintf(p.out, "\r\033[K%s", line)
+	case done:
+		fmt.Fprintln(p.out, line)
+	}
+	p.pending = p.terminal && !done
+	return len(data), nil
+}
+
+// finish ends a line left open by a failed import.
+func (p *progressWriter) finish() {
+	if p.pending {
+		fmt.Fprintln(p.out)
+		p.pending = false
+	}
+}
@@ -0,0 +1,91 @@
+//cli_test.go
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// setupCLI points the configuration at an empty installation in a
+// temporary directory.
+func setupCLI(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("SIMPLEGIT_JWT_SECRET", "test")
+	t.Setenv("SIMPLEGIT_DATA_DIR", dir)
+	t.Setenv("SIMPLEGIT_DB_PATH", filepath.Join(dir, "githost.db"))
+	t.Setenv("SIMPLEGIT_REPO_PATH", filepath.Join(dir, "repositories"))
+	t.Setenv("SIMPLEGIT_SSH_KEY_PATH", filepath.Join(dir, "ssh", "host_key"))
+	t.Setenv("SIMPLEGIT_BACKUP_DIR", filepath.Join(dir, "backups"))
+	return dir
+}
+
+// runCLI runs a command and returns its exit status and standard output.
+func runCLI(t *testing.T, args ...string) (int, string) {
+	t.Helper()
+	stdout, err := os.CreateTemp(t.TempDir(), "stdout")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stdout.Close()
+	devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer devNull.Close()
+
+	oldStdout, oldStderr := os.Stdout, os.Stderr
+	os.Stdout, os.Stderr = stdout, devNull
+	code := run(args)
+	os.Stdout, os.Stderr = oldStdout, oldStderr
+
+	out, err := os.ReadFile(stdout.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return code, string(out)
+}
+
+func TestCLIExitStatus(t *testing.T) {
+	setupCLI(t)
+
+	tests := []struct {
+		args []string
+		code int
+	}{
+		{[]string{"help"}, 0},
+		{[]string{"nonsense"}, 2},
+		{[]string{"user"}, 2},
+		{[]string{"user", "create", "alice"}, 2},
+		{[]string{"user", "create", "--bogus", "alice", "alice@example.com"}, 2},
+		{[]string{"user", "create", "--admin", "alice", "alice@example.com", "--password", "secret"}, 0},
+		{[]string{"user", "create", "alice", "other@example.com", "--password", "secret"}, 1},
+		{[]string{"user", "set-admin", "alice", "maybe"}, 2},
+		{[]string{"user", "delete", "nobody"}, 1},
+		{[]string{"repo", "create", "alice"}, 1},
+		{[]string{"repo", "create", "--description", "A demo", "alice/demo"}, 0},
+		{[]string{"repo", "create", "alice/demo"}, 1},
+		{[]string{"repo", "list", "extra"}, 2},
+	}
+	for _, tt := range tests {
+		if code, _ := runCLI(t, tt.args...); code != tt.code {
+			t.Errorf("%s: exit status %d, want %d", strings.Join(tt.args, " "), code, tt.code)
+		}
+	}
+
+	if code, out := runCLI(t, "user", "list"); code != 0 || !strings.Contains(out, "alice") {
+		t.Errorf("user list: exit status %d, output %q", code, out)
+	}
+	if code, out := runCLI(t, "repo", "list"); code != 0 || !strings.Contains(out, "alice/demo") {
+		t.Errorf("repo list: exit status %d, output %q", code, out)
+	}
+
+	// Without a JWT secret the configuration does not load
+	t.Setenv("SIMPLEGIT_JWT_SECRET", "")
+	if code, _ := runCLI(t, "user", "list"); code != 1 {
+		t.Errorf("user list without a secret: exit status %d, want 1", code)
+	}
+}
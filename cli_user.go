@@ -0,0 +1,164 @@
+//cli_user.go
+
+package main
+
+import (
+	"SimpleGit/config"
+	"SimpleGit/models"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+)
+
+// openUsers opens the users of the installation.
+func openUsers() (*models.UserService, error) {
+	db, err := openDB()
+	if err != nil {
+		return nil, err
+	}
+	return models.NewUserService(db, []byte(config.GlobalConfig.JWTSecret)), nil
+}
+
+// userCreate creates a user. Without --password the password is read from
+// standard input.
+func userCreate(args []string) error {
+	fs := flag.NewFlagSet("user create", flag.ContinueOnError)
+	admin := fs.Bool("admin", false, "")
+	password := fs.String("password", "", "")
+	args, err := parseFlags(fs, args, 2)
+	if err != nil {
+		return err
+	}
+
+	users, err := openUsers()
+	if err != nil {
+		return err
+	}
+	pw, err := readPassword(*password)
+	if err != nil {
+		return err
+	}
+	user, err := users.CreateUser(args[0], args[1], pw, *admin)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Created user %s\n", user.Username)
+	return nil
+}
+
+func userDelete(args []string) error {
+	args, err := parseFlags(flag.NewFlagSet("user delete", flag.ContinueOnError), args, 1)
+	if err != nil {
+		return err
+	}
+
+	// Only the database and the owner directory are read, so unlike
+	// openServer this leaves the repositories of a running server alone
+	db, err := openDB()
+	if err != nil {
+		return err
+	}
+	server, _, err := newServer(db)
+	if err != nil {
+		return err
+	}
+	if err := server.DeleteUser(args[0]); err != nil {
+		return err
+	}
+	fmt.Printf("Deleted user %s\n", args[0])
+	return nil
+}
+
+func userList(args []string) error {
+	if _, err := parseFlags(flag.NewFlagSet("user list", flag.ContinueOnError), args, 0); err != nil {
+		return err
+	}
+
+	users, err := openUsers()
+	if err != nil {
+		return err
+	}
+	list, err := users.ListUsers()
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "USERNAME\tEMAIL\tADMIN\tCREATED")
+	for _, user := range list {
+		fmt.Fprintf(tw, "%s\t%s\t%v\t%s\n", user.Username, user.Email, user.IsAdmin, user.CreatedAt.Format(config.GlobalConfig.DateFormat))
+	}
+	return tw.Flush()
+}
+
+// userSetAdmin grants or revokes site admin rights. The last admin keeps
+// them.
+func userSetAdmin(args []string) error {
+	args, err := parseFlags(flag.NewFlagSet("user set-admin", flag.ContinueOnError), args, 2)
+	if err != nil {
+		return err
+	}
+	isAdmin, err := strconv.ParseBool(args[1])
+	if err != nil {
+		return errUsage
+	}
+
+	users, err := openUsers()
+	if err != nil {
+		return err
+	}
+	user, err := users.GetUserByUsername(args[0])
+	if err != nil {
+		return fmt.Errorf("user %s not found", args[0])
+	}
+	if user.IsAdmin && !isAdmin {
+		admins, err := users.GetAdminCount()
+		if err != nil {
+			return err
+		}
+		if admins <= 1 {
+			return fmt.Errorf("%s is the only admin", user.Username)
+		}
+	}
+	if err := users.SetAdmin(user.ID, isAdmin); err != nil {
+		return err
+	}
+
+	if isAdmin {
+		fmt.Printf("%s is an admin\n", user.Username)
+	} else {
+		fmt.Printf("%s is no longer an admin\n", user.Username)
+	}
+	return nil
+}
+
+// userResetPassword sets a new password. Without --password it is read
+// from standard input.
+func userResetPassword(args []string) error {
+	fs := flag.NewFlagSet("user reset-password", flag.ContinueOnError)
+	password := fs.String("password", "", "")
+	args, err := parseFlags(fs, args, 1)
+	if err != nil {
+		return err
+	}
+
+	users, err := openUsers()
+	if err != nil {
+		return err
+	}
+	user, err := users.GetUserByUsername(args[0])
+	if err != nil {
+		return fmt.Errorf("user %s not found", args[0])
+	}
+	pw, err := readPassword(*password)
+	if err != nil {
+		return err
+	}
+	if err := users.SetPassword(user.ID, pw); err != nil {
+		return err
+	}
+	fmt.Printf("Changed the password of %s\n", user.Username)
+	return nil
+}
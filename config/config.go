@@ -2,6 +2,8 @@ package config
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"os"
 	"path/filepath"
@@ -23,15 +25,65 @@ type Config struct {
 	RepoPath     string `json:"repo_path" envconfig:"REPO_PATH" default:"repositories"`
 	DBPath       string `json:"db_path" envconfig:"DB_PATH"`
 	TSServiceURL string `json:"ts_service_url" envconfig:"TS_SERVICE_URL" default:"http://localhost:3001"`
+
+	// Owner of repositories moved from the flat layout of earlier
+	// versions; the first admin if empty.
+	MigrationOwner string `json:"migration_owner" envconfig:"MIGRATION_OWNER"`
+
+	HighlightCacheBytes     int64 `json:"highlight_cache_bytes" envconfig:"HIGHLIGHT_CACHE_BYTES" default:"67108864"`
+	HighlightDiskCacheBytes int64 `json:"highlight_disk_cache_bytes" envconfig:"HIGHLIGHT_DISK_CACHE_BYTES" default:"0"`
+
+	CIEnabled     bool `json:"ci_enabled" envconfig:"CI_ENABLED"`
+	CIWorkers     int  `json:"ci_workers" envconfig:"CI_WORKERS" default:"1"`
+	CIStepTimeout int  `json:"ci_step_timeout" envconfig:"CI_STEP_TIMEOUT" default:"600"`
+
+	// Days deleted repositories can be restored before they are removed
+	// for good.
+	DeletedRepoRetentionDays int `json:"deleted_repo_retention_days" envconfig:"DELETED_REPO_RETENTION_DAYS" default:"7"`
+
+	// Backups are written to BackupDir every BackupInterval hours, 0 to
+	// only back up on demand, and the BackupKeep most recent are kept.
+	BackupDir      string `json:"backup_dir" envconfig:"BACKUP_DIR" default:"backups"`
+	BackupInterval int    `json:"backup_interval" envconfig:"BACKUP_INTERVAL" default:"24"`
+	BackupKeep     int    `json:"backup_keep" envconfig:"BACKUP_KEEP" default:"7"`
+
+	// Seconds running git operations get to finish when the server is
+	// asked to stop, before their connections are closed.
+	ShutdownTimeout int `json:"shutdown_timeout" envconfig:"SHUTDOWN_TIMEOUT" default:"30"`
 }
 
 var GlobalConfig Config
 
-// Init initializes the configuration by:
+// Init initializes the configuration with Load, exits if it is invalid
+// and logs the settings.
+func Init() {
+	if err := Load(); err != nil {
+		log.Fatal(err)
+	}
+
+	// Log non-sensitive configuration
+	log.Printf("Configuration loaded:")
+	log.Printf("- HTTP Port: %d", GlobalConfig.Port)
+	log.Printf("- SSH Port: %d", GlobalConfig.SSHPort)
+	log.Printf("- Domain: %s", GlobalConfig.Domain)
+	log.Printf("- Data Directory: %s", GlobalConfig.DataDir)
+	log.Printf("- Repository Path: %s", GlobalConfig.RepoPath)
+	log.Printf("- Development Mode: %v", GlobalConfig.DevMode)
+	log.Printf("- Max File Size: %d bytes", GlobalConfig.MaxFileSize)
+	log.Printf("- Date Format: %s", GlobalConfig.DateFormat)
+	log.Printf("TSService URL: %s", GlobalConfig.TSServiceURL)
+	log.Printf("- Highlight Cache: %d bytes (disk: %d bytes)", GlobalConfig.HighlightCacheBytes, GlobalConfig.HighlightDiskCacheBytes)
+	log.Printf("- CI Runner: %v (workers: %d, step timeout: %ds)", GlobalConfig.CIEnabled, GlobalConfig.CIWorkers, GlobalConfig.CIStepTimeout)
+	log.Printf("- Deleted Repository Retention: %d days", GlobalConfig.DeletedRepoRetentionDays)
+	log.Printf("- Backups: %s (every %d hours, keeping %d)", GlobalConfig.BackupDir, GlobalConfig.BackupInterval, GlobalConfig.BackupKeep)
+	log.Printf("- Shutdown Timeout: %ds", GlobalConfig.ShutdownTimeout)
+}
+
+// Load reads the configuration without logging it:
 // 1. Loading defaults
 // 2. Loading JSON config if present (optional)
 // 3. Overriding with environment variables
-func Init() {
+func Load() error {
 	// Set initial defaults
 	GlobalConfig = Config{
 		Port:        3000,
@@ -42,31 +94,32 @@ func Init() {
 		SSHKeyPath:  "ssh/host_key",
 		RepoPath:    "repositories",
 		MaxFileSize: 20485760, // 20MB
+
+		HighlightCacheBytes: 64 * 1024 * 1024,
+
+		CIWorkers:     1,
+		CIStepTimeout: 600, // 10 minutes
+
+		DeletedRepoRetentionDays: 7,
+
+		BackupDir:      "backups",
+		BackupInterval: 24,
+		BackupKeep:     7,
+
+		ShutdownTimeout: 30,
 	}
 
 	// Try to load JSON config
 	if configData, err := findConfigFile(); err == nil {
 		if err := json.Unmarshal(configData, &GlobalConfig); err != nil {
 			log.Printf("Warning: Failed to parse config.json: %v", err)
-		} else {
-			log.Printf("Successfully loaded config.json")
-			// Debug: print the loaded config
-			//prettyConfig, _ := json.MarshalIndent(GlobalConfig, "", "    ")
-			//log.Printf("Loaded config: %s", string(prettyConfig))
 		}
-	} else {
-		log.Printf("No config.json found, using defaults and environment variables")
 	}
 
 	// Override with environment variables
 	if err := envconfig.Process("SIMPLEGIT", &GlobalConfig); err != nil {
 		log.Printf("Warning: Environment variable processing error: %v", err)
 		// Continue anyway since we might have values from config.json
-	} else {
-		log.Printf("Successfully loaded environment variables")
-		// Debug: print the loaded config
-		//prettyConfig, _ := json.MarshalIndent(GlobalConfig, "", "    ")
-		//log.Printf("Loaded config: %s", string(prettyConfig))
 	}
 
 	// Post-processing
@@ -88,34 +141,22 @@ func Init() {
 	if !filepath.IsAbs(GlobalConfig.SSHKeyPath) {
 		GlobalConfig.SSHKeyPath, _ = filepath.Abs(GlobalConfig.SSHKeyPath)
 	}
+	if !filepath.IsAbs(GlobalConfig.BackupDir) {
+		GlobalConfig.BackupDir, _ = filepath.Abs(GlobalConfig.BackupDir)
+	}
 
 	// Validate JWT secret after all loading is done
 	if GlobalConfig.JWTSecret == "" {
-		log.Fatal("JWT secret is required. Set it in config.json or using SIMPLEGIT_JWT_SECRET environment variable")
+		return errors.New("JWT secret is required. Set it in config.json or using SIMPLEGIT_JWT_SECRET environment variable")
 	}
 
 	// Create necessary directories
-	createDirIfNotExists(GlobalConfig.DataDir)
-	createDirIfNotExists(GlobalConfig.RepoPath)
-	createDirIfNotExists(filepath.Dir(GlobalConfig.SSHKeyPath))
-
-	// Log non-sensitive configuration
-	log.Printf("Configuration loaded:")
-	log.Printf("- HTTP Port: %d", GlobalConfig.Port)
-	log.Printf("- SSH Port: %d", GlobalConfig.SSHPort)
-	log.Printf("- Domain: %s", GlobalConfig.Domain)
-	log.Printf("- Data Directory: %s", GlobalConfig.DataDir)
-	log.Printf("- Repository Path: %s", GlobalConfig.RepoPath)
-	log.Printf("- Development Mode: %v", GlobalConfig.DevMode)
-	log.Printf("- Max File Size: %d bytes", GlobalConfig.MaxFileSize)
-	log.Printf("- Date Format: %s", GlobalConfig.DateFormat)
-	log.Printf("TSService URL: %s", GlobalConfig.TSServiceURL)
-}
-
-func createDirIfNotExists(path string) {
-	if err := os.MkdirAll(path, 0755); err != nil {
-		log.Fatalf("Failed to create directory %s: %v", path, err)
+	for _, dir := range []string{GlobalConfig.DataDir, GlobalConfig.RepoPath, filepath.Dir(GlobalConfig.SSHKeyPath)} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to create directory %s: %w", dir, err)
+		}
 	}
+	return nil
 }
 
 func findConfigFile() ([]byte, error) {
@@ -57,7 +57,7 @@ func InitDB(dataDir string) (*gorm.DB, error) {
 	}
 
 	// Auto migrate the schemas
-	if err := db.AutoMigrate(&models.User{}, &models.SSHKey{}); err != nil {
+	if err := db.AutoMigrate(&models.User{}, &models.SSHKey{}, &models.PullRequest{}, &models.ReviewThread{}, &models.ReviewComment{}, &models.Issue{}, &models.Label{}, &models.IssueComment{}, &models.CommitStatus{}, &models.CIJob{}, &models.CIStep{}, &models.AccessToken{}, &models.BranchProtection{}, &models.Organization{}, &models.OrgMember{}, &models.Team{}, &models.TeamMember{}, &models.TeamRepo{}, &models.RepoGrant{}, &models.RepoRedirect{}, &models.DeletedRepo{}, &models.RepoImport{}); err != nil {
 		return nil, err
 	}
 
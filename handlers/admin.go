@@ -3,7 +3,11 @@
 package handlers
 
 import (
+	"SimpleGit/config"
 	"SimpleGit/models"
+	"encoding/json"
+	"fmt"
+	"log"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -14,17 +18,50 @@ import (
 )
 
 func (s *Server) handleAdminDashboard(w http.ResponseWriter, r *http.Request) {
+	var users []models.User
+	if err := s.db.Find(&users).Error; err != nil {
+		http.Error(w, "Failed to fetch users", http.StatusInternalServerError)
+		return
+	}
+
 	data := map[string]interface{}{
-		"AdminPage": "dashboard",
-		"Repos":     s.Repos,
+		"AdminPage":   "dashboard",
+		"Repos":       s.Repositories(),
+		"Users":       users,
+		"CacheStats":  s.HighlightCache.Stats(),
+		"SearchStats": s.codeSearch.Stats(),
 	}
 	s.tmpl.ExecuteTemplate(w, "admin-dashboard.html", s.addCommonData(r, data))
 }
 
+// handleHighlightCacheStats returns the highlight cache hit/miss counters.
+func (s *Server) handleHighlightCacheStats(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(s.HighlightCache.Stats()); err != nil {
+		models.HandleError(w, r, models.NewInternalError("Failed to encode response").WithError(err))
+	}
+}
+
+// handleCodeSearchStats returns the size of the code search indexes.
+func (s *Server) handleCodeSearchStats(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(s.codeSearch.Stats()); err != nil {
+		models.HandleError(w, r, models.NewInternalError("Failed to encode response").WithError(err))
+	}
+}
+
 func (s *Server) handleAdminRepos(w http.ResponseWriter, r *http.Request) {
+	deleted, err := s.deletedRepos.List()
+	if err != nil {
+		http.Error(w, "Failed to fetch deleted repositories", http.StatusInternalServerError)
+		return
+	}
+
 	data := map[string]interface{}{
-		"AdminPage": "dashboard",
-		"Repos":     s.Repos,
+		"AdminPage":     "dashboard",
+		"Repos":         s.Repositories(),
+		"Deleted":       deleted,
+		"RetentionDays": config.GlobalConfig.DeletedRepoRetentionDays,
 	}
 	s.tmpl.ExecuteTemplate(w, "admin-repos.html", data)
 }
@@ -69,54 +106,58 @@ func (s *Server) handleCreateUser(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Server) handleCreateRepo(w http.ResponseWriter, r *http.Request) {
+	user, ok := getUserFromContext(r)
+	if !ok {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+	owners, err := s.creatableOwners(user)
+	if err != nil {
+		models.HandleError(w, r, models.NewInternalError("Failed to load owners").WithError(err))
+		return
+	}
+	data := map[string]interface{}{
+		"Owners":    owners,
+		"CanImport": s.importer != nil,
+	}
+
 	if r.Method == "GET" {
-		s.tmpl.ExecuteTemplate(w, "admin-repo-create.html", nil)
+		s.tmpl.ExecuteTemplate(w, "admin-repo-create.html", data)
 		return
 	}
 
 	// Handle POST request
+	r.Body = http.MaxBytesReader(w, r.Body, maxBundleSize)
+	owner := r.FormValue("owner")
 	name := r.FormValue("name")
 	description := r.FormValue("description")
 
-	// Validate repository name
-	if name == "" {
-		data := map[string]interface{}{
-			"Error": "Repository name is required",
-		}
-		w.WriteHeader(http.StatusBadRequest)
+	renderError := func(err *models.AppError) {
+		data["Error"] = err.Message
+		w.WriteHeader(err.Code)
 		s.tmpl.ExecuteTemplate(w, "admin-repo-create.html", data)
-		return
 	}
 
-	// Create repository directory
-	repoPath := filepath.Join(s.RepoPath, name)
-	if err := os.MkdirAll(repoPath, 0755); err != nil {
-		data := map[string]interface{}{
-			"Error": "Failed to create repository directory",
-		}
-		w.WriteHeader(http.StatusInternalServerError)
-		s.tmpl.ExecuteTemplate(w, "admin-repo-create.html", data)
+	req, appErr := s.parseImportRequest(r)
+	if appErr != nil {
+		renderError(appErr)
 		return
 	}
 
-	// Initialize git repository
-	_, err := git.PlainInit(repoPath, true)
-	if err != nil {
-		data := map[string]interface{}{
-			"Error": "Failed to initialize git repository",
-		}
-		w.WriteHeader(http.StatusInternalServerError)
-		s.tmpl.ExecuteTemplate(w, "admin-repo-create.html", data)
+	repo, appErr := s.createRepositoryAs(user, owner, name, description)
+	if appErr != nil {
+		req.discard()
+		renderError(appErr)
 		return
 	}
 
-	// Add to repositories map
-	s.Repos[name] = &models.Repository{
-		ID:          name,
-		Name:        name,
-		Path:        repoPath,
-		Description: description,
-		CreatedAt:   time.Now(),
+	if req != nil {
+		if appErr := s.startImport(user, repo, req); appErr != nil {
+			models.HandleError(w, r, appErr)
+			return
+		}
+		http.Redirect(w, r, "/admin/repos/"+repo.Name+"/import", http.StatusSeeOther)
+		return
 	}
 
 	http.Redirect(w, r, "/admin/repos", http.StatusSeeOther)
@@ -136,15 +177,78 @@ func (s *Server) handleDeleteUser(w http.ResponseWriter, r *http.Request) {
 	}
 	userID := parts[3]
 
-	// Delete the user from the database
-	if err := s.db.Delete(&models.User{}, "id = ?", userID).Error; err != nil {
-		http.Error(w, "Failed to delete user", http.StatusInternalServerError)
+	var user models.User
+	if err := s.db.First(&user, "id = ?", userID).Error; err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+	if appErr := s.deleteUser(&user); appErr != nil {
+		http.Error(w, appErr.Message, appErr.Code)
 		return
 	}
 
 	w.WriteHeader(http.StatusOK)
 }
 
+// deleteUser removes a user and everything that gives them access. Users
+// still owning repositories, including deleted ones that could be
+// restored, are not deleted: whoever registered the name next would own
+// them.
+func (s *Server) deleteUser(user *models.User) *models.AppError {
+	owned, err := s.ownedRepositories(user.Username)
+	if err != nil {
+		return models.NewInternalError("Failed to list repositories").WithError(err)
+	}
+	if owned > 0 {
+		return models.NewConflictError(fmt.Sprintf("%s still owns repositories; delete or transfer them first", user.Username)).ShowInProduction()
+	}
+
+	if err := s.db.Delete(&models.User{}, "id = ?", user.ID).Error; err != nil {
+		return models.NewInternalError("Failed to delete user").WithError(err)
+	}
+	if err := s.db.Delete(&models.AccessToken{}, "user_id = ?", user.ID).Error; err != nil {
+		log.Printf("Failed to delete access tokens of user %s: %v", user.ID, err)
+	}
+	if err := s.db.Delete(&models.SSHKey{}, "user_id = ?", user.ID).Error; err != nil {
+		log.Printf("Failed to delete SSH keys of user %s: %v", user.ID, err)
+	}
+	if err := s.orgs.DeleteByUser(user.ID); err != nil {
+		log.Printf("Failed to remove user %s from organizations: %v", user.ID, err)
+	}
+	if err := s.teams.DeleteByUser(user.ID); err != nil {
+		log.Printf("Failed to remove user %s from teams: %v", user.ID, err)
+	}
+	if err := s.permissions.DeleteByUser(user.ID); err != nil {
+		log.Printf("Failed to delete access grants of user %s: %v", user.ID, err)
+	}
+	// The owner directory is empty; while it exists the name stays taken
+	if err := os.Remove(filepath.Join(s.RepoPath, user.Username)); err != nil && !os.IsNotExist(err) {
+		log.Printf("Failed to remove the directory of user %s: %v", user.Username, err)
+	}
+	return nil
+}
+
+// ownedRepositories counts the repositories in the directory of owner and
+// the deleted repositories of owner waiting to be purged.
+func (s *Server) ownedRepositories(owner string) (int, error) {
+	entries, err := os.ReadDir(filepath.Join(s.RepoPath, owner))
+	if err != nil && !os.IsNotExist(err) {
+		return 0, err
+	}
+	owned := len(entries)
+
+	deleted, err := s.deletedRepos.List()
+	if err != nil {
+		return 0, err
+	}
+	for _, d := range deleted {
+		if strings.HasPrefix(d.Name, owner+"/") {
+			owned++
+		}
+	}
+	return owned, nil
+}
+
 func (s *Server) handleDeleteRepo(w http.ResponseWriter, r *http.Request) {
 	if r.Method != "DELETE" {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -152,28 +256,113 @@ func (s *Server) handleDeleteRepo(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Extract repository name from URL
-	parts := strings.Split(r.URL.Path, "/")
-	if len(parts) < 4 {
+	parts := repoPathParts(r.URL.Path, 2)
+	if len(parts) != 3 {
 		http.Error(w, "Invalid request", http.StatusBadRequest)
 		return
 	}
-	repoName := parts[3]
+	repoName := parts[2]
 
 	// Get repository path
-	repo, ok := s.Repos[repoName]
+	repo, ok := s.Repository(repoName)
 	if !ok {
 		http.Error(w, "Repository not found", http.StatusNotFound)
 		return
 	}
 
-	// Delete repository directory
-	if err := os.RemoveAll(repo.Path); err != nil {
+	user, _ := getUserFromContext(r)
+	if _, err := s.deleteRepository(repo, user.Username); err != nil {
 		http.Error(w, "Failed to delete repository", http.StatusInternalServerError)
 		return
 	}
 
-	// Remove from repositories map
-	delete(s.Repos, repoName)
-
 	w.WriteHeader(http.StatusOK)
 }
+
+// createRepository initialises an empty bare repository and registers it.
+//
+// Parameters:
+//   - name: The full repository name, <owner>/<name>.
+//   - description: An optional description.
+func (s *Server) createRepository(name, description string) (*models.Repository, *models.AppError) {
+	owner, shortName, _ := strings.Cut(name, "/")
+	if shortName == "" {
+		return nil, models.NewBadRequestError("Repository name is required").ShowInProduction()
+	}
+	if !models.ValidRepoName(shortName) {
+		return nil, models.NewBadRequestError("Repository names may only contain letters, numbers, dots, hyphens and underscores").ShowInProduction()
+	}
+	if !models.ValidOwnerName(owner) {
+		return nil, models.NewBadRequestError("Invalid owner name").ShowInProduction()
+	}
+	if _, exists := s.Repository(name); exists {
+		return nil, models.NewConflictError("A repository with this name already exists").ShowInProduction()
+	}
+
+	// Create repository directory
+	repoPath := models.RepoDir(s.RepoPath, name)
+	if err := os.MkdirAll(repoPath, 0755); err != nil {
+		return nil, models.NewInternalError("Failed to create repository directory").WithError(err)
+	}
+
+	// Initialize git repository
+	if _, err := git.PlainInit(repoPath, true); err != nil {
+		return nil, models.NewGitError("Failed to initialize git repository", err)
+	}
+
+	repo := &models.Repository{
+		ID:        name,
+		Name:      name,
+		Owner:     owner,
+		Path:      repoPath,
+		CreatedAt: time.Now(),
+	}
+	if err := repo.SetDescription(description); err != nil {
+		log.Printf("Failed to store description of %s: %v", name, err)
+	}
+
+	// Add to repositories map
+	s.addRepository(repo)
+	if err := s.redirects.Release(name); err != nil {
+		log.Printf("Failed to release the name %s: %v", name, err)
+	}
+	return repo, nil
+}
+
+// deleteRepoRecords removes everything stored about a repository that
+// was removed from disk.
+func (s *Server) deleteRepoRecords(repoName string) {
+	s.xref.Forget(repoName)
+	s.codeSearch.Forget(repoName)
+	s.commitSearch.Forget(repoName)
+	if err := s.pullRequests.DeleteByRepo(repoName); err != nil {
+		log.Printf("Failed to delete pull requests of %s: %v", repoName, err)
+	}
+	if err := s.reviews.DeleteByRepo(repoName); err != nil {
+		log.Printf("Failed to delete review threads of %s: %v", repoName, err)
+	}
+	if err := s.issues.DeleteByRepo(repoName); err != nil {
+		log.Printf("Failed to delete issues of %s: %v", repoName, err)
+	}
+	if err := s.ciJobs.DeleteByRepo(repoName); err != nil {
+		log.Printf("Failed to delete CI jobs of %s: %v", repoName, err)
+	}
+	if err := s.statuses.DeleteByRepo(repoName); err != nil {
+		log.Printf("Failed to delete commit statuses of %s: %v", repoName, err)
+	}
+	if err := s.protections.DeleteByRepo(repoName); err != nil {
+		log.Printf("Failed to delete branch protection of %s: %v", repoName, err)
+	}
+	if err := s.LFS.DeleteRepo(repoName); err != nil {
+		log.Printf("Failed to delete LFS objects of %s: %v", repoName, err)
+	}
+	if err := s.teams.DeleteByRepo(repoName); err != nil {
+		log.Printf("Failed to delete team grants of %s: %v", repoName, err)
+	}
+	if err := s.imports.DeleteByRepo(repoName); err != nil {
+		log.Printf("Failed to delete imports of %s: %v", repoName, err)
+	}
+	if err := s.permissions.DeleteByRepo(repoName); err != nil {
+		log.Printf("Failed to delete access grants of %s: %v", repoName, err)
+	}
+}
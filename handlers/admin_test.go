@@ -0,0 +1,83 @@
+//handlers/admin_test.go
+
+package handlers
+
+import (
+	"SimpleGit/models"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestDeleteUserWithRepositories checks that a user is only deleted once
+// nothing is left under their name, so that registering the name again
+// gives no access to their repositories.
+func TestDeleteUserWithRepositories(t *testing.T) {
+	s, _ := newTestServer(t)
+	bob, err := s.userService.CreateUser("bob", "bob@example.com", "password", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"kept", "trashed"} {
+		if _, err := s.CreateRepository("bob", name, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The admin page answers with the reason
+	req := httptest.NewRequest("DELETE", "/admin/users/"+bob.ID, nil)
+	rec := httptest.NewRecorder()
+	s.handleDeleteUser(rec, req)
+	if rec.Code != http.StatusConflict || !strings.Contains(rec.Body.String(), "still owns repositories") {
+		t.Fatalf("deleting a user with repositories: got %d %q, want 409", rec.Code, rec.Body.String())
+	}
+
+	// Deleted repositories can be restored into the name
+	if err := s.DeleteRepository("bob/kept", "alice", true); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.DeleteRepository("bob/trashed", "alice", false); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.DeleteUser("bob"); err == nil || !strings.Contains(err.Error(), "still owns repositories") {
+		t.Fatalf("deleting a user with a deleted repository: %v, want a refusal", err)
+	}
+
+	deleted, err := s.deletedRepos.List()
+	if err != nil || len(deleted) != 1 {
+		t.Fatalf("deleted repositories %v, %v, want bob/trashed", deleted, err)
+	}
+	if err := s.purgeRepository(&deleted[0]); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.DeleteUser("bob"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(s.RepoPath, "bob")); !os.IsNotExist(err) {
+		t.Errorf("the directory of bob is left behind: %v", err)
+	}
+
+	// The name is free again and comes with nothing
+	if _, err := s.userService.CreateUser("bob", "new-bob@example.com", "password", false); err != nil {
+		t.Fatalf("registering bob again: %v", err)
+	}
+	for _, repo := range s.Repositories() {
+		if repo.Owner == "bob" {
+			t.Errorf("the new bob owns %s", repo.Name)
+		}
+	}
+
+	// A directory left behind by an owner deleted before keeps the name
+	if err := os.MkdirAll(models.RepoDir(s.RepoPath, "Carol/old"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.userService.CreateUser("carol", "carol@example.com", "password", false); err != models.ErrOwnerNameTaken {
+		t.Errorf("registering carol over a repository directory: %v, want %v", err, models.ErrOwnerNameTaken)
+	}
+	if _, err := s.orgs.Create("carol", "", bob.ID); err != models.ErrOwnerNameTaken {
+		t.Errorf("creating the organization carol over a repository directory: %v, want %v", err, models.ErrOwnerNameTaken)
+	}
+}
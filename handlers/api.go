@@ -0,0 +1,323 @@
+//handlers/api.go
+
+package handlers
+
+import (
+	"SimpleGit/models"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Page sizes of paginated API lists.
+const (
+	apiDefaultPerPage = 30
+	apiMaxPerPage     = 100
+)
+
+// apiPage is the page of a list an API request asks for with the page and
+// per_page query parameters. Pages are numbered from 1.
+type apiPage struct {
+	Number  int
+	PerPage int
+}
+
+// parseAPIPage reads the page and per_page query parameters.
+func parseAPIPage(r *http.Request) (apiPage, *models.AppError) {
+	page := apiPage{Number: 1, PerPage: apiDefaultPerPage}
+	query := r.URL.Query()
+
+	if value := query.Get("page"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			return page, models.NewBadRequestError("page must be a positive integer").ShowInProduction()
+		}
+		page.Number = n
+	}
+	if value := query.Get("per_page"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > apiMaxPerPage {
+			return page, models.NewBadRequestError(fmt.Sprintf("per_page must be between 1 and %d", apiMaxPerPage)).ShowInProduction()
+		}
+		page.PerPage = n
+	}
+	// The end of the page must not overflow
+	if page.Number > math.MaxInt/page.PerPage {
+		return page, models.NewBadRequestError("page is too large").ShowInProduction()
+	}
+
+	return page, nil
+}
+
+// Offset returns the number of items before the page.
+func (p apiPage) Offset() int {
+	return (p.Number - 1) * p.PerPage
+}
+
+// paginate returns the page of items and whether a next page exists.
+func paginate[T any](items []T, page apiPage) ([]T, bool) {
+	start := page.Offset()
+	if start >= len(items) {
+		return []T{}, false
+	}
+	end := start + page.PerPage
+	if end >= len(items) {
+		return items[start:], false
+	}
+	return items[start:end], true
+}
+
+// writeAPIPage writes one page of a list. Every list endpoint answers the
+// same way: a JSON array, a Link header with the next, prev, first and
+// last pages, and X-Total-Count when the total is known.
+//
+// Parameters:
+//   - items: The items of the page.
+//   - hasNext: Whether a next page exists.
+//   - total: The number of items of all pages, or -1 if unknown.
+func writeAPIPage(w http.ResponseWriter, r *http.Request, page apiPage, items interface{}, hasNext bool, total int) {
+	pageURL := func(n int) string {
+		query := r.URL.Query()
+		query.Set("page", strconv.Itoa(n))
+		query.Set("per_page", strconv.Itoa(page.PerPage))
+		return r.URL.Path + "?" + query.Encode()
+	}
+
+	var links []string
+	if hasNext {
+		links = append(links, fmt.Sprintf(`<%s>; rel="next"`, pageURL(page.Number+1)))
+	}
+	if page.Number > 1 {
+		links = append(links, fmt.Sprintf(`<%s>; rel="prev"`, pageURL(page.Number-1)))
+		links = append(links, fmt.Sprintf(`<%s>; rel="first"`, pageURL(1)))
+	}
+	if total >= 0 {
+		last := (total + page.PerPage - 1) / page.PerPage
+		if last > page.Number {
+			links = append(links, fmt.Sprintf(`<%s>; rel="last"`, pageURL(last)))
+		}
+		w.Header().Set("X-Total-Count", strconv.Itoa(total))
+	}
+	if len(links) > 0 {
+		w.Header().Set("Link", strings.Join(links, ", "))
+	}
+
+	writeJSON(w, http.StatusOK, items)
+}
+
+// writeJSON writes v as the JSON response body.
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// decodeJSON reads a JSON request body into v.
+func decodeJSON(r *http.Request, v interface{}) *models.AppError {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		return models.NewBadRequestError("Invalid request body").WithError(err).ShowInProduction()
+	}
+	return nil
+}
+
+// requireAPIUser reports whether the request was made by a signed in user
+// and writes an error if not.
+func requireAPIUser(w http.ResponseWriter, r *http.Request) bool {
+	if _, ok := getUserFromContext(r); !ok {
+		models.HandleError(w, r, models.NewUnauthorizedError("Authentication required").ShowInProduction())
+		return false
+	}
+	return true
+}
+
+// requireAPIAdmin reports whether the request was made by an admin and
+// writes an error if not.
+func requireAPIAdmin(w http.ResponseWriter, r *http.Request) bool {
+	user, ok := getUserFromContext(r)
+	if !ok {
+		models.HandleError(w, r, models.NewUnauthorizedError("Authentication required").ShowInProduction())
+		return false
+	}
+	if !user.IsAdmin {
+		models.HandleError(w, r, models.NewForbiddenError("Only admins can do this").ShowInProduction())
+		return false
+	}
+	return true
+}
+
+// apiMethodNotAllowed writes the error for a method a route does not
+// support.
+func apiMethodNotAllowed(w http.ResponseWriter, r *http.Request, allowed ...string) {
+	w.Header().Set("Allow", strings.Join(allowed, ", "))
+	models.HandleError(w, r, models.NewMethodNotAllowedError(fmt.Sprintf("%s is not allowed here", r.Method)).ShowInProduction())
+}
+
+// handleAPIv1 routes the versioned JSON API. Reads are public; creating
+// repositories, changing branches and forking require a signed in user and
+// changing repositories or branch protection an admin, by access token or
+// session.
+//
+// Routes:
+//   - /api/v1/repos: List (GET) or create (POST) repositories.
+//   - /api/v1/repos/<repo>: Get (GET), update (PATCH) or delete (DELETE) a
+//     repository.
+//   - /api/v1/repos/<repo>/branches: List (GET) or create (POST) branches.
+//   - /api/v1/repos/<repo>/branches/<branch>: Get (GET), rename (PATCH) or
+//     delete (DELETE) a branch.
+//   - /api/v1/repos/<repo>/protected-branches[/<id>]: List (GET), add
+//     (POST) or remove (DELETE) branch protection rules.
+//   - /api/v1/repos/<repo>/tags[/<tag>]: Tags.
+//   - /api/v1/repos/<repo>/tree[/<path>]?ref=: A directory listing.
+//   - /api/v1/repos/<repo>/blobs/<path>?ref=: A file, base64 encoded.
+//   - /api/v1/repos/<repo>/raw/<path>?ref=: A file's raw content.
+//   - /api/v1/repos/<repo>/commits?ref=&path=: Commit history.
+//   - /api/v1/repos/<repo>/commits/<ref>: A commit with its diff.
+//   - /api/v1/repos/<repo>/commits/<ref>/status: The combined status.
+//   - /api/v1/repos/<repo>/statuses/<ref>: List (GET) or set (POST)
+//     commit statuses.
+//   - /api/v1/repos/<repo>/forks: List (GET) or create (POST) forks.
+//   - /api/v1/repos/<repo>/compare/<base>...<head>: Compare two refs. A
+//     base of upstream:<branch> names a branch of the parent of a fork.
+//   - /api/v1/orgs/...: Organizations, see apiOrgs.
+//
+// <repo> is the full name of a repository, <owner>/<name>.
+func (s *Server) handleAPIv1(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/v1"), "/")
+	if first, _, _ := strings.Cut(path, "/"); first == "orgs" {
+		s.apiOrgs(w, r, strings.Split(path, "/")[1:])
+		return
+	}
+
+	parts := repoPathParts(path, 1)
+	if len(parts) == 0 || parts[0] != "repos" {
+		models.HandleError(w, r, models.NewNotFoundError("Not found").ShowInProduction())
+		return
+	}
+
+	if len(parts) == 1 {
+		switch r.Method {
+		case http.MethodGet:
+			s.apiListRepos(w, r)
+		case http.MethodPost:
+			s.apiCreateRepo(w, r)
+		default:
+			apiMethodNotAllowed(w, r, http.MethodGet, http.MethodPost)
+		}
+		return
+	}
+
+	repo, ok := s.Repository(parts[1])
+	if !ok {
+		models.HandleError(w, r, models.NewNotFoundError("Repository not found").WithDetail(fmt.Sprintf("Repository: %s", parts[1])).ShowInProduction())
+		return
+	}
+
+	if len(parts) == 2 {
+		switch r.Method {
+		case http.MethodGet:
+			writeJSON(w, http.StatusOK, newAPIRepository(repo))
+		case http.MethodPatch:
+			s.apiUpdateRepo(w, r, repo)
+		case http.MethodDelete:
+			s.apiDeleteRepo(w, r, repo)
+		default:
+			apiMethodNotAllowed(w, r, http.MethodGet, http.MethodPatch, http.MethodDelete)
+		}
+		return
+	}
+
+	rest := strings.Join(parts[3:], "/")
+	switch parts[2] {
+	case "branches":
+		switch {
+		case r.Method == http.MethodGet:
+			s.apiBranches(w, r, repo, rest)
+		case r.Method == http.MethodPost && rest == "":
+			s.apiCreateBranch(w, r, repo)
+		case r.Method == http.MethodPatch && rest != "":
+			s.apiRenameBranch(w, r, repo, rest)
+		case r.Method == http.MethodDelete && rest != "":
+			s.apiDeleteBranch(w, r, repo, rest)
+		case rest == "":
+			apiMethodNotAllowed(w, r, http.MethodGet, http.MethodPost)
+		default:
+			apiMethodNotAllowed(w, r, http.MethodGet, http.MethodPatch, http.MethodDelete)
+		}
+		return
+	case "protected-branches":
+		switch {
+		case r.Method == http.MethodGet && rest == "":
+			s.apiListProtections(w, r, repo)
+		case r.Method == http.MethodPost && rest == "":
+			s.apiAddProtection(w, r, repo)
+		case r.Method == http.MethodDelete && rest != "":
+			s.apiDeleteProtection(w, r, repo, rest)
+		case rest == "":
+			apiMethodNotAllowed(w, r, http.MethodGet, http.MethodPost)
+		default:
+			apiMethodNotAllowed(w, r, http.MethodDelete)
+		}
+		return
+	case "forks":
+		switch {
+		case r.Method == http.MethodGet && rest == "":
+			s.apiListForks(w, r, repo)
+		case r.Method == http.MethodPost && rest == "":
+			s.apiCreateFork(w, r, repo)
+		case rest == "":
+			apiMethodNotAllowed(w, r, http.MethodGet, http.MethodPost)
+		default:
+			models.HandleError(w, r, models.NewNotFoundError("Not found").ShowInProduction())
+		}
+		return
+	case "statuses":
+		if rest == "" {
+			models.HandleError(w, r, models.NewNotFoundError("Not found").ShowInProduction())
+			return
+		}
+		switch r.Method {
+		case http.MethodGet:
+			s.handleListStatuses(w, r, repo, rest)
+		case http.MethodPost:
+			s.requireToken(func(w http.ResponseWriter, r *http.Request) {
+				s.handleSetStatus(w, r, repo, rest)
+			})(w, r)
+		default:
+			apiMethodNotAllowed(w, r, http.MethodGet, http.MethodPost)
+		}
+		return
+	}
+
+	// Everything else below a repository is read-only
+	if r.Method != http.MethodGet {
+		apiMethodNotAllowed(w, r, http.MethodGet)
+		return
+	}
+
+	switch parts[2] {
+	case "tags":
+		s.apiTags(w, r, repo, rest)
+	case "tree":
+		s.apiTree(w, r, repo, rest)
+	case "blobs":
+		s.apiBlob(w, r, repo, rest, false)
+	case "raw":
+		s.apiBlob(w, r, repo, rest, true)
+	case "commits":
+		switch {
+		case rest == "":
+			s.apiListCommits(w, r, repo)
+		case strings.HasSuffix(rest, "/status"):
+			s.handleCombinedStatus(w, r, repo, strings.TrimSuffix(rest, "/status"))
+		default:
+			s.apiGetCommit(w, r, repo, rest)
+		}
+	case "compare":
+		s.apiCompare(w, r, repo, rest)
+	default:
+		models.HandleError(w, r, models.NewNotFoundError("Not found").ShowInProduction())
+	}
+}
@@ -0,0 +1,202 @@
+//handlers/api_orgs.go
+
+package handlers
+
+import (
+	"SimpleGit/models"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// apiOrgRequest is the body of a request to create an organization.
+type apiOrgRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// apiOrgMember is a member of an organization as the API returns it.
+type apiOrgMember struct {
+	Username string    `json:"username"`
+	Role     string    `json:"role"`
+	Since    time.Time `json:"since"`
+}
+
+// apiOrgMemberRequest is the optional body of a request to add a member
+// or change a member's role. Role defaults to member.
+type apiOrgMemberRequest struct {
+	Role string `json:"role,omitempty"`
+}
+
+// apiOrgs routes the organization endpoints of the API. Reads are public;
+// creating organizations requires a signed in user and changing members an
+// owner of the organization or an admin.
+//
+// Routes:
+//   - /api/v1/orgs: List (GET) or create (POST) organizations.
+//   - /api/v1/orgs/<org>: Get an organization.
+//   - /api/v1/orgs/<org>/members: List members.
+//   - /api/v1/orgs/<org>/members/<username>: Add a member or change the
+//     member's role (PUT) or remove the member (DELETE).
+func (s *Server) apiOrgs(w http.ResponseWriter, r *http.Request, parts []string) {
+	if len(parts) == 0 {
+		switch r.Method {
+		case http.MethodGet:
+			s.apiListOrgs(w, r)
+		case http.MethodPost:
+			s.apiCreateOrg(w, r)
+		default:
+			apiMethodNotAllowed(w, r, http.MethodGet, http.MethodPost)
+		}
+		return
+	}
+
+	org, err := s.orgs.Get(parts[0])
+	if errors.Is(err, models.ErrOrganizationNotFound) {
+		models.HandleError(w, r, models.NewNotFoundError("Organization not found").WithDetail(fmt.Sprintf("Organization: %s", parts[0])).ShowInProduction())
+		return
+	}
+	if err != nil {
+		models.HandleError(w, r, models.NewInternalError("Failed to load organization").WithError(err))
+		return
+	}
+
+	switch {
+	case len(parts) == 1:
+		if r.Method != http.MethodGet {
+			apiMethodNotAllowed(w, r, http.MethodGet)
+			return
+		}
+		writeJSON(w, http.StatusOK, org)
+	case len(parts) == 2 && parts[1] == "members":
+		if r.Method != http.MethodGet {
+			apiMethodNotAllowed(w, r, http.MethodGet)
+			return
+		}
+		s.apiListOrgMembers(w, r, org)
+	case len(parts) == 3 && parts[1] == "members":
+		switch r.Method {
+		case http.MethodPut, http.MethodDelete:
+			s.apiChangeOrgMember(w, r, org, parts[2])
+		default:
+			apiMethodNotAllowed(w, r, http.MethodPut, http.MethodDelete)
+		}
+	default:
+		models.HandleError(w, r, models.NewNotFoundError("Not found").ShowInProduction())
+	}
+}
+
+// apiListOrgs lists the organizations sorted by name.
+func (s *Server) apiListOrgs(w http.ResponseWriter, r *http.Request) {
+	page, appErr := parseAPIPage(r)
+	if appErr != nil {
+		models.HandleError(w, r, appErr)
+		return
+	}
+
+	orgs, err := s.orgs.List()
+	if err != nil {
+		models.HandleError(w, r, models.NewInternalError("Failed to list organizations").WithError(err))
+		return
+	}
+
+	pageOrgs, hasNext := paginate(orgs, page)
+	writeAPIPage(w, r, page, pageOrgs, hasNext, len(orgs))
+}
+
+// apiCreateOrg creates an organization owned by the authenticated user.
+func (s *Server) apiCreateOrg(w http.ResponseWriter, r *http.Request) {
+	if !requireAPIUser(w, r) {
+		return
+	}
+	user, _ := getUserFromContext(r)
+
+	var req apiOrgRequest
+	if err := decodeJSON(r, &req); err != nil {
+		models.HandleError(w, r, err)
+		return
+	}
+
+	org, err := s.orgs.Create(strings.TrimSpace(req.Name), strings.TrimSpace(req.Description), user.ID)
+	if errors.Is(err, models.ErrOwnerNameTaken) {
+		models.HandleError(w, r, models.NewConflictError(err.Error()).ShowInProduction())
+		return
+	}
+	if err != nil {
+		models.HandleError(w, r, models.NewBadRequestError(err.Error()).ShowInProduction())
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, org)
+}
+
+// apiListOrgMembers lists the members of an organization, owners first.
+func (s *Server) apiListOrgMembers(w http.ResponseWriter, r *http.Request, org *models.Organization) {
+	page, appErr := parseAPIPage(r)
+	if appErr != nil {
+		models.HandleError(w, r, appErr)
+		return
+	}
+
+	members, err := s.orgs.Members(org.ID)
+	if err != nil {
+		models.HandleError(w, r, models.NewInternalError("Failed to list members").WithError(err))
+		return
+	}
+
+	pageMembers, hasNext := paginate(members, page)
+	result := make([]apiOrgMember, len(pageMembers))
+	for i, member := range pageMembers {
+		result[i] = apiOrgMember{Username: member.User.Username, Role: member.Role, Since: member.CreatedAt}
+	}
+
+	writeAPIPage(w, r, page, result, hasNext, len(members))
+}
+
+// apiChangeOrgMember adds, updates (PUT) or removes (DELETE) a member of
+// an organization.
+func (s *Server) apiChangeOrgMember(w http.ResponseWriter, r *http.Request, org *models.Organization, username string) {
+	if !requireAPIUser(w, r) {
+		return
+	}
+	user, _ := getUserFromContext(r)
+	if !s.canManageOrg(user, org) {
+		models.HandleError(w, r, models.NewForbiddenError("Only owners of the organization can change its members").ShowInProduction())
+		return
+	}
+
+	member, err := s.userService.GetUserByUsername(username)
+	if err != nil {
+		models.HandleError(w, r, models.NewNotFoundError("User not found").WithDetail(fmt.Sprintf("User: %s", username)).ShowInProduction())
+		return
+	}
+
+	if r.Method == http.MethodDelete {
+		err = s.orgs.RemoveMember(org.ID, member.ID)
+	} else {
+		// The body is optional
+		var req apiOrgMemberRequest
+		if r.ContentLength != 0 {
+			if appErr := decodeJSON(r, &req); appErr != nil {
+				models.HandleError(w, r, appErr)
+				return
+			}
+		}
+		if req.Role == "" {
+			req.Role = models.OrgRoleMember
+		}
+		err = s.orgs.SetMember(org.ID, member.ID, req.Role)
+	}
+	if errors.Is(err, models.ErrLastOrgOwner) {
+		models.HandleError(w, r, models.NewConflictError(err.Error()).ShowInProduction())
+		return
+	}
+	if err != nil {
+		models.HandleError(w, r, models.NewBadRequestError(err.Error()).ShowInProduction())
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,759 @@
+//handlers/api_repos.go
+
+package handlers
+
+import (
+	"SimpleGit/config"
+	"SimpleGit/models"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// apiCompareCommitLimit is the maximum number of commits a comparison
+// lists.
+const apiCompareCommitLimit = 250
+
+// apiRepository is a repository as the API returns it.
+type apiRepository struct {
+	Name          string    `json:"name"`
+	Owner         string    `json:"owner"`
+	Description   string    `json:"description"`
+	DefaultBranch string    `json:"default_branch,omitempty"`
+	Parent        string    `json:"parent,omitempty"`
+	Archived      bool      `json:"archived"`
+	Empty         bool      `json:"empty"`
+	CloneURL      string    `json:"clone_url"`
+	CreatedAt     time.Time `json:"created_at"`
+	Size          int64     `json:"size"`
+}
+
+// apiRepositoryRequest is the body of a request to create or update a
+// repository. Description and Archived are pointers so updates can leave
+// them unchanged. Owner defaults to the authenticated user on creation;
+// on updates a different Owner transfers the repository and a different
+// Name renames it. DefaultBranch and Archived are only used by updates.
+type apiRepositoryRequest struct {
+	Owner         string  `json:"owner,omitempty"`
+	Name          string  `json:"name"`
+	Description   *string `json:"description"`
+	DefaultBranch string  `json:"default_branch,omitempty"`
+	Archived      *bool   `json:"archived,omitempty"`
+}
+
+// apiBranchRequest is the body of a request to create or rename a branch.
+// From is the branch, tag or commit a new branch starts at and defaults to
+// the default branch.
+type apiBranchRequest struct {
+	Name string `json:"name"`
+	From string `json:"from,omitempty"`
+}
+
+// apiProtectionRequest is the body of a request to protect branches.
+type apiProtectionRequest struct {
+	Pattern string `json:"pattern"`
+}
+
+// apiForkRequest is the body of a request to fork a repository. Owner
+// defaults to the authenticated user and Name to the name of the
+// repository.
+type apiForkRequest struct {
+	Owner string `json:"owner,omitempty"`
+	Name  string `json:"name"`
+}
+
+// apiBlob is a file with its content base64 encoded.
+type apiBlob struct {
+	Path     string `json:"path"`
+	SHA      string `json:"sha"`
+	Size     int64  `json:"size"`
+	Encoding string `json:"encoding"`
+	Content  string `json:"content"`
+}
+
+// apiDiffStats totals the changes of a diff.
+type apiDiffStats struct {
+	Additions int `json:"additions"`
+	Deletions int `json:"deletions"`
+	Files     int `json:"files"`
+}
+
+// apiCommit is a commit with its parents and diff.
+type apiCommit struct {
+	models.CommitInfo
+	Committer   string            `json:"committer"`
+	CommittedAt time.Time         `json:"committed_at"`
+	Parents     []string          `json:"parents"`
+	Stats       apiDiffStats      `json:"stats"`
+	Files       []models.FileDiff `json:"files"`
+}
+
+// apiComparison compares two refs the way a pull request would.
+type apiComparison struct {
+	Base      string              `json:"base"`
+	Head      string              `json:"head"`
+	MergeBase string              `json:"merge_base"`
+	AheadBy   int                 `json:"ahead_by"`
+	BehindBy  int                 `json:"behind_by"`
+	Commits   []models.CommitInfo `json:"commits"`
+	Stats     apiDiffStats        `json:"stats"`
+	Files     []models.FileDiff   `json:"files"`
+}
+
+func newAPIRepository(repo *models.Repository) apiRepository {
+	defaultBranch, _ := repo.DefaultBranch()
+	return apiRepository{
+		Name:          repo.Name,
+		Owner:         repo.Owner,
+		Description:   repo.Description,
+		DefaultBranch: defaultBranch,
+		Parent:        repo.Parent,
+		Archived:      repo.Archived,
+		Empty:         defaultBranch == "",
+		CloneURL:      repo.CloneURL(),
+		CreatedAt:     repo.CreatedAt,
+		Size:          repo.Size,
+	}
+}
+
+func diffStats(files []models.FileDiff) apiDiffStats {
+	stats := apiDiffStats{Files: len(files)}
+	for _, file := range files {
+		stats.Additions += file.Additions
+		stats.Deletions += file.Deletions
+	}
+	return stats
+}
+
+// resolveAPIRef returns the commit ref names, the default branch if ref is
+// empty, or writes an error.
+func resolveAPIRef(w http.ResponseWriter, r *http.Request, repo *models.Repository, ref string) (*object.Commit, bool) {
+	if ref == "" {
+		defaultBranch, err := repo.DefaultBranch()
+		if err != nil || defaultBranch == "" {
+			models.HandleError(w, r, models.NewConflictError("Repository is empty").ShowInProduction())
+			return nil, false
+		}
+		ref = defaultBranch
+	}
+
+	commit, err := repo.ResolveCommit(ref)
+	if err != nil {
+		models.HandleError(w, r, models.NewNotFoundError("Ref not found").WithDetail(fmt.Sprintf("Ref: %s", ref)).ShowInProduction())
+		return nil, false
+	}
+	return commit, true
+}
+
+// resolveUpstreamRef returns the commit a branch of the repository repo
+// was forked from points to, or writes an error.
+func (s *Server) resolveUpstreamRef(w http.ResponseWriter, r *http.Request, repo *models.Repository, branch string) (*object.Commit, bool) {
+	parent, ok := s.Repository(repo.Parent)
+	if repo.Parent == "" || !ok {
+		models.HandleError(w, r, models.NewBadRequestError("This repository is not a fork of an existing repository").ShowInProduction())
+		return nil, false
+	}
+	if branch == "" {
+		branch, _ = parent.DefaultBranch()
+	}
+
+	hash, err := repo.FetchUpstream(parent, branch)
+	if err != nil {
+		models.HandleError(w, r, branchError(err).WithDetail(fmt.Sprintf("Branch: %s", branch)))
+		return nil, false
+	}
+	commit, err := repo.ResolveCommit(hash.String())
+	if err != nil {
+		models.HandleError(w, r, models.NewGitError("Failed to read upstream commit", err))
+		return nil, false
+	}
+	return commit, true
+}
+
+// apiListRepos lists the repositories sorted by name, optionally only
+// those of the owner query parameter.
+func (s *Server) apiListRepos(w http.ResponseWriter, r *http.Request) {
+	page, appErr := parseAPIPage(r)
+	if appErr != nil {
+		models.HandleError(w, r, appErr)
+		return
+	}
+
+	owner := r.URL.Query().Get("owner")
+	all := s.Repositories()
+	names := make([]string, 0, len(all))
+	for name, repo := range all {
+		if owner == "" || repo.Owner == owner {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	pageNames, hasNext := paginate(names, page)
+	repos := make([]apiRepository, len(pageNames))
+	for i, name := range pageNames {
+		repos[i] = newAPIRepository(all[name])
+	}
+
+	writeAPIPage(w, r, page, repos, hasNext, len(names))
+}
+
+// apiCreateRepo creates an empty repository for the authenticated user or
+// an owner the user may create repositories for.
+func (s *Server) apiCreateRepo(w http.ResponseWriter, r *http.Request) {
+	if !requireAPIUser(w, r) {
+		return
+	}
+	user, _ := getUserFromContext(r)
+
+	var req apiRepositoryRequest
+	if err := decodeJSON(r, &req); err != nil {
+		models.HandleError(w, r, err)
+		return
+	}
+
+	description := ""
+	if req.Description != nil {
+		description = strings.TrimSpace(*req.Description)
+	}
+
+	owner := strings.TrimSpace(req.Owner)
+	if owner == "" {
+		owner = user.Username
+	}
+
+	repo, err := s.createRepositoryAs(user, owner, strings.TrimSpace(req.Name), description)
+	if err != nil {
+		models.HandleError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, newAPIRepository(repo))
+}
+
+// apiListForks lists the forks of a repository sorted by name.
+func (s *Server) apiListForks(w http.ResponseWriter, r *http.Request, repo *models.Repository) {
+	page, appErr := parseAPIPage(r)
+	if appErr != nil {
+		models.HandleError(w, r, appErr)
+		return
+	}
+
+	forks := s.forksOf(repo)
+	pageForks, hasNext := paginate(forks, page)
+	repos := make([]apiRepository, len(pageForks))
+	for i, fork := range pageForks {
+		repos[i] = newAPIRepository(fork)
+	}
+
+	writeAPIPage(w, r, page, repos, hasNext, len(forks))
+}
+
+// apiCreateFork forks a repository for the authenticated user.
+func (s *Server) apiCreateFork(w http.ResponseWriter, r *http.Request, repo *models.Repository) {
+	if !requireAPIUser(w, r) {
+		return
+	}
+	user, _ := getUserFromContext(r)
+
+	var req apiForkRequest
+	if err := decodeJSON(r, &req); err != nil {
+		models.HandleError(w, r, err)
+		return
+	}
+	owner := strings.TrimSpace(req.Owner)
+	if owner == "" {
+		owner = user.Username
+	}
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		name = repo.ShortName()
+	}
+
+	fork, appErr := s.forkRepository(user, repo, owner, name)
+	if appErr != nil {
+		models.HandleError(w, r, appErr)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, newAPIRepository(fork))
+}
+
+// apiUpdateRepo updates the description or default branch of a
+// repository, renames it, transfers it to another owner or archives it.
+// An archived repository can be renamed, transferred and unarchived but
+// not otherwise changed.
+func (s *Server) apiUpdateRepo(w http.ResponseWriter, r *http.Request, repo *models.Repository) {
+	user, ok := getUserFromContext(r)
+	if !ok {
+		models.HandleError(w, r, models.NewUnauthorizedError("Authentication required").ShowInProduction())
+		return
+	}
+	if !s.canAdministerRepo(user, repo) {
+		models.HandleError(w, r, models.NewForbiddenError(fmt.Sprintf("You need admin access to %s to do this", repo.Name)).ShowInProduction())
+		return
+	}
+
+	var req apiRepositoryRequest
+	if err := decodeJSON(r, &req); err != nil {
+		models.HandleError(w, r, err)
+		return
+	}
+
+	if req.Archived != nil && !*req.Archived {
+		if err := repo.SetArchived(false); err != nil {
+			models.HandleError(w, r, models.NewGitError("Failed to unarchive repository", err))
+			return
+		}
+	}
+	if repo.Archived && (req.Description != nil || req.DefaultBranch != "") {
+		models.HandleError(w, r, models.NewForbiddenError(deniedMessage(repo, models.PermissionWrite)).ShowInProduction())
+		return
+	}
+
+	if req.Description != nil {
+		if err := repo.SetDescription(strings.TrimSpace(*req.Description)); err != nil {
+			models.HandleError(w, r, models.NewInternalError("Failed to update repository").WithError(err))
+			return
+		}
+	}
+	if req.DefaultBranch != "" {
+		if appErr := s.setDefaultBranch(repo, req.DefaultBranch); appErr != nil {
+			models.HandleError(w, r, appErr)
+			return
+		}
+	}
+	if req.Name != "" && req.Name != repo.Name && req.Name != repo.ShortName() {
+		if appErr := s.moveRepository(repo, repo.Owner+"/"+req.Name); appErr != nil {
+			models.HandleError(w, r, appErr)
+			return
+		}
+	}
+	if req.Owner != "" && req.Owner != repo.Owner {
+		if appErr := s.transferRepository(user, repo, req.Owner); appErr != nil {
+			models.HandleError(w, r, appErr)
+			return
+		}
+	}
+	if req.Archived != nil && *req.Archived {
+		if err := repo.SetArchived(true); err != nil {
+			models.HandleError(w, r, models.NewGitError("Failed to archive repository", err))
+			return
+		}
+	}
+
+	writeJSON(w, http.StatusOK, newAPIRepository(repo))
+}
+
+// apiDeleteRepo deletes a repository. Admins can restore it until the
+// retention period is over.
+func (s *Server) apiDeleteRepo(w http.ResponseWriter, r *http.Request, repo *models.Repository) {
+	if !s.requirePermission(w, r, repo, models.PermissionAdmin) {
+		return
+	}
+
+	user, _ := getUserFromContext(r)
+	if _, err := s.deleteRepository(repo, user.Username); err != nil {
+		models.HandleError(w, r, models.NewInternalError("Failed to delete repository").WithError(err))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// apiBranches lists the branches of a repository, or returns one if name
+// is set.
+func (s *Server) apiBranches(w http.ResponseWriter, r *http.Request, repo *models.Repository, name string) {
+	branches, err := s.listBranches(repo)
+	if err != nil {
+		models.HandleError(w, r, models.NewGitError("Failed to list branches", err))
+		return
+	}
+
+	if name != "" {
+		s.writeAPIBranch(w, r, repo, name, http.StatusOK)
+		return
+	}
+
+	page, appErr := parseAPIPage(r)
+	if appErr != nil {
+		models.HandleError(w, r, appErr)
+		return
+	}
+	if branches == nil {
+		branches = []models.Branch{}
+	}
+	items, hasNext := paginate(branches, page)
+	writeAPIPage(w, r, page, items, hasNext, len(branches))
+}
+
+// writeAPIBranch writes a branch of a repository with the given status.
+func (s *Server) writeAPIBranch(w http.ResponseWriter, r *http.Request, repo *models.Repository, name string, status int) {
+	branches, err := s.listBranches(repo)
+	if err != nil {
+		models.HandleError(w, r, models.NewGitError("Failed to list branches", err))
+		return
+	}
+
+	for _, branch := range branches {
+		if branch.Name == name {
+			writeJSON(w, status, branch)
+			return
+		}
+	}
+	models.HandleError(w, r, models.NewNotFoundError("Branch not found").WithDetail(fmt.Sprintf("Branch: %s", name)).ShowInProduction())
+}
+
+// apiCreateBranch creates a branch.
+func (s *Server) apiCreateBranch(w http.ResponseWriter, r *http.Request, repo *models.Repository) {
+	if !s.requirePermission(w, r, repo, models.PermissionWrite) {
+		return
+	}
+
+	var req apiBranchRequest
+	if err := decodeJSON(r, &req); err != nil {
+		models.HandleError(w, r, err)
+		return
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if appErr := s.createBranch(repo, name, strings.TrimSpace(req.From)); appErr != nil {
+		models.HandleError(w, r, appErr)
+		return
+	}
+
+	s.writeAPIBranch(w, r, repo, name, http.StatusCreated)
+}
+
+// apiRenameBranch renames a branch.
+func (s *Server) apiRenameBranch(w http.ResponseWriter, r *http.Request, repo *models.Repository, name string) {
+	if !s.requirePermission(w, r, repo, models.PermissionWrite) {
+		return
+	}
+
+	var req apiBranchRequest
+	if err := decodeJSON(r, &req); err != nil {
+		models.HandleError(w, r, err)
+		return
+	}
+
+	newName := strings.TrimSpace(req.Name)
+	if appErr := s.renameBranch(repo, name, newName); appErr != nil {
+		models.HandleError(w, r, appErr)
+		return
+	}
+
+	s.writeAPIBranch(w, r, repo, newName, http.StatusOK)
+}
+
+// apiDeleteBranch deletes a branch.
+func (s *Server) apiDeleteBranch(w http.ResponseWriter, r *http.Request, repo *models.Repository, name string) {
+	if !s.requirePermission(w, r, repo, models.PermissionWrite) {
+		return
+	}
+
+	if appErr := s.deleteBranch(repo, name); appErr != nil {
+		models.HandleError(w, r, appErr)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// apiListProtections lists the branch protection rules of a repository.
+func (s *Server) apiListProtections(w http.ResponseWriter, r *http.Request, repo *models.Repository) {
+	rules, err := s.protections.List(repo.Name)
+	if err != nil {
+		models.HandleError(w, r, models.NewInternalError("Failed to load branch protection").WithError(err))
+		return
+	}
+	if rules == nil {
+		rules = []models.BranchProtection{}
+	}
+
+	writeJSON(w, http.StatusOK, rules)
+}
+
+// apiAddProtection protects the branches matching a pattern.
+func (s *Server) apiAddProtection(w http.ResponseWriter, r *http.Request, repo *models.Repository) {
+	if !s.requirePermission(w, r, repo, models.PermissionAdmin) {
+		return
+	}
+
+	var req apiProtectionRequest
+	if err := decodeJSON(r, &req); err != nil {
+		models.HandleError(w, r, err)
+		return
+	}
+
+	rule, appErr := s.protectBranches(repo, strings.TrimSpace(req.Pattern))
+	if appErr != nil {
+		models.HandleError(w, r, appErr)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, rule)
+}
+
+// apiDeleteProtection removes a branch protection rule.
+func (s *Server) apiDeleteProtection(w http.ResponseWriter, r *http.Request, repo *models.Repository, id string) {
+	if !s.requirePermission(w, r, repo, models.PermissionAdmin) {
+		return
+	}
+
+	if err := s.protections.Delete(repo.Name, id); err != nil {
+		models.HandleError(w, r, models.NewInternalError("Failed to remove protection").WithError(err))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// apiTags lists the tags of a repository, or returns one if name is set.
+func (s *Server) apiTags(w http.ResponseWriter, r *http.Request, repo *models.Repository, name string) {
+	tags, err := repo.ListTags()
+	if err != nil {
+		models.HandleError(w, r, models.NewGitError("Failed to list tags", err))
+		return
+	}
+
+	if name != "" {
+		for _, tag := range tags {
+			if tag.Name == name {
+				writeJSON(w, http.StatusOK, tag)
+				return
+			}
+		}
+		models.HandleError(w, r, models.NewNotFoundError("Tag not found").WithDetail(fmt.Sprintf("Tag: %s", name)).ShowInProduction())
+		return
+	}
+
+	page, appErr := parseAPIPage(r)
+	if appErr != nil {
+		models.HandleError(w, r, appErr)
+		return
+	}
+	items, hasNext := paginate(tags, page)
+	writeAPIPage(w, r, page, items, hasNext, len(tags))
+}
+
+// apiTree lists a directory at a ref.
+func (s *Server) apiTree(w http.ResponseWriter, r *http.Request, repo *models.Repository, dirPath string) {
+	page, appErr := parseAPIPage(r)
+	if appErr != nil {
+		models.HandleError(w, r, appErr)
+		return
+	}
+
+	commit, ok := resolveAPIRef(w, r, repo, r.URL.Query().Get("ref"))
+	if !ok {
+		return
+	}
+
+	items, err := repo.ListTree(commit, dirPath)
+	if err != nil {
+		models.HandleError(w, r, models.NewNotFoundError("Directory not found").WithDetail(fmt.Sprintf("Path: %s", dirPath)).ShowInProduction())
+		return
+	}
+
+	pageItems, hasNext := paginate(items, page)
+	writeAPIPage(w, r, page, pageItems, hasNext, len(items))
+}
+
+// apiBlob returns a file at a ref, base64 encoded in JSON or raw.
+func (s *Server) apiBlob(w http.ResponseWriter, r *http.Request, repo *models.Repository, filePath string, raw bool) {
+	if filePath == "" {
+		models.HandleError(w, r, models.NewBadRequestError("A file path is required").ShowInProduction())
+		return
+	}
+
+	commit, ok := resolveAPIRef(w, r, repo, r.URL.Query().Get("ref"))
+	if !ok {
+		return
+	}
+
+	file, err := commit.File(filePath)
+	if err != nil {
+		models.HandleError(w, r, models.NewNotFoundError("File not found").WithDetail(fmt.Sprintf("Path: %s", filePath)).ShowInProduction())
+		return
+	}
+
+	if !raw && file.Size > config.GlobalConfig.MaxFileSize {
+		models.HandleError(w, r, models.NewBadRequestError("File is too large to encode; use the raw endpoint").ShowInProduction())
+		return
+	}
+
+	reader, err := file.Reader()
+	if err != nil {
+		models.HandleError(w, r, models.NewGitError("Failed to read file", err))
+		return
+	}
+	defer reader.Close()
+
+	if raw {
+		// Served as a download so HTML and SVG files cannot run as pages
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.Header().Set("Content-Length", strconv.FormatInt(file.Size, 10))
+		io.Copy(w, reader)
+		return
+	}
+
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		models.HandleError(w, r, models.NewGitError("Failed to read file", err))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, apiBlob{
+		Path:     file.Name,
+		SHA:      file.Hash.String(),
+		Size:     file.Size,
+		Encoding: "base64",
+		Content:  base64.StdEncoding.EncodeToString(content),
+	})
+}
+
+// apiListCommits lists the history of a ref, optionally limited to the
+// commits that touched a path.
+func (s *Server) apiListCommits(w http.ResponseWriter, r *http.Request, repo *models.Repository) {
+	page, appErr := parseAPIPage(r)
+	if appErr != nil {
+		models.HandleError(w, r, appErr)
+		return
+	}
+
+	query := r.URL.Query()
+	commit, ok := resolveAPIRef(w, r, repo, query.Get("ref"))
+	if !ok {
+		return
+	}
+
+	// Fetch one extra commit to know whether there is a next page
+	commits, err := repo.ListCommits(commit, query.Get("path"), page.Offset(), page.PerPage+1)
+	if err != nil {
+		models.HandleError(w, r, models.NewGitError("Failed to list commits", err))
+		return
+	}
+
+	hasNext := len(commits) > page.PerPage
+	if hasNext {
+		commits = commits[:page.PerPage]
+	}
+	writeAPIPage(w, r, page, commits, hasNext, -1)
+}
+
+// apiGetCommit returns a commit with its diff against its first parent.
+func (s *Server) apiGetCommit(w http.ResponseWriter, r *http.Request, repo *models.Repository, ref string) {
+	commit, ok := resolveAPIRef(w, r, repo, ref)
+	if !ok {
+		return
+	}
+
+	var parent *object.Commit
+	if commit.NumParents() > 0 {
+		var err error
+		if parent, err = commit.Parent(0); err != nil {
+			models.HandleError(w, r, models.NewGitError("Failed to get parent commit", err))
+			return
+		}
+	}
+
+	files, err := repo.DiffCommits(parent, commit)
+	if err != nil {
+		models.HandleError(w, r, models.NewGitError("Failed to get diff", err))
+		return
+	}
+
+	parents := make([]string, len(commit.ParentHashes))
+	for i, hash := range commit.ParentHashes {
+		parents[i] = hash.String()
+	}
+
+	writeJSON(w, http.StatusOK, apiCommit{
+		CommitInfo: models.CommitInfo{
+			Hash:      commit.Hash.String(),
+			Author:    commit.Author.Name,
+			Email:     commit.Author.Email,
+			Message:   commit.Message,
+			Timestamp: commit.Author.When,
+		},
+		Committer:   commit.Committer.Name,
+		CommittedAt: commit.Committer.When,
+		Parents:     parents,
+		Stats:       diffStats(files),
+		Files:       files,
+	})
+}
+
+// apiCompare compares head against base: the commits only in head and
+// the diff from their merge base.
+func (s *Server) apiCompare(w http.ResponseWriter, r *http.Request, repo *models.Repository, spec string) {
+	baseRef, headRef, ok := strings.Cut(spec, "...")
+	if !ok || baseRef == "" || headRef == "" {
+		models.HandleError(w, r, models.NewBadRequestError("Compare as <base>...<head>").ShowInProduction())
+		return
+	}
+
+	var base *object.Commit
+	if branch, upstream := strings.CutPrefix(baseRef, "upstream:"); upstream {
+		base, ok = s.resolveUpstreamRef(w, r, repo, branch)
+	} else {
+		base, ok = resolveAPIRef(w, r, repo, baseRef)
+	}
+	if !ok {
+		return
+	}
+	head, ok := resolveAPIRef(w, r, repo, headRef)
+	if !ok {
+		return
+	}
+
+	mergeBase, err := repo.MergeBase(base.Hash.String(), head.Hash.String())
+	if err != nil {
+		models.HandleError(w, r, models.NewConflictError("The refs have no common history").ShowInProduction())
+		return
+	}
+
+	ahead, behind, err := repo.AheadBehind(base.Hash.String(), head.Hash.String())
+	if err != nil {
+		models.HandleError(w, r, models.NewGitError("Failed to count commits", err))
+		return
+	}
+
+	commits, err := repo.CommitsBetween(base.Hash.String(), head.Hash.String(), apiCompareCommitLimit)
+	if err != nil {
+		models.HandleError(w, r, models.NewGitError("Failed to list commits", err))
+		return
+	}
+
+	mergeBaseCommit, err := repo.ResolveCommit(mergeBase)
+	if err != nil {
+		models.HandleError(w, r, models.NewGitError("Failed to get merge base", err))
+		return
+	}
+
+	files, err := repo.DiffCommits(mergeBaseCommit, head)
+	if err != nil {
+		models.HandleError(w, r, models.NewGitError("Failed to get diff", err))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, apiComparison{
+		Base:      base.Hash.String(),
+		Head:      head.Hash.String(),
+		MergeBase: mergeBase,
+		AheadBy:   ahead,
+		BehindBy:  behind,
+		Commits:   commits,
+		Stats:     diffStats(files),
+		Files:     files,
+	})
+}
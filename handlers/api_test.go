@@ -0,0 +1,75 @@
+//handlers/api_test.go
+
+package handlers
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+func TestParseAPIPage(t *testing.T) {
+	tests := []struct {
+		query  string
+		want   apiPage
+		offset int
+		ok     bool
+	}{
+		{"", apiPage{1, apiDefaultPerPage}, 0, true},
+		{"page=3&per_page=10", apiPage{3, 10}, 20, true},
+		{"page=0", apiPage{}, 0, false},
+		{"page=-1", apiPage{}, 0, false},
+		{"page=two", apiPage{}, 0, false},
+		{"per_page=0", apiPage{}, 0, false},
+		{"per_page=101", apiPage{}, 0, false},
+		{"page=307445734561825862&per_page=30", apiPage{}, 0, false},
+		{"page=" + strconv.Itoa(math.MaxInt) + "&per_page=1", apiPage{math.MaxInt, 1}, math.MaxInt - 1, true},
+		{"page=" + strconv.Itoa(math.MaxInt/2+1) + "&per_page=2", apiPage{}, 0, false},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/api/v1/repos?"+tt.query, nil)
+		page, err := parseAPIPage(r)
+		if (err == nil) != tt.ok {
+			t.Errorf("parseAPIPage(%q) error = %v, want ok %v", tt.query, err, tt.ok)
+			continue
+		}
+		if !tt.ok {
+			if err.Code != http.StatusBadRequest {
+				t.Errorf("parseAPIPage(%q) error code = %d, want 400", tt.query, err.Code)
+			}
+			continue
+		}
+		if page != tt.want || page.Offset() != tt.offset {
+			t.Errorf("parseAPIPage(%q) = %+v at offset %d, want %+v at %d", tt.query, page, page.Offset(), tt.want, tt.offset)
+		}
+	}
+
+	// The last page an int can address is past any list
+	if items, hasNext := paginate([]int{1, 2, 3}, apiPage{math.MaxInt, 1}); len(items) != 0 || hasNext {
+		t.Errorf("paginate of page %d = %v, %v, want an empty last page", math.MaxInt, items, hasNext)
+	}
+}
+
+// TestAPIPageTooLarge checks that list endpoints answer a page whose
+// offset overflows with 400 rather than panicking.
+func TestAPIPageTooLarge(t *testing.T) {
+	s, cookie := newTestServer(t)
+	handler := s.addAPIUserData(s.handleAPIv1)
+
+	for _, path := range []string{
+		"/api/v1/repos",
+		"/api/v1/repos/alice/demo/branches",
+		"/api/v1/repos/alice/demo/commits",
+		"/api/v1/orgs",
+	} {
+		req := httptest.NewRequest("GET", path+"?page=307445734561825862&per_page=30", nil)
+		req.AddCookie(cookie)
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("GET %s with a huge page: got %d, want 400", path, rec.Code)
+		}
+	}
+}
@@ -5,8 +5,10 @@ package handlers
 import (
 	"SimpleGit/models"
 	"context"
+	"log"
 	"net/http"
 	"os"
+	"strings"
 )
 
 type contextKey string
@@ -56,6 +58,70 @@ func (s *Server) AdminMiddleware(next http.HandlerFunc) http.HandlerFunc {
 	}
 }
 
+// accessTokenFromRequest returns the access token of an API request sent
+// as "Authorization: token <token>" or "Authorization: Bearer <token>".
+func accessTokenFromRequest(r *http.Request) string {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok {
+		return ""
+	}
+	if !strings.EqualFold(scheme, "token") && !strings.EqualFold(scheme, "bearer") {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
+// apiUser authenticates an API request with an access token, falling back
+// to the session cookie so the web interface can call the same endpoints.
+// It returns nil without an error for anonymous requests.
+func (s *Server) apiUser(r *http.Request) (*models.User, error) {
+	if token := accessTokenFromRequest(r); token != "" {
+		return s.userService.VerifyAccessToken(token)
+	}
+	if cookie, err := r.Cookie("auth_token"); err == nil {
+		return s.userService.VerifyToken(cookie.Value)
+	}
+	return nil, nil
+}
+
+// withUser returns r with user stored in its context.
+func withUser(r *http.Request, user *models.User) *http.Request {
+	ctx := context.WithValue(r.Context(), userContextKey, user)
+	ctx = context.WithValue(ctx, userIDContextKey, user.ID)
+	ctx = context.WithValue(ctx, userIsAdminContextKey, user.IsAdmin)
+	return r.WithContext(ctx)
+}
+
+// TokenMiddleware authenticates API requests with an access token or the
+// session cookie. Unlike AuthMiddleware it answers with a JSON error
+// instead of redirecting to the login page.
+func (s *Server) TokenMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, err := s.apiUser(r)
+		if err != nil || user == nil {
+			models.HandleError(w, r, models.NewUnauthorizedError("Authentication required").ShowInProduction())
+			return
+		}
+		next.ServeHTTP(w, withUser(r, user))
+	}
+}
+
+// addAPIUserData is addUserData for API routes: it accepts access tokens
+// and rejects invalid ones instead of treating the request as anonymous.
+func (s *Server) addAPIUserData(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, err := s.apiUser(r)
+		if err != nil && accessTokenFromRequest(r) != "" {
+			models.HandleError(w, r, models.NewUnauthorizedError("Invalid access token").ShowInProduction())
+			return
+		}
+		if user != nil {
+			r = withUser(r, user)
+		}
+		next.ServeHTTP(w, r)
+	}
+}
+
 func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
 	if r.Method == "GET" {
 		s.tmpl.ExecuteTemplate(w, "login.html", nil)
@@ -156,6 +222,13 @@ func (s *Server) handleAdminSetup(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Repositories from before owners existed belong to the first admin
+	if err := s.MigrateFlatRepositories(); err != nil {
+		log.Printf("Failed to move repositories to the new admin: %v", err)
+	} else if err := s.ScanRepositories(); err != nil {
+		log.Printf("Error rescanning repositories: %v", err)
+	}
+
 	// Delete setup token file
 	os.Remove("admin_setup_token.txt")
 	http.Redirect(w, r, "/login", http.StatusSeeOther)
@@ -165,6 +238,10 @@ func (s *Server) requireAuth(next http.HandlerFunc) http.HandlerFunc {
 	return s.AuthMiddleware(next)
 }
 
+func (s *Server) requireToken(next http.HandlerFunc) http.HandlerFunc {
+	return s.TokenMiddleware(next)
+}
+
 func (s *Server) requireAdmin(next http.HandlerFunc) http.HandlerFunc {
 	return s.AuthMiddleware(s.AdminMiddleware(next))
 }
@@ -0,0 +1,410 @@
+//handlers/branches.go
+
+package handlers
+
+import (
+	"SimpleGit/models"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+// handleBranches lists the branches of a repository and manages them.
+// Signed in users can create, rename and delete branches; admins can also
+// change the default branch and the protection rules.
+//
+// Routes:
+//   - /branches/<repo>?filter=: The list of branches, optionally only the
+//     active, stale or merged ones.
+//   - /branches/<repo>/{create,rename,delete}: Branch actions (POST), with
+//     write access.
+//   - /branches/<repo>/delete-merged: Delete every merged branch (POST),
+//     with write access.
+//   - /branches/<repo>/{default,protect,unprotect}: Actions needing admin
+//     access (POST).
+func (s *Server) handleBranches(w http.ResponseWriter, r *http.Request) {
+	parts := repoPathParts(r.URL.Path, 1)
+	if len(parts) < 2 || len(parts) > 3 {
+		models.HandleError(w, r, models.NewBadRequestError("Invalid repository path"))
+		return
+	}
+
+	repo, ok := s.Repository(parts[1])
+	if !ok {
+		models.HandleError(w, r, models.NewNotFoundError("Repository not found").WithDetail(fmt.Sprintf("Repository: %s", parts[1])))
+		return
+	}
+
+	if len(parts) == 2 {
+		s.handleBranchList(w, r, repo)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := getUserFromContext(r)
+	if !ok {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+	perm := s.permission(user, repo)
+
+	var appErr *models.AppError
+	switch parts[2] {
+	case "create", "rename", "delete", "delete-merged":
+		if !perm.CanWrite() {
+			models.HandleError(w, r, models.NewForbiddenError(deniedMessage(repo, models.PermissionWrite)).ShowInProduction())
+			return
+		}
+	}
+	switch parts[2] {
+	case "create":
+		appErr = s.createBranch(repo, strings.TrimSpace(r.FormValue("name")), strings.TrimSpace(r.FormValue("from")))
+	case "rename":
+		appErr = s.renameBranch(repo, r.FormValue("branch"), strings.TrimSpace(r.FormValue("name")))
+	case "delete":
+		appErr = s.deleteBranch(repo, r.FormValue("branch"))
+	case "delete-merged":
+		_, appErr = s.deleteMergedBranches(repo)
+	case "default", "protect", "unprotect":
+		if !perm.CanAdmin() {
+			appErr = models.NewForbiddenError(deniedMessage(repo, models.PermissionAdmin)).ShowInProduction()
+			break
+		}
+		switch parts[2] {
+		case "default":
+			appErr = s.setDefaultBranch(repo, r.FormValue("branch"))
+		case "protect":
+			_, appErr = s.protectBranches(repo, strings.TrimSpace(r.FormValue("pattern")))
+		case "unprotect":
+			if err := s.protections.Delete(repo.Name, r.FormValue("id")); err != nil {
+				appErr = models.NewInternalError("Failed to remove protection").WithError(err)
+			}
+		}
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	if appErr != nil {
+		models.HandleError(w, r, appErr)
+		return
+	}
+	http.Redirect(w, r, "/branches/"+repo.Name, http.StatusSeeOther)
+}
+
+// branchFilters are the filters of the branch list besides all branches.
+var branchFilters = map[string]func(models.Branch) bool{
+	"active": func(b models.Branch) bool { return !b.Default && !b.Stale && !b.Merged },
+	"stale":  func(b models.Branch) bool { return b.Stale },
+	"merged": func(b models.Branch) bool { return b.Merged },
+}
+
+// listBranches returns the branches of a repository. Besides the branches
+// the default branch contains, branches whose tip was merged by a pull
+// request into the default branch count as merged, since squash and
+// rebase merges copy their commits.
+func (s *Server) listBranches(repo *models.Repository) ([]models.Branch, error) {
+	branches, err := repo.ListBranches()
+	if err != nil || len(branches) == 0 || !branches[0].Default {
+		return branches, err
+	}
+
+	heads, err := s.pullRequests.MergedHeads(repo.Name, branches[0].Name)
+	if err != nil {
+		log.Printf("Failed to load merged pull requests of %s: %v", repo.Name, err)
+		return branches, nil
+	}
+	for i := range branches[1:] {
+		if heads[branches[i+1].Commit.Hash] {
+			branches[i+1].Merged = true
+		}
+	}
+	return branches, nil
+}
+
+// handleBranchList renders the branches of a repository with the commit at
+// each tip, its combined status, how it compares to the default branch and
+// whether the branch is protected.
+func (s *Server) handleBranchList(w http.ResponseWriter, r *http.Request, repo *models.Repository) {
+	filter := r.URL.Query().Get("filter")
+	keep, ok := branchFilters[filter]
+	if filter != "" && !ok {
+		models.HandleError(w, r, models.NewBadRequestError("Invalid filter").WithDetail(fmt.Sprintf("Filter: %s", filter)).ShowInProduction())
+		return
+	}
+
+	all, err := s.listBranches(repo)
+	if err != nil {
+		models.HandleError(w, r, models.NewGitError("Failed to list branches", err))
+		return
+	}
+
+	names := make([]string, len(all))
+	counts := make(map[string]int)
+	for i, branch := range all {
+		names[i] = branch.Name
+		for name, matches := range branchFilters {
+			if matches(branch) {
+				counts[name]++
+			}
+		}
+	}
+
+	branches := all
+	if keep != nil {
+		branches = nil
+		for _, branch := range all {
+			if keep(branch) {
+				branches = append(branches, branch)
+			}
+		}
+	}
+
+	hashes := make([]string, len(branches))
+	for i, branch := range branches {
+		hashes[i] = branch.Commit.Hash
+	}
+
+	rules, err := s.protections.List(repo.Name)
+	if err != nil {
+		models.HandleError(w, r, models.NewInternalError("Failed to load branch protection").WithError(err))
+		return
+	}
+	protected, err := s.protections.Protected(repo.Name, names)
+	if err != nil {
+		models.HandleError(w, r, models.NewInternalError("Failed to load branch protection").WithError(err))
+		return
+	}
+
+	deletable := 0
+	for _, branch := range all {
+		if branch.Merged && !protected[branch.Name] {
+			deletable++
+		}
+	}
+
+	data := map[string]interface{}{
+		"Repo":      repo,
+		"Branches":  branches,
+		"AllNames":  names,
+		"Filter":    filter,
+		"Counts":    counts,
+		"Total":     len(all),
+		"Deletable": deletable,
+		"Statuses":  s.commitStatuses(repo, hashes),
+		"Protected": protected,
+		"Rules":     rules,
+	}
+
+	if err := s.tmpl.ExecuteTemplate(w, "branches.html", s.addCommonData(r, data)); err != nil {
+		models.HandleError(w, r, models.NewInternalError("Failed to render template").WithError(err))
+	}
+}
+
+// branchError converts an error of a branch operation to the error shown
+// to the user.
+func branchError(err error) *models.AppError {
+	switch {
+	case errors.Is(err, models.ErrBranchNotFound):
+		return models.NewNotFoundError("Branch not found").ShowInProduction()
+	case errors.Is(err, models.ErrBranchExists):
+		return models.NewConflictError("A branch with that name already exists").ShowInProduction()
+	case errors.Is(err, models.ErrBranchMoved):
+		return models.NewConflictError("The branch changed while it was being updated").ShowInProduction()
+	case errors.Is(err, models.ErrInvalidChange):
+		return models.NewBadRequestError(err.Error()).ShowInProduction()
+	default:
+		return models.NewGitError("Failed to update branch", err)
+	}
+}
+
+// checkBranchUnprotected returns an error if a protection rule of the
+// repository covers branch.
+func (s *Server) checkBranchUnprotected(repo *models.Repository, branch string) *models.AppError {
+	protected, err := s.protections.IsProtected(repo.Name, branch)
+	if err != nil {
+		return models.NewInternalError("Failed to load branch protection").WithError(err)
+	}
+	if protected {
+		return models.NewForbiddenError(fmt.Sprintf("Branch %s is protected", branch)).ShowInProduction()
+	}
+	return nil
+}
+
+// createBranch creates a branch at the commit from names, or at the
+// default branch if from is empty.
+func (s *Server) createBranch(repo *models.Repository, name, from string) *models.AppError {
+	if !models.ValidBranchName(name) {
+		return models.NewBadRequestError("Invalid branch name").WithDetail(fmt.Sprintf("Branch: %s", name)).ShowInProduction()
+	}
+	if from == "" {
+		defaultBranch, err := repo.DefaultBranch()
+		if err != nil || defaultBranch == "" {
+			return models.NewConflictError("The repository is empty").ShowInProduction()
+		}
+		from = defaultBranch
+	}
+
+	hash, err := repo.CreateBranch(name, from)
+	if err != nil {
+		return branchError(err)
+	}
+
+	s.HandlePush(repo.Path, []models.RefUpdate{{Name: plumbing.NewBranchReferenceName(name), New: hash}})
+	return nil
+}
+
+// deleteBranch deletes a branch unless it is the default branch or
+// protected.
+func (s *Server) deleteBranch(repo *models.Repository, name string) *models.AppError {
+	if defaultBranch, _ := repo.DefaultBranch(); name == defaultBranch {
+		return models.NewConflictError("The default branch cannot be deleted").ShowInProduction()
+	}
+	if appErr := s.checkBranchUnprotected(repo, name); appErr != nil {
+		return appErr
+	}
+
+	hash, err := repo.DeleteBranch(name)
+	if err != nil {
+		return branchError(err)
+	}
+
+	s.HandlePush(repo.Path, []models.RefUpdate{{Name: plumbing.NewBranchReferenceName(name), Old: hash}})
+	return nil
+}
+
+// deleteMergedBranches deletes the merged branches of a repository that
+// are not protected and not used by an open pull request, and returns how
+// many were deleted.
+func (s *Server) deleteMergedBranches(repo *models.Repository) (int, *models.AppError) {
+	branches, err := s.listBranches(repo)
+	if err != nil {
+		return 0, models.NewGitError("Failed to list branches", err)
+	}
+
+	var names []string
+	for _, branch := range branches {
+		if branch.Merged {
+			names = append(names, branch.Name)
+		}
+	}
+	protected, err := s.protections.Protected(repo.Name, names)
+	if err != nil {
+		return 0, models.NewInternalError("Failed to load branch protection").WithError(err)
+	}
+
+	var updates []models.RefUpdate
+	for _, name := range names {
+		if protected[name] {
+			continue
+		}
+		if prs, err := s.pullRequests.ListOpenForBranch(repo.Name, name); err != nil || len(prs) > 0 {
+			continue
+		}
+
+		hash, err := repo.DeleteBranch(name)
+		if err != nil {
+			log.Printf("Failed to delete merged branch %s of %s: %v", name, repo.Name, err)
+			continue
+		}
+		updates = append(updates, models.RefUpdate{Name: plumbing.NewBranchReferenceName(name), Old: hash})
+	}
+
+	if len(updates) > 0 {
+		s.HandlePush(repo.Path, updates)
+	}
+	return len(updates), nil
+}
+
+// renameBranch renames an unprotected branch. Open pull requests and HEAD
+// follow the branch.
+func (s *Server) renameBranch(repo *models.Repository, oldName, newName string) *models.AppError {
+	if !models.ValidBranchName(newName) {
+		return models.NewBadRequestError("Invalid branch name").WithDetail(fmt.Sprintf("Branch: %s", newName)).ShowInProduction()
+	}
+	if oldName == newName {
+		return nil
+	}
+	if appErr := s.checkBranchUnprotected(repo, oldName); appErr != nil {
+		return appErr
+	}
+
+	hash, err := repo.RenameBranch(oldName, newName)
+	if err != nil {
+		return branchError(err)
+	}
+
+	if err := s.pullRequests.RenameBranch(repo.Name, oldName, newName); err != nil {
+		log.Printf("Failed to retarget pull requests of %s from %s to %s: %v", repo.Name, oldName, newName, err)
+	}
+
+	s.HandlePush(repo.Path, []models.RefUpdate{
+		{Name: plumbing.NewBranchReferenceName(oldName), Old: hash},
+		{Name: plumbing.NewBranchReferenceName(newName), New: hash},
+	})
+	return nil
+}
+
+// setDefaultBranch points HEAD at a branch.
+func (s *Server) setDefaultBranch(repo *models.Repository, name string) *models.AppError {
+	if err := repo.SetDefaultBranch(name); err != nil {
+		return branchError(err)
+	}
+
+	// Code search indexes the default branch
+	s.codeSearch.Update(repo.Name, repo.Path)
+	return nil
+}
+
+// protectBranches adds a protection rule to a repository.
+func (s *Server) protectBranches(repo *models.Repository, pattern string) (*models.BranchProtection, *models.AppError) {
+	if !models.ValidProtectionPattern(pattern) {
+		return nil, models.NewBadRequestError("Invalid branch pattern").WithDetail(fmt.Sprintf("Pattern: %s", pattern)).ShowInProduction()
+	}
+
+	rule, err := s.protections.Add(repo.Name, pattern)
+	if err != nil {
+		return nil, models.NewInternalError("Failed to protect branches").WithError(err)
+	}
+	return rule, nil
+}
+
+// ReceivePackEnv returns the environment git receive-pack needs to enforce
+// branch protection for the repository at repoPath. Pushes over HTTP and
+// SSH both use it. If the protected branches cannot be worked out the
+// push is rejected rather than let through unprotected.
+func (s *Server) ReceivePackEnv(repoPath string) []string {
+	repo := s.repoByPath(repoPath)
+	if repo == nil {
+		return models.RejectPushEnv(s.hooksPath, "the repository is not known to the server; try again later")
+	}
+
+	defaultBranch, _ := repo.DefaultBranch()
+
+	branches, err := repo.GetBranches()
+	if err != nil {
+		log.Printf("Failed to list branches of %s: %v", repo.Name, err)
+		return models.RejectPushEnv(s.hooksPath, "branch protection could not be checked; try again later")
+	}
+	matches, err := s.protections.Protected(repo.Name, branches)
+	if err != nil {
+		log.Printf("Failed to load branch protection of %s: %v", repo.Name, err)
+		return models.RejectPushEnv(s.hooksPath, "branch protection could not be checked; try again later")
+	}
+
+	var protected []string
+	for _, branch := range branches {
+		if matches[branch] {
+			protected = append(protected, branch)
+		}
+	}
+	return models.ReceivePackEnv(s.hooksPath, defaultBranch, protected)
+}
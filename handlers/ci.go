@@ -0,0 +1,323 @@
+//handlers/ci.go
+
+package handlers
+
+import (
+	"SimpleGit/models"
+	"SimpleGit/services"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+// ciJobListLimit is the number of jobs listed on the CI page.
+const ciJobListLimit = 50
+
+// ciPollInterval is how often the log stream checks for new output.
+const ciPollInterval = 500 * time.Millisecond
+
+// EnableCI starts the CI runner. Without it pushes do not run pipelines.
+//
+// Parameters:
+//   - logDir: The directory step logs are written to.
+//   - workers: How many jobs may run at the same time.
+//   - stepTimeout: The timeout of steps that do not set one.
+func (s *Server) EnableCI(logDir string, workers int, stepTimeout time.Duration) {
+	s.ciRunner = services.NewCIRunner(s.ciJobs, s.statuses, logDir, workers)
+	s.ciStepTimeout = stepTimeout
+}
+
+// triggerCI queues a job for every pushed branch whose new commit has a
+// pipeline file that matches the branch. Invalid pipeline files produce a
+// failed job so the author sees why nothing ran.
+//
+// Parameters:
+//   - repo: The repository that was pushed to.
+//   - updates: The references the push changed.
+func (s *Server) triggerCI(repo *models.Repository, updates []models.RefUpdate) {
+	if s.ciRunner == nil {
+		return
+	}
+
+	gitRepo, err := repo.Git()
+	if err != nil {
+		return
+	}
+
+	for _, update := range updates {
+		if !update.IsBranch() || update.IsDelete() {
+			continue
+		}
+		branch := update.Name.Short()
+
+		commit, err := gitRepo.CommitObject(update.New)
+		if err != nil {
+			continue
+		}
+		file, err := commit.File(services.PipelinePath)
+		if err != nil {
+			continue
+		}
+		content, err := file.Contents()
+		if err != nil {
+			continue
+		}
+
+		job := &models.CIJob{
+			RepoName: repo.Name,
+			Ref:      branch,
+			Commit:   update.New.String(),
+		}
+
+		pipeline, err := services.ParsePipeline([]byte(content))
+		if err != nil {
+			job.Pipeline = "ci"
+			job.Status = models.CIError
+			job.Error = err.Error()
+			if err := s.ciJobs.CreateJob(job); err != nil {
+				log.Printf("CI: failed to record job for %s@%s: %v", repo.Name, branch, err)
+				continue
+			}
+			s.statuses.Set(&models.CommitStatus{
+				RepoName:    repo.Name,
+				Commit:      job.Commit,
+				Context:     services.StatusContext(job.Pipeline),
+				State:       models.StatusError,
+				TargetURL:   fmt.Sprintf("/ci/%s/%d", repo.Name, job.Number),
+				Description: "Invalid pipeline",
+			})
+			continue
+		}
+		if !pipeline.Matches(branch) {
+			continue
+		}
+
+		job.Pipeline = pipeline.Name
+		job.Steps = pipeline.JobSteps(s.ciStepTimeout)
+		if err := s.ciJobs.CreateJob(job); err != nil {
+			log.Printf("CI: failed to create job for %s@%s: %v", repo.Name, branch, err)
+			continue
+		}
+
+		log.Printf("CI: queued %s#%d for %s@%s", repo.Name, job.Number, branch, job.Commit[:7])
+		s.ciRunner.Enqueue(job, repo.Path, pipeline.Env)
+	}
+}
+
+// commitStatuses returns the combined statuses of the given commits, or
+// nil if they cannot be loaded.
+func (s *Server) commitStatuses(repo *models.Repository, hashes []string) map[string]*models.CombinedStatus {
+	statuses, err := s.statuses.Combined(repo.Name, hashes)
+	if err != nil {
+		log.Printf("Failed to load commit statuses of %s: %v", repo.Name, err)
+		return nil
+	}
+	return statuses
+}
+
+// handleCI routes the CI pages of a repository.
+//
+// Routes:
+//   - /ci/<repo>: The most recent jobs.
+//   - /ci/<repo>/<number>: A job with its steps and logs.
+//   - /ci/<repo>/<number>/events: The job's progress as server-sent events.
+//   - /ci/<repo>/<number>/log/<step>: The raw log of a step.
+func (s *Server) handleCI(w http.ResponseWriter, r *http.Request) {
+	parts := repoPathParts(r.URL.Path, 1)
+	if len(parts) < 2 {
+		models.HandleError(w, r, models.NewBadRequestError("Invalid repository path"))
+		return
+	}
+
+	repo, ok := s.Repository(parts[1])
+	if !ok {
+		models.HandleError(w, r, models.NewNotFoundError("Repository not found").WithDetail(fmt.Sprintf("Repository: %s", parts[1])))
+		return
+	}
+
+	if len(parts) == 2 {
+		s.handleCIJobList(w, r, repo)
+		return
+	}
+
+	number, err := strconv.Atoi(parts[2])
+	if err != nil {
+		models.HandleError(w, r, models.NewNotFoundError("Job not found"))
+		return
+	}
+
+	job, err := s.ciJobs.GetJob(repo.Name, number)
+	if err != nil {
+		models.HandleError(w, r, models.NewNotFoundError("Job not found").WithError(err))
+		return
+	}
+
+	switch {
+	case len(parts) == 3:
+		data := map[string]interface{}{
+			"Repo":    repo,
+			"Job":     job,
+			"Enabled": s.ciRunner != nil,
+		}
+		if err := s.tmpl.ExecuteTemplate(w, "ci_job.html", s.addCommonData(r, data)); err != nil {
+			models.HandleError(w, r, models.NewInternalError("Failed to render template").WithError(err))
+		}
+	case len(parts) == 4 && parts[3] == "events":
+		s.handleCIEvents(w, r, job)
+	case len(parts) == 5 && parts[3] == "log":
+		s.handleCILog(w, r, job, parts[4])
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// handleCIJobList lists the most recent jobs of a repository.
+func (s *Server) handleCIJobList(w http.ResponseWriter, r *http.Request, repo *models.Repository) {
+	jobs, err := s.ciJobs.ListJobs(repo.Name, ciJobListLimit)
+	if err != nil {
+		models.HandleError(w, r, models.NewInternalError("Failed to list jobs").WithError(err))
+		return
+	}
+
+	data := map[string]interface{}{
+		"Repo":         repo,
+		"Jobs":         jobs,
+		"Enabled":      s.ciRunner != nil,
+		"PipelinePath": services.PipelinePath,
+	}
+
+	if err := s.tmpl.ExecuteTemplate(w, "ci.html", s.addCommonData(r, data)); err != nil {
+		models.HandleError(w, r, models.NewInternalError("Failed to render template").WithError(err))
+	}
+}
+
+// handleCILog serves the raw log of one step.
+func (s *Server) handleCILog(w http.ResponseWriter, r *http.Request, job *models.CIJob, stepParam string) {
+	step, err := strconv.Atoi(stepParam)
+	if err != nil || step < 0 || step >= len(job.Steps) || s.ciRunner == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	http.ServeFile(w, r, s.ciRunner.LogPath(job.ID, step))
+}
+
+// ciLogEvent carries new output of a step.
+type ciLogEvent struct {
+	Step int    `json:"step"`
+	Text string `json:"text"`
+}
+
+// ciStepEvent carries the state of a step.
+type ciStepEvent struct {
+	Step     int    `json:"step"`
+	Status   string `json:"status"`
+	ExitCode int    `json:"exit_code"`
+}
+
+// ciJobEvent carries the state of the job.
+type ciJobEvent struct {
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+	Duration string `json:"duration,omitempty"`
+}
+
+// handleCIEvents streams a job's logs and state changes as server-sent
+// events until the job has finished and all of its output was sent.
+//
+// Events:
+//   - log: New output of a step (ciLogEvent).
+//   - step: A step changed state (ciStepEvent).
+//   - job: The job changed state (ciJobEvent).
+//   - done: The job finished; the stream ends.
+func (s *Server) handleCIEvents(w http.ResponseWriter, r *http.Request, job *models.CIJob) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+
+	send := func(event string, payload interface{}) {
+		data, _ := json.Marshal(payload)
+		fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+	}
+
+	offsets := make([]int64, len(job.Steps))
+	stepStates := make([]string, len(job.Steps))
+	jobState := ""
+
+	ticker := time.NewTicker(ciPollInterval)
+	defer ticker.Stop()
+
+	for {
+		finished := job.Finished()
+
+		for i, step := range job.Steps {
+			if s.ciRunner != nil {
+				path := s.ciRunner.LogPath(job.ID, i)
+				for text := readLogFrom(path, &offsets[i]); text != ""; text = readLogFrom(path, &offsets[i]) {
+					send("log", ciLogEvent{Step: i, Text: text})
+				}
+			}
+			if step.Status != stepStates[i] {
+				stepStates[i] = step.Status
+				send("step", ciStepEvent{Step: i, Status: step.Status, ExitCode: step.ExitCode})
+			}
+		}
+		if job.Status != jobState {
+			jobState = job.Status
+			send("job", ciJobEvent{Status: job.Status, Error: job.Error, Duration: job.Duration().String()})
+		}
+		flusher.Flush()
+
+		if finished {
+			send("done", ciJobEvent{Status: job.Status})
+			flusher.Flush()
+			return
+		}
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-s.streamsDone:
+			return
+		case <-ticker.C:
+		}
+
+		latest, err := s.ciJobs.GetJobByID(job.ID)
+		if err != nil {
+			return
+		}
+		job = latest
+	}
+}
+
+// readLogFrom returns the part of a log file after *offset and advances
+// the offset. Missing files read as empty.
+func readLogFrom(path string, offset *int64) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	if _, err := f.Seek(*offset, io.SeekStart); err != nil {
+		return ""
+	}
+	data, err := io.ReadAll(io.LimitReader(f, 1<<20))
+	if err != nil {
+		return ""
+	}
+	*offset += int64(len(data))
+	return string(data)
+}
@@ -2,14 +2,22 @@ package handlers
 
 import (
 	"SimpleGit/models"
+	"SimpleGit/utils"
+	"html/template"
+	"log"
 	"net/http"
 	"path/filepath"
 	"strings"
 	"time"
 
 	"github.com/go-git/go-git/v5/plumbing"
+	fdiff "github.com/go-git/go-git/v5/plumbing/format/diff"
+	"github.com/go-git/go-git/v5/plumbing/object"
 )
 
+// diffContextLines is the number of unchanged lines shown around changes.
+const diffContextLines = 3
+
 type CommitInfo struct {
 	Hash      string    `json:"hash"`
 	Author    string    `json:"author"`
@@ -29,11 +37,12 @@ type Diff struct {
 }
 
 type PatchInfo struct {
-	Content            string `json:"content"`
-	HighlightedContent string `json:"highlighted_content"`
-	Type               string `json:"type"`
-	OldNum             int    `json:"old_num"`
-	NewNum             int    `json:"new_num"`
+	Content            string                 `json:"content"`
+	HighlightedContent string                 `json:"highlighted_content"`
+	Type               string                 `json:"type"`
+	OldNum             int                    `json:"old_num"`
+	NewNum             int                    `json:"new_num"`
+	Threads            []*models.ReviewThread `json:"threads,omitempty"`
 }
 
 /*
@@ -59,7 +68,7 @@ func prettyPrintDiff(diff Diff) {
 */
 
 func (s *Server) handleViewCommit(w http.ResponseWriter, r *http.Request) {
-	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	parts := repoPathParts(r.URL.Path, 1)
 	if len(parts) < 3 {
 		models.HandleError(w, r, models.NewBadRequestError("Invalid commit path"))
 		return
@@ -68,7 +77,7 @@ func (s *Server) handleViewCommit(w http.ResponseWriter, r *http.Request) {
 	repoName := parts[1]
 	commitHash := parts[2]
 
-	repo, ok := s.Repos[repoName]
+	repo, ok := s.Repository(repoName)
 	if !ok {
 		models.HandleError(w, r, models.NewNotFoundError("Repository not found"))
 		return
@@ -87,175 +96,200 @@ func (s *Server) handleViewCommit(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	parentCommit, err := commit.Parent(0)
+	currentTree, err := commit.Tree()
 	if err != nil {
-		models.HandleError(w, r, models.NewGitError("Failed to get parent commit", err))
+		models.HandleError(w, r, models.NewGitError("Failed to get current tree", err))
 		return
 	}
 
-	var diffs []Diff
-	if parentCommit != nil {
-		//Get the trees for comparison
-		parentTree, err := parentCommit.Tree()
+	// Root commits are compared against an empty tree
+	var parentTree *object.Tree
+	if commit.NumParents() > 0 {
+		parentCommit, err := commit.Parent(0)
 		if err != nil {
-			models.HandleError(w, r, models.NewGitError("Failed to get parent tree", err))
+			models.HandleError(w, r, models.NewGitError("Failed to get parent commit", err))
 			return
 		}
 
-		currentTree, err := commit.Tree()
+		parentTree, err = parentCommit.Tree()
 		if err != nil {
-			models.HandleError(w, r, models.NewGitError("Failed to get current tree", err))
+			models.HandleError(w, r, models.NewGitError("Failed to get parent tree", err))
 			return
 		}
+	}
+
+	diffs, err := s.treeDiffs(parentTree, currentTree)
+	if err != nil {
+		models.HandleError(w, r, models.NewGitError("Failed to get diff", err))
+		return
+	}
+
+	review := &ReviewContext{Repo: repoName, Commit: commit.Hash.String()}
+	if _, ok := getUserFromContext(r); ok {
+		review.CanComment = true
+	}
+
+	threads, err := s.reviews.ListForCommit(repoName, commit.Hash.String())
+	if err != nil {
+		log.Printf("Review: failed to list threads of %s@%s: %v", repoName, commit.Hash, err)
+	}
+	parentHash := ""
+	if commit.NumParents() > 0 {
+		parentHash = commit.ParentHashes[0].String()
+	}
+	review.Unplaced = attachThreads(diffs, threads, parentHash, commit.Hash.String())
+
+	statuses, err := s.statuses.List(repoName, commit.Hash.String())
+	if err != nil {
+		log.Printf("Failed to load commit statuses of %s@%s: %v", repoName, commit.Hash, err)
+	}
+
+	data := map[string]interface{}{
+		"Repo":     repo,
+		"Statuses": statuses,
+		"Review":   review,
+		"Commit": CommitInfo{
+			Hash:      commit.Hash.String(),
+			Author:    commit.Author.Name,
+			Email:     commit.Author.Email,
+			Message:   commit.Message,
+			Timestamp: commit.Author.When,
+		},
+		"Diffs":   diffs,
+		"Message": utils.LinkIssueReferences(commit.Message, s.issueLinker(repo)),
+	}
+
+	if err := s.tmpl.ExecuteTemplate(w, "commit.html", s.addCommonData(r, data)); err != nil {
+		models.HandleError(w, r, models.NewInternalError("Failed to render template").WithError(err))
+		return
+	}
+}
+
+// treeDiffs builds the rendered diff of every file that changed between
+// two trees. A nil oldTree shows every file of newTree as added.
+//
+// Parameters:
+//   - oldTree: The tree before the change, or nil.
+//   - newTree: The tree after the change.
+func (s *Server) treeDiffs(oldTree, newTree *object.Tree) ([]Diff, error) {
+	changes, err := object.DiffTree(oldTree, newTree)
+	if err != nil {
+		return nil, err
+	}
+
+	// Pre-allocate the diffs slice
+	diffs := make([]Diff, 0, len(changes))
 
-		changes, err := currentTree.Diff(parentTree)
+	for _, change := range changes {
+		from, to := change.From, change.To
+		diff := Diff{
+			Path:      to.Name,
+			OldPath:   from.Name,
+			IsDeleted: to.Name == "",
+			IsNew:     from.Name == "",
+		}
+		if diff.IsDeleted {
+			diff.Path = from.Name
+		}
+
+		patch, err := change.Patch()
 		if err != nil {
-			models.HandleError(w, r, models.NewGitError("Failed to get diff", err))
-			return
+			continue
 		}
 
-		// Pre-allocate the diffs slice
-		diffs = make([]Diff, 0, len(changes))
+		// Process file stats
+		for _, fileStat := range patch.Stats() {
+			diff.Additions += fileStat.Addition
+			diff.Deletions += fileStat.Deletion
+		}
 
-		for _, change := range changes {
-			from, to := change.From, change.To
-			diff := Diff{
-				Path:      to.Name,
-				OldPath:   from.Name,
-				IsDeleted: to.Name == "",
-				IsNew:     from.Name == "",
-			}
+		// Pre-allocate patches slice
+		diff.Patches = make([]PatchInfo, 0, len(patch.FilePatches())*10) // rough estimate
 
-			patch, err := change.Patch()
-			if err != nil {
-				continue
-			}
+		ext := filepath.Ext(diff.Path)
+		if ext != "" {
+			ext = ext[1:] // Remove the leading dot
+		}
+
+		for _, p := range patch.FilePatches() {
+			oldLineNum := 0
+			newLineNum := 0
 
-			// Process file stats
-			// NOTE: go-git's diff shows changes from current -> parent
-			// but we want to show parent -> current, so we swap Addition/Deletion
-			for _, fileStat := range patch.Stats() {
-				diff.Additions += fileStat.Deletion // Deletion in parent means Addition in current
-				diff.Deletions += fileStat.Addition // Addition in parent means Deletion in current
+			emit := func(line string, op fdiff.Operation) {
+				patchInfo := PatchInfo{
+					Content:            line,
+					HighlightedContent: template.HTMLEscapeString(line),
+				}
+
+				// Highlight the content, falling back to the escaped line
+				if result, err := s.tsService.Highlight(line, ext, diff.Path); err == nil {
+					patchInfo.HighlightedContent = result.Highlighted
+				}
+
+				switch op {
+				case fdiff.Equal:
+					oldLineNum++
+					newLineNum++
+					patchInfo.Type = "context"
+					patchInfo.OldNum = oldLineNum
+					patchInfo.NewNum = newLineNum
+				case fdiff.Add:
+					newLineNum++
+					patchInfo.Type = "addition"
+					patchInfo.NewNum = newLineNum
+				case fdiff.Delete:
+					oldLineNum++
+					patchInfo.Type = "deletion"
+					patchInfo.OldNum = oldLineNum
+				}
+
+				diff.Patches = append(diff.Patches, patchInfo)
 			}
 
-			// Pre-allocate patches slice
-			diff.Patches = make([]PatchInfo, 0, len(patch.FilePatches())*10) // rough estimate
-
-			for _, p := range patch.FilePatches() {
-				oldLineNum := 0
-				newLineNum := 0
-
-				// Track the last printed line numbers to add spacing between chunks
-				lastOldNum := 0
-				lastNewNum := 0
-
-				for _, chunk := range p.Chunks() {
-					lines := strings.Split(strings.TrimRight(chunk.Content(), "\n"), "\n")
-
-					// For context chunks, only show 3 lines before and after changes
-					if chunk.Type() == 0 { // Equal/Context
-						if lastOldNum > 0 { // After a change
-							// Show only 3 lines after a change
-							if len(lines) > 3 {
-								lines = lines[:3]
-							}
-						} else { // Before a change
-							// Show only 3 lines before a change
-							if len(lines) > 3 {
-								oldLineNum += len(lines) - 3
-								newLineNum += len(lines) - 3
-								lines = lines[len(lines)-3:]
-							}
-						}
-					}
+			chunks := p.Chunks()
+			for i, chunk := range chunks {
+				lines := strings.Split(strings.TrimSuffix(chunk.Content(), "\n"), "\n")
 
-					// If there's a gap between chunks, add a separator line
-					if (lastOldNum > 0 && oldLineNum > lastOldNum+1) ||
-						(lastNewNum > 0 && newLineNum > lastNewNum+1) {
-						diff.Patches = append(diff.Patches, PatchInfo{
-							Content: "...",
-							Type:    "separator",
-							OldNum:  0,
-							NewNum:  0,
-						})
+				if chunk.Type() != fdiff.Equal {
+					for _, line := range lines {
+						emit(line, chunk.Type())
 					}
+					continue
+				}
 
-					// Process each line in the chunk
+				// Keep a few context lines next to each change and collapse the rest
+				head, tail := 0, 0
+				if i > 0 {
+					head = diffContextLines
+				}
+				if i < len(chunks)-1 {
+					tail = diffContextLines
+				}
+				if head+tail >= len(lines) {
 					for _, line := range lines {
-						patchInfo := PatchInfo{
-							Content: line,
-						}
-
-						if line == "..." {
-							patchInfo.Type = "separator"
-							patchInfo.Content = line
-							patchInfo.HighlightedContent = line
-						} else {
-							// Get file extension for language detection
-							ext := filepath.Ext(diff.Path)
-							if ext != "" {
-								ext = ext[1:] // Remove the leading dot
-							}
-
-							// Highlight the content
-							result, err := s.tsService.Highlight(line, ext, diff.Path)
-							if err != nil {
-								// On error, fall back to unhighlighted content
-								patchInfo.HighlightedContent = line
-							} else {
-								patchInfo.HighlightedContent = result.Highlighted
-							}
-						}
-
-						// Set line numbers and type based on chunk type
-						// NOTE: go-git's chunks are from the perspective of going from current -> parent
-						// but we want to show parent -> current, so we swap the meaning of Add/Delete
-						switch chunk.Type() {
-						case 0: // Equal - no change
-							oldLineNum++
-							newLineNum++
-							patchInfo.Type = "context"
-							patchInfo.OldNum = oldLineNum
-							patchInfo.NewNum = newLineNum
-						case 1: // Add in parent means it was deleted in current
-							newLineNum++
-							patchInfo.Type = "deletion"
-							patchInfo.OldNum = 0
-							patchInfo.NewNum = newLineNum
-						case 2: // Delete in parent means it was added in current
-							oldLineNum++
-							patchInfo.Type = "addition"
-							patchInfo.OldNum = oldLineNum
-							patchInfo.NewNum = 0
-						}
-
-						diff.Patches = append(diff.Patches, patchInfo)
+						emit(line, fdiff.Equal)
 					}
+					continue
+				}
 
-					lastOldNum = oldLineNum
-					lastNewNum = newLineNum
+				for _, line := range lines[:head] {
+					emit(line, fdiff.Equal)
+				}
+				skipped := len(lines) - head - tail
+				oldLineNum += skipped
+				newLineNum += skipped
+				diff.Patches = append(diff.Patches, PatchInfo{
+					Content: "...",
+					Type:    "separator",
+				})
+				for _, line := range lines[len(lines)-tail:] {
+					emit(line, fdiff.Equal)
 				}
 			}
-
-			diffs = append(diffs, diff)
 		}
-	}
 
-	data := map[string]interface{}{
-		"Repo": repo,
-		"Commit": CommitInfo{
-			Hash:      commit.Hash.String(),
-			Author:    commit.Author.Name,
-			Email:     commit.Author.Email,
-			Message:   commit.Message,
-			Timestamp: commit.Author.When,
-		},
-		"Diffs": diffs,
+		diffs = append(diffs, diff)
 	}
 
-	if err := s.tmpl.ExecuteTemplate(w, "commit.html", s.addCommonData(r, data)); err != nil {
-		models.HandleError(w, r, models.NewInternalError("Failed to render template").WithError(err))
-		return
-	}
+	return diffs, nil
 }
@@ -0,0 +1,354 @@
+//handlers/edit.go
+
+package handlers
+
+import (
+	"SimpleGit/config"
+	"SimpleGit/models"
+	"SimpleGit/utils"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+// maxUploadSize bounds the request body of a file upload.
+const maxUploadSize = 32 << 20
+
+// editTarget is the repository, branch and path an edit form works on.
+//
+// Parameters:
+//   - Branch: The branch the change starts from.
+//   - Parent: The tip of Branch when the form was loaded, empty for an
+//     empty repository. It is posted back to detect concurrent changes.
+//   - Path: The file (edit) or directory (new, upload) in the repository.
+type editTarget struct {
+	Repo   *models.Repository
+	User   *models.User
+	Branch string
+	Parent string
+	Path   string
+}
+
+// loadEditTarget parses /<action>/<repo>/<path> and the branch of the
+// request, or writes an error. Editing requires a signed in user with write
+// access to the repository.
+func (s *Server) loadEditTarget(w http.ResponseWriter, r *http.Request) (*editTarget, bool) {
+	user, ok := getUserFromContext(r)
+	if !ok {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return nil, false
+	}
+
+	parts := repoPathParts(r.URL.Path, 1)
+	if len(parts) < 2 {
+		models.HandleError(w, r, models.NewBadRequestError("Invalid repository path"))
+		return nil, false
+	}
+
+	repo, ok := s.Repository(parts[1])
+	if !ok {
+		models.HandleError(w, r, models.NewNotFoundError("Repository not found").WithDetail(fmt.Sprintf("Repository: %s", parts[1])))
+		return nil, false
+	}
+
+	if !s.requirePermission(w, r, repo, models.PermissionWrite) {
+		return nil, false
+	}
+
+	target := &editTarget{Repo: repo, User: user, Path: strings.Join(parts[2:], "/")}
+
+	target.Branch = r.FormValue("branch")
+	if target.Branch == "" {
+		branch, err := repo.DefaultBranch()
+		if err != nil {
+			models.HandleError(w, r, models.NewGitError("Failed to get default branch", err))
+			return nil, false
+		}
+		if branch == "" {
+			// The first commit of an empty repository goes to HEAD's branch
+			if branch, err = repo.HeadBranch(); err != nil {
+				branch = "main"
+			}
+		}
+		target.Branch = branch
+	}
+
+	if r.Method == http.MethodPost {
+		target.Parent = r.FormValue("parent")
+		return target, true
+	}
+
+	if tip, err := repo.ResolveBranch(target.Branch); err == nil {
+		target.Parent = tip.String()
+	} else if err != plumbing.ErrReferenceNotFound {
+		models.HandleError(w, r, models.NewGitError("Failed to resolve branch", err))
+		return nil, false
+	} else if branches, _ := repo.GetBranches(); len(branches) > 0 {
+		models.HandleError(w, r, models.NewNotFoundError("Branch not found").WithDetail(fmt.Sprintf("Branch: %s", target.Branch)))
+		return nil, false
+	}
+
+	return target, true
+}
+
+// commitEdit commits files from a submitted edit form and redirects to
+// redirectPath on the branch the commit landed on.
+//
+// Form values:
+//   - message: The commit message, defaultMessage if empty.
+//   - target: "new" to commit to the branch named new_branch.
+func (s *Server) commitEdit(w http.ResponseWriter, r *http.Request, target *editTarget, defaultMessage string, files []models.FileChange, redirectPath string) {
+	message := strings.TrimSpace(strings.ReplaceAll(r.FormValue("message"), "\r\n", "\n"))
+	if message == "" {
+		message = defaultMessage
+	}
+
+	newBranch := ""
+	if r.FormValue("target") == "new" {
+		newBranch = strings.TrimSpace(r.FormValue("new_branch"))
+		if !models.ValidBranchName(newBranch) {
+			models.HandleError(w, r, models.NewBadRequestError("Invalid branch name").WithDetail(fmt.Sprintf("Branch: %s", newBranch)).ShowInProduction())
+			return
+		}
+	}
+
+	hash, err := target.Repo.CommitFiles(models.CommitFilesOptions{
+		Branch:    target.Branch,
+		Parent:    target.Parent,
+		NewBranch: newBranch,
+		Message:   message,
+		Author:    models.MergeSignature{Name: target.User.Username, Email: target.User.Email},
+		Files:     files,
+	})
+	switch {
+	case err == nil:
+	case errors.Is(err, models.ErrBranchMoved):
+		models.HandleError(w, r, models.NewConflictError(fmt.Sprintf("%s has changed since you started editing. Reload the page and make your change again, or commit it to a new branch.", target.Branch)).ShowInProduction())
+		return
+	case errors.Is(err, models.ErrBranchExists):
+		models.HandleError(w, r, models.NewConflictError(fmt.Sprintf("Branch %s already exists", newBranch)).ShowInProduction())
+		return
+	case errors.Is(err, models.ErrFileExists):
+		models.HandleError(w, r, models.NewConflictError("A file with that name already exists").ShowInProduction())
+		return
+	case errors.Is(err, models.ErrNoChanges):
+		models.HandleError(w, r, models.NewBadRequestError("There are no changes to commit").ShowInProduction())
+		return
+	case errors.Is(err, models.ErrInvalidChange):
+		models.HandleError(w, r, models.NewBadRequestError(err.Error()).ShowInProduction())
+		return
+	default:
+		models.HandleError(w, r, models.NewGitError("Failed to commit", err))
+		return
+	}
+
+	branch := target.Branch
+	old := plumbing.NewHash(target.Parent)
+	if newBranch != "" {
+		branch = newBranch
+		old = plumbing.ZeroHash
+	}
+	s.HandlePush(target.Repo.Path, []models.RefUpdate{{
+		Name: plumbing.NewBranchReferenceName(branch),
+		Old:  old,
+		New:  hash,
+	}})
+
+	http.Redirect(w, r, fmt.Sprintf("%s?branch=%s", redirectPath, url.QueryEscape(branch)), http.StatusSeeOther)
+}
+
+// renderEditForm renders the edit, new file or upload form.
+func (s *Server) renderEditForm(w http.ResponseWriter, r *http.Request, target *editTarget, data map[string]interface{}) {
+	data["Repo"] = target.Repo
+	data["Branch"] = target.Branch
+	data["Parent"] = target.Parent
+	data["Path"] = target.Path
+
+	if err := s.tmpl.ExecuteTemplate(w, "edit.html", s.addCommonData(r, data)); err != nil {
+		models.HandleError(w, r, models.NewInternalError("Failed to render template").WithError(err))
+	}
+}
+
+// normalizeNewlines converts the CRLF line endings browsers submit to LF
+// unless the original content used CRLF.
+func normalizeNewlines(content string, original []byte) []byte {
+	if bytes.Contains(original, []byte("\r\n")) {
+		return []byte(content)
+	}
+	return []byte(strings.ReplaceAll(content, "\r\n", "\n"))
+}
+
+// handleEditFile shows the editor for a file and commits the change.
+//
+// Routes:
+//   - GET /edit/<repo>/<path>?branch=: The editor.
+//   - POST /edit/<repo>/<path>: Commit the new content. Changing the file
+//     name in the form moves the file.
+func (s *Server) handleEditFile(w http.ResponseWriter, r *http.Request) {
+	target, ok := s.loadEditTarget(w, r)
+	if !ok {
+		return
+	}
+	if target.Path == "" {
+		models.HandleError(w, r, models.NewBadRequestError("Invalid file path"))
+		return
+	}
+
+	// The editor shows the file as it was at the commit the form was loaded
+	// for, so a conflict is reported instead of editing newer content
+	rev := target.Parent
+	if rev == "" {
+		rev = target.Branch
+	}
+	commit, err := target.Repo.ResolveCommit(rev)
+	if err != nil {
+		models.HandleError(w, r, models.NewNotFoundError("Branch not found").WithDetail(fmt.Sprintf("Branch: %s", target.Branch)))
+		return
+	}
+	file, err := commit.File(target.Path)
+	if err != nil {
+		models.HandleError(w, r, models.NewNotFoundError("File not found").WithDetail(fmt.Sprintf("File: %s", target.Path)))
+		return
+	}
+	if file.Size > config.GlobalConfig.MaxFileSize {
+		models.HandleError(w, r, models.NewBadRequestError("This file is too large to edit in the browser").ShowInProduction())
+		return
+	}
+	contents, err := file.Contents()
+	if err != nil {
+		models.HandleError(w, r, models.NewGitError("Failed to read file", err))
+		return
+	}
+	if utils.IsBinaryFile([]byte(contents)) {
+		models.HandleError(w, r, models.NewBadRequestError("Binary files cannot be edited in the browser").ShowInProduction())
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		s.renderEditForm(w, r, target, map[string]interface{}{
+			"Mode":     "edit",
+			"FileName": target.Path,
+			"Content":  contents,
+		})
+		return
+	}
+
+	newPath := strings.Trim(strings.TrimSpace(r.FormValue("filename")), "/")
+	if newPath == "" {
+		newPath = target.Path
+	}
+
+	files := []models.FileChange{{
+		Path:    newPath,
+		Content: normalizeNewlines(r.FormValue("content"), []byte(contents)),
+		Create:  newPath != target.Path,
+	}}
+	message := fmt.Sprintf("Update %s", path.Base(target.Path))
+	if newPath != target.Path {
+		files = append(files, models.FileChange{Path: target.Path, Delete: true})
+		message = fmt.Sprintf("Rename %s to %s", target.Path, newPath)
+	}
+
+	s.commitEdit(w, r, target, message, files, fmt.Sprintf("/file/%s/%s", target.Repo.Name, newPath))
+}
+
+// handleNewFile shows the form to create a file and commits it.
+//
+// Routes:
+//   - GET /new/<repo>/<dir>?branch=: The form.
+//   - POST /new/<repo>/<dir>: Commit the file. The file name may contain
+//     slashes to create directories.
+func (s *Server) handleNewFile(w http.ResponseWriter, r *http.Request) {
+	target, ok := s.loadEditTarget(w, r)
+	if !ok {
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		s.renderEditForm(w, r, target, map[string]interface{}{"Mode": "new"})
+		return
+	}
+
+	name := strings.Trim(strings.TrimSpace(r.FormValue("filename")), "/")
+	if name == "" {
+		models.HandleError(w, r, models.NewBadRequestError("File name is required").ShowInProduction())
+		return
+	}
+	filePath := path.Join(target.Path, name)
+
+	files := []models.FileChange{{
+		Path:    filePath,
+		Content: normalizeNewlines(r.FormValue("content"), nil),
+		Create:  true,
+	}}
+
+	s.commitEdit(w, r, target, fmt.Sprintf("Create %s", filePath), files, fmt.Sprintf("/file/%s/%s", target.Repo.Name, filePath))
+}
+
+// handleUploadFiles shows the upload form and commits the uploaded files
+// to a directory, replacing files with the same name.
+//
+// Routes:
+//   - GET /upload/<repo>/<dir>?branch=: The form.
+//   - POST /upload/<repo>/<dir>: Commit the files (multipart, "files").
+func (s *Server) handleUploadFiles(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPost {
+		r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize)
+		if err := r.ParseMultipartForm(maxUploadSize); err != nil {
+			models.HandleError(w, r, models.NewBadRequestError(fmt.Sprintf("Uploads are limited to %d MB", maxUploadSize>>20)).WithError(err).ShowInProduction())
+			return
+		}
+	}
+
+	target, ok := s.loadEditTarget(w, r)
+	if !ok {
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		s.renderEditForm(w, r, target, map[string]interface{}{
+			"Mode":          "upload",
+			"MaxUploadSize": maxUploadSize,
+		})
+		return
+	}
+
+	headers := r.MultipartForm.File["files"]
+	if len(headers) == 0 {
+		models.HandleError(w, r, models.NewBadRequestError("Choose at least one file to upload").ShowInProduction())
+		return
+	}
+
+	files := make([]models.FileChange, 0, len(headers))
+	for _, header := range headers {
+		f, err := header.Open()
+		if err != nil {
+			models.HandleError(w, r, models.NewInternalError("Failed to read upload").WithError(err))
+			return
+		}
+		content, err := io.ReadAll(f)
+		f.Close()
+		if err != nil {
+			models.HandleError(w, r, models.NewInternalError("Failed to read upload").WithError(err))
+			return
+		}
+
+		// Browsers send the base name, but strip directories just in case
+		name := path.Base(strings.ReplaceAll(header.Filename, "\\", "/"))
+		files = append(files, models.FileChange{Path: path.Join(target.Path, name), Content: content})
+	}
+
+	message := fmt.Sprintf("Add %d files", len(files))
+	if len(files) == 1 {
+		message = fmt.Sprintf("Add %s", path.Base(files[0].Path))
+	}
+
+	s.commitEdit(w, r, target, message, files, fmt.Sprintf("/repo/%s/%s", target.Repo.Name, target.Path))
+}
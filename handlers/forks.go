@@ -0,0 +1,222 @@
+//handlers/forks.go
+
+package handlers
+
+import (
+	"SimpleGit/models"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// handleFork forks a repository for the signed in user and opens the fork.
+//
+// Routes:
+//   - /fork/<owner>/<repo>: Fork a repository (POST) for the owner form
+//     field under the name form field.
+func (s *Server) handleFork(w http.ResponseWriter, r *http.Request) {
+	parts := repoPathParts(r.URL.Path, 1)
+	if len(parts) != 2 {
+		models.HandleError(w, r, models.NewBadRequestError("Invalid repository path"))
+		return
+	}
+
+	repo, ok := s.Repository(parts[1])
+	if !ok {
+		models.HandleError(w, r, models.NewNotFoundError("Repository not found").WithDetail(fmt.Sprintf("Repository: %s", parts[1])))
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	user, ok := getUserFromContext(r)
+	if !ok {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	fork, appErr := s.forkRepository(user, repo, r.FormValue("owner"), strings.TrimSpace(r.FormValue("name")))
+	if appErr != nil {
+		models.HandleError(w, r, appErr)
+		return
+	}
+	http.Redirect(w, r, "/repo/"+fork.Name, http.StatusSeeOther)
+}
+
+// forkRepository creates a fork of repo for owner named name with its
+// branches, tags and LFS objects, after checking that user may create
+// repositories for owner.
+func (s *Server) forkRepository(user *models.User, repo *models.Repository, ownerName, name string) (*models.Repository, *models.AppError) {
+	if !models.ValidRepoName(name) {
+		return nil, models.NewBadRequestError("Repository names may only contain letters, numbers, dots, hyphens and underscores").ShowInProduction()
+	}
+	owner, err := s.lookupOwner(ownerName)
+	if err != nil {
+		return nil, models.NewInternalError("Failed to load owner").WithError(err)
+	}
+	if owner == nil {
+		return nil, models.NewNotFoundError("No user or organization has this name").WithDetail(fmt.Sprintf("Owner: %s", ownerName)).ShowInProduction()
+	}
+	if !s.canCreateIn(user, owner) {
+		return nil, models.NewForbiddenError("You cannot create repositories for this owner").ShowInProduction()
+	}
+	name = owner.Name + "/" + name
+	if _, exists := s.Repository(name); exists {
+		return nil, models.NewConflictError("A repository with this name already exists").ShowInProduction()
+	}
+	if defaultBranch, _ := repo.DefaultBranch(); defaultBranch == "" {
+		return nil, models.NewConflictError("Empty repositories cannot be forked").ShowInProduction()
+	}
+
+	fork, err := repo.Fork(models.RepoDir(s.RepoPath, name))
+	if err != nil {
+		return nil, models.NewGitError("Failed to fork repository", err)
+	}
+	fork.ID = name
+	fork.Name = name
+	fork.Owner = owner.Name
+	fork.CreatedAt = time.Now()
+	s.addRepository(fork)
+	if err := s.redirects.Release(name); err != nil {
+		log.Printf("Failed to release the name %s: %v", name, err)
+	}
+
+	if err := s.LFS.CopyRepo(repo.Name, name); err != nil {
+		log.Printf("Failed to copy LFS objects of %s to %s: %v", repo.Name, name, err)
+	}
+
+	if err := s.xref.Refresh(fork.Name, fork.Path); err != nil {
+		log.Printf("Xref: failed to refresh %s: %v", fork.Name, err)
+	}
+	s.codeSearch.Update(fork.Name, fork.Path)
+	s.commitSearch.Update(fork.Name, fork.Path)
+	return fork, nil
+}
+
+// forksOf returns the forks of a repository sorted by name.
+func (s *Server) forksOf(repo *models.Repository) []*models.Repository {
+	s.reposMu.RLock()
+	defer s.reposMu.RUnlock()
+	return s.forksOfLocked(repo)
+}
+
+// forksOfLocked is forksOf for callers holding reposMu.
+func (s *Server) forksOfLocked(repo *models.Repository) []*models.Repository {
+	var forks []*models.Repository
+	for _, other := range s.repos {
+		if other.Parent == repo.Name {
+			forks = append(forks, other)
+		}
+	}
+	sort.Slice(forks, func(i, j int) bool { return forks[i].Name < forks[j].Name })
+	return forks
+}
+
+// upstreamComparison compares a branch of a fork with a branch of its
+// parent.
+//
+// Parameters:
+//   - Base: The branch of the parent.
+//   - Head: The branch of the fork.
+//   - Ahead: The number of commits only the fork's branch has.
+//   - Behind: The number of commits only the parent's branch has.
+type upstreamComparison struct {
+	Base   string
+	Head   string
+	Ahead  int
+	Behind int
+	*comparison
+}
+
+// compareUpstream compares branch head of fork with branch base of its
+// parent. Empty names stand for the default branches.
+func (s *Server) compareUpstream(fork *models.Repository, base, head string) (*upstreamComparison, *models.AppError) {
+	parent, ok := s.Repository(fork.Parent)
+	if fork.Parent == "" || !ok {
+		return nil, models.NewBadRequestError("This repository is not a fork of an existing repository").ShowInProduction()
+	}
+	if base == "" {
+		base, _ = parent.DefaultBranch()
+	}
+	if head == "" {
+		head, _ = fork.DefaultBranch()
+	}
+
+	baseHash, err := fork.FetchUpstream(parent, base)
+	if err != nil {
+		return nil, branchError(err)
+	}
+	headHash, err := fork.ResolveBranch(head)
+	if err != nil {
+		return nil, models.NewNotFoundError("Branch not found").WithDetail(fmt.Sprintf("Branch: %s", head)).ShowInProduction()
+	}
+
+	ahead, behind, err := fork.AheadBehind(baseHash.String(), headHash.String())
+	if err != nil {
+		return nil, models.NewGitError("Failed to count commits", err)
+	}
+	cmp, err := s.compareCommits(fork, baseHash.String(), headHash.String())
+	if err != nil {
+		return nil, models.NewConflictError(err.Error()).ShowInProduction()
+	}
+
+	return &upstreamComparison{Base: base, Head: head, Ahead: ahead, Behind: behind, comparison: cmp}, nil
+}
+
+// handleCompareUpstream shows how a branch of a fork differs from a branch
+// of the repository it was forked from.
+//
+// Routes:
+//   - /compare/<fork>?base=&head=: Compare head of the fork with base of
+//     its parent, by default both default branches.
+func (s *Server) handleCompareUpstream(w http.ResponseWriter, r *http.Request) {
+	parts := repoPathParts(r.URL.Path, 1)
+	if len(parts) != 2 {
+		models.HandleError(w, r, models.NewBadRequestError("Invalid repository path"))
+		return
+	}
+
+	repo, ok := s.Repository(parts[1])
+	if !ok {
+		models.HandleError(w, r, models.NewNotFoundError("Repository not found").WithDetail(fmt.Sprintf("Repository: %s", parts[1])))
+		return
+	}
+
+	cmp, appErr := s.compareUpstream(repo, r.URL.Query().Get("base"), r.URL.Query().Get("head"))
+	if appErr != nil {
+		models.HandleError(w, r, appErr)
+		return
+	}
+
+	parent, ok := s.Repository(repo.Parent)
+	if !ok {
+		models.HandleError(w, r, models.NewNotFoundError("Repository not found").WithDetail(fmt.Sprintf("Repository: %s", repo.Parent)))
+		return
+	}
+	baseBranches, err := parent.GetBranches()
+	if err != nil {
+		models.HandleError(w, r, models.NewGitError("Failed to get branches", err))
+		return
+	}
+	headBranches, err := repo.GetBranches()
+	if err != nil {
+		models.HandleError(w, r, models.NewGitError("Failed to get branches", err))
+		return
+	}
+
+	data := map[string]interface{}{
+		"Repo":         repo,
+		"BaseBranches": baseBranches,
+		"HeadBranches": headBranches,
+		"Compare":      cmp,
+	}
+
+	if err := s.tmpl.ExecuteTemplate(w, "compare.html", s.addCommonData(r, data)); err != nil {
+		models.HandleError(w, r, models.NewInternalError("Failed to render template").WithError(err))
+	}
+}
@@ -4,20 +4,22 @@ package handlers
 import (
 	"SimpleGit/models"
 	"fmt"
+	"log"
 	"net/http"
+	"os"
 	"os/exec"
 	"strings"
 )
 
 func (s *Server) handleGitRequest(w http.ResponseWriter, r *http.Request) {
-	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	parts := repoPathParts(r.URL.Path, 1)
 	if len(parts) < 2 {
 		http.Error(w, "Invalid repository path", http.StatusBadRequest)
 		return
 	}
 
 	repoName := strings.TrimSuffix(parts[1], ".git")
-	repo, ok := s.Repos[repoName]
+	repo, ok := s.Repository(repoName)
 	if !ok {
 		http.NotFound(w, r)
 		return
@@ -33,12 +35,79 @@ func (s *Server) handleGitRequest(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// gitUser authenticates a smart HTTP or LFS request. Clients send HTTP
+// Basic credentials: a username or email with the password or an access
+// token. LFS clients may instead send the bearer token git-lfs-authenticate
+// handed out over SSH, which only covers one repository and may be
+// read-only. Anonymous requests return a nil user without an error.
+//
+// Returns:
+//   - The user, or nil for anonymous requests.
+//   - Whether the credentials allow writing.
+func (s *Server) gitUser(r *http.Request, repo *models.Repository) (*models.User, bool, error) {
+	if username, password, ok := r.BasicAuth(); ok {
+		if user, err := s.userService.VerifyAccessToken(password); err == nil {
+			return user, true, nil
+		}
+		user, _, err := s.userService.AuthenticateUser(username, password)
+		if err != nil {
+			return nil, false, fmt.Errorf("invalid credentials")
+		}
+		return user, true, nil
+	}
+
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if ok && strings.EqualFold(scheme, "bearer") {
+		return s.userService.VerifyLFSToken(strings.TrimSpace(token), repo.Name)
+	}
+	return nil, false, nil
+}
+
+// authorizeGit checks that a smart HTTP or LFS request may read, or with
+// write push to, a repository and writes an error if not. Reads are public
+// and writes need a user with write permission on the repository. A 401
+// asks git for credentials.
+func (s *Server) authorizeGit(w http.ResponseWriter, r *http.Request, repo *models.Repository, write bool) bool {
+	user, canWrite, err := s.gitUser(r, repo)
+	if err != nil || (write && user == nil) {
+		message := "Authentication required"
+		if err != nil {
+			message = "Invalid credentials"
+		}
+		w.Header().Set("WWW-Authenticate", `Basic realm="SimpleGit"`)
+		writeGitError(w, r, http.StatusUnauthorized, message)
+		return false
+	}
+	if write && !canWrite {
+		writeGitError(w, r, http.StatusForbidden, "Read-only credentials")
+		return false
+	}
+	if write && !s.permission(user, repo).CanWrite() {
+		writeGitError(w, r, http.StatusForbidden, deniedMessage(repo, models.PermissionWrite))
+		return false
+	}
+	return true
+}
+
+// writeGitError writes an error for a git client, in the JSON format LFS
+// clients expect for LFS requests.
+func writeGitError(w http.ResponseWriter, r *http.Request, status int, message string) {
+	if strings.Contains(r.URL.Path, "/info/lfs/") {
+		writeLFSJSON(w, status, lfsErrorResponse{Message: message})
+		return
+	}
+	http.Error(w, message, status)
+}
+
 func (s *Server) handleInfoRefs(w http.ResponseWriter, r *http.Request, repo *models.Repository) {
 	service := r.URL.Query().Get("service")
 	if service != "git-upload-pack" && service != "git-receive-pack" {
 		http.Error(w, "Service not available", http.StatusForbidden)
 		return
 	}
+	if !s.authorizeGit(w, r, repo, service == "git-receive-pack") {
+		return
+	}
 
 	w.Header().Set("Content-Type", fmt.Sprintf("application/x-%s-advertisement", service))
 	w.WriteHeader(http.StatusOK)
@@ -74,11 +143,29 @@ func (s *Server) handleReceivePack(w http.ResponseWriter, r *http.Request, repo
 		return
 	}
 
+	if !s.authorizeGit(w, r, repo, true) {
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/x-git-receive-pack-result")
 
+	before, err := models.SnapshotRefs(repo.Path)
+	if err != nil {
+		log.Printf("Warning: Failed to snapshot refs: %v", err)
+	}
+
 	cmd := exec.Command("git", "receive-pack", "--stateless-rpc", ".")
 	cmd.Dir = repo.Path
+	cmd.Env = append(os.Environ(), s.ReceivePackEnv(repo.Path)...)
 	cmd.Stdin = r.Body
 	cmd.Stdout = w
-	cmd.Run()
+	if err := cmd.Run(); err != nil {
+		return
+	}
+
+	after, err := models.SnapshotRefs(repo.Path)
+	if err != nil {
+		log.Printf("Warning: Failed to snapshot refs: %v", err)
+	}
+	s.HandlePush(repo.Path, models.DiffRefs(before, after))
 }
@@ -29,83 +29,45 @@ import (
 //
 // Parameters:
 //   - RepoPath: The path to the repository directory.
-//   - Repos: A map of repository names to repository objects.
+//   - repos: A map of repository names to repository objects, guarded by
+//     reposMu. Use Repository and Repositories to read it.
 //   - tmpl: The template engine instance.
 //   - userService: The user service instance.
 //   - db: The database instance.
+//   - HighlightCache: The content-addressed cache for highlighted files.
+//   - LFS: The store of Git LFS objects.
 type Server struct {
 	RepoPath       string
-	Repos          map[string]*models.Repository
+	repos          map[string]*models.Repository
+	reposMu        sync.RWMutex
 	tmpl           *template.Template
 	userService    *models.UserService
 	db             *gorm.DB
 	tsService      *services.TSService
-	HighlightCache *HighlightCache
-}
-
-// HighlightCache represents a cache for highlighted code.
-//
-// Parameters:
-//   - RWMutex: A read-write mutex for concurrent access.
-//   - entries: A map of file paths to highlight responses.
-//   - maxSize: The maximum number of entries to store.
-type HighlightCache struct {
-	sync.RWMutex
-	entries map[string]HighlightCacheEntry
-	maxSize int
-}
-
-type HighlightCacheEntry struct {
-	Response  services.HighlightResponse
-	CreatedAt time.Time
-}
-
-func NewHighlightCache(maxSize int) *HighlightCache {
-	return &HighlightCache{
-		entries: make(map[string]HighlightCacheEntry),
-		maxSize: maxSize,
-	}
-}
-
-func (c *HighlightCache) Get(key string) (services.HighlightResponse, bool) {
-	c.RLock()
-	defer c.RUnlock()
-
-	entry, exists := c.entries[key]
-
-	// Optional: Implement cache expiration (e.g., 1 hour)
-	if exists && time.Since(entry.CreatedAt) < time.Hour {
-		return entry.Response, true
-	}
-
-	return services.HighlightResponse{}, false
-}
-
-func (c *HighlightCache) Set(key string, value services.HighlightResponse) {
-	c.Lock()
-	defer c.Unlock()
-
-	// Implement LRU with size limit
-	if len(c.entries) >= c.maxSize {
-		var oldestKey string
-		var oldestTime time.Time
-
-		for k, entry := range c.entries {
-			if oldestKey == "" || entry.CreatedAt.Before(oldestTime) {
-				oldestKey = k
-				oldestTime = entry.CreatedAt
-			}
-		}
-
-		if oldestKey != "" {
-			delete(c.entries, oldestKey)
-		}
-	}
-
-	c.entries[key] = HighlightCacheEntry{
-		Response:  value,
-		CreatedAt: time.Now(),
-	}
+	HighlightCache *services.HighlightCache
+	LFS            services.LFSStore
+	symbolCache    *utils.LRU[string, []utils.Symbol]
+	xref           *services.XrefService
+	codeSearch     *services.CodeSearchService
+	commitSearch   *services.CommitSearchService
+	pullRequests   *models.PullRequestService
+	reviews        *models.ReviewService
+	issues         *models.IssueService
+	ciJobs         *models.CIService
+	statuses       *models.CommitStatusService
+	protections    *models.BranchProtectionService
+	orgs           *models.OrganizationService
+	teams          *models.TeamService
+	permissions    *models.PermissionService
+	redirects      *models.RedirectService
+	deletedRepos   *models.DeletedRepoService
+	ciRunner       *services.CIRunner
+	imports        *models.ImportService
+	importer       *services.Importer
+	ciStepTimeout  time.Duration
+	hooksPath      string
+	streamsDone    chan struct{}
+	endStreams     sync.Once
 }
 
 // NewServer creates a new server instance with the given repository path.
@@ -118,11 +80,28 @@ func NewServer(repoPath string) (*Server, error) {
 	}
 
 	s := &Server{
-		RepoPath:  repoPath,
-		Repos:     make(map[string]*models.Repository),
-		tsService: services.NewTSService(),
+		RepoPath:     repoPath,
+		repos:        make(map[string]*models.Repository),
+		tsService:    services.NewTSService(),
+		symbolCache:  newSymbolCache(),
+		xref:         services.NewXrefService(),
+		codeSearch:   services.NewCodeSearchService(),
+		commitSearch: services.NewCommitSearchService(),
+		hooksPath:    filepath.Join(config.GlobalConfig.DataDir, "hooks"),
+		streamsDone:  make(chan struct{}),
+	}
+
+	// Pushes run the server's hooks to enforce branch protection
+	if err := models.InstallHooks(s.hooksPath); err != nil {
+		return nil, err
 	}
 
+	return s, nil
+}
+
+// LoadTemplates parses the page templates in the templates directory.
+// Only serving pages needs them.
+func (s *Server) LoadTemplates() error {
 	// Create template functions
 	funcMap := template.FuncMap{
 		"formatSize": func(size int64) string {
@@ -134,6 +113,9 @@ func NewServer(repoPath string) (*Server, error) {
 			}
 			return fmt.Sprintf("%.1f MB", float64(size)/(1024*1024))
 		},
+		"percent": func(f float64) string {
+			return fmt.Sprintf("%.0f%%", f*100)
+		},
 		"formatDate": func(t time.Time) string {
 			return t.Format(config.GlobalConfig.DateFormat)
 		},
@@ -169,16 +151,16 @@ func NewServer(repoPath string) (*Server, error) {
 		"safeHTML": func(s string) template.HTML {
 			return template.HTML(s)
 		},
+		"markRanges": markRanges,
 	}
 
 	// Parse templates
 	tmpl, err := template.New("").Funcs(funcMap).ParseGlob("templates/*.html")
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse templates: %w", err)
+		return fmt.Errorf("failed to parse templates: %w", err)
 	}
 	s.tmpl = tmpl
-
-	return s, nil
+	return nil
 }
 
 // addCommonData adds common data to the given map.
@@ -195,9 +177,14 @@ func (s *Server) addCommonData(r *http.Request, data map[string]interface{}) map
 		data = make(map[string]interface{})
 	}
 
-	if user, ok := getUserFromContext(r); ok {
+	user, ok := getUserFromContext(r)
+	if ok {
 		data["User"] = user
 	}
+	if repo, isRepo := data["Repo"].(*models.Repository); isRepo {
+		data["Permission"] = s.permission(user, repo)
+		data["CanAdminister"] = s.canAdministerRepo(user, repo)
+	}
 
 	return data
 }
@@ -214,9 +201,18 @@ func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Archived repositories stay browsable but are not listed
+	all := s.Repositories()
+	repos := make(map[string]*models.Repository, len(all))
+	for name, repo := range all {
+		if !repo.Archived {
+			repos[name] = repo
+		}
+	}
+
 	data := map[string]interface{}{
 		"Title": "Repositories",
-		"Repos": s.Repos,
+		"Repos": repos,
 	}
 
 	s.tmpl.ExecuteTemplate(w, "index.html", s.addCommonData(r, data))
@@ -228,8 +224,9 @@ func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
 //   - w: The HTTP response writer.
 //   - r: The HTTP request.
 func (s *Server) handleListRepos(w http.ResponseWriter, r *http.Request) {
-	repos := make([]*models.Repository, 0, len(s.Repos))
-	for _, repo := range s.Repos {
+	all := s.Repositories()
+	repos := make([]*models.Repository, 0, len(all))
+	for _, repo := range all {
 		repos = append(repos, repo)
 	}
 
@@ -245,14 +242,14 @@ func (s *Server) handleListRepos(w http.ResponseWriter, r *http.Request) {
 //   - w: The HTTP response writer.
 //   - r: The HTTP request.
 func (s *Server) handleRepoView(w http.ResponseWriter, r *http.Request) {
-	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	parts := repoPathParts(r.URL.Path, 1)
 	if len(parts) < 2 {
 		models.HandleError(w, r, models.NewBadRequestError("Invalid repository path"))
 		return
 	}
 
 	repoName := parts[1]
-	repo, ok := s.Repos[repoName]
+	repo, ok := s.Repository(repoName)
 	if !ok {
 		// Try to rescan repositories in case it was just created
 		if err := s.ScanRepositories(); err != nil {
@@ -260,7 +257,7 @@ func (s *Server) handleRepoView(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		repo, ok = s.Repos[repoName]
+		repo, ok = s.Repository(repoName)
 		if !ok {
 			models.HandleError(w, r, models.NewNotFoundError("Repository not found").WithDetail(fmt.Sprintf("Repository: %s", repoName)))
 			return
@@ -287,8 +284,11 @@ func (s *Server) handleRepoView(w http.ResponseWriter, r *http.Request) {
 			"Commits":  []models.Commit{},
 			"IsEmpty":  true,
 		}
+		if imp, err := s.imports.Latest(repo.Name); err == nil && imp.Status != models.ImportSuccess {
+			data["Import"] = imp
+		}
 
-		if err := s.tmpl.ExecuteTemplate(w, "repo.html", data); err != nil {
+		if err := s.tmpl.ExecuteTemplate(w, "repo.html", s.addCommonData(r, data)); err != nil {
 			models.HandleError(w, r, models.NewInternalError("Failed to render template").WithError(err))
 		}
 		return
@@ -296,7 +296,10 @@ func (s *Server) handleRepoView(w http.ResponseWriter, r *http.Request) {
 
 	branch := r.URL.Query().Get("branch")
 	if branch == "" {
-		branch = branches[0]
+		if branch, err = repo.DefaultBranch(); err != nil {
+			models.HandleError(w, r, models.NewGitError("Failed to get default branch", err))
+			return
+		}
 	}
 
 	gitRepo, err := repo.Git()
@@ -325,14 +328,28 @@ func (s *Server) handleRepoView(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	readme, readmeName := s.renderReadme(repo, branch, path, entries)
+
 	data := map[string]interface{}{
-		"Repo":     repo,
-		"Path":     path,
-		"Branches": branches,
-		"Branch":   branch,
-		"Entries":  entries,
-		"Commits":  commits,
-		"IsEmpty":  false,
+		"Repo":       repo,
+		"Path":       path,
+		"Branches":   branches,
+		"Branch":     branch,
+		"Entries":    entries,
+		"Commits":    commits,
+		"IsEmpty":    false,
+		"Readme":     readme,
+		"ReadmeName": readmeName,
+	}
+
+	hashes := make([]string, len(commits))
+	for i, commit := range commits {
+		hashes[i] = commit.Hash
+	}
+	data["Statuses"] = s.commitStatuses(repo, hashes)
+
+	if user, ok := getUserFromContext(r); ok {
+		data["ForkOwners"], _ = s.creatableOwners(user)
 	}
 
 	if err := s.tmpl.ExecuteTemplate(w, "repo.html", s.addCommonData(r, data)); err != nil {
@@ -346,14 +363,14 @@ func (s *Server) handleRepoView(w http.ResponseWriter, r *http.Request) {
 //   - w: The HTTP response writer.
 //   - r: The HTTP request.
 func (s *Server) handleViewFile(w http.ResponseWriter, r *http.Request) {
-	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	parts := repoPathParts(r.URL.Path, 1)
 	if len(parts) < 3 {
 		models.HandleError(w, r, models.NewBadRequestError("Invalid file path"))
 		return
 	}
 
 	repoName := parts[1]
-	repo, ok := s.Repos[repoName]
+	repo, ok := s.Repository(repoName)
 	if !ok {
 		models.HandleError(w, r, models.NewNotFoundError("Repository not found").WithDetail(fmt.Sprintf("Repository: %s", repoName)))
 		return
@@ -363,19 +380,19 @@ func (s *Server) handleViewFile(w http.ResponseWriter, r *http.Request) {
 
 	branch := r.URL.Query().Get("branch")
 	if branch == "" {
-		branches, err := repo.GetBranches()
+		defaultBranch, err := repo.DefaultBranch()
 		if err != nil {
-			models.HandleError(w, r, models.NewGitError("Failed to get branches", err))
+			models.HandleError(w, r, models.NewGitError("Failed to get default branch", err))
 			return
 		}
-		if len(branches) == 0 {
+		if defaultBranch == "" {
 			models.HandleError(w, r, models.NewGitError("No branches found", nil))
 			return
 		}
-		branch = branches[0]
+		branch = defaultBranch
 	}
 
-	content, err := repo.GetFile(path, branch)
+	file, err := repo.GetFileObject(path, branch)
 	if err != nil {
 		if err == plumbing.ErrObjectNotFound {
 			models.HandleError(w, r, models.NewNotFoundError("File not found"))
@@ -385,6 +402,30 @@ func (s *Server) handleViewFile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	fileContents, err := file.Contents()
+	if err != nil {
+		models.HandleError(w, r, models.NewGitError("Failed to read file", err))
+		return
+	}
+	content := []byte(fileContents)
+
+	// Files tracked by Git LFS are shown with the object the pointer names
+	lfsPointer, isLFS := s.resolveLFSPointer(repo, content)
+	if isLFS {
+		if lfsPointer.Size > config.GlobalConfig.MaxFileSize {
+			s.renderLFSFile(w, r, repo, path, branch, lfsPointer)
+			return
+		}
+		if content, err = s.readLFSObject(repo, lfsPointer.Oid); err != nil {
+			models.HandleError(w, r, models.NewInternalError("Failed to read LFS object").WithError(err))
+			return
+		}
+		if utils.IsBinaryFile(content) {
+			s.renderLFSFile(w, r, repo, path, branch, lfsPointer)
+			return
+		}
+	}
+
 	// Check if file is binary
 	if utils.IsBinaryFile(content) {
 		w.Header().Set("Content-Type", "text/html")
@@ -413,62 +454,52 @@ func (s *Server) handleViewFile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Split content into lines
-	//lines := strings.Split(string(content), "\n")
-
 	// Parse symbols from content
-	symbols := utils.ParseSymbols(content)
+	symbols := s.fileSymbols(file.Hash.String(), path, content)
 
 	ext := filepath.Ext(path)
 	if ext != "" {
 		ext = ext[1:] // Remove the leading dot
 	}
 
-	// Generate a cache key
-	cacheKey := fmt.Sprintf("%s-%s-%d", path, ext, len(content))
+	// Cache by blob hash so every version of the file gets its own entry
+	cacheKey := services.HighlightCacheKey(file.Hash.String(), ext)
 
-	// Check cache for highlighted content
+	var lines []string
 	if cachedResult, found := s.HighlightCache.Get(cacheKey); found {
-		data := map[string]interface{}{
-			"Repo":    repo,
-			"Path":    path,
-			"Lines":   strings.Split(cachedResult.Highlighted, "\n"),
-			"Size":    int64(len(content)),
-			"Symbols": utils.ParseSymbols(content),
-			"Branch":  branch,
-		}
-		s.tmpl.ExecuteTemplate(w, "file.html", s.addCommonData(r, data))
-		return
-	}
-
-	result, err := s.tsService.Highlight(string(content), ext, path)
-	if err != nil {
+		lines = strings.Split(cachedResult.Highlighted, "\n")
+	} else if result, err := s.tsService.Highlight(string(content), ext, path); err != nil {
 		// Fallback to simple line splitting if TS service fails
 		log.Printf("TS service error: %v, falling back to basic display", err)
-		lines := strings.Split(string(content), "\n")
-		data := map[string]interface{}{
-			"Repo":    repo,
-			"Path":    path,
-			"Lines":   lines,
-			"Size":    int64(len(content)),
-			"Symbols": utils.ParseSymbols(content), // Use your existing symbol parsing
-			"Branch":  branch,
-		}
-		s.tmpl.ExecuteTemplate(w, "file.html", s.addCommonData(r, data))
-		return
+		lines = strings.Split(template.HTMLEscapeString(string(content)), "\n")
+	} else {
+		s.HighlightCache.Set(cacheKey, *result)
+		lines = strings.Split(result.Highlighted, "\n")
 	}
 
-	s.HighlightCache.Set(cacheKey, *result)
-
-	highlightedLines := strings.Split(result.Highlighted, "\n")
-
 	data := map[string]interface{}{
 		"Repo":    repo,
 		"Path":    path,
-		"Lines":   highlightedLines,
+		"Lines":   lines,
 		"Size":    int64(len(content)),
 		"Symbols": symbols,
 		"Branch":  branch,
+		"LFS":     isLFS,
+	}
+
+	// Identifier links are added once the commit has been indexed
+	if commit, err := repo.ResolveBranch(branch); err == nil {
+		if xref := s.fileXref(repo, branch, commit.String(), path); xref != nil {
+			data["Xref"] = xref
+		}
+	}
+
+	// Markdown files default to the rendered view with a toggle to the source
+	if utils.IsMarkdownFile(path) {
+		data["IsMarkdown"] = true
+		if r.URL.Query().Get("view") != "source" {
+			data["Rendered"] = template.HTML(utils.MarkdownToHTML(content, s.markdownLinks(repo, branch, filepath.Dir(path))))
+		}
 	}
 
 	if err := s.tmpl.ExecuteTemplate(w, "file.html", s.addCommonData(r, data)); err != nil {
@@ -482,14 +513,14 @@ func (s *Server) handleViewFile(w http.ResponseWriter, r *http.Request) {
 //   - w: The HTTP response writer.
 //   - r: The HTTP request.
 func (s *Server) handleRawFile(w http.ResponseWriter, r *http.Request) {
-	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	parts := repoPathParts(r.URL.Path, 1)
 	if len(parts) < 3 {
 		models.HandleError(w, r, models.NewBadRequestError("Invalid file path"))
 		return
 	}
 
 	repoName := parts[1]
-	repo, ok := s.Repos[repoName]
+	repo, ok := s.Repository(repoName)
 	if !ok {
 		models.HandleError(w, r, models.NewNotFoundError("Repository not found").WithDetail(fmt.Sprintf("Repository: %s", repoName)))
 		return
@@ -498,16 +529,16 @@ func (s *Server) handleRawFile(w http.ResponseWriter, r *http.Request) {
 	path := strings.Join(parts[2:], "/")
 	branch := r.URL.Query().Get("branch")
 	if branch == "" {
-		branches, err := repo.GetBranches()
+		defaultBranch, err := repo.DefaultBranch()
 		if err != nil {
-			models.HandleError(w, r, models.NewGitError("Failed to get branches", err))
+			models.HandleError(w, r, models.NewGitError("Failed to get default branch", err))
 			return
 		}
-		if len(branches) == 0 {
+		if defaultBranch == "" {
 			models.HandleError(w, r, models.NewGitError("No branches found", nil))
 			return
 		}
-		branch = branches[0]
+		branch = defaultBranch
 	}
 
 	content, err := repo.GetFile(path, branch)
@@ -527,6 +558,15 @@ func (s *Server) handleRawFile(w http.ResponseWriter, r *http.Request) {
 	}
 	w.Header().Set("Content-Type", contentType)
 
+	// Serve the object of an LFS pointer instead of the pointer
+	if pointer, ok := s.resolveLFSPointer(repo, content); ok {
+		if !strings.HasPrefix(contentType, "image/") && !strings.HasPrefix(contentType, "text/") {
+			w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s", filepath.Base(path)))
+		}
+		s.serveLFSObject(w, r, repo, pointer.Oid)
+		return
+	}
+
 	if utils.IsBinaryFile(content) {
 		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s", filepath.Base(path)))
 	}
@@ -534,9 +574,41 @@ func (s *Server) handleRawFile(w http.ResponseWriter, r *http.Request) {
 	w.Write(content)
 }
 
+// Repository returns the repository with the full name name.
+func (s *Server) Repository(name string) (*models.Repository, bool) {
+	s.reposMu.RLock()
+	defer s.reposMu.RUnlock()
+	repo, ok := s.repos[name]
+	return repo, ok
+}
+
+// Repositories returns a copy of the map of repositories by full name.
+func (s *Server) Repositories() map[string]*models.Repository {
+	s.reposMu.RLock()
+	defer s.reposMu.RUnlock()
+	repos := make(map[string]*models.Repository, len(s.repos))
+	for name, repo := range s.repos {
+		repos[name] = repo
+	}
+	return repos
+}
+
+// addRepository adds a repository created on disk to the map.
+func (s *Server) addRepository(repo *models.Repository) {
+	s.reposMu.Lock()
+	defer s.reposMu.Unlock()
+	s.repos[repo.Name] = repo
+}
+
 // ScanRepositories scans the repository directory and updates the server's repository map.
+// Repositories are stored as <RepoPath>/<owner>/<name>.git. The map stays
+// locked during the scan so that repositories moved or deleted meanwhile
+// are not brought back under their former names.
 func (s *Server) ScanRepositories() error {
-	entries, err := os.ReadDir(s.RepoPath)
+	s.reposMu.Lock()
+	defer s.reposMu.Unlock()
+
+	owners, err := os.ReadDir(s.RepoPath)
 	if err != nil {
 		return fmt.Errorf("failed to read repo directory: %w", err)
 	}
@@ -544,65 +616,86 @@ func (s *Server) ScanRepositories() error {
 	// Create a new map to avoid duplicates
 	repos := make(map[string]*models.Repository)
 
-	for _, entry := range entries {
-		if !entry.IsDir() {
-			continue
-		}
-
-		name := entry.Name()
-		path := filepath.Join(s.RepoPath, name)
-
-		// Check if it's a git repository (has .git directory or is a bare repo)
-		_, errGit := os.Stat(filepath.Join(path, ".git"))
-		_, errBare := os.Stat(filepath.Join(path, "HEAD"))
-
-		if os.IsNotExist(errGit) && os.IsNotExist(errBare) {
+	for _, owner := range owners {
+		if !owner.IsDir() || !models.ValidOwnerName(owner.Name()) {
 			continue
 		}
 
-		// Get repository info
-		info, err := entry.Info()
+		entries, err := os.ReadDir(filepath.Join(s.RepoPath, owner.Name()))
 		if err != nil {
 			continue
 		}
 
-		// Add or update repository
-		if existing, ok := s.Repos[name]; ok {
-			// Update existing repository
-			existing.Path = path
-			existing.Size = info.Size()
-			repos[name] = existing
-		} else {
-			// Create new repository entry
-			repos[name] = &models.Repository{
-				ID:        name,
-				Name:      name,
-				Path:      path,
-				CreatedAt: info.ModTime(),
-				Size:      info.Size(),
+		for _, entry := range entries {
+			if !entry.IsDir() || !strings.HasSuffix(entry.Name(), ".git") {
+				continue
+			}
+
+			name := owner.Name() + "/" + strings.TrimSuffix(entry.Name(), ".git")
+			path := filepath.Join(s.RepoPath, owner.Name(), entry.Name())
+
+			if _, err := os.Stat(filepath.Join(path, "HEAD")); err != nil {
+				continue
+			}
+
+			// Get repository info
+			info, err := entry.Info()
+			if err != nil {
+				continue
+			}
+
+			// Add or update repository
+			if existing, ok := s.repos[name]; ok {
+				// Update existing repository
+				existing.Path = path
+				existing.Size = info.Size()
+				repos[name] = existing
+			} else {
+				// Create new repository entry
+				repo := &models.Repository{
+					ID:        name,
+					Name:      name,
+					Owner:     owner.Name(),
+					Path:      path,
+					CreatedAt: info.ModTime(),
+					Size:      info.Size(),
+				}
+				repo.LoadDescription()
+				repo.LoadParent()
+				repo.LoadArchived()
+				repos[name] = repo
 			}
 		}
 	}
 
 	// Update the server's repository map
-	s.Repos = repos
+	s.repos = repos
 	return nil
 }
 
 func (s *Server) handleRepo(w http.ResponseWriter, r *http.Request) {
-	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	parts := repoPathParts(r.URL.Path, 1)
 	if len(parts) < 2 {
 		models.HandleError(w, r, models.NewBadRequestError("Invalid repository path"))
 		return
 	}
+	if len(parts) == 2 && !strings.Contains(parts[1], "/") {
+		s.handleOwner(w, r, parts[1])
+		return
+	}
 
 	repoName := strings.TrimSuffix(parts[1], ".git")
-	repo, ok := s.Repos[repoName]
+	repo, ok := s.Repository(repoName)
 	if !ok {
 		models.HandleError(w, r, models.NewNotFoundError("Repository not found").WithDetail(fmt.Sprintf("Repository: %s", repoName)))
 		return
 	}
 
+	if strings.Contains(r.URL.Path, "/info/lfs/") {
+		s.handleLFS(w, r, repo)
+		return
+	}
+
 	if strings.HasSuffix(r.URL.Path, "/info/refs") ||
 		strings.HasSuffix(r.URL.Path, "/git-upload-pack") ||
 		strings.HasSuffix(r.URL.Path, "/git-receive-pack") {
@@ -669,6 +762,18 @@ func (s *Server) InitAdminSetup() error {
 // SetDB sets the database instance for the server.
 func (s *Server) SetDB(db *gorm.DB) {
 	s.db = db
+	s.pullRequests = models.NewPullRequestService(db)
+	s.reviews = models.NewReviewService(db)
+	s.issues = models.NewIssueService(db)
+	s.ciJobs = models.NewCIService(db)
+	s.statuses = models.NewCommitStatusService(db)
+	s.protections = models.NewBranchProtectionService(db)
+	s.orgs = models.NewOrganizationService(db)
+	s.teams = models.NewTeamService(db)
+	s.permissions = models.NewPermissionService(db)
+	s.redirects = models.NewRedirectService(db)
+	s.deletedRepos = models.NewDeletedRepoService(db)
+	s.imports = models.NewImportService(db)
 }
 
 // SetUserService sets the user service instance for the server.
@@ -0,0 +1,246 @@
+//handlers/imports.go
+
+package handlers
+
+import (
+	"SimpleGit/models"
+	"SimpleGit/services"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// maxBundleSize bounds the request body of a repository created from an
+// uploaded bundle.
+const maxBundleSize = 2 << 30
+
+// importWorkers is how many imports may run at the same time.
+const importWorkers = 2
+
+// importPollInterval is how often the progress stream checks for changes.
+const importPollInterval = 500 * time.Millisecond
+
+// EnableImports starts the importer. Without it repositories can only be
+// created empty.
+//
+// Parameters:
+//   - uploadDir: The directory uploaded bundles are kept in until imported.
+func (s *Server) EnableImports(uploadDir string) error {
+	importer, err := services.NewImporter(s.imports, uploadDir, importWorkers, s.importDone)
+	if err != nil {
+		return err
+	}
+	s.importer = importer
+	return nil
+}
+
+// importDone indexes a repository once its import succeeded. The
+// repository may have been renamed while it was imported.
+func (s *Server) importDone(imp *models.RepoImport) {
+	latest, err := s.imports.Get(imp.ID)
+	if err != nil {
+		return
+	}
+	if repo, ok := s.Repository(latest.RepoName); ok {
+		s.reindexRepository(repo)
+	}
+}
+
+// importRequest is an import asked for in the form creating a repository.
+type importRequest struct {
+	kind   string
+	source string // The URL to import from, with credentials
+	label  string // What the import record shows as its source
+	bundle string // The uploaded bundle, until the import takes it over
+}
+
+// parseImportRequest reads the import_url field and the bundle file of the
+// form creating a repository. It returns nil if neither is set. Uploaded
+// bundles are checked and stored right away; callers remove them with
+// discard if the repository is not created.
+func (s *Server) parseImportRequest(r *http.Request) (*importRequest, *models.AppError) {
+	if s.importer == nil {
+		return nil, nil
+	}
+
+	rawURL := strings.TrimSpace(r.FormValue("import_url"))
+	file, header, err := r.FormFile("bundle")
+	if err != nil && !errors.Is(err, http.ErrMissingFile) && !errors.Is(err, http.ErrNotMultipart) {
+		return nil, models.NewBadRequestError(fmt.Sprintf("Bundles are limited to %d MB", maxBundleSize>>20)).WithError(err).ShowInProduction()
+	}
+	if file != nil {
+		defer file.Close()
+	}
+
+	switch {
+	case rawURL != "" && file != nil:
+		return nil, models.NewBadRequestError("Import from a URL or from a bundle, not both").ShowInProduction()
+	case rawURL != "":
+		u, err := url.Parse(rawURL)
+		if err != nil || u.Host == "" {
+			return nil, models.NewBadRequestError("The import URL is not valid").ShowInProduction()
+		}
+		switch u.Scheme {
+		case "http", "https", "git", "ssh":
+		default:
+			return nil, models.NewBadRequestError("Imports need an http, https, git or ssh URL").ShowInProduction()
+		}
+		label := *u
+		label.User = nil
+		return &importRequest{kind: models.ImportFromURL, source: rawURL, label: label.String()}, nil
+	case file != nil:
+		path, err := s.storeBundle(file)
+		if err != nil {
+			return nil, models.NewBadRequestError(fmt.Sprintf("The uploaded file cannot be imported: %v", err)).ShowInProduction()
+		}
+		return &importRequest{kind: models.ImportFromBundle, label: header.Filename, bundle: path}, nil
+	}
+	return nil, nil
+}
+
+// storeBundle writes an uploaded bundle to a temporary file next to the
+// bundles waiting to be imported and checks it.
+func (s *Server) storeBundle(file io.Reader) (string, error) {
+	tmp, err := os.CreateTemp(s.importer.UploadDir(), "upload-*.bundle")
+	if err != nil {
+		return "", err
+	}
+	_, err = io.Copy(tmp, file)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = models.VerifyBundle(tmp.Name())
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	return tmp.Name(), nil
+}
+
+// discard removes the uploaded bundle of an import that was not started.
+func (req *importRequest) discard() {
+	if req != nil && req.bundle != "" {
+		os.Remove(req.bundle)
+	}
+}
+
+// startImport records an import into a new, empty repository and queues it.
+func (s *Server) startImport(user *models.User, repo *models.Repository, req *importRequest) *models.AppError {
+	imp := &models.RepoImport{
+		RepoName:  repo.Name,
+		Kind:      req.kind,
+		Source:    req.label,
+		CreatedBy: user.Username,
+	}
+	if err := s.imports.Create(imp); err != nil {
+		req.discard()
+		return models.NewInternalError("Failed to start the import").WithError(err)
+	}
+	if req.bundle != "" {
+		if err := os.Rename(req.bundle, s.importer.BundlePath(imp.ID)); err != nil {
+			req.discard()
+			s.imports.Finish(imp, models.ImportFailure, "The uploaded bundle could not be stored")
+			return models.NewInternalError("Failed to store the bundle").WithError(err)
+		}
+	}
+	s.importer.Enqueue(imp, repo, req.source)
+	return nil
+}
+
+// handleAdminRepoImport shows the latest import of a repository.
+//
+// Routes:
+//   - /admin/repos/<repo>/import: The import and its progress.
+//   - /admin/repos/<repo>/import/events: The progress as server-sent events.
+func (s *Server) handleAdminRepoImport(w http.ResponseWriter, r *http.Request, repo *models.Repository, events bool) {
+	imp, err := s.imports.Latest(repo.Name)
+	if err != nil {
+		models.HandleError(w, r, models.NewNotFoundError("This repository was not imported").WithError(err).ShowInProduction())
+		return
+	}
+
+	if events {
+		s.handleImportEvents(w, r, imp)
+		return
+	}
+
+	data := map[string]interface{}{
+		"AdminPage": "repos",
+		"Repo":      repo,
+		"Import":    imp,
+	}
+	if err := s.tmpl.ExecuteTemplate(w, "admin-repo-import.html", s.addCommonData(r, data)); err != nil {
+		models.HandleError(w, r, models.NewInternalError("Failed to render template").WithError(err))
+	}
+}
+
+// importEvent carries the state of an import.
+type importEvent struct {
+	Status   string `json:"status"`
+	Progress string `json:"progress,omitempty"`
+	Percent  int    `json:"percent"`
+	Error    string `json:"error,omitempty"`
+}
+
+// handleImportEvents streams the state of an import as server-sent events
+// until it has finished.
+//
+// Events:
+//   - import: The import changed state or made progress (importEvent).
+//   - done: The import finished; the stream ends.
+func (s *Server) handleImportEvents(w http.ResponseWriter, r *http.Request, imp *models.RepoImport) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+
+	send := func(event string, payload interface{}) {
+		data, _ := json.Marshal(payload)
+		fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+	}
+
+	ticker := time.NewTicker(importPollInterval)
+	defer ticker.Stop()
+
+	var last importEvent
+	for {
+		state := importEvent{Status: imp.Status, Progress: imp.Progress, Percent: imp.Percent, Error: imp.Error}
+		if state != last {
+			last = state
+			send("import", state)
+		}
+		if imp.Finished() {
+			send("done", importEvent{Status: imp.Status})
+			flusher.Flush()
+			return
+		}
+		flusher.Flush()
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-s.streamsDone:
+			return
+		case <-ticker.C:
+		}
+
+		latest, err := s.imports.Get(imp.ID)
+		if err != nil {
+			return
+		}
+		imp = latest
+	}
+}
@@ -0,0 +1,381 @@
+//handlers/issues.go
+
+package handlers
+
+import (
+	"SimpleGit/models"
+	"SimpleGit/utils"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// closingCommitLimit bounds how many pushed commits are scanned for
+// closing keywords.
+const closingCommitLimit = 1000
+
+// issueCommentView is a comment with its rendered body.
+type issueCommentView struct {
+	models.IssueComment
+	HTML template.HTML
+}
+
+// issueLinker returns a resolver for #123 references in a repository. It
+// only links issues that exist and remembers the answers.
+func (s *Server) issueLinker(repo *models.Repository) func(number int) string {
+	known := make(map[int]bool)
+	return func(number int) string {
+		exists, ok := known[number]
+		if !ok {
+			found, err := s.issues.Numbers(repo.Name, []int{number})
+			exists = err == nil && found[number]
+			known[number] = exists
+		}
+		if !exists {
+			return ""
+		}
+		return fmt.Sprintf("/issues/%s/%d", repo.Name, number)
+	}
+}
+
+// renderIssueMarkdown renders an issue, comment or pull request body with
+// repository links and #123 references.
+func (s *Server) renderIssueMarkdown(repo *models.Repository, body string) template.HTML {
+	branch, _ := repo.DefaultBranch()
+	links := s.markdownLinks(repo, branch, "")
+	links.Issue = s.issueLinker(repo)
+	return template.HTML(utils.MarkdownToHTML([]byte(body), links))
+}
+
+// canManageIssue reports whether user may close, label and assign an
+// issue: its author, its assignees and users who can write to the
+// repository may.
+func (s *Server) canManageIssue(user *models.User, repo *models.Repository, issue *models.Issue) bool {
+	return user.ID == issue.AuthorID || issue.HasAssignee(user.ID) || s.permission(user, repo).CanWrite()
+}
+
+// canEditIssue reports whether user may edit the title and description of
+// an issue: its author and repository admins may.
+func (s *Server) canEditIssue(user *models.User, repo *models.Repository, issue *models.Issue) bool {
+	return user.ID == issue.AuthorID || s.permission(user, repo).CanAdmin()
+}
+
+// handleIssues routes the issue pages of a repository.
+//
+// Routes:
+//   - /issues/<repo>: The list of issues.
+//   - /issues/<repo>/new: The form to open an issue.
+//   - /issues/<repo>/<number>: A single issue.
+//   - /issues/<repo>/<number>/{comment,close,reopen,edit,labels,assignees}:
+//     Actions (POST).
+func (s *Server) handleIssues(w http.ResponseWriter, r *http.Request) {
+	parts := repoPathParts(r.URL.Path, 1)
+	if len(parts) < 2 {
+		models.HandleError(w, r, models.NewBadRequestError("Invalid repository path"))
+		return
+	}
+
+	repo, ok := s.Repository(parts[1])
+	if !ok {
+		models.HandleError(w, r, models.NewNotFoundError("Repository not found").WithDetail(fmt.Sprintf("Repository: %s", parts[1])))
+		return
+	}
+	if rejectArchived(w, r, repo) {
+		return
+	}
+
+	if len(parts) == 2 {
+		s.handleIssueList(w, r, repo)
+		return
+	}
+
+	if parts[2] == "new" {
+		s.handleNewIssue(w, r, repo)
+		return
+	}
+
+	number, err := strconv.Atoi(parts[2])
+	if err != nil {
+		models.HandleError(w, r, models.NewNotFoundError("Issue not found"))
+		return
+	}
+
+	issue, err := s.issues.Get(repo.Name, number)
+	if err != nil {
+		models.HandleError(w, r, models.NewNotFoundError("Issue not found").WithError(err))
+		return
+	}
+
+	if len(parts) == 3 {
+		s.handleViewIssue(w, r, repo, issue)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := getUserFromContext(r)
+	if !ok {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	switch parts[3] {
+	case "comment":
+		body := strings.TrimSpace(r.FormValue("body"))
+		if body == "" {
+			models.HandleError(w, r, models.NewBadRequestError("Comment cannot be empty").ShowInProduction())
+			return
+		}
+		if err := s.issues.AddComment(issue, user.ID, body); err != nil {
+			models.HandleError(w, r, models.NewInternalError("Failed to add comment").WithError(err))
+			return
+		}
+
+	case "close", "reopen":
+		if !s.canManageIssue(user, repo, issue) {
+			models.HandleError(w, r, models.NewForbiddenError("Only the author, assignees and collaborators can change this issue").ShowInProduction())
+			return
+		}
+		if err := s.issues.SetState(issue, parts[3] == "close", user.ID, ""); err != nil {
+			models.HandleError(w, r, models.NewInternalError("Failed to update issue").WithError(err))
+			return
+		}
+
+	case "edit":
+		if !s.canEditIssue(user, repo, issue) {
+			models.HandleError(w, r, models.NewForbiddenError("Only the author can edit this issue").ShowInProduction())
+			return
+		}
+		title := strings.TrimSpace(r.FormValue("title"))
+		if title == "" {
+			models.HandleError(w, r, models.NewBadRequestError("Title is required").ShowInProduction())
+			return
+		}
+		if err := s.issues.Update(issue, title, r.FormValue("body")); err != nil {
+			models.HandleError(w, r, models.NewInternalError("Failed to update issue").WithError(err))
+			return
+		}
+
+	case "labels":
+		if !s.canManageIssue(user, repo, issue) {
+			models.HandleError(w, r, models.NewForbiddenError("Only the author, assignees and collaborators can change this issue").ShowInProduction())
+			return
+		}
+		if err := s.issues.SetLabels(issue, strings.Split(r.FormValue("labels"), ",")); err != nil {
+			models.HandleError(w, r, models.NewInternalError("Failed to update labels").WithError(err))
+			return
+		}
+
+	case "assignees":
+		if !s.canManageIssue(user, repo, issue) {
+			models.HandleError(w, r, models.NewForbiddenError("Only the author, assignees and collaborators can change this issue").ShowInProduction())
+			return
+		}
+		r.ParseForm()
+		if err := s.issues.SetAssignees(issue, r.Form["assignees"]); err != nil {
+			models.HandleError(w, r, models.NewInternalError("Failed to update assignees").WithError(err))
+			return
+		}
+
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	http.Redirect(w, r, fmt.Sprintf("/issues/%s/%d", repo.Name, issue.Number), http.StatusSeeOther)
+}
+
+// handleIssueList lists the issues of a repository.
+//
+// Query parameters:
+//   - state: open (default), closed or all.
+//   - label: Only issues with this label.
+//   - assignee: Only issues assigned to this username.
+func (s *Server) handleIssueList(w http.ResponseWriter, r *http.Request, repo *models.Repository) {
+	query := r.URL.Query()
+	filter := models.IssueFilter{
+		State:    query.Get("state"),
+		Label:    query.Get("label"),
+		Assignee: query.Get("assignee"),
+	}
+	if filter.State == "" {
+		filter.State = models.IssueOpen
+	}
+	state := filter.State
+	if state == "all" {
+		filter.State = ""
+	}
+
+	issues, err := s.issues.List(repo.Name, filter)
+	if err != nil {
+		models.HandleError(w, r, models.NewInternalError("Failed to list issues").WithError(err))
+		return
+	}
+
+	labels, err := s.issues.ListLabels(repo.Name)
+	if err != nil {
+		models.HandleError(w, r, models.NewInternalError("Failed to list labels").WithError(err))
+		return
+	}
+
+	openCount, _ := s.issues.CountOpen(repo.Name)
+
+	data := map[string]interface{}{
+		"Repo":      repo,
+		"Issues":    issues,
+		"Labels":    labels,
+		"State":     state,
+		"Label":     filter.Label,
+		"Assignee":  filter.Assignee,
+		"OpenCount": openCount,
+	}
+
+	if err := s.tmpl.ExecuteTemplate(w, "issues.html", s.addCommonData(r, data)); err != nil {
+		models.HandleError(w, r, models.NewInternalError("Failed to render template").WithError(err))
+	}
+}
+
+// handleNewIssue shows the new issue form and, on POST, opens the issue.
+func (s *Server) handleNewIssue(w http.ResponseWriter, r *http.Request, repo *models.Repository) {
+	user, ok := getUserFromContext(r)
+	if !ok {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		issue := &models.Issue{
+			RepoName: repo.Name,
+			Title:    strings.TrimSpace(r.FormValue("title")),
+			Body:     r.FormValue("body"),
+			AuthorID: user.ID,
+		}
+		if issue.Title == "" {
+			models.HandleError(w, r, models.NewBadRequestError("Title is required").ShowInProduction())
+			return
+		}
+
+		if err := s.issues.Create(issue); err != nil {
+			models.HandleError(w, r, models.NewInternalError("Failed to create issue").WithError(err))
+			return
+		}
+		if labels := r.FormValue("labels"); labels != "" {
+			if err := s.issues.SetLabels(issue, strings.Split(labels, ",")); err != nil {
+				log.Printf("Issues: failed to label %s#%d: %v", repo.Name, issue.Number, err)
+			}
+		}
+		if assignees := r.Form["assignees"]; len(assignees) > 0 {
+			if err := s.issues.SetAssignees(issue, assignees); err != nil {
+				log.Printf("Issues: failed to assign %s#%d: %v", repo.Name, issue.Number, err)
+			}
+		}
+
+		http.Redirect(w, r, fmt.Sprintf("/issues/%s/%d", repo.Name, issue.Number), http.StatusSeeOther)
+		return
+	}
+
+	var users []models.User
+	if err := s.db.Order("username ASC").Find(&users).Error; err != nil {
+		models.HandleError(w, r, models.NewInternalError("Failed to list users").WithError(err))
+		return
+	}
+
+	data := map[string]interface{}{
+		"Repo":  repo,
+		"Users": users,
+	}
+
+	if err := s.tmpl.ExecuteTemplate(w, "issue_new.html", s.addCommonData(r, data)); err != nil {
+		models.HandleError(w, r, models.NewInternalError("Failed to render template").WithError(err))
+	}
+}
+
+// handleViewIssue shows an issue with its comments.
+func (s *Server) handleViewIssue(w http.ResponseWriter, r *http.Request, repo *models.Repository, issue *models.Issue) {
+	comments := make([]issueCommentView, len(issue.Comments))
+	for i, comment := range issue.Comments {
+		comments[i] = issueCommentView{IssueComment: comment}
+		if comment.Body != "" {
+			comments[i].HTML = s.renderIssueMarkdown(repo, comment.Body)
+		}
+	}
+
+	data := map[string]interface{}{
+		"Repo":     repo,
+		"Issue":    issue,
+		"Comments": comments,
+	}
+	if issue.Body != "" {
+		data["Body"] = s.renderIssueMarkdown(repo, issue.Body)
+	}
+
+	if user, ok := getUserFromContext(r); ok {
+		data["CanManage"] = s.canManageIssue(user, repo, issue)
+		data["CanEdit"] = s.canEditIssue(user, repo, issue)
+
+		var users []models.User
+		if err := s.db.Order("username ASC").Find(&users).Error; err == nil {
+			data["Users"] = users
+		}
+	}
+
+	if err := s.tmpl.ExecuteTemplate(w, "issue.html", s.addCommonData(r, data)); err != nil {
+		models.HandleError(w, r, models.NewInternalError("Failed to render template").WithError(err))
+	}
+}
+
+// closeIssuesFromPush closes the issues that commits pushed to the default
+// branch reference with a closing keyword such as "fixes #12".
+//
+// Parameters:
+//   - repo: The repository that was pushed to.
+//   - updates: The references the push changed.
+func (s *Server) closeIssuesFromPush(repo *models.Repository, updates []models.RefUpdate) {
+	defaultBranch, err := repo.DefaultBranch()
+	if err != nil {
+		return
+	}
+
+	for _, update := range updates {
+		if !update.IsBranch() || update.IsDelete() || update.Name.Short() != defaultBranch {
+			continue
+		}
+
+		base := ""
+		if !update.Old.IsZero() {
+			base = update.Old.String()
+		}
+		commits, err := repo.CommitsBetween(base, update.New.String(), closingCommitLimit)
+		if err != nil {
+			log.Printf("Issues: failed to list pushed commits of %s: %v", repo.Name, err)
+			continue
+		}
+
+		// Oldest first, so the first closing commit is the one recorded
+		for i := len(commits) - 1; i >= 0; i-- {
+			commit := commits[i]
+			for _, number := range utils.ClosingReferences(commit.Message) {
+				issue, err := s.issues.Get(repo.Name, number)
+				if err != nil || !issue.IsOpen() {
+					continue
+				}
+
+				userID := ""
+				if user, err := s.userService.GetUserByEmail(commit.Email); err == nil {
+					userID = user.ID
+				}
+				if err := s.issues.SetState(issue, true, userID, commit.Hash); err != nil {
+					log.Printf("Issues: failed to close %s#%d: %v", repo.Name, number, err)
+					continue
+				}
+				log.Printf("Issues: closed %s#%d by %s", repo.Name, number, commit.Hash[:7])
+			}
+		}
+	}
+}
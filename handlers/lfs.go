@@ -0,0 +1,304 @@
+//handlers/lfs.go
+
+package handlers
+
+import (
+	"SimpleGit/models"
+	"SimpleGit/services"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// lfsMediaType is the content type of LFS API requests and responses.
+const lfsMediaType = "application/vnd.git-lfs+json"
+
+// lfsTokenTTL is how long a token from git-lfs-authenticate is valid.
+const lfsTokenTTL = time.Hour
+
+// lfsObject names an LFS object by its SHA-256 and size.
+type lfsObject struct {
+	Oid  string `json:"oid"`
+	Size int64  `json:"size"`
+}
+
+// lfsBatchRequest asks to download or upload a set of objects.
+type lfsBatchRequest struct {
+	Operation string      `json:"operation"`
+	Transfers []string    `json:"transfers,omitempty"`
+	Objects   []lfsObject `json:"objects"`
+}
+
+// lfsAction tells the client where to transfer an object.
+type lfsAction struct {
+	Href      string            `json:"href"`
+	Header    map[string]string `json:"header,omitempty"`
+	ExpiresIn int               `json:"expires_in,omitempty"`
+}
+
+// lfsObjectError is the error of a single object in a batch response.
+type lfsObjectError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// lfsObjectResponse is an object of a batch response with the actions to
+// transfer it, or the error why it cannot be transferred. An upload of an
+// object the server already has has no actions.
+type lfsObjectResponse struct {
+	lfsObject
+	Authenticated bool                 `json:"authenticated,omitempty"`
+	Actions       map[string]lfsAction `json:"actions,omitempty"`
+	Error         *lfsObjectError      `json:"error,omitempty"`
+}
+
+// lfsBatchResponse answers a batch request.
+type lfsBatchResponse struct {
+	Transfer string              `json:"transfer"`
+	Objects  []lfsObjectResponse `json:"objects"`
+}
+
+// lfsErrorResponse is the body of a failed LFS request.
+type lfsErrorResponse struct {
+	Message string `json:"message"`
+}
+
+// lfsAuthenticateResponse is what git-lfs-authenticate prints over SSH.
+type lfsAuthenticateResponse struct {
+	Href      string            `json:"href"`
+	Header    map[string]string `json:"header"`
+	ExpiresIn int               `json:"expires_in"`
+}
+
+func writeLFSJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", lfsMediaType)
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// lfsURL returns the LFS API endpoint of a repository.
+func lfsURL(repo *models.Repository) string {
+	return repo.CloneURL() + "/info/lfs"
+}
+
+// handleLFS serves the Git LFS API of a repository with the basic
+// transfer adapter. Downloads are public like clones; uploads need the
+// same credentials as a push.
+//
+// Routes:
+//   - /repo/<repo>.git/info/lfs/objects/batch: The batch API (POST).
+//   - /repo/<repo>.git/info/lfs/objects/<oid>: Download (GET) or upload
+//     (PUT) an object.
+func (s *Server) handleLFS(w http.ResponseWriter, r *http.Request, repo *models.Repository) {
+	_, rest, _ := strings.Cut(r.URL.Path, "/info/lfs/")
+
+	switch {
+	case rest == "objects/batch":
+		if r.Method != http.MethodPost {
+			writeLFSJSON(w, http.StatusMethodNotAllowed, lfsErrorResponse{Message: "Method not allowed"})
+			return
+		}
+		s.handleLFSBatch(w, r, repo)
+	case strings.HasPrefix(rest, "objects/"):
+		oid := strings.TrimPrefix(rest, "objects/")
+		if !services.ValidLFSOid(oid) {
+			writeLFSJSON(w, http.StatusNotFound, lfsErrorResponse{Message: "Object not found"})
+			return
+		}
+		switch r.Method {
+		case http.MethodGet:
+			s.handleLFSDownload(w, r, repo, oid)
+		case http.MethodPut:
+			s.handleLFSUpload(w, r, repo, oid)
+		default:
+			writeLFSJSON(w, http.StatusMethodNotAllowed, lfsErrorResponse{Message: "Method not allowed"})
+		}
+	default:
+		writeLFSJSON(w, http.StatusNotFound, lfsErrorResponse{Message: "Not found"})
+	}
+}
+
+// handleLFSBatch tells the client how to download or upload each object of
+// a batch.
+func (s *Server) handleLFSBatch(w http.ResponseWriter, r *http.Request, repo *models.Repository) {
+	var req lfsBatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeLFSJSON(w, http.StatusBadRequest, lfsErrorResponse{Message: "Invalid request body"})
+		return
+	}
+	if req.Operation != "download" && req.Operation != "upload" {
+		writeLFSJSON(w, http.StatusUnprocessableEntity, lfsErrorResponse{Message: "Unknown operation"})
+		return
+	}
+	if !s.authorizeGit(w, r, repo, req.Operation == "upload") {
+		return
+	}
+
+	// Transfers reuse the credentials of the batch request
+	var header map[string]string
+	if auth := r.Header.Get("Authorization"); auth != "" {
+		header = map[string]string{"Authorization": auth}
+	}
+
+	resp := lfsBatchResponse{Transfer: "basic", Objects: make([]lfsObjectResponse, 0, len(req.Objects))}
+	for _, object := range req.Objects {
+		item := lfsObjectResponse{lfsObject: object, Authenticated: header != nil}
+		if !services.ValidLFSOid(object.Oid) || object.Size < 0 {
+			item.Error = &lfsObjectError{Code: http.StatusUnprocessableEntity, Message: "Invalid oid or size"}
+			resp.Objects = append(resp.Objects, item)
+			continue
+		}
+
+		action := lfsAction{Href: lfsURL(repo) + "/objects/" + object.Oid, Header: header}
+		size, err := s.LFS.Size(repo.Name, object.Oid)
+		switch {
+		case err != nil && !errors.Is(err, services.ErrLFSObjectNotFound):
+			log.Printf("Failed to stat LFS object %s of %s: %v", object.Oid, repo.Name, err)
+			item.Error = &lfsObjectError{Code: http.StatusInternalServerError, Message: "Failed to read object"}
+		case req.Operation == "download" && err != nil:
+			item.Error = &lfsObjectError{Code: http.StatusNotFound, Message: "Object does not exist"}
+		case req.Operation == "download":
+			item.Size = size
+			item.Actions = map[string]lfsAction{"download": action}
+		case err != nil:
+			item.Actions = map[string]lfsAction{"upload": action}
+		}
+		resp.Objects = append(resp.Objects, item)
+	}
+
+	writeLFSJSON(w, http.StatusOK, resp)
+}
+
+// handleLFSDownload sends an object.
+func (s *Server) handleLFSDownload(w http.ResponseWriter, r *http.Request, repo *models.Repository, oid string) {
+	if !s.authorizeGit(w, r, repo, false) {
+		return
+	}
+	s.serveLFSObject(w, r, repo, oid)
+}
+
+// serveLFSObject writes the content of an object, supporting range
+// requests.
+func (s *Server) serveLFSObject(w http.ResponseWriter, r *http.Request, repo *models.Repository, oid string) {
+	object, err := s.LFS.Open(repo.Name, oid)
+	if errors.Is(err, services.ErrLFSObjectNotFound) {
+		writeLFSJSON(w, http.StatusNotFound, lfsErrorResponse{Message: "Object not found"})
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to open LFS object %s of %s: %v", oid, repo.Name, err)
+		writeLFSJSON(w, http.StatusInternalServerError, lfsErrorResponse{Message: "Failed to read object"})
+		return
+	}
+	defer object.Close()
+
+	if w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", "application/octet-stream")
+	}
+	http.ServeContent(w, r, "", time.Time{}, object)
+}
+
+// handleLFSUpload stores an object. The content must match the oid.
+func (s *Server) handleLFSUpload(w http.ResponseWriter, r *http.Request, repo *models.Repository, oid string) {
+	if !s.authorizeGit(w, r, repo, true) {
+		return
+	}
+
+	if r.ContentLength < 0 {
+		writeLFSJSON(w, http.StatusLengthRequired, lfsErrorResponse{Message: "Content-Length required"})
+		return
+	}
+
+	err := s.LFS.Put(repo.Name, oid, r.ContentLength, r.Body)
+	if errors.Is(err, services.ErrLFSObjectMismatch) {
+		writeLFSJSON(w, http.StatusUnprocessableEntity, lfsErrorResponse{Message: err.Error()})
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to store LFS object %s of %s: %v", oid, repo.Name, err)
+		writeLFSJSON(w, http.StatusInternalServerError, lfsErrorResponse{Message: "Failed to store object"})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// LFSAuthenticate answers git-lfs-authenticate over SSH for a user. It returns the LFS endpoint of the repository at repoPath with a
+// token valid for operation, which is download or upload.
+func (s *Server) LFSAuthenticate(username, repoPath, operation string) ([]byte, error) {
+	if operation != "download" && operation != "upload" {
+		return nil, fmt.Errorf("unknown LFS operation: %s", operation)
+	}
+
+	repo := s.repoByPath(repoPath)
+	if repo == nil {
+		return nil, fmt.Errorf("repository not found")
+	}
+	user, err := s.userService.GetUserByUsername(username)
+	if err != nil {
+		return nil, fmt.Errorf("user not found")
+	}
+	if operation == "upload" && !s.permission(user, repo).CanWrite() {
+		return nil, errors.New(deniedMessage(repo, models.PermissionWrite))
+	}
+
+	token, err := s.userService.IssueLFSToken(user, repo.Name, operation == "upload", lfsTokenTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(lfsAuthenticateResponse{
+		Href:      lfsURL(repo),
+		Header:    map[string]string{"Authorization": "Bearer " + token},
+		ExpiresIn: int(lfsTokenTTL.Seconds()),
+	})
+}
+
+// resolveLFSPointer returns the object an LFS pointer blob names if the
+// server has it.
+func (s *Server) resolveLFSPointer(repo *models.Repository, content []byte) (services.LFSPointer, bool) {
+	pointer, ok := services.ParseLFSPointer(content)
+	if !ok || s.LFS == nil {
+		return pointer, false
+	}
+	if _, err := s.LFS.Size(repo.Name, pointer.Oid); err != nil {
+		return pointer, false
+	}
+	return pointer, true
+}
+
+// readLFSObject returns the content of an object.
+func (s *Server) readLFSObject(repo *models.Repository, oid string) ([]byte, error) {
+	object, err := s.LFS.Open(repo.Name, oid)
+	if err != nil {
+		return nil, err
+	}
+	defer object.Close()
+	return io.ReadAll(object)
+}
+
+// renderLFSFile renders the page of an LFS file that is binary or too
+// large to show, with a preview for images and a download link.
+func (s *Server) renderLFSFile(w http.ResponseWriter, r *http.Request, repo *models.Repository, path, branch string, pointer services.LFSPointer) {
+	data := map[string]interface{}{
+		"Repo":      repo,
+		"Path":      path,
+		"Size":      pointer.Size,
+		"Branch":    branch,
+		"LFS":       true,
+		"LFSOid":    pointer.Oid,
+		"LFSBinary": true,
+		"LFSImage":  strings.HasPrefix(mime.TypeByExtension(filepath.Ext(path)), "image/"),
+	}
+
+	if err := s.tmpl.ExecuteTemplate(w, "file.html", s.addCommonData(r, data)); err != nil {
+		models.HandleError(w, r, models.NewInternalError("Failed to render template").WithError(err))
+	}
+}
@@ -0,0 +1,485 @@
+//handlers/lifecycle.go
+
+package handlers
+
+import (
+	"SimpleGit/config"
+	"SimpleGit/models"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// followRenames redirects requests for the former name of a renamed or
+// transferred repository, found at segment i of the path, to its current
+// name. Reads are redirected permanently and other methods with 308, so
+// clients repeat them with their body. Git follows the redirect of its
+// first request and talks to the new URL from then on.
+func (s *Server) followRenames(i int, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+		if len(parts) > i+1 {
+			shortName := strings.TrimSuffix(parts[i+1], ".git")
+			if newName, ok := s.currentName(parts[i] + "/" + shortName); ok {
+				owner, newShortName, _ := strings.Cut(newName, "/")
+				parts[i], parts[i+1] = owner, strings.Replace(parts[i+1], shortName, newShortName, 1)
+
+				target := "/" + strings.Join(parts, "/")
+				if strings.HasSuffix(r.URL.Path, "/") {
+					target += "/"
+				}
+				if r.URL.RawQuery != "" {
+					target += "?" + r.URL.RawQuery
+				}
+				status := http.StatusMovedPermanently
+				if r.Method != http.MethodGet && r.Method != http.MethodHead {
+					status = http.StatusPermanentRedirect
+				}
+				http.Redirect(w, r, target, status)
+				return
+			}
+		}
+		next(w, r)
+	}
+}
+
+// currentName returns the name a repository that no longer exists under
+// name was renamed or transferred to.
+func (s *Server) currentName(name string) (string, bool) {
+	if _, ok := s.Repository(name); ok {
+		return "", false
+	}
+	newName, ok := s.redirects.Lookup(name)
+	if !ok {
+		return "", false
+	}
+	_, exists := s.Repository(newName)
+	return newName, exists
+}
+
+// ResolveRepoName returns the current name of the repository with the full
+// name name, following the redirect of a former name. The SSH server uses
+// it so remotes keep working after a rename or transfer.
+func (s *Server) ResolveRepoName(name string) string {
+	if newName, ok := s.currentName(name); ok {
+		return newName
+	}
+	return name
+}
+
+// rejectArchived writes an error and reports true for requests that would
+// change an archived repository.
+func rejectArchived(w http.ResponseWriter, r *http.Request, repo *models.Repository) bool {
+	if !repo.Archived || r.Method == http.MethodGet || r.Method == http.MethodHead {
+		return false
+	}
+	models.HandleError(w, r, models.NewForbiddenError(deniedMessage(repo, models.PermissionWrite)).ShowInProduction())
+	return true
+}
+
+// moveRepository renames a repository or transfers it to another owner,
+// newName being its new full name. Everything stored about it follows,
+// and the former name redirects to the new one. Callers check that the
+// new owner exists and that the user may create repositories for it.
+func (s *Server) moveRepository(repo *models.Repository, newName string) *models.AppError {
+	ownerName, shortName, _ := strings.Cut(newName, "/")
+	if !models.ValidRepoName(shortName) {
+		return models.NewBadRequestError("Repository names may only contain letters, numbers, dots, hyphens and underscores").ShowInProduction()
+	}
+
+	s.reposMu.Lock()
+	if newName == repo.Name {
+		s.reposMu.Unlock()
+		return nil
+	}
+	appErr := s.moveRepositoryLocked(repo, ownerName, newName)
+	s.reposMu.Unlock()
+	if appErr != nil {
+		return appErr
+	}
+
+	s.reindexRepository(repo)
+	return nil
+}
+
+// moveRepositoryLocked does the work of moveRepository. The caller holds
+// reposMu, so no request sees the repository under both names or neither.
+func (s *Server) moveRepositoryLocked(repo *models.Repository, ownerName, newName string) *models.AppError {
+	if s.repos[repo.Name] != repo {
+		return models.NewNotFoundError("Repository not found").WithDetail(fmt.Sprintf("Repository: %s", repo.Name)).ShowInProduction()
+	}
+	if _, exists := s.repos[newName]; exists {
+		return models.NewConflictError("A repository with this name already exists").ShowInProduction()
+	}
+	newPath := models.RepoDir(s.RepoPath, newName)
+	if _, err := os.Stat(newPath); err == nil {
+		return models.NewConflictError("A repository with this name already exists").ShowInProduction()
+	}
+	if err := os.MkdirAll(filepath.Dir(newPath), 0755); err != nil {
+		return models.NewInternalError("Failed to create owner directory").WithError(err)
+	}
+
+	oldName, oldPath, oldOwner := repo.Name, repo.Path, repo.Owner
+	forks := s.forksOfLocked(repo)
+	if err := os.Rename(oldPath, newPath); err != nil {
+		return models.NewInternalError("Failed to move repository").WithError(err)
+	}
+	delete(s.repos, oldName)
+	repo.Move(newName, newPath)
+	s.repos[newName] = repo
+
+	// Forks name their parent and borrow its objects by path
+	for _, fork := range forks {
+		if err := fork.MoveAlternate(oldPath, newPath); err != nil {
+			log.Printf("Failed to relink fork %s to %s: %v", fork.Name, newName, err)
+		}
+		if err := fork.SetParent(newName); err != nil {
+			log.Printf("Failed to record parent of %s: %v", fork.Name, err)
+		}
+	}
+
+	s.renameRepoRecords(oldName, newName)
+	if ownerName != oldOwner {
+		// Teams belong to the previous owner
+		if err := s.teams.DeleteByRepo(newName); err != nil {
+			log.Printf("Failed to remove %s from the teams of %s: %v", newName, oldOwner, err)
+		}
+	}
+	if err := s.redirects.Add(oldName, newName); err != nil {
+		log.Printf("Failed to redirect %s to %s: %v", oldName, newName, err)
+	}
+
+	log.Printf("Moved repository %s to %s", oldName, newName)
+	return nil
+}
+
+// reindexRepository rebuilds the cross-reference and search indexes of a
+// repository.
+func (s *Server) reindexRepository(repo *models.Repository) {
+	if err := s.xref.Refresh(repo.Name, repo.Path); err != nil {
+		log.Printf("Xref: failed to refresh %s: %v", repo.Name, err)
+	}
+	s.codeSearch.Update(repo.Name, repo.Path)
+	s.commitSearch.Update(repo.Name, repo.Path)
+}
+
+// deleteRepository deletes a repository on behalf of the user with
+// username. It can be restored until the retention period is over: the
+// directory and everything stored about it are kept under the trash name
+// of the deletion until then, and forks keep borrowing its objects.
+func (s *Server) deleteRepository(repo *models.Repository, username string) (*models.DeletedRepo, error) {
+	s.reposMu.Lock()
+	defer s.reposMu.Unlock()
+	if s.repos[repo.Name] != repo {
+		return nil, fmt.Errorf("repository %s no longer exists", repo.Name)
+	}
+
+	retention := time.Duration(config.GlobalConfig.DeletedRepoRetentionDays) * 24 * time.Hour
+	deleted, err := s.deletedRepos.Add(repo.Name, username, retention)
+	if err != nil {
+		return nil, err
+	}
+
+	trashPath := models.RepoDir(s.RepoPath, deleted.TrashName())
+	if err := os.MkdirAll(filepath.Dir(trashPath), 0755); err != nil {
+		s.deletedRepos.Remove(deleted.ID)
+		return nil, err
+	}
+	forks := s.forksOfLocked(repo)
+	if err := os.Rename(repo.Path, trashPath); err != nil {
+		s.deletedRepos.Remove(deleted.ID)
+		return nil, err
+	}
+	for _, fork := range forks {
+		if err := fork.MoveAlternate(repo.Path, trashPath); err != nil {
+			log.Printf("Failed to relink fork %s: %v", fork.Name, err)
+		}
+	}
+
+	delete(s.repos, repo.Name)
+	s.renameRepoRecords(repo.Name, deleted.TrashName())
+
+	log.Printf("Deleted repository %s; it can be restored until %s", repo.Name, deleted.PurgeAt.Format(time.RFC3339))
+	return deleted, nil
+}
+
+// restoreRepository brings back a deleted repository under its name.
+func (s *Server) restoreRepository(deleted *models.DeletedRepo) *models.AppError {
+	s.reposMu.Lock()
+	repo, appErr := s.restoreRepositoryLocked(deleted)
+	s.reposMu.Unlock()
+	if appErr != nil {
+		return appErr
+	}
+
+	s.reindexRepository(repo)
+	log.Printf("Restored repository %s", repo.Name)
+	return nil
+}
+
+// restoreRepositoryLocked does the work of restoreRepository. The caller
+// holds reposMu.
+func (s *Server) restoreRepositoryLocked(deleted *models.DeletedRepo) (*models.Repository, *models.AppError) {
+	if _, exists := s.repos[deleted.Name]; exists {
+		return nil, models.NewConflictError(fmt.Sprintf("A repository named %s exists again; rename it first", deleted.Name)).ShowInProduction()
+	}
+	ownerName, _, _ := strings.Cut(deleted.Name, "/")
+	owner, err := s.lookupOwner(ownerName)
+	if err != nil {
+		return nil, models.NewInternalError("Failed to load owner").WithError(err)
+	}
+	if owner == nil {
+		return nil, models.NewConflictError(fmt.Sprintf("%s no longer exists", ownerName)).ShowInProduction()
+	}
+
+	path := models.RepoDir(s.RepoPath, deleted.Name)
+	trashPath := models.RepoDir(s.RepoPath, deleted.TrashName())
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, models.NewInternalError("Failed to create owner directory").WithError(err)
+	}
+	if err := os.Rename(trashPath, path); err != nil {
+		return nil, models.NewInternalError("Failed to restore repository").WithError(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, models.NewInternalError("Failed to restore repository").WithError(err)
+	}
+
+	repo := &models.Repository{
+		ID:        deleted.Name,
+		Name:      deleted.Name,
+		Owner:     ownerName,
+		Path:      path,
+		CreatedAt: info.ModTime(),
+		Size:      info.Size(),
+	}
+	repo.LoadDescription()
+	repo.LoadParent()
+	repo.LoadArchived()
+	s.repos[repo.Name] = repo
+
+	s.renameRepoRecords(deleted.TrashName(), repo.Name)
+	for _, fork := range s.forksOfLocked(repo) {
+		if err := fork.MoveAlternate(trashPath, path); err != nil {
+			log.Printf("Failed to relink fork %s: %v", fork.Name, err)
+		}
+	}
+	if err := s.redirects.Release(repo.Name); err != nil {
+		log.Printf("Failed to release the name %s: %v", repo.Name, err)
+	}
+	if err := s.deletedRepos.Remove(deleted.ID); err != nil {
+		log.Printf("Failed to forget deleted repository %s: %v", repo.Name, err)
+	}
+	return repo, nil
+}
+
+// purgeRepository removes a deleted repository for good. It holds
+// reposMu so that the repository is not restored meanwhile and its forks
+// do not change.
+func (s *Server) purgeRepository(deleted *models.DeletedRepo) error {
+	s.reposMu.Lock()
+	defer s.reposMu.Unlock()
+
+	trashPath := models.RepoDir(s.RepoPath, deleted.TrashName())
+	_, replaced := s.repos[deleted.Name]
+
+	// Forks borrow objects from the repository, so they need their own copy
+	for _, fork := range s.repos {
+		if fork.Parent != deleted.Name {
+			continue
+		}
+		if err := fork.Dissociate(); err != nil {
+			return fmt.Errorf("failed to detach fork %s: %w", fork.Name, err)
+		}
+		if !replaced {
+			if err := fork.SetParent(""); err != nil {
+				return fmt.Errorf("failed to detach fork %s: %w", fork.Name, err)
+			}
+		}
+	}
+
+	if err := os.RemoveAll(trashPath); err != nil {
+		return err
+	}
+	s.deleteRepoRecords(deleted.TrashName())
+	if !replaced {
+		if err := s.redirects.DeleteByRepo(deleted.Name); err != nil {
+			log.Printf("Failed to delete redirects to %s: %v", deleted.Name, err)
+		}
+	}
+	return s.deletedRepos.Remove(deleted.ID)
+}
+
+// PurgeDeletedRepositories removes the deleted repositories whose
+// retention period is over.
+func (s *Server) PurgeDeletedRepositories() {
+	expired, err := s.deletedRepos.Expired(time.Now())
+	if err != nil {
+		log.Printf("Failed to load deleted repositories: %v", err)
+		return
+	}
+	for i := range expired {
+		if err := s.purgeRepository(&expired[i]); err != nil {
+			log.Printf("Failed to purge deleted repository %s: %v", expired[i].Name, err)
+			continue
+		}
+		log.Printf("Purged deleted repository %s", expired[i].Name)
+	}
+}
+
+// handleRepoSettings shows the settings of a repository to the users who
+// administer it and applies them.
+//
+// Routes:
+//   - /settings/<repo>: The settings page.
+//   - /settings/<repo>/rename: Rename the repository (POST).
+//   - /settings/<repo>/transfer: Transfer it to another owner (POST).
+//   - /settings/<repo>/{archive,unarchive}: Archive or unarchive it (POST).
+//   - /settings/<repo>/delete: Delete it (POST).
+func (s *Server) handleRepoSettings(w http.ResponseWriter, r *http.Request) {
+	parts := repoPathParts(r.URL.Path, 1)
+	if len(parts) < 2 || len(parts) > 3 {
+		models.HandleError(w, r, models.NewNotFoundError("Page not found").WithDetail(fmt.Sprintf("Path: %s", r.URL.Path)))
+		return
+	}
+
+	repo, ok := s.Repository(parts[1])
+	if !ok {
+		models.HandleError(w, r, models.NewNotFoundError("Repository not found").WithDetail(fmt.Sprintf("Repository: %s", parts[1])))
+		return
+	}
+
+	user, ok := getUserFromContext(r)
+	if !ok {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+	if !s.canAdministerRepo(user, repo) {
+		models.HandleError(w, r, models.NewForbiddenError(fmt.Sprintf("You need admin access to %s to do this", repo.Name)).ShowInProduction())
+		return
+	}
+
+	if len(parts) == 2 {
+		s.renderRepoSettings(w, r, repo, user)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var appErr *models.AppError
+	switch parts[2] {
+	case "rename":
+		appErr = s.moveRepository(repo, repo.Owner+"/"+strings.TrimSpace(r.FormValue("name")))
+	case "transfer":
+		appErr = s.transferRepository(user, repo, strings.TrimSpace(r.FormValue("owner")))
+	case "archive", "unarchive":
+		if err := repo.SetArchived(parts[2] == "archive"); err != nil {
+			appErr = models.NewGitError("Failed to change the repository", err)
+		}
+	case "delete":
+		if r.FormValue("confirm") != repo.Name {
+			appErr = models.NewBadRequestError("Type the full name of the repository to delete it").ShowInProduction()
+			break
+		}
+		if _, err := s.deleteRepository(repo, user.Username); err != nil {
+			appErr = models.NewInternalError("Failed to delete repository").WithError(err)
+			break
+		}
+		http.Redirect(w, r, "/repo/"+repo.Owner, http.StatusSeeOther)
+		return
+	default:
+		appErr = models.NewNotFoundError("Page not found").WithDetail(fmt.Sprintf("Path: %s", r.URL.Path))
+	}
+	if appErr != nil {
+		models.HandleError(w, r, appErr)
+		return
+	}
+
+	http.Redirect(w, r, "/settings/"+repo.Name, http.StatusSeeOther)
+}
+
+// renderRepoSettings renders the settings page of a repository.
+func (s *Server) renderRepoSettings(w http.ResponseWriter, r *http.Request, repo *models.Repository, user *models.User) {
+	owners, err := s.creatableOwners(user)
+	if err != nil {
+		models.HandleError(w, r, models.NewInternalError("Failed to load owners").WithError(err))
+		return
+	}
+	transferable := make([]string, 0, len(owners))
+	for _, owner := range owners {
+		if owner != repo.Owner {
+			transferable = append(transferable, owner)
+		}
+	}
+
+	data := map[string]interface{}{
+		"Repo":          repo,
+		"Owners":        transferable,
+		"RetentionDays": config.GlobalConfig.DeletedRepoRetentionDays,
+	}
+	if err := s.tmpl.ExecuteTemplate(w, "repo_settings.html", s.addCommonData(r, data)); err != nil {
+		models.HandleError(w, r, models.NewInternalError("Failed to render template").WithError(err))
+	}
+}
+
+// transferRepository gives a repository to the owner named ownerName,
+// keeping its name. user must be able to create repositories there.
+func (s *Server) transferRepository(user *models.User, repo *models.Repository, ownerName string) *models.AppError {
+	owner, err := s.lookupOwner(ownerName)
+	if err != nil {
+		return models.NewInternalError("Failed to load owner").WithError(err)
+	}
+	if owner == nil {
+		return models.NewNotFoundError("No user or organization has this name").WithDetail(fmt.Sprintf("Owner: %s", ownerName)).ShowInProduction()
+	}
+	if !s.canCreateIn(user, owner) {
+		return models.NewForbiddenError("You cannot create repositories for this owner").ShowInProduction()
+	}
+	return s.moveRepository(repo, owner.Name+"/"+repo.ShortName())
+}
+
+// handleAdminDeletedRepo restores or purges a deleted repository.
+//
+// Routes:
+//   - /admin/deleted/<id>/restore: Restore the repository (POST).
+//   - /admin/deleted/<id>/purge: Remove it for good now (POST).
+func (s *Server) handleAdminDeletedRepo(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) != 4 || (parts[3] != "restore" && parts[3] != "purge") {
+		models.HandleError(w, r, models.NewNotFoundError("Page not found").WithDetail(fmt.Sprintf("Path: %s", r.URL.Path)))
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	deleted, err := s.deletedRepos.Get(parts[2])
+	if errors.Is(err, models.ErrDeletedRepoNotFound) {
+		models.HandleError(w, r, models.NewNotFoundError("Deleted repository not found").ShowInProduction())
+		return
+	}
+	if err != nil {
+		models.HandleError(w, r, models.NewInternalError("Failed to load deleted repository").WithError(err))
+		return
+	}
+
+	if parts[3] == "purge" {
+		if err := s.purgeRepository(deleted); err != nil {
+			models.HandleError(w, r, models.NewInternalError("Failed to purge repository").WithError(err))
+			return
+		}
+	} else if appErr := s.restoreRepository(deleted); appErr != nil {
+		models.HandleError(w, r, appErr)
+		return
+	}
+
+	http.Redirect(w, r, "/admin/repos", http.StatusSeeOther)
+}
@@ -0,0 +1,109 @@
+//handlers/lifecycle_test.go
+
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestMoveRepository(t *testing.T) {
+	s, _ := newTestServer(t)
+	repo, _ := s.Repository("alice/demo")
+	oldPath := repo.Path
+
+	if appErr := s.moveRepository(repo, "alice/app"); appErr != nil {
+		t.Fatal(appErr)
+	}
+	if _, ok := s.Repository("alice/demo"); ok {
+		t.Error("alice/demo still exists")
+	}
+	if moved, ok := s.Repository("alice/app"); !ok || moved != repo || repo.Name != "alice/app" {
+		t.Fatalf("alice/app = %v, %v; want the moved repository", moved, ok)
+	}
+	if _, err := os.Stat(oldPath); !os.IsNotExist(err) {
+		t.Errorf("%s is still on disk: %v", oldPath, err)
+	}
+
+	// Transferring keeps redirects to one hop
+	if appErr := s.moveRepository(repo, "acme/app"); appErr != nil {
+		t.Fatal(appErr)
+	}
+	for _, name := range []string{"alice/demo", "alice/app"} {
+		if newName, ok := s.currentName(name); !ok || newName != "acme/app" {
+			t.Errorf("currentName(%s) = %q, %v; want acme/app", name, newName, ok)
+		}
+	}
+	if got := s.ResolveRepoName("alice/demo"); got != "acme/app" {
+		t.Errorf("ResolveRepoName(alice/demo) = %q, want acme/app", got)
+	}
+	if got := s.ResolveRepoName("alice/gone"); got != "alice/gone" {
+		t.Errorf("ResolveRepoName(alice/gone) = %q, want alice/gone", got)
+	}
+
+	gone, _ := s.Repository("alice/gone")
+	tests := []struct {
+		name    string
+		newName string
+		code    int
+	}{
+		{"taken", "acme/app", http.StatusConflict},
+		{"invalid", "alice/bad name", http.StatusBadRequest},
+		{"unchanged", "alice/gone", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code := 0
+			if appErr := s.moveRepository(gone, tt.newName); appErr != nil {
+				code = appErr.Code
+			}
+			if code != tt.code || gone.Name != "alice/gone" {
+				t.Errorf("moving to %s: code %d, name %s; want %d and alice/gone", tt.newName, code, gone.Name, tt.code)
+			}
+		})
+	}
+
+	// A repository taking a former name ends its redirect
+	if _, err := s.CreateRepository("alice", "demo", ""); err != nil {
+		t.Fatal(err)
+	}
+	if newName, ok := s.currentName("alice/demo"); ok {
+		t.Errorf("alice/demo still redirects to %s", newName)
+	}
+}
+
+func TestFollowRenames(t *testing.T) {
+	s, _ := newTestServer(t)
+	repo, _ := s.Repository("alice/demo")
+	if appErr := s.moveRepository(repo, "acme/app"); appErr != nil {
+		t.Fatal(appErr)
+	}
+
+	tests := []struct {
+		method   string
+		segment  int
+		path     string
+		code     int
+		location string
+	}{
+		{"GET", 1, "/repo/alice/demo", http.StatusMovedPermanently, "/repo/acme/app"},
+		{"GET", 1, "/file/alice/demo/docs/README.md?branch=main", http.StatusMovedPermanently, "/file/acme/app/docs/README.md?branch=main"},
+		{"GET", 1, "/repo/alice/demo/", http.StatusMovedPermanently, "/repo/acme/app/"},
+		{"GET", 1, "/repo/alice/demo.git/info/refs", http.StatusMovedPermanently, "/repo/acme/app.git/info/refs"},
+		{"POST", 1, "/settings/alice/demo", http.StatusPermanentRedirect, "/settings/acme/app"},
+		{"PATCH", 3, "/api/v1/repos/alice/demo/branches/main", http.StatusPermanentRedirect, "/api/v1/repos/acme/app/branches/main"},
+		{"GET", 1, "/repo/acme/app", http.StatusOK, ""},
+		{"GET", 1, "/repo/alice/gone", http.StatusOK, ""},
+		{"GET", 1, "/repo/alice/missing", http.StatusOK, ""},
+	}
+	next := func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) }
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		s.followRenames(tt.segment, next)(rec, httptest.NewRequest(tt.method, tt.path, nil))
+		if rec.Code != tt.code || rec.Header().Get("Location") != tt.location {
+			t.Errorf("%s %s = %d to %q, want %d to %q", tt.method, tt.path, rec.Code, rec.Header().Get("Location"), tt.code, tt.location)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+//handlers/markdown.go
+
+package handlers
+
+import (
+	"SimpleGit/models"
+	"SimpleGit/utils"
+	"html/template"
+	"path/filepath"
+	"strings"
+)
+
+// readmeNames lists the README file names we render, in order of preference.
+var readmeNames = []string{"README.md", "README.markdown", "README", "README.txt"}
+
+// markdownLinks returns the link rewriting context for a markdown document
+// stored in dir on the given branch.
+func (s *Server) markdownLinks(repo *models.Repository, branch, dir string) *utils.MarkdownLinks {
+	links := &utils.MarkdownLinks{
+		Repo:   repo.Name,
+		Branch: branch,
+		Dir:    dir,
+	}
+
+	if tree, err := repo.BranchTree(branch); err == nil {
+		links.IsDir = func(path string) bool {
+			_, err := tree.Tree(path)
+			return err == nil
+		}
+	}
+
+	return links
+}
+
+// findReadme returns the README entry of a directory listing, if any.
+func findReadme(entries []models.TreeEntry) (models.TreeEntry, bool) {
+	for _, name := range readmeNames {
+		for _, entry := range entries {
+			if entry.Type == "blob" && strings.EqualFold(entry.Name, name) {
+				return entry, true
+			}
+		}
+	}
+	return models.TreeEntry{}, false
+}
+
+// renderReadme renders the README of the directory listed in entries.
+// Markdown READMEs are rendered to sanitized HTML, anything else is shown
+// as preformatted text.
+//
+// Returns:
+//
+//	The rendered README and its file name, or an empty name if the
+//	directory has no README.
+func (s *Server) renderReadme(repo *models.Repository, branch, dir string, entries []models.TreeEntry) (template.HTML, string) {
+	entry, ok := findReadme(entries)
+	if !ok {
+		return "", ""
+	}
+
+	content, err := repo.GetFile(entry.Path, branch)
+	if err != nil || utils.IsBinaryFile(content) {
+		return "", ""
+	}
+
+	if utils.IsMarkdownFile(entry.Name) {
+		return template.HTML(utils.MarkdownToHTML(content, s.markdownLinks(repo, branch, filepath.Dir(entry.Path)))), entry.Name
+	}
+
+	return template.HTML("<pre>" + template.HTMLEscapeString(string(content)) + "</pre>"), entry.Name
+}
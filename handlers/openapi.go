@@ -0,0 +1,455 @@
+//handlers/openapi.go
+
+package handlers
+
+import (
+	"SimpleGit/models"
+	"SimpleGit/services"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// Authentication an API operation requires.
+const (
+	apiAuthPublic  = ""
+	apiAuthSession = "session"
+	apiAuthToken   = "token"
+	apiAuthAdmin   = "admin"
+	// apiAuthWrite and apiAuthRepoAdmin need a token or session of a user
+	// with write or admin access to the repository.
+	apiAuthWrite     = "write"
+	apiAuthRepoAdmin = "repo-admin"
+)
+
+// apiParam is a query parameter of an API operation.
+type apiParam struct {
+	Name        string
+	Type        string
+	Description string
+}
+
+// apiOperation documents one method of a JSON route. Request and response
+// schemas are derived from the Go types of Body and Response so the
+// document cannot drift from what the handlers encode.
+type apiOperation struct {
+	Method      string
+	Path        string
+	Summary     string
+	Tag         string
+	Auth        string
+	Query       []apiParam
+	Body        interface{}
+	Response    interface{}
+	Status      int
+	Paginated   bool
+	ContentType string
+}
+
+// pageParams are the query parameters of paginated lists.
+var pageParams = []apiParam{
+	{"page", "integer", "The page to return, from 1."},
+	{"per_page", "integer", fmt.Sprintf("Items per page, at most %d (default %d).", apiMaxPerPage, apiDefaultPerPage)},
+}
+
+// refParam selects the commit a read operates on.
+var refParam = apiParam{"ref", "string", "A branch, tag or commit. Defaults to the default branch."}
+
+// apiOperations describes every JSON route registered in SetupRoutes.
+// TestOpenAPICoverage fails when a registered route is missing here.
+var apiOperations = []apiOperation{
+	{Method: "GET", Path: "/api/openapi.json", Tag: "meta", Summary: "This OpenAPI document", Response: map[string]interface{}{}},
+
+	{Method: "GET", Path: "/api/repos", Tag: "legacy", Auth: apiAuthSession, Summary: "List repositories", Response: []*models.Repository{}},
+	{Method: "GET", Path: "/api/search", Tag: "search", Summary: "Search code on default branches", Response: services.CodeSearchResults{}, Query: []apiParam{
+		{"q", "string", "The pattern, optionally with repo: and path: filters."},
+		{"repo", "string", "Only search this repository."},
+		{"path", "string", "Only search paths matching this filter."},
+		{"regex", "boolean", "Treat the pattern as a regular expression."},
+		{"case", "boolean", "Match case."},
+		{"context", "integer", "Lines of context around matches, at most 10."},
+		{"limit", "integer", "Maximum number of files."},
+	}},
+	{Method: "GET", Path: "/api/search/commits", Tag: "search", Summary: "Search commit messages and metadata", Response: services.CommitSearchResults{}, Query: []apiParam{
+		{"q", "string", "Search terms, optionally with repo:, path: and author: filters."},
+		{"author", "string", "Only commits by this author name or email."},
+		{"path", "string", "Only commits touching this path."},
+		{"repo", "string", "Only commits of this repository."},
+		{"offset", "integer", "Results to skip."},
+		{"limit", "integer", "Maximum number of results."},
+	}},
+	{Method: "GET", Path: "/api/xref/{owner}/{repo}", Tag: "search", Summary: "A Go definition and its references", Response: xrefResponse{}, Query: []apiParam{
+		{"branch", "string", "The branch the file view was rendered for."},
+		{"commit", "string", "The commit the index was built for."},
+		{"def", "integer", "The definition ID from the file view."},
+	}},
+	{Method: "GET", Path: "/api/metrics/highlight-cache", Tag: "admin", Auth: apiAuthAdmin, Summary: "Highlight cache counters", Response: services.HighlightCacheStats{}},
+	{Method: "GET", Path: "/api/metrics/code-search", Tag: "admin", Auth: apiAuthAdmin, Summary: "Code search index size", Response: services.CodeSearchStats{}},
+
+	{Method: "GET", Path: "/api/ssh-keys", Tag: "user", Auth: apiAuthSession, Summary: "List your SSH keys", Response: []models.SSHKey{}},
+	{Method: "POST", Path: "/api/ssh-keys/add", Tag: "user", Auth: apiAuthSession, Summary: "Add an SSH key", Body: SSHKeyRequest{}, Response: models.SSHKey{}},
+	{Method: "DELETE", Path: "/api/ssh-keys/{id}", Tag: "user", Auth: apiAuthSession, Summary: "Delete an SSH key", Status: http.StatusNoContent},
+	{Method: "GET", Path: "/api/tokens", Tag: "user", Auth: apiAuthSession, Summary: "List your access tokens", Response: []models.AccessToken{}},
+	{Method: "POST", Path: "/api/tokens/add", Tag: "user", Auth: apiAuthSession, Summary: "Create an access token; the token is only returned here", Body: AccessTokenRequest{}, Response: AccessTokenResponse{}, Status: http.StatusCreated},
+	{Method: "DELETE", Path: "/api/tokens/{id}", Tag: "user", Auth: apiAuthSession, Summary: "Revoke an access token", Status: http.StatusNoContent},
+
+	{Method: "GET", Path: "/api/repos/{owner}/{repo}/statuses/{ref}", Tag: "statuses", Summary: "List the statuses of a commit", Response: []models.CommitStatus{}},
+	{Method: "POST", Path: "/api/repos/{owner}/{repo}/statuses/{ref}", Tag: "statuses", Auth: apiAuthWrite, Summary: "Set the status of a commit for a context", Body: CommitStatusRequest{}, Response: models.CommitStatus{}, Status: http.StatusCreated},
+	{Method: "GET", Path: "/api/repos/{owner}/{repo}/commits/{ref}/status", Tag: "statuses", Summary: "The combined status of a commit", Response: CombinedStatusResponse{}},
+
+	{Method: "GET", Path: "/api/v1/repos", Tag: "repositories", Summary: "List repositories", Response: []apiRepository{}, Paginated: true, Query: []apiParam{
+		{"owner", "string", "Only repositories of this user or organization."},
+	}},
+	{Method: "POST", Path: "/api/v1/repos", Tag: "repositories", Auth: apiAuthToken, Summary: "Create a repository for yourself or an organization you are a member of", Body: apiRepositoryRequest{}, Response: apiRepository{}, Status: http.StatusCreated},
+	{Method: "GET", Path: "/api/v1/repos/{owner}/{repo}", Tag: "repositories", Summary: "Get a repository", Response: apiRepository{}},
+	{Method: "PATCH", Path: "/api/v1/repos/{owner}/{repo}", Tag: "repositories", Auth: apiAuthRepoAdmin, Summary: "Update, rename, transfer or archive a repository", Body: apiRepositoryRequest{}, Response: apiRepository{}},
+	{Method: "DELETE", Path: "/api/v1/repos/{owner}/{repo}", Tag: "repositories", Auth: apiAuthRepoAdmin, Summary: "Delete a repository; an admin can restore it until the retention period ends", Status: http.StatusNoContent},
+	{Method: "GET", Path: "/api/v1/repos/{owner}/{repo}/forks", Tag: "repositories", Summary: "List the forks of a repository", Response: []apiRepository{}, Paginated: true},
+	{Method: "POST", Path: "/api/v1/repos/{owner}/{repo}/forks", Tag: "repositories", Auth: apiAuthToken, Summary: "Fork a repository", Body: apiForkRequest{}, Response: apiRepository{}, Status: http.StatusCreated},
+	{Method: "GET", Path: "/api/v1/repos/{owner}/{repo}/branches", Tag: "refs", Summary: "List branches", Response: []models.Branch{}, Paginated: true},
+	{Method: "POST", Path: "/api/v1/repos/{owner}/{repo}/branches", Tag: "refs", Auth: apiAuthWrite, Summary: "Create a branch", Body: apiBranchRequest{}, Response: models.Branch{}, Status: http.StatusCreated},
+	{Method: "GET", Path: "/api/v1/repos/{owner}/{repo}/branches/{branch}", Tag: "refs", Summary: "Get a branch", Response: models.Branch{}},
+	{Method: "PATCH", Path: "/api/v1/repos/{owner}/{repo}/branches/{branch}", Tag: "refs", Auth: apiAuthWrite, Summary: "Rename an unprotected branch", Body: apiBranchRequest{}, Response: models.Branch{}},
+	{Method: "DELETE", Path: "/api/v1/repos/{owner}/{repo}/branches/{branch}", Tag: "refs", Auth: apiAuthWrite, Summary: "Delete an unprotected branch other than the default branch", Status: http.StatusNoContent},
+	{Method: "GET", Path: "/api/v1/repos/{owner}/{repo}/protected-branches", Tag: "refs", Summary: "List branch protection rules", Response: []models.BranchProtection{}},
+	{Method: "POST", Path: "/api/v1/repos/{owner}/{repo}/protected-branches", Tag: "refs", Auth: apiAuthRepoAdmin, Summary: "Protect the branches matching a pattern", Body: apiProtectionRequest{}, Response: models.BranchProtection{}, Status: http.StatusCreated},
+	{Method: "DELETE", Path: "/api/v1/repos/{owner}/{repo}/protected-branches/{id}", Tag: "refs", Auth: apiAuthRepoAdmin, Summary: "Remove a branch protection rule", Status: http.StatusNoContent},
+	{Method: "GET", Path: "/api/v1/repos/{owner}/{repo}/tags", Tag: "refs", Summary: "List tags", Response: []models.Tag{}, Paginated: true},
+	{Method: "GET", Path: "/api/v1/repos/{owner}/{repo}/tags/{tag}", Tag: "refs", Summary: "Get a tag", Response: models.Tag{}},
+	{Method: "GET", Path: "/api/v1/repos/{owner}/{repo}/tree", Tag: "contents", Summary: "List the root directory", Response: []models.TreeItem{}, Paginated: true, Query: []apiParam{refParam}},
+	{Method: "GET", Path: "/api/v1/repos/{owner}/{repo}/tree/{path}", Tag: "contents", Summary: "List a directory", Response: []models.TreeItem{}, Paginated: true, Query: []apiParam{refParam}},
+	{Method: "GET", Path: "/api/v1/repos/{owner}/{repo}/blobs/{path}", Tag: "contents", Summary: "Get a file, base64 encoded", Response: apiBlob{}, Query: []apiParam{refParam}},
+	{Method: "GET", Path: "/api/v1/repos/{owner}/{repo}/raw/{path}", Tag: "contents", Summary: "Get a file's raw content", ContentType: "application/octet-stream", Query: []apiParam{refParam}},
+	{Method: "GET", Path: "/api/v1/repos/{owner}/{repo}/commits", Tag: "commits", Summary: "List commits, newest first", Response: []models.CommitInfo{}, Paginated: true, Query: []apiParam{
+		refParam,
+		{"path", "string", "Only commits that touched this path."},
+	}},
+	{Method: "GET", Path: "/api/v1/repos/{owner}/{repo}/commits/{ref}", Tag: "commits", Summary: "Get a commit with its diff", Response: apiCommit{}},
+	{Method: "GET", Path: "/api/v1/repos/{owner}/{repo}/commits/{ref}/status", Tag: "statuses", Summary: "The combined status of a commit", Response: CombinedStatusResponse{}},
+	{Method: "GET", Path: "/api/v1/repos/{owner}/{repo}/statuses/{ref}", Tag: "statuses", Summary: "List the statuses of a commit", Response: []models.CommitStatus{}},
+	{Method: "POST", Path: "/api/v1/repos/{owner}/{repo}/statuses/{ref}", Tag: "statuses", Auth: apiAuthWrite, Summary: "Set the status of a commit for a context", Body: CommitStatusRequest{}, Response: models.CommitStatus{}, Status: http.StatusCreated},
+	{Method: "GET", Path: "/api/v1/repos/{owner}/{repo}/compare/{basehead}", Tag: "commits", Summary: "Compare <base>...<head>; a base of upstream:<branch> names a branch of a fork's parent", Response: apiComparison{}},
+
+	{Method: "GET", Path: "/api/v1/orgs", Tag: "organizations", Summary: "List organizations", Response: []models.Organization{}, Paginated: true},
+	{Method: "POST", Path: "/api/v1/orgs", Tag: "organizations", Auth: apiAuthToken, Summary: "Create an organization you own", Body: apiOrgRequest{}, Response: models.Organization{}, Status: http.StatusCreated},
+	{Method: "GET", Path: "/api/v1/orgs/{org}", Tag: "organizations", Summary: "Get an organization", Response: models.Organization{}},
+	{Method: "GET", Path: "/api/v1/orgs/{org}/members", Tag: "organizations", Summary: "List the members of an organization, owners first", Response: []apiOrgMember{}, Paginated: true},
+	{Method: "PUT", Path: "/api/v1/orgs/{org}/members/{username}", Tag: "organizations", Auth: apiAuthToken, Summary: "Add a member or change a member's role; requires an owner of the organization", Body: apiOrgMemberRequest{}, Status: http.StatusNoContent},
+	{Method: "DELETE", Path: "/api/v1/orgs/{org}/members/{username}", Tag: "organizations", Auth: apiAuthToken, Summary: "Remove a member; requires an owner of the organization", Status: http.StatusNoContent},
+}
+
+// openAPIPathParams describes the path parameters of the operations.
+var openAPIPathParams = map[string]string{
+	"owner":    "The user or organization owning the repository.",
+	"repo":     "The repository name.",
+	"org":      "The organization name.",
+	"username": "The username of a user.",
+	"ref":      "A branch, tag or commit.",
+	"branch":   "The branch name; may contain slashes.",
+	"tag":      "The tag name; may contain slashes.",
+	"path":     "A path in the repository; may contain slashes.",
+	"basehead": "Two refs separated by three dots: <base>...<head>.",
+	"id":       "The ID of the item.",
+}
+
+var pathParamPattern = regexp.MustCompile(`\{([a-z]+)\}`)
+
+var (
+	openAPIOnce     sync.Once
+	openAPIDocument []byte
+	openAPIErr      error
+)
+
+// handleOpenAPI serves the OpenAPI 3 document of the JSON API.
+func (s *Server) handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	openAPIOnce.Do(func() {
+		openAPIDocument, openAPIErr = json.MarshalIndent(buildOpenAPI(apiOperations), "", "  ")
+	})
+	if openAPIErr != nil {
+		models.HandleError(w, r, models.NewInternalError("Failed to build OpenAPI document").WithError(openAPIErr))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(openAPIDocument)
+}
+
+// handleAPI registers a JSON route. Every route registered this way must
+// be described by apiOperations; TestOpenAPICoverage checks that it is.
+func (s *Server) handleAPI(pattern string, handler http.HandlerFunc) {
+	http.HandleFunc(pattern, handler)
+}
+
+// buildOpenAPI assembles the OpenAPI document of the given operations.
+func buildOpenAPI(operations []apiOperation) map[string]interface{} {
+	schemas := newSchemaRegistry()
+	errorRef := schemas.schemaFor(reflect.TypeOf(models.AppError{}))
+	schemas.defs["AppError"].(map[string]interface{})["description"] = "An error. In production, errors that are not meant for clients are replaced by {\"error\": \"Internal Server Error\"}."
+
+	paths := make(map[string]map[string]interface{})
+	tagSet := make(map[string]bool)
+
+	for _, op := range operations {
+		var params []interface{}
+		for _, match := range pathParamPattern.FindAllStringSubmatch(op.Path, -1) {
+			params = append(params, map[string]interface{}{
+				"name":        match[1],
+				"in":          "path",
+				"required":    true,
+				"description": openAPIPathParams[match[1]],
+				"schema":      map[string]interface{}{"type": "string"},
+			})
+		}
+		query := op.Query
+		if op.Paginated {
+			query = append(append([]apiParam{}, query...), pageParams...)
+		}
+		for _, param := range query {
+			params = append(params, map[string]interface{}{
+				"name":        param.Name,
+				"in":          "query",
+				"description": param.Description,
+				"schema":      map[string]interface{}{"type": param.Type},
+			})
+		}
+
+		status := op.Status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		success := map[string]interface{}{"description": http.StatusText(status)}
+		switch {
+		case op.ContentType != "":
+			success["content"] = map[string]interface{}{
+				op.ContentType: map[string]interface{}{"schema": map[string]interface{}{"type": "string", "format": "binary"}},
+			}
+		case op.Response != nil:
+			success["content"] = map[string]interface{}{
+				"application/json": map[string]interface{}{"schema": schemas.schemaFor(reflect.TypeOf(op.Response))},
+			}
+		}
+		if op.Paginated {
+			success["headers"] = map[string]interface{}{
+				"Link":          map[string]interface{}{"description": "URLs of the next, prev, first and last pages.", "schema": map[string]interface{}{"type": "string"}},
+				"X-Total-Count": map[string]interface{}{"description": "The number of items of all pages, when known.", "schema": map[string]interface{}{"type": "integer"}},
+			}
+		}
+
+		operation := map[string]interface{}{
+			"summary":     op.Summary,
+			"operationId": operationID(op),
+			"tags":        []string{op.Tag},
+			"responses": map[string]interface{}{
+				fmt.Sprint(status): success,
+				"default": map[string]interface{}{
+					"description": "An error",
+					"content": map[string]interface{}{
+						"application/json": map[string]interface{}{"schema": errorRef},
+					},
+				},
+			},
+		}
+		if len(params) > 0 {
+			operation["parameters"] = params
+		}
+		if op.Body != nil {
+			operation["requestBody"] = map[string]interface{}{
+				"required": true,
+				"content": map[string]interface{}{
+					"application/json": map[string]interface{}{"schema": schemas.schemaFor(reflect.TypeOf(op.Body))},
+				},
+			}
+		}
+		switch op.Auth {
+		case apiAuthSession:
+			operation["security"] = []map[string][]string{{"session": {}}}
+		case apiAuthToken, apiAuthAdmin, apiAuthWrite, apiAuthRepoAdmin:
+			operation["security"] = []map[string][]string{{"token": {}}, {"session": {}}}
+		}
+		switch op.Auth {
+		case apiAuthAdmin:
+			operation["description"] = "Requires an admin."
+		case apiAuthWrite:
+			operation["description"] = "Requires write access to the repository."
+		case apiAuthRepoAdmin:
+			operation["description"] = "Requires admin access to the repository."
+		}
+
+		if paths[op.Path] == nil {
+			paths[op.Path] = make(map[string]interface{})
+		}
+		paths[op.Path][strings.ToLower(op.Method)] = operation
+		tagSet[op.Tag] = true
+	}
+
+	tags := make([]string, 0, len(tagSet))
+	for tag := range tagSet {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	tagList := make([]map[string]string, len(tags))
+	for i, tag := range tags {
+		tagList[i] = map[string]string{"name": tag}
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":       "SimpleGit API",
+			"version":     "1",
+			"description": "JSON API of SimpleGit. Errors are AppError objects; in production, internal errors only carry a generic message.",
+		},
+		"tags":  tagList,
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": schemas.defs,
+			"securitySchemes": map[string]interface{}{
+				"token": map[string]interface{}{
+					"type":        "http",
+					"scheme":      "bearer",
+					"description": "An access token from the profile page, sent as \"Authorization: token <token>\" or \"Authorization: Bearer <token>\".",
+				},
+				"session": map[string]interface{}{
+					"type": "apiKey",
+					"in":   "cookie",
+					"name": "auth_token",
+				},
+			},
+		},
+	}
+}
+
+// operationID derives a stable operation ID such as getApiV1ReposRepoBranches.
+func operationID(op apiOperation) string {
+	var b strings.Builder
+	b.WriteString(strings.ToLower(op.Method))
+	for _, word := range strings.FieldsFunc(op.Path, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		b.WriteString(strings.ToUpper(word[:1]) + word[1:])
+	}
+	return b.String()
+}
+
+// schemaRegistry turns Go types into OpenAPI schemas. Named structs become
+// components referenced with $ref.
+type schemaRegistry struct {
+	defs  map[string]interface{}
+	names map[reflect.Type]string
+}
+
+func newSchemaRegistry() *schemaRegistry {
+	return &schemaRegistry{
+		defs:  make(map[string]interface{}),
+		names: make(map[reflect.Type]string),
+	}
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// schemaFor returns the schema of t following encoding/json's rules.
+func (g *schemaRegistry) schemaFor(t reflect.Type) map[string]interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t {
+	case timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case durationType:
+		return map[string]interface{}{"type": "integer", "format": "int64", "description": "Nanoseconds."}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Int64, reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "format": "int64"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]interface{}{"type": "string", "format": "byte"}
+		}
+		return map[string]interface{}{"type": "array", "items": g.schemaFor(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": g.schemaFor(t.Elem())}
+	case reflect.Struct:
+		return g.structRef(t)
+	}
+	return map[string]interface{}{}
+}
+
+// structRef registers a struct as a component and returns a reference.
+func (g *schemaRegistry) structRef(t reflect.Type) map[string]interface{} {
+	name, ok := g.names[t]
+	if !ok {
+		name = schemaName(t)
+		if _, taken := g.defs[name]; taken {
+			pkg := t.PkgPath()[strings.LastIndex(t.PkgPath(), "/")+1:]
+			name = strings.ToUpper(pkg[:1]) + pkg[1:] + name
+		}
+		g.names[t] = name
+		g.defs[name] = map[string]interface{}{} // placeholder for recursive types
+
+		properties := make(map[string]interface{})
+		g.collectFields(t, properties)
+		g.defs[name] = map[string]interface{}{
+			"type":       "object",
+			"properties": properties,
+		}
+	}
+	return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+}
+
+// collectFields adds the JSON properties of a struct, flattening embedded
+// structs the way encoding/json does.
+func (g *schemaRegistry) collectFields(t reflect.Type, properties map[string]interface{}) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Ptr {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				g.collectFields(embedded, properties)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		properties[name] = g.schemaFor(field.Type)
+	}
+}
+
+// schemaName is the component name of a type: its Go name, capitalised.
+func schemaName(t reflect.Type) string {
+	name := t.Name()
+	if name == "" {
+		return ""
+	}
+	return strings.ToUpper(name[:1]) + name[1:]
+}
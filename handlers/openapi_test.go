@@ -0,0 +1,221 @@
+//handlers/openapi_test.go
+
+package handlers
+
+import (
+	"SimpleGit/config"
+	"SimpleGit/models"
+	"SimpleGit/services"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// apiRequest is a concrete request for an operation of the OpenAPI
+// document.
+type apiRequest struct {
+	method string
+	path   string
+}
+
+// apiRequests has a request for every operation in apiOperations. Bodies
+// are invalid JSON so that no request changes anything but the one
+// deleting alice/gone.
+var apiRequests = []apiRequest{
+	{"GET", "/api/openapi.json"},
+
+	{"GET", "/api/repos"},
+	{"GET", "/api/search"},
+	{"GET", "/api/search/commits"},
+	{"GET", "/api/xref/alice/demo"},
+	{"GET", "/api/metrics/highlight-cache"},
+	{"GET", "/api/metrics/code-search"},
+
+	{"GET", "/api/ssh-keys"},
+	{"POST", "/api/ssh-keys/add"},
+	{"DELETE", "/api/ssh-keys/999"},
+	{"GET", "/api/tokens"},
+	{"POST", "/api/tokens/add"},
+	{"DELETE", "/api/tokens/999"},
+
+	{"GET", "/api/repos/alice/demo/statuses/main"},
+	{"POST", "/api/repos/alice/demo/statuses/main"},
+	{"GET", "/api/repos/alice/demo/commits/main/status"},
+
+	{"GET", "/api/v1/repos"},
+	{"POST", "/api/v1/repos"},
+	{"GET", "/api/v1/repos/alice/demo"},
+	{"PATCH", "/api/v1/repos/alice/demo"},
+	{"DELETE", "/api/v1/repos/alice/gone"},
+	{"GET", "/api/v1/repos/alice/demo/forks"},
+	{"POST", "/api/v1/repos/alice/demo/forks"},
+	{"GET", "/api/v1/repos/alice/demo/branches"},
+	{"POST", "/api/v1/repos/alice/demo/branches"},
+	{"GET", "/api/v1/repos/alice/demo/branches/main"},
+	{"PATCH", "/api/v1/repos/alice/demo/branches/feature/x"},
+	{"DELETE", "/api/v1/repos/alice/demo/branches/missing"},
+	{"GET", "/api/v1/repos/alice/demo/protected-branches"},
+	{"POST", "/api/v1/repos/alice/demo/protected-branches"},
+	{"DELETE", "/api/v1/repos/alice/demo/protected-branches/999"},
+	{"GET", "/api/v1/repos/alice/demo/tags"},
+	{"GET", "/api/v1/repos/alice/demo/tags/v1"},
+	{"GET", "/api/v1/repos/alice/demo/tree"},
+	{"GET", "/api/v1/repos/alice/demo/tree/docs"},
+	{"GET", "/api/v1/repos/alice/demo/blobs/docs/README.md"},
+	{"GET", "/api/v1/repos/alice/demo/raw/docs/README.md"},
+	{"GET", "/api/v1/repos/alice/demo/commits"},
+	{"GET", "/api/v1/repos/alice/demo/commits/main"},
+	{"GET", "/api/v1/repos/alice/demo/commits/main/status"},
+	{"GET", "/api/v1/repos/alice/demo/statuses/main"},
+	{"POST", "/api/v1/repos/alice/demo/statuses/main"},
+	{"GET", "/api/v1/repos/alice/demo/compare/main...main"},
+
+	{"GET", "/api/v1/orgs"},
+	{"POST", "/api/v1/orgs"},
+	{"GET", "/api/v1/orgs/acme"},
+	{"GET", "/api/v1/orgs/acme/members"},
+	{"PUT", "/api/v1/orgs/acme/members/nobody"},
+	{"DELETE", "/api/v1/orgs/acme/members/nobody"},
+}
+
+// operationPattern matches the concrete paths of a documented path.
+// Parameters that may contain slashes match them.
+func operationPattern(path string) *regexp.Regexp {
+	var b strings.Builder
+	b.WriteString("^")
+	last := 0
+	for _, m := range pathParamPattern.FindAllStringSubmatchIndex(path, -1) {
+		b.WriteString(regexp.QuoteMeta(path[last:m[0]]))
+		switch path[m[2]:m[3]] {
+		case "path", "branch", "tag":
+			b.WriteString(`.+`)
+		default:
+			b.WriteString(`[^/]+`)
+		}
+		last = m[1]
+	}
+	b.WriteString(regexp.QuoteMeta(path[last:]) + "$")
+	return regexp.MustCompile(b.String())
+}
+
+// routed reports whether a response came from the handler of a route
+// rather than from the routing itself: the catch-all page route and the
+// API dispatchers answer unknown paths with a bare "Not found" or "Page
+// not found", and methods a route lacks with 405.
+func routed(rec *httptest.ResponseRecorder) bool {
+	if rec.Code == http.StatusMethodNotAllowed {
+		return false
+	}
+	if rec.Code != http.StatusNotFound {
+		return true
+	}
+	var body struct{ Message string }
+	json.Unmarshal(rec.Body.Bytes(), &body)
+	return body.Message != "Not found" && body.Message != "Page not found"
+}
+
+// newTestServer sets up a server with a database in a temporary
+// directory, the admin alice, the organization acme and the repositories
+// alice/demo and alice/gone. It returns the session cookie of alice.
+func newTestServer(t *testing.T) (*Server, *http.Cookie) {
+	t.Helper()
+	db, dir := newTestDB(t)
+	config.GlobalConfig.RepoPath = filepath.Join(dir, "repos")
+	config.GlobalConfig.DateFormat = "Jan 2, 2006 15:04:05"
+	// Errors carry their message, which tells routing errors apart
+	config.GlobalConfig.DevMode = true
+
+	users := models.NewUserService(db, []byte("test"))
+	s, err := NewServer(config.GlobalConfig.RepoPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetDB(db)
+	s.SetUserService(users)
+	s.HighlightCache = services.NewHighlightCache(1<<20, filepath.Join(dir, "cache"), 0)
+	if s.LFS, err = services.NewLocalLFSStore(filepath.Join(dir, "lfs")); err != nil {
+		t.Fatal(err)
+	}
+
+	alice, err := users.CreateUser("alice", "alice@example.com", "password", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, token, err := users.AuthenticateUser("alice", "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.orgs.Create("acme", "", alice.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"demo", "gone"} {
+		repo, err := s.CreateRepository("alice", name, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = repo.CommitFiles(models.CommitFilesOptions{
+			Branch:  "main",
+			Message: "Add README",
+			Author:  models.MergeSignature{Name: "Alice", Email: "alice@example.com"},
+			Files:   []models.FileChange{{Path: "docs/README.md", Content: []byte("# Demo\n")}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return s, &http.Cookie{Name: "auth_token", Value: token}
+}
+
+// TestOpenAPICoverage sends every request of apiRequests through the
+// routes and checks that each reaches a handler and is documented by
+// exactly one operation, and that every operation has a request.
+func TestOpenAPICoverage(t *testing.T) {
+	s, cookie := newTestServer(t)
+	s.SetupRoutes()
+
+	serve := func(method, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader("not json"))
+		req.Header.Set("Content-Type", "application/json")
+		req.AddCookie(cookie)
+		rec := httptest.NewRecorder()
+		http.DefaultServeMux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// The check must notice requests no route serves
+	for _, req := range []apiRequest{{"GET", "/api/v1/repos/alice/demo/nonsense"}, {"PUT", "/api/v1/repos"}, {"GET", "/api/nonsense"}} {
+		if rec := serve(req.method, req.path); routed(rec) {
+			t.Fatalf("%s %s: got %d, want a routing error", req.method, req.path, rec.Code)
+		}
+	}
+
+	covered := make([]bool, len(apiOperations))
+	for _, req := range apiRequests {
+		var matches []int
+		for i, op := range apiOperations {
+			if op.Method == req.method && operationPattern(op.Path).MatchString(req.path) {
+				matches = append(matches, i)
+				covered[i] = true
+			}
+		}
+		if len(matches) != 1 {
+			t.Errorf("%s %s matches %d documented operations, want 1", req.method, req.path, len(matches))
+		}
+
+		if rec := serve(req.method, req.path); !routed(rec) {
+			t.Errorf("%s %s is documented but not served: %d %s", req.method, req.path, rec.Code, strings.TrimSpace(rec.Body.String()))
+		}
+	}
+
+	for i, op := range apiOperations {
+		if !covered[i] {
+			t.Errorf("%s %s has no request in apiRequests", op.Method, op.Path)
+		}
+	}
+}
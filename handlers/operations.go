@@ -0,0 +1,126 @@
+//handlers/operations.go
+
+package handlers
+
+import (
+	"SimpleGit/models"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path/filepath"
+)
+
+// The operations in this file administer the server without a request, for
+// the command line. They act on the database and the repository directory
+// and return plain errors.
+
+// CreateRepository creates an empty repository for an existing user or
+// organization.
+//
+// Parameters:
+//   - ownerName: The user or organization owning the repository.
+//   - name: The repository name within the owner.
+//   - description: An optional description.
+func (s *Server) CreateRepository(ownerName, name, description string) (*models.Repository, error) {
+	owner, err := s.lookupOwner(ownerName)
+	if err != nil {
+		return nil, err
+	}
+	if owner == nil {
+		return nil, fmt.Errorf("no user or organization is named %s", ownerName)
+	}
+	repo, appErr := s.createRepository(owner.Name+"/"+name, description)
+	if appErr != nil {
+		return nil, plainError(appErr)
+	}
+	return repo, nil
+}
+
+// plainError turns an error meant for a response into one for a person at
+// a terminal: the message and its cause.
+func plainError(appErr *models.AppError) error {
+	if appErr.Err != nil {
+		return fmt.Errorf("%s: %w", appErr.Message, appErr.Err)
+	}
+	return errors.New(appErr.Message)
+}
+
+// DeleteRepository deletes a repository on behalf of username. Unless purge
+// is set it can be restored until the retention period is over.
+func (s *Server) DeleteRepository(name, username string, purge bool) error {
+	repo, ok := s.Repository(name)
+	if !ok {
+		return fmt.Errorf("repository %s not found", name)
+	}
+	deleted, err := s.deleteRepository(repo, username)
+	if err != nil {
+		return err
+	}
+	if purge {
+		return s.purgeRepository(deleted)
+	}
+	return nil
+}
+
+// ImportRepository fetches the branches and tags of a URL or a bundle file
+// into an empty repository and waits for it to finish. The import is
+// recorded like the ones started from the web interface.
+//
+// Parameters:
+//   - ctx: Cancels the import.
+//   - repo: The empty repository to import into.
+//   - source: A URL, or the path of a bundle file.
+//   - createdBy: Who the import is recorded for.
+//   - progress: Receives git's progress output.
+func (s *Server) ImportRepository(ctx context.Context, repo *models.Repository, source, createdBy string, progress io.Writer) error {
+	imp := &models.RepoImport{RepoName: repo.Name, CreatedBy: createdBy}
+	if u, err := url.Parse(source); err == nil && u.Scheme != "" && u.Host != "" {
+		label := *u
+		label.User = nil
+		imp.Kind, imp.Source = models.ImportFromURL, label.String()
+	} else {
+		if err := models.VerifyBundle(source); err != nil {
+			return err
+		}
+		if source, err = filepath.Abs(source); err != nil {
+			return err
+		}
+		imp.Kind, imp.Source = models.ImportFromBundle, filepath.Base(source)
+	}
+
+	if err := s.imports.Create(imp); err != nil {
+		return err
+	}
+	if err := s.imports.Start(imp); err != nil {
+		return err
+	}
+	if err := repo.Import(ctx, source, progress); err != nil {
+		s.imports.Finish(imp, models.ImportFailure, err.Error())
+		return err
+	}
+	return s.imports.Finish(imp, models.ImportSuccess, "")
+}
+
+// DeleteUser removes a user and everything that gives them access. The last
+// site admin cannot be removed.
+func (s *Server) DeleteUser(username string) error {
+	user, err := s.userService.GetUserByUsername(username)
+	if err != nil {
+		return fmt.Errorf("user %s not found", username)
+	}
+	if user.IsAdmin {
+		admins, err := s.userService.GetAdminCount()
+		if err != nil {
+			return err
+		}
+		if admins <= 1 {
+			return fmt.Errorf("%s is the only admin", username)
+		}
+	}
+	if appErr := s.deleteUser(user); appErr != nil {
+		return plainError(appErr)
+	}
+	return nil
+}
@@ -0,0 +1,431 @@
+//handlers/owners.go
+
+package handlers
+
+import (
+	"SimpleGit/config"
+	"SimpleGit/models"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// repoPathParts splits a URL path into its segments and joins the owner
+// and name of the repository that starts at segment i, so parts[i] is the
+// repository's full name and the segments after it keep their positions.
+func repoPathParts(path string, i int) []string {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	if len(parts) > i+1 {
+		parts[i] = parts[i] + "/" + parts[i+1]
+		parts = append(parts[:i+1], parts[i+2:]...)
+	}
+	return parts
+}
+
+// repoOwner is a user or organization that owns repositories.
+//
+// Parameters:
+//   - Name: The name repositories are stored under.
+//   - User: The user, for users.
+//   - Org: The organization, for organizations.
+type repoOwner struct {
+	Name string
+	User *models.User
+	Org  *models.Organization
+}
+
+// lookupOwner returns the user or organization with name, or nil if there
+// is none.
+func (s *Server) lookupOwner(name string) (*repoOwner, error) {
+	if user, err := s.userService.GetUserByUsername(name); err == nil {
+		return &repoOwner{Name: user.Username, User: user}, nil
+	}
+	org, err := s.orgs.Get(name)
+	if errors.Is(err, models.ErrOrganizationNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &repoOwner{Name: org.Name, Org: org}, nil
+}
+
+// canCreateIn reports whether user may create repositories owned by
+// owner: users in their own namespace and in organizations they are a
+// member of, admins everywhere.
+func (s *Server) canCreateIn(user *models.User, owner *repoOwner) bool {
+	switch {
+	case user == nil || owner == nil:
+		return false
+	case user.IsAdmin:
+		return true
+	case owner.User != nil:
+		return owner.User.ID == user.ID
+	}
+	role, err := s.orgs.Role(owner.Org.ID, user.ID)
+	return err == nil && role != ""
+}
+
+// canManageOrg reports whether user may change the members of org.
+func (s *Server) canManageOrg(user *models.User, org *models.Organization) bool {
+	if user == nil {
+		return false
+	}
+	if user.IsAdmin {
+		return true
+	}
+	role, err := s.orgs.Role(org.ID, user.ID)
+	return err == nil && role == models.OrgRoleOwner
+}
+
+// creatableOwners returns the names of the owners user may create
+// repositories for, the user first.
+func (s *Server) creatableOwners(user *models.User) ([]string, error) {
+	owners := []string{user.Username}
+
+	var orgs []models.Organization
+	var err error
+	if user.IsAdmin {
+		var users []models.User
+		if err := s.db.Order("username").Find(&users).Error; err != nil {
+			return nil, err
+		}
+		for _, other := range users {
+			if other.ID != user.ID {
+				owners = append(owners, other.Username)
+			}
+		}
+		orgs, err = s.orgs.List()
+	} else {
+		orgs, err = s.orgs.ListForUser(user.ID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, org := range orgs {
+		owners = append(owners, org.Name)
+	}
+	return owners, nil
+}
+
+// reposOf returns the repositories of an owner sorted by name.
+func (s *Server) reposOf(owner string) []*models.Repository {
+	var repos []*models.Repository
+	for _, repo := range s.Repositories() {
+		if repo.Owner == owner {
+			repos = append(repos, repo)
+		}
+	}
+	sort.Slice(repos, func(i, j int) bool { return repos[i].Name < repos[j].Name })
+	return repos
+}
+
+// handleOwner shows the repositories of a user or organization and, for
+// organizations, their members.
+//
+// Routes:
+//   - /repo/<owner>: The owner's page.
+func (s *Server) handleOwner(w http.ResponseWriter, r *http.Request, name string) {
+	owner, err := s.lookupOwner(name)
+	if err != nil {
+		models.HandleError(w, r, models.NewInternalError("Failed to load owner").WithError(err))
+		return
+	}
+	if owner == nil {
+		models.HandleError(w, r, models.NewNotFoundError("Repository not found").WithDetail(fmt.Sprintf("Repository: %s", name)))
+		return
+	}
+
+	user, _ := getUserFromContext(r)
+	data := map[string]interface{}{
+		"Owner":     owner,
+		"OwnerPage": true,
+		"Repos":     s.reposOf(owner.Name),
+		"CanCreate": s.canCreateIn(user, owner),
+	}
+
+	if owner.Org != nil {
+		members, err := s.orgs.Members(owner.Org.ID)
+		if err != nil {
+			models.HandleError(w, r, models.NewInternalError("Failed to load members").WithError(err))
+			return
+		}
+		data["Members"] = members
+		data["CanManage"] = s.canManageOrg(user, owner.Org)
+		data["Roles"] = []string{models.OrgRoleMember, models.OrgRoleOwner}
+	}
+
+	if err := s.tmpl.ExecuteTemplate(w, "owner.html", s.addCommonData(r, data)); err != nil {
+		models.HandleError(w, r, models.NewInternalError("Failed to render template").WithError(err))
+	}
+}
+
+// handleNewRepo creates a repository for the signed in user or one of the
+// user's organizations.
+//
+// Routes:
+//   - /repos/new?owner=: The form (GET) and creation (POST).
+func (s *Server) handleNewRepo(w http.ResponseWriter, r *http.Request) {
+	user, ok := getUserFromContext(r)
+	if !ok {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	owners, err := s.creatableOwners(user)
+	if err != nil {
+		models.HandleError(w, r, models.NewInternalError("Failed to load owners").WithError(err))
+		return
+	}
+
+	data := map[string]interface{}{
+		"Owners":      owners,
+		"Owner":       r.FormValue("owner"),
+		"Name":        r.FormValue("name"),
+		"Description": r.FormValue("description"),
+	}
+
+	if r.Method == http.MethodPost {
+		repo, appErr := s.createRepositoryAs(user, r.FormValue("owner"), strings.TrimSpace(r.FormValue("name")), strings.TrimSpace(r.FormValue("description")))
+		if appErr == nil {
+			http.Redirect(w, r, "/repo/"+repo.Name, http.StatusSeeOther)
+			return
+		}
+		data["Error"] = appErr.Message
+		w.WriteHeader(appErr.Code)
+	}
+
+	if err := s.tmpl.ExecuteTemplate(w, "repo_new.html", s.addCommonData(r, data)); err != nil {
+		models.HandleError(w, r, models.NewInternalError("Failed to render template").WithError(err))
+	}
+}
+
+// createRepositoryAs creates a repository for owner after checking that
+// user may.
+func (s *Server) createRepositoryAs(user *models.User, ownerName, name, description string) (*models.Repository, *models.AppError) {
+	owner, err := s.lookupOwner(ownerName)
+	if err != nil {
+		return nil, models.NewInternalError("Failed to load owner").WithError(err)
+	}
+	if owner == nil {
+		return nil, models.NewNotFoundError("No user or organization has this name").WithDetail(fmt.Sprintf("Owner: %s", ownerName)).ShowInProduction()
+	}
+	if !s.canCreateIn(user, owner) {
+		return nil, models.NewForbiddenError("You cannot create repositories for this owner").ShowInProduction()
+	}
+	return s.createRepository(owner.Name+"/"+name, description)
+}
+
+// handleOrgs creates organizations and manages their members.
+//
+// Routes:
+//   - /orgs/new: The form (GET) and creation (POST) of an organization.
+//   - /orgs/<org>/members: Add a member or change a member's role (POST).
+//   - /orgs/<org>/members/remove: Remove a member (POST).
+func (s *Server) handleOrgs(w http.ResponseWriter, r *http.Request) {
+	user, ok := getUserFromContext(r)
+	if !ok {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) == 2 && parts[1] == "new" {
+		s.handleNewOrg(w, r, user)
+		return
+	}
+	if len(parts) < 3 || parts[2] != "members" || len(parts) > 4 || (len(parts) == 4 && parts[3] != "remove") {
+		models.HandleError(w, r, models.NewNotFoundError("Page not found").WithDetail(fmt.Sprintf("Path: %s", r.URL.Path)))
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	org, err := s.orgs.Get(parts[1])
+	if err != nil {
+		models.HandleError(w, r, models.NewNotFoundError("Organization not found").WithDetail(fmt.Sprintf("Organization: %s", parts[1])))
+		return
+	}
+	if !s.canManageOrg(user, org) {
+		models.HandleError(w, r, models.NewForbiddenError("Only owners of the organization can change its members").ShowInProduction())
+		return
+	}
+
+	member, err := s.userService.GetUserByUsername(strings.TrimSpace(r.FormValue("username")))
+	if err != nil {
+		models.HandleError(w, r, models.NewNotFoundError("User not found").ShowInProduction())
+		return
+	}
+
+	if len(parts) == 4 {
+		err = s.orgs.RemoveMember(org.ID, member.ID)
+	} else {
+		err = s.orgs.SetMember(org.ID, member.ID, r.FormValue("role"))
+	}
+	if errors.Is(err, models.ErrLastOrgOwner) {
+		models.HandleError(w, r, models.NewConflictError(err.Error()).ShowInProduction())
+		return
+	}
+	if err != nil {
+		models.HandleError(w, r, models.NewBadRequestError(err.Error()).ShowInProduction())
+		return
+	}
+
+	http.Redirect(w, r, "/repo/"+org.Name, http.StatusSeeOther)
+}
+
+// handleNewOrg creates an organization owned by the signed in user.
+func (s *Server) handleNewOrg(w http.ResponseWriter, r *http.Request, user *models.User) {
+	data := map[string]interface{}{
+		"Name":        r.FormValue("name"),
+		"Description": r.FormValue("description"),
+	}
+
+	if r.Method == http.MethodPost {
+		org, err := s.orgs.Create(strings.TrimSpace(r.FormValue("name")), strings.TrimSpace(r.FormValue("description")), user.ID)
+		if err == nil {
+			http.Redirect(w, r, "/repo/"+org.Name, http.StatusSeeOther)
+			return
+		}
+		data["Error"] = err.Error()
+		w.WriteHeader(http.StatusBadRequest)
+	}
+
+	if err := s.tmpl.ExecuteTemplate(w, "org_new.html", s.addCommonData(r, data)); err != nil {
+		models.HandleError(w, r, models.NewInternalError("Failed to render template").WithError(err))
+	}
+}
+
+// renameRepoRecords moves everything stored about a repository under
+// oldName to newName.
+func (s *Server) renameRepoRecords(oldName, newName string) {
+	if err := s.pullRequests.RenameRepo(oldName, newName); err != nil {
+		log.Printf("Failed to rename pull requests of %s: %v", oldName, err)
+	}
+	if err := s.reviews.RenameRepo(oldName, newName); err != nil {
+		log.Printf("Failed to rename review threads of %s: %v", oldName, err)
+	}
+	if err := s.issues.RenameRepo(oldName, newName); err != nil {
+		log.Printf("Failed to rename issues of %s: %v", oldName, err)
+	}
+	if err := s.ciJobs.RenameRepo(oldName, newName); err != nil {
+		log.Printf("Failed to rename CI jobs of %s: %v", oldName, err)
+	}
+	if err := s.statuses.RenameRepo(oldName, newName); err != nil {
+		log.Printf("Failed to rename commit statuses of %s: %v", oldName, err)
+	}
+	if err := s.protections.RenameRepo(oldName, newName); err != nil {
+		log.Printf("Failed to rename branch protection of %s: %v", oldName, err)
+	}
+	if err := s.LFS.RenameRepo(oldName, newName); err != nil {
+		log.Printf("Failed to rename LFS objects of %s: %v", oldName, err)
+	}
+	if err := s.teams.RenameRepo(oldName, newName); err != nil {
+		log.Printf("Failed to rename team grants of %s: %v", oldName, err)
+	}
+	if err := s.permissions.RenameRepo(oldName, newName); err != nil {
+		log.Printf("Failed to rename access grants of %s: %v", oldName, err)
+	}
+	if err := s.imports.RenameRepo(oldName, newName); err != nil {
+		log.Printf("Failed to rename imports of %s: %v", oldName, err)
+	}
+	s.xref.Forget(oldName)
+	s.codeSearch.Forget(oldName)
+	s.commitSearch.Forget(oldName)
+}
+
+// MigrateFlatRepositories moves repositories stored by earlier versions as
+// <RepoPath>/<name> to <RepoPath>/<owner>/<name>.git and renames
+// everything stored about them to <owner>/<name>. The owner is the
+// configured migration owner or else the first admin; until one exists the
+// repositories are left alone.
+func (s *Server) MigrateFlatRepositories() error {
+	entries, err := os.ReadDir(s.RepoPath)
+	if err != nil {
+		return fmt.Errorf("failed to read repo directory: %w", err)
+	}
+
+	var flat []string
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(s.RepoPath, entry.Name(), "HEAD")); err == nil {
+			flat = append(flat, entry.Name())
+		}
+	}
+	if len(flat) == 0 {
+		return nil
+	}
+
+	owner := config.GlobalConfig.MigrationOwner
+	if owner == "" {
+		admin, err := s.userService.FirstAdmin()
+		if err != nil {
+			log.Printf("%d repositories use the flat layout; they are moved once an admin exists or SIMPLEGIT_MIGRATION_OWNER is set", len(flat))
+			return nil
+		}
+		owner = admin.Username
+	}
+	if !models.ValidOwnerName(owner) {
+		return fmt.Errorf("invalid migration owner %q", owner)
+	}
+
+	moved := make(map[string]string)
+	for _, name := range flat {
+		newName := owner + "/" + name
+		oldPath := filepath.Join(s.RepoPath, name)
+		newPath := models.RepoDir(s.RepoPath, newName)
+
+		if !models.ValidRepoName(name) {
+			log.Printf("Not moving repository %s: invalid name", name)
+			continue
+		}
+		if _, err := os.Stat(newPath); err == nil {
+			log.Printf("Not moving repository %s: %s already exists", name, newName)
+			continue
+		}
+
+		// Go through a temporary name: the owner directory may be the
+		// repository itself
+		tmpPath := filepath.Join(s.RepoPath, ".migrate-"+name)
+		if err := os.Rename(oldPath, tmpPath); err != nil {
+			return fmt.Errorf("failed to move repository %s: %w", name, err)
+		}
+		if err := os.MkdirAll(filepath.Dir(newPath), 0755); err != nil {
+			return fmt.Errorf("failed to move repository %s: %w", name, err)
+		}
+		if err := os.Rename(tmpPath, newPath); err != nil {
+			return fmt.Errorf("failed to move repository %s: %w", name, err)
+		}
+
+		s.renameRepoRecords(name, newName)
+		moved[name] = newName
+		log.Printf("Moved repository %s to %s", name, newName)
+	}
+
+	// Forks name their parent and borrow its objects by path
+	for name, newName := range moved {
+		repo := &models.Repository{Name: newName, Path: models.RepoDir(s.RepoPath, newName)}
+		repo.LoadParent()
+		parent, ok := moved[repo.Parent]
+		if !ok {
+			continue
+		}
+		if err := repo.MoveAlternate(filepath.Join(s.RepoPath, repo.Parent), models.RepoDir(s.RepoPath, parent)); err != nil {
+			log.Printf("Failed to relink fork %s to %s: %v", name, parent, err)
+		}
+		if err := repo.SetParent(parent); err != nil {
+			log.Printf("Failed to record parent of %s: %v", newName, err)
+		}
+	}
+
+	return nil
+}
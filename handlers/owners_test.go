@@ -0,0 +1,81 @@
+//handlers/owners_test.go
+
+package handlers
+
+import (
+	"SimpleGit/models"
+	"SimpleGit/services"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMigrateFlatRepositories(t *testing.T) {
+	db, dir := newTestDB(t)
+	users := models.NewUserService(db, []byte("test"))
+	if _, err := users.CreateUser("alice", "alice@example.com", "password", true); err != nil {
+		t.Fatal(err)
+	}
+
+	s := &Server{
+		RepoPath:     filepath.Join(dir, "repos"),
+		repos:        make(map[string]*models.Repository),
+		xref:         services.NewXrefService(),
+		codeSearch:   services.NewCodeSearchService(),
+		commitSearch: services.NewCommitSearchService(),
+	}
+	t.Cleanup(func() {
+		s.xref.Stop()
+		s.codeSearch.Stop()
+		s.commitSearch.Stop()
+	})
+	s.SetDB(db)
+	s.SetUserService(users)
+	var err error
+	if s.LFS, err = services.NewLocalLFSStore(filepath.Join(dir, "lfs")); err != nil {
+		t.Fatal(err)
+	}
+
+	// Flat repositories: demo, a fork of it, and one named like its owner
+	newBareRepo(t, filepath.Join(s.RepoPath, "demo"))
+	newBareRepo(t, filepath.Join(s.RepoPath, "alice"))
+	demo := &models.Repository{Name: "demo", Path: filepath.Join(s.RepoPath, "demo")}
+	fork, err := demo.Fork(filepath.Join(s.RepoPath, "fork"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fork.SetParent("demo"); err != nil {
+		t.Fatal(err)
+	}
+	tip := runGit(t, demo.Path, "rev-parse", "main")
+	if err := s.statuses.Set(&models.CommitStatus{RepoName: "demo", Commit: tip, Context: "ci", State: models.StatusSuccess}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.MigrateFlatRepositories(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"demo", "fork", "alice"} {
+		if _, err := os.Stat(filepath.Join(s.RepoPath, name, "HEAD")); err == nil {
+			t.Errorf("%s is still stored flat", name)
+		}
+		repo := &models.Repository{Name: "alice/" + name, Path: models.RepoDir(s.RepoPath, "alice/"+name)}
+		if _, err := repo.ResolveCommit("main"); err != nil {
+			t.Errorf("alice/%s: %v", name, err)
+		}
+	}
+
+	// The fork still borrows its objects and names its parent by full name
+	migrated := &models.Repository{Path: models.RepoDir(s.RepoPath, "alice/fork")}
+	if migrated.LoadParent(); migrated.Parent != "alice/demo" {
+		t.Errorf("parent of alice/fork = %q, want alice/demo", migrated.Parent)
+	}
+	if _, err := migrated.ResolveCommit(tip); err != nil {
+		t.Errorf("alice/fork cannot read %s: %v", tip, err)
+	}
+
+	if statuses, err := s.statuses.List("alice/demo", tip); err != nil || len(statuses) != 1 {
+		t.Errorf("statuses of alice/demo = %v, %v; want the status of demo", statuses, err)
+	}
+}
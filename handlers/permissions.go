@@ -0,0 +1,86 @@
+//handlers/permissions.go
+
+package handlers
+
+import (
+	"SimpleGit/models"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+)
+
+// permission returns the effective permission of user, nil for anonymous
+// requests, on a repository. Archived repositories are read-only, so
+// nobody gets more than read on them. Errors are logged and leave the
+// public read permission.
+func (s *Server) permission(user *models.User, repo *models.Repository) models.Permission {
+	perm := s.grantedPermission(user, repo)
+	if repo.Archived && perm > models.PermissionRead {
+		return models.PermissionRead
+	}
+	return perm
+}
+
+// grantedPermission returns the permission of user on a repository
+// whether or not it is archived.
+func (s *Server) grantedPermission(user *models.User, repo *models.Repository) models.Permission {
+	perm, err := s.permissions.Effective(user, repo.Name)
+	if err != nil {
+		log.Printf("Failed to resolve permission on %s: %v", repo.Name, err)
+	}
+	return perm
+}
+
+// canAdministerRepo reports whether user may change the settings of a
+// repository, including unarchiving it.
+func (s *Server) canAdministerRepo(user *models.User, repo *models.Repository) bool {
+	return user != nil && s.grantedPermission(user, repo).CanAdmin()
+}
+
+// deniedMessage explains why a user without needed on a repository is
+// turned away.
+func deniedMessage(repo *models.Repository, needed models.Permission) string {
+	if repo.Archived && needed > models.PermissionRead {
+		return fmt.Sprintf("%s is archived and read-only", repo.Name)
+	}
+	return fmt.Sprintf("You need %s access to %s to do this", needed, repo.Name)
+}
+
+// requirePermission reports whether the signed in user has at least
+// needed on a repository and writes an error if not.
+func (s *Server) requirePermission(w http.ResponseWriter, r *http.Request, repo *models.Repository, needed models.Permission) bool {
+	user, ok := getUserFromContext(r)
+	if !ok {
+		models.HandleError(w, r, models.NewUnauthorizedError("Authentication required").ShowInProduction())
+		return false
+	}
+	if s.permission(user, repo) < needed {
+		models.HandleError(w, r, models.NewForbiddenError(deniedMessage(repo, needed)).ShowInProduction())
+		return false
+	}
+	return true
+}
+
+// GitAuthorize checks that the user with username may read, or with write
+// push to, the repository at repoPath. The SSH server calls it before
+// running git-upload-pack or git-receive-pack.
+func (s *Server) GitAuthorize(username, repoPath string, write bool) error {
+	repo := s.repoByPath(repoPath)
+	if repo == nil {
+		return fmt.Errorf("repository not found")
+	}
+	user, err := s.userService.GetUserByUsername(username)
+	if err != nil {
+		return fmt.Errorf("user not found")
+	}
+
+	needed := models.PermissionRead
+	if write {
+		needed = models.PermissionWrite
+	}
+	if s.permission(user, repo) < needed {
+		return errors.New(deniedMessage(repo, needed))
+	}
+	return nil
+}
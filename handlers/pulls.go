@@ -0,0 +1,418 @@
+//handlers/pulls.go
+
+package handlers
+
+import (
+	"SimpleGit/models"
+	"SimpleGit/utils"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+// pullCommitLimit is the maximum number of commits listed on a pull request.
+const pullCommitLimit = 250
+
+// handlePulls routes the pull request pages of a repository.
+//
+// Routes:
+//   - /pulls/<repo>: The list of pull requests.
+//   - /pulls/<repo>/new: The form to compare branches and open a pull request.
+//   - /pulls/<repo>/<number>: A single pull request.
+//   - /pulls/<repo>/<number>/{merge,close,reopen}: Actions (POST).
+func (s *Server) handlePulls(w http.ResponseWriter, r *http.Request) {
+	parts := repoPathParts(r.URL.Path, 1)
+	if len(parts) < 2 {
+		models.HandleError(w, r, models.NewBadRequestError("Invalid repository path"))
+		return
+	}
+
+	repo, ok := s.Repository(parts[1])
+	if !ok {
+		models.HandleError(w, r, models.NewNotFoundError("Repository not found").WithDetail(fmt.Sprintf("Repository: %s", parts[1])))
+		return
+	}
+	if rejectArchived(w, r, repo) {
+		return
+	}
+
+	if len(parts) == 2 {
+		s.handlePullList(w, r, repo)
+		return
+	}
+
+	if parts[2] == "new" {
+		s.handleNewPull(w, r, repo)
+		return
+	}
+
+	number, err := strconv.Atoi(parts[2])
+	if err != nil {
+		models.HandleError(w, r, models.NewNotFoundError("Pull request not found"))
+		return
+	}
+
+	pr, err := s.pullRequests.Get(repo.Name, number)
+	if err != nil {
+		models.HandleError(w, r, models.NewNotFoundError("Pull request not found").WithError(err))
+		return
+	}
+
+	if len(parts) == 3 {
+		s.handleViewPull(w, r, repo, pr)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := getUserFromContext(r)
+	if !ok {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	switch parts[3] {
+	case "merge":
+		s.handleMergePull(w, r, repo, pr, user)
+	case "close", "reopen":
+		s.handleClosePull(w, r, repo, pr, user, parts[3] == "close")
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// handlePullList lists the pull requests of a repository filtered by the
+// state query parameter (open by default, closed, merged or all).
+func (s *Server) handlePullList(w http.ResponseWriter, r *http.Request, repo *models.Repository) {
+	state := r.URL.Query().Get("state")
+	if state == "" {
+		state = models.PullRequestOpen
+	}
+
+	filter := state
+	if filter == "all" {
+		filter = ""
+	}
+
+	prs, err := s.pullRequests.List(repo.Name, filter)
+	if err != nil {
+		models.HandleError(w, r, models.NewInternalError("Failed to list pull requests").WithError(err))
+		return
+	}
+
+	openCount, _ := s.pullRequests.CountOpen(repo.Name)
+
+	data := map[string]interface{}{
+		"Repo":         repo,
+		"PullRequests": prs,
+		"State":        state,
+		"OpenCount":    openCount,
+	}
+
+	if err := s.tmpl.ExecuteTemplate(w, "pulls.html", s.addCommonData(r, data)); err != nil {
+		models.HandleError(w, r, models.NewInternalError("Failed to render template").WithError(err))
+	}
+}
+
+// handleNewPull shows the comparison between two branches and, on POST,
+// opens a pull request for them.
+func (s *Server) handleNewPull(w http.ResponseWriter, r *http.Request, repo *models.Repository) {
+	user, ok := getUserFromContext(r)
+	if !ok {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		pr := &models.PullRequest{
+			RepoName:     repo.Name,
+			Title:        strings.TrimSpace(r.FormValue("title")),
+			Description:  r.FormValue("description"),
+			SourceBranch: r.FormValue("source"),
+			TargetBranch: r.FormValue("target"),
+			AuthorID:     user.ID,
+		}
+
+		if pr.Title == "" {
+			models.HandleError(w, r, models.NewBadRequestError("Title is required").ShowInProduction())
+			return
+		}
+		if pr.SourceBranch == pr.TargetBranch {
+			models.HandleError(w, r, models.NewBadRequestError("Source and target branch must differ").ShowInProduction())
+			return
+		}
+		for _, branch := range []string{pr.SourceBranch, pr.TargetBranch} {
+			if _, err := repo.ResolveBranch(branch); err != nil {
+				models.HandleError(w, r, models.NewBadRequestError(fmt.Sprintf("Branch %s does not exist", branch)).ShowInProduction())
+				return
+			}
+		}
+
+		if err := s.pullRequests.Create(pr); err != nil {
+			models.HandleError(w, r, models.NewInternalError("Failed to create pull request").WithError(err))
+			return
+		}
+
+		http.Redirect(w, r, fmt.Sprintf("/pulls/%s/%d", repo.Name, pr.Number), http.StatusSeeOther)
+		return
+	}
+
+	branches, err := repo.GetBranches()
+	if err != nil {
+		models.HandleError(w, r, models.NewGitError("Failed to get branches", err))
+		return
+	}
+
+	target := r.URL.Query().Get("target")
+	if target == "" {
+		target, _ = repo.DefaultBranch()
+	}
+	source := r.URL.Query().Get("source")
+
+	data := map[string]interface{}{
+		"Repo":         repo,
+		"Branches":     branches,
+		"TargetBranch": target,
+		"SourceBranch": source,
+		"Author":       user,
+	}
+
+	if source != "" && source != target {
+		base, errBase := repo.ResolveBranch(target)
+		head, errHead := repo.ResolveBranch(source)
+		if errBase != nil || errHead != nil {
+			data["CompareError"] = "Both branches must exist"
+		} else {
+			cmp, err := s.compareCommits(repo, base.String(), head.String())
+			if err != nil {
+				data["CompareError"] = err.Error()
+			} else {
+				data["Commits"] = cmp.Commits
+				data["Diffs"] = cmp.Diffs
+				if len(cmp.Commits) > 0 {
+					data["DefaultTitle"] = strings.TrimSpace(strings.SplitN(cmp.Commits[len(cmp.Commits)-1].Message, "\n", 2)[0])
+				}
+			}
+			if status, err := repo.CheckMerge(target, source); err == nil {
+				data["MergeStatus"] = status
+			}
+		}
+	}
+
+	if err := s.tmpl.ExecuteTemplate(w, "pull_new.html", s.addCommonData(r, data)); err != nil {
+		models.HandleError(w, r, models.NewInternalError("Failed to render template").WithError(err))
+	}
+}
+
+// comparison is the result of comparing two commits.
+//
+// Parameters:
+//   - Commits: The commits of head that are not in base.
+//   - Diffs: The diff from the merge base to head.
+//   - MergeBase: The commit the diff starts from.
+type comparison struct {
+	Commits   []models.CommitInfo
+	Diffs     []Diff
+	MergeBase string
+}
+
+// compareCommits compares head against base the way a pull request shows
+// it: the commits only in head and the diff from their merge base.
+func (s *Server) compareCommits(repo *models.Repository, base, head string) (*comparison, error) {
+	commits, err := repo.CommitsBetween(base, head, pullCommitLimit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list commits: %w", err)
+	}
+
+	gitRepo, err := repo.Git()
+	if err != nil {
+		return nil, err
+	}
+
+	mergeBase, err := repo.MergeBase(base, head)
+	if err != nil {
+		return nil, fmt.Errorf("the branches have no common history")
+	}
+
+	baseCommit, err := gitRepo.CommitObject(plumbing.NewHash(mergeBase))
+	if err != nil {
+		return nil, err
+	}
+	headCommit, err := gitRepo.CommitObject(plumbing.NewHash(head))
+	if err != nil {
+		return nil, err
+	}
+
+	baseTree, err := baseCommit.Tree()
+	if err != nil {
+		return nil, err
+	}
+	headTree, err := headCommit.Tree()
+	if err != nil {
+		return nil, err
+	}
+
+	diffs, err := s.treeDiffs(baseTree, headTree)
+	if err != nil {
+		return nil, err
+	}
+
+	return &comparison{Commits: commits, Diffs: diffs, MergeBase: mergeBase}, nil
+}
+
+// handleViewPull shows a pull request with its commits, its diff and, for
+// open pull requests, the merge box.
+func (s *Server) handleViewPull(w http.ResponseWriter, r *http.Request, repo *models.Repository, pr *models.PullRequest) {
+	tab := r.URL.Query().Get("tab")
+	if tab != "files" {
+		tab = "commits"
+	}
+
+	data := map[string]interface{}{
+		"Repo":        repo,
+		"PullRequest": pr,
+		"Tab":         tab,
+		"Strategies":  []string{models.MergeStrategyMerge, models.MergeStrategySquash, models.MergeStrategyFastForward},
+	}
+
+	review := &ReviewContext{Repo: repo.Name, Pull: pr.Number}
+	data["Review"] = review
+
+	if pr.Description != "" {
+		links := s.markdownLinks(repo, pr.SourceBranch, "")
+		links.Issue = s.issueLinker(repo)
+		data["Description"] = utils.MarkdownToHTML([]byte(pr.Description), links)
+	}
+
+	// Open pull requests follow the branches, others show what was recorded
+	base, head := pr.BaseCommit, pr.HeadCommit
+	if pr.IsOpen() {
+		status, err := repo.CheckMerge(pr.TargetBranch, pr.SourceBranch)
+		if err != nil {
+			models.HandleError(w, r, models.NewGitError("Failed to check mergeability", err))
+			return
+		}
+		data["MergeStatus"] = status
+		base, head = status.BaseCommit, status.HeadCommit
+	}
+
+	if base != "" && head != "" {
+		cmp, err := s.compareCommits(repo, base, head)
+		if err != nil {
+			data["CompareError"] = err.Error()
+		} else {
+			data["Commits"] = cmp.Commits
+			data["Diffs"] = cmp.Diffs
+			additions, deletions := 0, 0
+			for _, diff := range cmp.Diffs {
+				additions += diff.Additions
+				deletions += diff.Deletions
+			}
+			data["Additions"] = additions
+			data["Deletions"] = deletions
+
+			threads, err := s.reviews.ListForPull(repo.Name, pr.Number)
+			if err != nil {
+				log.Printf("Review: failed to list threads of %s#%d: %v", repo.Name, pr.Number, err)
+			}
+			review.Unplaced = attachThreads(cmp.Diffs, threads, cmp.MergeBase, head)
+			data["ThreadCount"] = len(threads)
+		}
+	}
+
+	if user, ok := getUserFromContext(r); ok {
+		data["CanClose"] = user.ID == pr.AuthorID || s.permission(user, repo).CanWrite()
+		review.CanComment = pr.IsOpen()
+	}
+
+	if err := s.tmpl.ExecuteTemplate(w, "pull.html", s.addCommonData(r, data)); err != nil {
+		models.HandleError(w, r, models.NewInternalError("Failed to render template").WithError(err))
+	}
+}
+
+// handleMergePull merges an open pull request with the requested strategy.
+// Merging needs write access to the repository.
+func (s *Server) handleMergePull(w http.ResponseWriter, r *http.Request, repo *models.Repository, pr *models.PullRequest, user *models.User) {
+	if !s.requirePermission(w, r, repo, models.PermissionWrite) {
+		return
+	}
+	if !pr.IsOpen() {
+		models.HandleError(w, r, models.NewBadRequestError("Pull request is not open").ShowInProduction())
+		return
+	}
+
+	strategy := r.FormValue("strategy")
+	if strategy == "" {
+		strategy = models.MergeStrategyMerge
+	}
+
+	message := strings.TrimSpace(r.FormValue("message"))
+	if message == "" {
+		message = fmt.Sprintf("Merge pull request #%d from %s\n\n%s", pr.Number, pr.SourceBranch, pr.Title)
+		if strategy == models.MergeStrategySquash {
+			message = fmt.Sprintf("%s (#%d)", pr.Title, pr.Number)
+		}
+	}
+
+	// The commits merged are those of the status Merge acted on; the
+	// branches may have moved since the page was shown
+	newTip, status, err := repo.Merge(pr.TargetBranch, pr.SourceBranch, strategy, message, models.MergeSignature{
+		Name:  user.Username,
+		Email: user.Email,
+	})
+	if err != nil {
+		models.HandleError(w, r, models.NewBadRequestError(fmt.Sprintf("Failed to merge pull request: %v", err)).ShowInProduction())
+		return
+	}
+
+	if err := s.pullRequests.MarkMerged(pr, user.ID, status.BaseCommit, status.HeadCommit, newTip); err != nil {
+		models.HandleError(w, r, models.NewInternalError("Failed to update pull request").WithError(err))
+		return
+	}
+
+	s.HandlePush(repo.Path, []models.RefUpdate{{
+		Name: plumbing.NewBranchReferenceName(pr.TargetBranch),
+		Old:  plumbing.NewHash(status.BaseCommit),
+		New:  plumbing.NewHash(newTip),
+	}})
+
+	http.Redirect(w, r, fmt.Sprintf("/pulls/%s/%d", repo.Name, pr.Number), http.StatusSeeOther)
+}
+
+// handleClosePull closes or reopens a pull request. Only its author and
+// users who can write to the repository may do so.
+func (s *Server) handleClosePull(w http.ResponseWriter, r *http.Request, repo *models.Repository, pr *models.PullRequest, user *models.User, closed bool) {
+	if user.ID != pr.AuthorID && !s.permission(user, repo).CanWrite() {
+		models.HandleError(w, r, models.NewForbiddenError("Only the author can close this pull request").ShowInProduction())
+		return
+	}
+
+	if pr.State == models.PullRequestMerged || pr.IsOpen() != closed {
+		http.Redirect(w, r, fmt.Sprintf("/pulls/%s/%d", repo.Name, pr.Number), http.StatusSeeOther)
+		return
+	}
+
+	var base, head string
+	if closed {
+		if hash, err := repo.ResolveBranch(pr.TargetBranch); err == nil {
+			base = hash.String()
+		}
+		if hash, err := repo.ResolveBranch(pr.SourceBranch); err == nil {
+			head = hash.String()
+		}
+	}
+
+	if err := s.pullRequests.SetClosed(pr, closed, base, head); err != nil {
+		models.HandleError(w, r, models.NewInternalError("Failed to update pull request").WithError(err))
+		return
+	}
+
+	http.Redirect(w, r, fmt.Sprintf("/pulls/%s/%d", repo.Name, pr.Number), http.StatusSeeOther)
+}
@@ -0,0 +1,52 @@
+//handlers/push.go
+
+package handlers
+
+import (
+	"SimpleGit/models"
+	"log"
+	"path/filepath"
+)
+
+// HandlePush updates everything derived from repository contents after a
+// push. It is called from both the HTTP and the SSH receive paths.
+//
+// Parameters:
+//   - repoPath: The path of the repository that was pushed to.
+//   - updates: The references the push changed.
+func (s *Server) HandlePush(repoPath string, updates []models.RefUpdate) {
+	repo := s.repoByPath(repoPath)
+	if repo == nil {
+		log.Printf("Push to unknown repository path %s", repoPath)
+		return
+	}
+
+	log.Printf("Push to %s updated %d refs", repo.Name, len(updates))
+	if len(updates) == 0 {
+		return
+	}
+
+	if err := s.xref.Refresh(repo.Name, repo.Path); err != nil {
+		log.Printf("Xref: failed to refresh %s: %v", repo.Name, err)
+	}
+	s.codeSearch.Update(repo.Name, repo.Path)
+	s.commitSearch.Update(repo.Name, repo.Path)
+	s.reanchorReviews(repo, updates)
+	s.closeIssuesFromPush(repo, updates)
+	s.triggerCI(repo, updates)
+}
+
+// repoByPath finds a repository by its path on disk.
+func (s *Server) repoByPath(repoPath string) *models.Repository {
+	abs, err := filepath.Abs(repoPath)
+	if err != nil {
+		return nil
+	}
+
+	for _, repo := range s.Repositories() {
+		if repoAbs, err := filepath.Abs(repo.Path); err == nil && repoAbs == abs {
+			return repo
+		}
+	}
+	return nil
+}
@@ -0,0 +1,343 @@
+//handlers/review.go
+
+package handlers
+
+import (
+	"SimpleGit/models"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+// ReviewContext carries what the diff template needs to show and start
+// review threads.
+//
+// Parameters:
+//   - Repo: The repository name.
+//   - Commit: The commit whose diff is shown, for commit pages.
+//   - Pull: The pull request number, for pull request pages.
+//   - CanComment: Whether the current user may start threads and reply.
+//   - Unplaced: Threads that are outdated or whose line is not in the diff.
+type ReviewContext struct {
+	Repo       string
+	Commit     string
+	Pull       int
+	CanComment bool
+	Unplaced   []*models.ReviewThread
+}
+
+// attachThreads puts each thread on the diff row it is anchored to and
+// returns the threads that could not be placed.
+//
+// Parameters:
+//   - diffs: The rendered diff.
+//   - threads: The threads to place.
+//   - oldCommit: The commit shown on the old side of the diff.
+//   - newCommit: The commit shown on the new side of the diff.
+func attachThreads(diffs []Diff, threads []models.ReviewThread, oldCommit, newCommit string) []*models.ReviewThread {
+	var unplaced []*models.ReviewThread
+
+	for i := range threads {
+		thread := &threads[i]
+		if !placeThread(diffs, thread, oldCommit, newCommit) {
+			unplaced = append(unplaced, thread)
+		}
+	}
+
+	return unplaced
+}
+
+// placeThread attaches a thread to its row and reports whether it found one.
+func placeThread(diffs []Diff, thread *models.ReviewThread, oldCommit, newCommit string) bool {
+	if thread.Outdated {
+		return false
+	}
+
+	anchor := newCommit
+	if thread.Side == models.ReviewSideOld {
+		anchor = oldCommit
+	}
+	if thread.AnchorCommit != anchor {
+		return false
+	}
+
+	for d := range diffs {
+		if diffs[d].Path != thread.Path {
+			continue
+		}
+		for p := range diffs[d].Patches {
+			patch := &diffs[d].Patches[p]
+			side, line := patchAnchor(patch)
+			if side == thread.Side && line == thread.Line {
+				patch.Threads = append(patch.Threads, thread)
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// patchAnchor returns the side and line a comment on a diff row refers to.
+// Deleted lines belong to the old side, everything else to the new side.
+func patchAnchor(patch *PatchInfo) (string, int) {
+	switch patch.Type {
+	case "deletion":
+		return models.ReviewSideOld, patch.OldNum
+	case "addition", "context":
+		return models.ReviewSideNew, patch.NewNum
+	}
+	return "", 0
+}
+
+// reviewURL returns the page a thread is shown on.
+func reviewURL(thread *models.ReviewThread) string {
+	if thread.PullNumber > 0 {
+		return fmt.Sprintf("/pulls/%s/%d?tab=files#thread-%s", thread.RepoName, thread.PullNumber, thread.ID)
+	}
+	return fmt.Sprintf("/commit/%s/%s#thread-%s", thread.RepoName, thread.CommitHash, thread.ID)
+}
+
+// handleReview handles review thread actions. All routes take POST and
+// need a logged in user.
+//
+// Routes:
+//   - /review/<repo>/threads: Start a thread (commit or pull, path, side,
+//     line and body form values).
+//   - /review/<repo>/threads/<id>/reply: Reply to a thread (body).
+//   - /review/<repo>/threads/<id>/{resolve,unresolve}: Change resolution.
+func (s *Server) handleReview(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := getUserFromContext(r)
+	if !ok {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	parts := repoPathParts(r.URL.Path, 1)
+	if len(parts) < 3 || parts[2] != "threads" {
+		http.NotFound(w, r)
+		return
+	}
+
+	repo, ok := s.Repository(parts[1])
+	if !ok {
+		models.HandleError(w, r, models.NewNotFoundError("Repository not found").WithDetail(fmt.Sprintf("Repository: %s", parts[1])))
+		return
+	}
+	if rejectArchived(w, r, repo) {
+		return
+	}
+
+	if len(parts) == 3 {
+		s.handleCreateThread(w, r, repo, user)
+		return
+	}
+	if len(parts) != 5 {
+		http.NotFound(w, r)
+		return
+	}
+
+	thread, err := s.reviews.GetThread(repo.Name, parts[3])
+	if err != nil {
+		models.HandleError(w, r, models.NewNotFoundError("Review thread not found").WithError(err))
+		return
+	}
+
+	switch parts[4] {
+	case "reply":
+		body := strings.TrimSpace(r.FormValue("body"))
+		if body == "" {
+			models.HandleError(w, r, models.NewBadRequestError("Comment cannot be empty").ShowInProduction())
+			return
+		}
+		if err := s.reviews.Reply(thread, user.ID, body); err != nil {
+			models.HandleError(w, r, models.NewInternalError("Failed to add comment").WithError(err))
+			return
+		}
+	case "resolve", "unresolve":
+		if err := s.reviews.SetResolved(thread, user.ID, parts[4] == "resolve"); err != nil {
+			models.HandleError(w, r, models.NewInternalError("Failed to update review thread").WithError(err))
+			return
+		}
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	http.Redirect(w, r, reviewURL(thread), http.StatusSeeOther)
+}
+
+// handleCreateThread starts a review thread on a line of a commit or pull
+// request diff. The anchor commit is worked out on the server from the
+// side, so the thread follows the diff the user was looking at.
+func (s *Server) handleCreateThread(w http.ResponseWriter, r *http.Request, repo *models.Repository, user *models.User) {
+	thread := &models.ReviewThread{
+		RepoName: repo.Name,
+		Path:     r.FormValue("path"),
+		Side:     r.FormValue("side"),
+	}
+
+	line, err := strconv.Atoi(r.FormValue("line"))
+	if err != nil || line < 1 {
+		models.HandleError(w, r, models.NewBadRequestError("Invalid line").ShowInProduction())
+		return
+	}
+	thread.Line = line
+
+	if thread.Path == "" || (thread.Side != models.ReviewSideOld && thread.Side != models.ReviewSideNew) {
+		models.HandleError(w, r, models.NewBadRequestError("Invalid path or side").ShowInProduction())
+		return
+	}
+
+	body := strings.TrimSpace(r.FormValue("body"))
+	if body == "" {
+		models.HandleError(w, r, models.NewBadRequestError("Comment cannot be empty").ShowInProduction())
+		return
+	}
+
+	var oldCommit, newCommit string
+	if number, err := strconv.Atoi(r.FormValue("pull")); err == nil && number > 0 {
+		pr, err := s.pullRequests.Get(repo.Name, number)
+		if err != nil {
+			models.HandleError(w, r, models.NewNotFoundError("Pull request not found").WithError(err))
+			return
+		}
+		if !pr.IsOpen() {
+			models.HandleError(w, r, models.NewBadRequestError("Pull request is not open").ShowInProduction())
+			return
+		}
+
+		status, err := repo.CheckMerge(pr.TargetBranch, pr.SourceBranch)
+		if err != nil || status.MergeBase == "" {
+			models.HandleError(w, r, models.NewGitError("Failed to compare branches", err))
+			return
+		}
+		thread.PullNumber = pr.Number
+		oldCommit, newCommit = status.MergeBase, status.HeadCommit
+	} else {
+		gitRepo, err := repo.Git()
+		if err != nil {
+			models.HandleError(w, r, models.NewGitError("Failed to open repository", err))
+			return
+		}
+
+		commit, err := gitRepo.CommitObject(plumbing.NewHash(r.FormValue("commit")))
+		if err != nil {
+			models.HandleError(w, r, models.NewNotFoundError("Commit not found"))
+			return
+		}
+		thread.CommitHash = commit.Hash.String()
+		newCommit = commit.Hash.String()
+		if commit.NumParents() > 0 {
+			oldCommit = commit.ParentHashes[0].String()
+		}
+	}
+
+	thread.AnchorCommit = newCommit
+	if thread.Side == models.ReviewSideOld {
+		thread.AnchorCommit = oldCommit
+	}
+	if thread.AnchorCommit == "" {
+		models.HandleError(w, r, models.NewBadRequestError("There is no old side to comment on").ShowInProduction())
+		return
+	}
+
+	if err := s.reviews.CreateThread(thread, user.ID, body); err != nil {
+		models.HandleError(w, r, models.NewInternalError("Failed to create review thread").WithError(err))
+		return
+	}
+
+	http.Redirect(w, r, reviewURL(thread), http.StatusSeeOther)
+}
+
+// reanchorReviews moves the review threads of open pull requests affected
+// by a push to the new head and merge base. Threads whose line changed
+// are marked outdated.
+//
+// Parameters:
+//   - repo: The repository that was pushed to.
+//   - updates: The references the push changed.
+func (s *Server) reanchorReviews(repo *models.Repository, updates []models.RefUpdate) {
+	seen := make(map[int]bool)
+
+	for _, update := range updates {
+		if !update.IsBranch() {
+			continue
+		}
+
+		prs, err := s.pullRequests.ListOpenForBranch(repo.Name, update.Name.Short())
+		if err != nil {
+			log.Printf("Review: failed to list pull requests of %s: %v", repo.Name, err)
+			continue
+		}
+
+		for i := range prs {
+			if seen[prs[i].Number] {
+				continue
+			}
+			seen[prs[i].Number] = true
+			if err := s.reanchorPull(repo, &prs[i]); err != nil {
+				log.Printf("Review: failed to re-anchor %s#%d: %v", repo.Name, prs[i].Number, err)
+			}
+		}
+	}
+}
+
+// reanchorPull re-anchors the live threads of one pull request.
+func (s *Server) reanchorPull(repo *models.Repository, pr *models.PullRequest) error {
+	threads, err := s.reviews.ListForPull(repo.Name, pr.Number)
+	if err != nil || len(threads) == 0 {
+		return err
+	}
+
+	base, err := repo.ResolveBranch(pr.TargetBranch)
+	if err != nil {
+		return nil
+	}
+	head, err := repo.ResolveBranch(pr.SourceBranch)
+	if err != nil {
+		return nil
+	}
+	mergeBase, err := repo.MergeBase(base.String(), head.String())
+	if err != nil {
+		return err
+	}
+
+	for i := range threads {
+		thread := &threads[i]
+		if thread.Outdated {
+			continue
+		}
+
+		target := head.String()
+		if thread.Side == models.ReviewSideOld {
+			target = mergeBase
+		}
+		if thread.AnchorCommit == target {
+			continue
+		}
+
+		line, ok, err := repo.MapLine(thread.Path, thread.AnchorCommit, target, thread.Line)
+		if err != nil {
+			return err
+		}
+		if ok {
+			err = s.reviews.Reanchor(thread, line, target)
+		} else {
+			err = s.reviews.MarkOutdated(thread)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
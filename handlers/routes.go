@@ -40,10 +40,24 @@ func (s *Server) SetupRoutes() {
 
 	// HTML routes
 	http.HandleFunc("/", s.addUserData(s.handleIndex))
-	http.HandleFunc("/repo/", s.addUserData(s.handleRepo))
-	http.HandleFunc("/file/", s.addUserData(s.handleViewFile))
-	http.HandleFunc("/commit/", s.addUserData(s.handleViewCommit))
-	http.HandleFunc("/raw/", s.addUserData(s.handleRawFile))
+	http.HandleFunc("/repo/", s.addUserData(s.followRenames(1, s.handleRepo)))
+	http.HandleFunc("/file/", s.addUserData(s.followRenames(1, s.handleViewFile)))
+	http.HandleFunc("/commit/", s.addUserData(s.followRenames(1, s.handleViewCommit)))
+	http.HandleFunc("/raw/", s.addUserData(s.followRenames(1, s.handleRawFile)))
+	http.HandleFunc("/search", s.addUserData(s.handleSearch))
+	http.HandleFunc("/pulls/", s.addUserData(s.followRenames(1, s.handlePulls)))
+	http.HandleFunc("/review/", s.addUserData(s.followRenames(1, s.handleReview)))
+	http.HandleFunc("/issues/", s.addUserData(s.followRenames(1, s.handleIssues)))
+	http.HandleFunc("/ci/", s.addUserData(s.followRenames(1, s.handleCI)))
+	http.HandleFunc("/branches/", s.addUserData(s.followRenames(1, s.handleBranches)))
+	http.HandleFunc("/edit/", s.addUserData(s.followRenames(1, s.handleEditFile)))
+	http.HandleFunc("/new/", s.addUserData(s.followRenames(1, s.handleNewFile)))
+	http.HandleFunc("/upload/", s.addUserData(s.followRenames(1, s.handleUploadFiles)))
+	http.HandleFunc("/fork/", s.addUserData(s.followRenames(1, s.handleFork)))
+	http.HandleFunc("/compare/", s.addUserData(s.followRenames(1, s.handleCompareUpstream)))
+	http.HandleFunc("/repos/new", s.addUserData(s.handleNewRepo))
+	http.HandleFunc("/orgs/", s.addUserData(s.handleOrgs))
+	http.HandleFunc("/settings/", s.addUserData(s.followRenames(1, s.handleRepoSettings)))
 
 	//Auth Route
 	http.HandleFunc("/login", s.handleLogin)
@@ -51,10 +65,21 @@ func (s *Server) SetupRoutes() {
 	http.HandleFunc("/profile", s.requireAuth(s.handleProfile))
 
 	// API routes
-	http.HandleFunc("/api/repos", s.requireAuth(s.handleListRepos))
-	http.HandleFunc("/api/ssh-keys", s.requireAuth(s.handleListSSHKeys))
-	http.HandleFunc("/api/ssh-keys/add", s.requireAuth(s.handleAddSSHKey))
-	http.HandleFunc("/api/ssh-keys/", s.requireAuth(s.handleDeleteSSHKey))
+	s.handleAPI("/api/repos", s.requireAuth(s.handleListRepos))
+	s.handleAPI("/api/ssh-keys", s.requireAuth(s.handleListSSHKeys))
+	s.handleAPI("/api/ssh-keys/add", s.requireAuth(s.handleAddSSHKey))
+	s.handleAPI("/api/ssh-keys/", s.requireAuth(s.handleDeleteSSHKey))
+	s.handleAPI("/api/tokens", s.requireAuth(s.handleListAccessTokens))
+	s.handleAPI("/api/tokens/add", s.requireAuth(s.handleCreateAccessToken))
+	s.handleAPI("/api/tokens/", s.requireAuth(s.handleDeleteAccessToken))
+	s.handleAPI("/api/repos/", s.addUserData(s.followRenames(2, s.handleStatusAPI)))
+	s.handleAPI("/api/v1/", s.addAPIUserData(s.followRenames(3, s.handleAPIv1)))
+	s.handleAPI("/api/search", s.addUserData(s.handleSearchAPI))
+	s.handleAPI("/api/search/commits", s.addUserData(s.handleCommitSearchAPI))
+	s.handleAPI("/api/xref/", s.addUserData(s.handleXref))
+	s.handleAPI("/api/metrics/highlight-cache", s.requireAdmin(s.handleHighlightCacheStats))
+	s.handleAPI("/api/metrics/code-search", s.requireAdmin(s.handleCodeSearchStats))
+	s.handleAPI("/api/openapi.json", s.handleOpenAPI)
 
 	// Admin routes
 	http.HandleFunc("/setup-admin", s.handleAdminSetup)
@@ -64,5 +89,9 @@ func (s *Server) SetupRoutes() {
 	http.HandleFunc("/admin/users/create", s.requireAdmin(s.handleCreateUser))
 	http.HandleFunc("/admin/repos/create", s.requireAdmin(s.handleCreateRepo))
 	http.HandleFunc("/admin/users/", s.requireAdmin(s.handleDeleteUser))
-	http.HandleFunc("/admin/repos/", s.requireAdmin(s.handleDeleteRepo))
+	http.HandleFunc("/admin/repos/", s.requireAdmin(s.handleAdminRepo))
+	http.HandleFunc("/admin/orgs", s.requireAdmin(s.handleAdminOrgs))
+	http.HandleFunc("/admin/orgs/", s.requireAdmin(s.handleAdminOrg))
+	http.HandleFunc("/admin/deleted/", s.requireAdmin(s.handleAdminDeletedRepo))
+
 }
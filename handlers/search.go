@@ -0,0 +1,217 @@
+//handlers/search.go
+
+package handlers
+
+import (
+	"SimpleGit/models"
+	"SimpleGit/services"
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// commitSearchPageSize is the number of commits shown per search page.
+const commitSearchPageSize = 25
+
+// RefreshSearch schedules code and commit search indexing of every
+// repository.
+func (s *Server) RefreshSearch() {
+	for name, repo := range s.Repositories() {
+		s.codeSearch.Update(name, repo.Path)
+		s.commitSearch.Update(name, repo.Path)
+	}
+}
+
+// parseCodeSearchQuery builds a query from the request. The q parameter
+// may contain repo:<name> and path:<filter> terms in addition to the
+// pattern; the repo and path parameters do the same.
+func parseCodeSearchQuery(r *http.Request) (services.CodeSearchQuery, string) {
+	query := r.URL.Query()
+	q := services.CodeSearchQuery{
+		Regex:         query.Get("regex") == "1" || query.Get("regex") == "true",
+		CaseSensitive: query.Get("case") == "1" || query.Get("case") == "true",
+		Path:          query.Get("path"),
+		Context:       -1,
+	}
+
+	if repo := query.Get("repo"); repo != "" {
+		q.Repos = append(q.Repos, repo)
+	}
+	if n, err := strconv.Atoi(query.Get("context")); err == nil {
+		q.Context = min(n, 10)
+	}
+	if n, err := strconv.Atoi(query.Get("limit")); err == nil {
+		q.Limit = n
+	}
+
+	raw := strings.TrimSpace(query.Get("q"))
+	var terms []string
+	for _, field := range strings.Fields(raw) {
+		switch {
+		case strings.HasPrefix(field, "repo:") && len(field) > len("repo:"):
+			q.Repos = append(q.Repos, strings.TrimPrefix(field, "repo:"))
+		case strings.HasPrefix(field, "path:") && len(field) > len("path:"):
+			q.Path = strings.TrimPrefix(field, "path:")
+		default:
+			terms = append(terms, field)
+		}
+	}
+	q.Pattern = strings.Join(terms, " ")
+
+	return q, raw
+}
+
+// parseCommitSearchQuery builds a commit query from the request. Besides
+// repo: and path:, the q parameter accepts author:<name or email>.
+func parseCommitSearchQuery(r *http.Request) services.CommitSearchQuery {
+	query := r.URL.Query()
+	q := services.CommitSearchQuery{
+		Author: query.Get("author"),
+		Path:   query.Get("path"),
+	}
+
+	if repo := query.Get("repo"); repo != "" {
+		q.Repos = append(q.Repos, repo)
+	}
+	if n, err := strconv.Atoi(query.Get("offset")); err == nil {
+		q.Offset = n
+	}
+	if n, err := strconv.Atoi(query.Get("limit")); err == nil {
+		q.Limit = n
+	}
+
+	for _, field := range strings.Fields(query.Get("q")) {
+		switch {
+		case strings.HasPrefix(field, "repo:") && len(field) > len("repo:"):
+			q.Repos = append(q.Repos, strings.TrimPrefix(field, "repo:"))
+		case strings.HasPrefix(field, "path:") && len(field) > len("path:"):
+			q.Path = strings.TrimPrefix(field, "path:")
+		case strings.HasPrefix(field, "author:") && len(field) > len("author:"):
+			q.Author = strings.TrimPrefix(field, "author:")
+		default:
+			q.Terms = append(q.Terms, field)
+		}
+	}
+
+	return q
+}
+
+// markRanges escapes a result line and wraps the matched ranges in <mark>.
+func markRanges(line services.CodeSearchLine) template.HTML {
+	var b strings.Builder
+	pos := 0
+	for _, rng := range line.Ranges {
+		from, to := min(rng[0], len(line.Text)), min(rng[1], len(line.Text))
+		if from < pos {
+			continue
+		}
+		b.WriteString(template.HTMLEscapeString(line.Text[pos:from]))
+		b.WriteString("<mark>")
+		b.WriteString(template.HTMLEscapeString(line.Text[from:to]))
+		b.WriteString("</mark>")
+		pos = to
+	}
+	b.WriteString(template.HTMLEscapeString(line.Text[pos:]))
+	return template.HTML(b.String())
+}
+
+// handleSearch renders the search page for code or, with type=commits,
+// for commit history.
+//
+// Query parameters:
+//   - q: The search terms, optionally with repo:, path: and author: filters.
+//   - type: "code" (default) or "commits".
+//   - regex: Treat the pattern as a regular expression.
+//   - case: Match case sensitively.
+//   - repo: Restrict the search to one repository.
+//   - path: Restrict the search to matching file paths.
+//   - offset: The first commit result to show.
+func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
+	q, raw := parseCodeSearchQuery(r)
+
+	data := map[string]interface{}{
+		"SearchPage": true,
+		"SearchType": "code",
+		"Query":      raw,
+		"Regex":      q.Regex,
+		"Case":       q.CaseSensitive,
+		"ScopeRepo":  r.URL.Query().Get("repo"),
+		"PathFilter": r.URL.Query().Get("path"),
+		"Indexed":    len(s.codeSearch.Status()),
+	}
+
+	if r.URL.Query().Get("type") == "commits" {
+		data["SearchType"] = "commits"
+		if raw != "" || r.URL.Query().Get("path") != "" {
+			cq := parseCommitSearchQuery(r)
+			cq.Limit = commitSearchPageSize
+			results, err := s.commitSearch.Search(cq)
+			if err != nil {
+				data["Error"] = err.Error()
+			} else {
+				data["Commits"] = results
+				if results.Offset > 0 {
+					data["HasPrev"] = true
+					data["PrevOffset"] = max(results.Offset-results.Limit, 0)
+				}
+				if results.Offset+results.Limit < results.Total {
+					data["NextOffset"] = results.Offset + results.Limit
+				}
+			}
+		}
+	} else if q.Pattern != "" {
+		results, err := s.codeSearch.Search(q)
+		if err != nil {
+			data["Error"] = err.Error()
+		} else {
+			data["Results"] = results
+		}
+	}
+
+	if err := s.tmpl.ExecuteTemplate(w, "search.html", s.addCommonData(r, data)); err != nil {
+		models.HandleError(w, r, models.NewInternalError("Failed to render template").WithError(err))
+	}
+}
+
+// handleSearchAPI runs a code search and returns the results as JSON.
+// It takes the same parameters as handleSearch plus context and limit.
+func (s *Server) handleSearchAPI(w http.ResponseWriter, r *http.Request) {
+	q, _ := parseCodeSearchQuery(r)
+	if q.Pattern == "" {
+		models.HandleError(w, r, models.NewBadRequestError("Missing search pattern").ShowInProduction())
+		return
+	}
+
+	results, err := s.codeSearch.Search(q)
+	if err != nil {
+		models.HandleError(w, r, models.NewBadRequestError(err.Error()).ShowInProduction())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(results); err != nil {
+		models.HandleError(w, r, models.NewInternalError("Failed to encode response").WithError(err))
+	}
+}
+
+// handleCommitSearchAPI searches commit messages and metadata and returns
+// a page of results as JSON.
+//
+// Query parameters:
+//   - q: The search terms, optionally with repo:, path: and author: filters.
+//   - author, path, repo: The same filters as separate parameters.
+//   - offset, limit: The page of results to return.
+func (s *Server) handleCommitSearchAPI(w http.ResponseWriter, r *http.Request) {
+	results, err := s.commitSearch.Search(parseCommitSearchQuery(r))
+	if err != nil {
+		models.HandleError(w, r, models.NewBadRequestError(err.Error()).ShowInProduction())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(results); err != nil {
+		models.HandleError(w, r, models.NewInternalError("Failed to encode response").WithError(err))
+	}
+}
@@ -0,0 +1,24 @@
+//handlers/shutdown.go
+
+package handlers
+
+// EndStreams ends the server-sent event streams of CI jobs and imports.
+// They would otherwise keep an HTTP server that is shutting down waiting
+// until its deadline; register it with http.Server.RegisterOnShutdown.
+func (s *Server) EndStreams() {
+	s.endStreams.Do(func() {
+		close(s.streamsDone)
+	})
+}
+
+// Stop stops the background work of the server once no more requests are
+// served: running CI jobs and imports are canceled and recorded as such.
+func (s *Server) Stop() {
+	s.EndStreams()
+	if s.ciRunner != nil {
+		s.ciRunner.Stop()
+	}
+	if s.importer != nil {
+		s.importer.Stop()
+	}
+}
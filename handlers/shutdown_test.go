@@ -0,0 +1,79 @@
+//handlers/shutdown_test.go
+
+package handlers
+
+import (
+	"SimpleGit/models"
+	"bufio"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestShutdownEndsStreams checks that the CI log stream of a running job
+// does not hold up a shutdown once EndStreams is registered with the HTTP
+// server, and that it does without.
+func TestShutdownEndsStreams(t *testing.T) {
+	db, dir := newTestDB(t)
+	s, err := NewServer(filepath.Join(dir, "repos"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetDB(db)
+
+	job := &models.CIJob{RepoName: "alice/demo", Status: models.CIRunning, Steps: []models.CIStep{{Name: "test", Command: "true"}}}
+	if err := s.ciJobs.CreateJob(job); err != nil {
+		t.Fatal(err)
+	}
+
+	// stream starts a server for the events of job and waits for the
+	// first one
+	stream := func(s *Server, endStreams bool) *httptest.Server {
+		srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			s.handleCIEvents(w, r, job)
+		}))
+		if endStreams {
+			srv.Config.RegisterOnShutdown(s.EndStreams)
+		}
+		srv.Start()
+
+		resp, err := srv.Client().Get(srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { resp.Body.Close() })
+		line, err := bufio.NewReader(resp.Body).ReadString('\n')
+		if err != nil || !strings.HasPrefix(line, "event: ") {
+			t.Fatalf("first line of the stream = %q, %v", line, err)
+		}
+		return srv
+	}
+
+	srv := stream(s, true)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := srv.Config.Shutdown(ctx); err != nil {
+		t.Errorf("Shutdown() = %v, want the stream to end", err)
+	}
+	srv.Close()
+
+	// Without EndStreams the stream runs until the deadline
+	other, err := NewServer(filepath.Join(dir, "other"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	other.SetDB(db)
+	srv = stream(other, false)
+	ctx, cancel = context.WithTimeout(context.Background(), 3*ciPollInterval)
+	defer cancel()
+	if err := srv.Config.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Shutdown() without EndStreams = %v, want %v", err, context.DeadlineExceeded)
+	}
+	other.EndStreams()
+	srv.Close()
+}
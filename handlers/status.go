@@ -0,0 +1,224 @@
+//handlers/status.go
+
+package handlers
+
+import (
+	"SimpleGit/models"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Limits of the fields of a reported status.
+const (
+	maxStatusContextLength     = 255
+	maxStatusDescriptionLength = 1024
+	maxStatusTargetURLLength   = 2048
+)
+
+// defaultStatusContext is the context of statuses reported without one.
+const defaultStatusContext = "default"
+
+// CommitStatusRequest is the body of a request to set a commit status.
+type CommitStatusRequest struct {
+	State       string `json:"state"`
+	Context     string `json:"context"`
+	TargetURL   string `json:"target_url"`
+	Description string `json:"description"`
+}
+
+// CombinedStatusResponse is the combined status of a commit.
+type CombinedStatusResponse struct {
+	SHA        string                `json:"sha"`
+	State      string                `json:"state"`
+	TotalCount int                   `json:"total_count"`
+	Statuses   []models.CommitStatus `json:"statuses"`
+}
+
+// handleStatusAPI routes the commit status API of a repository. Reading
+// statuses is public; setting one requires an access token of a user with
+// write access.
+//
+// Routes:
+//   - GET /api/repos/<repo>/statuses/<ref>: The statuses of a commit.
+//   - POST /api/repos/<repo>/statuses/<sha>: Set the status of a commit
+//     for a context (CommitStatusRequest).
+//   - GET /api/repos/<repo>/commits/<ref>/status: The combined status of a
+//     commit.
+func (s *Server) handleStatusAPI(w http.ResponseWriter, r *http.Request) {
+	parts := repoPathParts(r.URL.Path, 2)
+	if len(parts) < 5 {
+		models.HandleError(w, r, models.NewNotFoundError("Not found").ShowInProduction())
+		return
+	}
+
+	repo, ok := s.Repository(parts[2])
+	if !ok {
+		models.HandleError(w, r, models.NewNotFoundError("Repository not found").WithDetail(fmt.Sprintf("Repository: %s", parts[2])).ShowInProduction())
+		return
+	}
+
+	switch {
+	case len(parts) == 5 && parts[3] == "statuses":
+		switch r.Method {
+		case http.MethodGet:
+			s.handleListStatuses(w, r, repo, parts[4])
+		case http.MethodPost:
+			s.requireToken(func(w http.ResponseWriter, r *http.Request) {
+				s.handleSetStatus(w, r, repo, parts[4])
+			})(w, r)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	case len(parts) == 6 && parts[3] == "commits" && parts[5] == "status":
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		s.handleCombinedStatus(w, r, repo, parts[4])
+	default:
+		models.HandleError(w, r, models.NewNotFoundError("Not found").ShowInProduction())
+	}
+}
+
+// resolveStatusCommit returns the full hash of the commit ref names, or
+// writes an error.
+func resolveStatusCommit(w http.ResponseWriter, r *http.Request, repo *models.Repository, ref string) (string, bool) {
+	commit, err := repo.ResolveCommit(ref)
+	if err != nil {
+		models.HandleError(w, r, models.NewNotFoundError("Commit not found").WithDetail(fmt.Sprintf("Commit: %s", ref)).ShowInProduction())
+		return "", false
+	}
+	return commit.Hash.String(), true
+}
+
+// handleListStatuses returns every status of a commit.
+func (s *Server) handleListStatuses(w http.ResponseWriter, r *http.Request, repo *models.Repository, ref string) {
+	sha, ok := resolveStatusCommit(w, r, repo, ref)
+	if !ok {
+		return
+	}
+
+	statuses, err := s.statuses.List(repo.Name, sha)
+	if err != nil {
+		models.HandleError(w, r, models.NewInternalError("Failed to list statuses").WithError(err))
+		return
+	}
+	if statuses == nil {
+		statuses = []models.CommitStatus{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(statuses)
+}
+
+// handleCombinedStatus returns the combined status of a commit. Commits
+// without statuses are pending.
+func (s *Server) handleCombinedStatus(w http.ResponseWriter, r *http.Request, repo *models.Repository, ref string) {
+	sha, ok := resolveStatusCommit(w, r, repo, ref)
+	if !ok {
+		return
+	}
+
+	combined, err := s.statuses.Combined(repo.Name, []string{sha})
+	if err != nil {
+		models.HandleError(w, r, models.NewInternalError("Failed to load statuses").WithError(err))
+		return
+	}
+
+	resp := CombinedStatusResponse{SHA: sha, State: models.StatusPending, Statuses: []models.CommitStatus{}}
+	if c, ok := combined[sha]; ok {
+		resp.State = c.State
+		resp.Statuses = c.Statuses
+	}
+	resp.TotalCount = len(resp.Statuses)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// handleSetStatus creates or replaces the status of a commit for a context
+// on behalf of the authenticated user, who needs write access.
+func (s *Server) handleSetStatus(w http.ResponseWriter, r *http.Request, repo *models.Repository, ref string) {
+	user, err := s.getUserFromRequest(r)
+	if err != nil {
+		models.HandleError(w, r, models.NewUnauthorizedError("Not authenticated").ShowInProduction())
+		return
+	}
+	if !s.permission(user, repo).CanWrite() {
+		models.HandleError(w, r, models.NewForbiddenError(deniedMessage(repo, models.PermissionWrite)).ShowInProduction())
+		return
+	}
+
+	var req CommitStatusRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		models.HandleError(w, r, models.NewBadRequestError("Invalid request body").WithError(err).ShowInProduction())
+		return
+	}
+
+	if err := validateStatusRequest(&req); err != nil {
+		models.HandleError(w, r, err)
+		return
+	}
+
+	sha, ok := resolveStatusCommit(w, r, repo, ref)
+	if !ok {
+		return
+	}
+
+	status := &models.CommitStatus{
+		RepoName:    repo.Name,
+		Commit:      sha,
+		Context:     req.Context,
+		State:       req.State,
+		TargetURL:   req.TargetURL,
+		Description: req.Description,
+		CreatorID:   user.ID,
+	}
+	if err := s.statuses.Set(status); err != nil {
+		models.HandleError(w, r, models.NewInternalError("Failed to set status").WithError(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(status)
+}
+
+// validateStatusRequest normalises a status request and checks its fields.
+// Target URLs must be http(s) or site-relative since they are rendered as
+// links.
+func validateStatusRequest(req *CommitStatusRequest) *models.AppError {
+	req.State = strings.ToLower(strings.TrimSpace(req.State))
+	req.Context = strings.TrimSpace(req.Context)
+	req.TargetURL = strings.TrimSpace(req.TargetURL)
+	req.Description = strings.TrimSpace(req.Description)
+
+	if !models.ValidStatusState(req.State) {
+		return models.NewBadRequestError("State must be pending, success, failure or error").ShowInProduction()
+	}
+	if req.Context == "" {
+		req.Context = defaultStatusContext
+	}
+	if len(req.Context) > maxStatusContextLength {
+		return models.NewBadRequestError(fmt.Sprintf("Context must be at most %d characters", maxStatusContextLength)).ShowInProduction()
+	}
+	if len(req.Description) > maxStatusDescriptionLength {
+		return models.NewBadRequestError(fmt.Sprintf("Description must be at most %d characters", maxStatusDescriptionLength)).ShowInProduction()
+	}
+
+	if req.TargetURL != "" {
+		if len(req.TargetURL) > maxStatusTargetURLLength {
+			return models.NewBadRequestError(fmt.Sprintf("Target URL must be at most %d characters", maxStatusTargetURLLength)).ShowInProduction()
+		}
+		target, err := url.Parse(req.TargetURL)
+		relative := target != nil && target.Scheme == "" && target.Host == "" && strings.HasPrefix(target.Path, "/")
+		if err != nil || !(relative || ((target.Scheme == "http" || target.Scheme == "https") && target.Host != "")) {
+			return models.NewBadRequestError("Target URL must be an http(s) URL or an absolute path").ShowInProduction()
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,163 @@
+//handlers/status_test.go
+
+package handlers
+
+import (
+	"SimpleGit/config"
+	"SimpleGit/database"
+	"SimpleGit/models"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+// newTestDB opens a database in a temporary directory, which becomes the
+// data directory, and returns both.
+func newTestDB(t *testing.T) (*gorm.DB, string) {
+	t.Helper()
+	log.SetOutput(io.Discard)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	dir := t.TempDir()
+	config.GlobalConfig = config.Config{DataDir: dir, DBPath: filepath.Join(dir, "githost.db")}
+	db, err := database.InitDB(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return db, dir
+}
+
+// runGit runs git in dir as Alice and returns its trimmed output.
+func runGit(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GIT_AUTHOR_NAME=Alice", "GIT_AUTHOR_EMAIL=alice@example.com",
+		"GIT_COMMITTER_NAME=Alice", "GIT_COMMITTER_EMAIL=alice@example.com")
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("git %s: %v", args[0], err)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// newBareRepo creates a bare repository at path whose default branch main
+// has one empty commit.
+func newBareRepo(t *testing.T, path string) {
+	t.Helper()
+	if err := os.MkdirAll(path, 0755); err != nil {
+		t.Fatal(err)
+	}
+	runGit(t, path, "init", "-q", "--bare")
+	emptyTree := runGit(t, path, "hash-object", "-t", "tree", "-w", "/dev/null")
+	runGit(t, path, "update-ref", "refs/heads/main", runGit(t, path, "commit-tree", emptyTree, "-m", "Initial commit"))
+	runGit(t, path, "symbolic-ref", "HEAD", "refs/heads/main")
+}
+
+func TestStatusAPITokens(t *testing.T) {
+	db, dir := newTestDB(t)
+	repoPath := filepath.Join(dir, "alice", "demo.git")
+	newBareRepo(t, repoPath)
+
+	users := models.NewUserService(db, []byte("test"))
+	s := &Server{repos: map[string]*models.Repository{"alice/demo": {Name: "alice/demo", Path: repoPath}}}
+	s.SetDB(db)
+	s.SetUserService(users)
+
+	alice, err := users.CreateUser("alice", "alice@example.com", "password", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, session, err := users.AuthenticateUser("alice", "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, _, err := users.CreateAccessToken(alice.ID, "ci")
+	if err != nil {
+		t.Fatal(err)
+	}
+	revoked, revokedToken, err := users.CreateAccessToken(alice.ID, "old")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := users.DeleteAccessToken(alice.ID, revokedToken.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	serve := func(handler http.HandlerFunc, method, path, auth string, cookie *http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(`{"state": "success", "context": "ci/test"}`))
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		return rec
+	}
+
+	tests := []struct {
+		name   string
+		auth   string
+		cookie *http.Cookie
+		code   int
+	}{
+		{"token", "token " + token, nil, http.StatusCreated},
+		{"bearer", "Bearer " + token, nil, http.StatusCreated},
+		{"session cookie", "", &http.Cookie{Name: "auth_token", Value: session}, http.StatusCreated},
+		{"revoked token", "token " + revoked, nil, http.StatusUnauthorized},
+		{"unknown token", "token sgp_nonsense", nil, http.StatusUnauthorized},
+		{"session token as access token", "token " + session, nil, http.StatusUnauthorized},
+		{"other scheme", "Basic " + token, nil, http.StatusUnauthorized},
+		{"anonymous", "", nil, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(s.handleStatusAPI, "POST", "/api/repos/alice/demo/statuses/main", tt.auth, tt.cookie)
+			if rec.Code != tt.code {
+				t.Fatalf("got %d, want %d: %s", rec.Code, tt.code, rec.Body.String())
+			}
+			if tt.code != http.StatusCreated {
+				return
+			}
+			var status models.CommitStatus
+			if err := json.NewDecoder(rec.Body).Decode(&status); err != nil {
+				t.Fatal(err)
+			}
+			if status.CreatorID != alice.ID || status.State != models.StatusSuccess {
+				t.Errorf("status %+v, want success created by alice", status)
+			}
+		})
+	}
+
+	// Reading statuses is public
+	rec := serve(s.handleStatusAPI, "GET", "/api/repos/alice/demo/commits/main/status", "", nil)
+	var combined CombinedStatusResponse
+	if err := json.NewDecoder(rec.Body).Decode(&combined); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("combined status: %d, %v", rec.Code, err)
+	}
+	if combined.State != models.StatusSuccess || combined.TotalCount != 1 {
+		t.Errorf("combined status %+v, want one success", combined)
+	}
+
+	// Access tokens only authenticate the token API, not the session routes
+	// that manage them
+	if rec := serve(s.requireAuth(s.handleListAccessTokens), "GET", "/api/tokens", "token "+token, nil); rec.Code == http.StatusOK {
+		t.Errorf("listing tokens with an access token: got %d", rec.Code)
+	}
+}
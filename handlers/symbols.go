@@ -0,0 +1,35 @@
+//handlers/symbols.go
+
+package handlers
+
+import (
+	"SimpleGit/utils"
+	"path/filepath"
+	"strings"
+)
+
+// symbolCacheBytes bounds the memory used by cached symbol outlines.
+const symbolCacheBytes = 16 * 1024 * 1024
+
+func newSymbolCache() *utils.LRU[string, []utils.Symbol] {
+	return utils.NewLRU(symbolCacheBytes, func(key string, symbols []utils.Symbol) int64 {
+		size := int64(len(key))
+		for _, sym := range symbols {
+			size += int64(len(sym.Name)+len(sym.Type)+len(sym.Icon)+len(sym.Parent)+len(sym.Receiver)) + 48
+		}
+		return size
+	})
+}
+
+// fileSymbols returns the symbol outline of a blob. Outlines are cached per
+// blob hash and extension since the extractor depends on both.
+func (s *Server) fileSymbols(blobHash, path string, content []byte) []utils.Symbol {
+	key := blobHash + ":" + strings.ToLower(filepath.Ext(path))
+	if symbols, ok := s.symbolCache.Get(key); ok {
+		return symbols
+	}
+
+	symbols := utils.ParseSymbols(path, content)
+	s.symbolCache.Add(key, symbols)
+	return symbols
+}
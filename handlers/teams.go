@@ -0,0 +1,305 @@
+//handlers/teams.go
+
+package handlers
+
+import (
+	"SimpleGit/models"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// teamView is a team with its members and repositories, as the admin page
+// of an organization shows it.
+type teamView struct {
+	Team    models.Team
+	Members []models.TeamMember
+	Repos   []string
+}
+
+// handleAdminOrgs lists the organizations for admins.
+func (s *Server) handleAdminOrgs(w http.ResponseWriter, r *http.Request) {
+	orgs, err := s.orgs.List()
+	if err != nil {
+		models.HandleError(w, r, models.NewInternalError("Failed to load organizations").WithError(err))
+		return
+	}
+
+	data := map[string]interface{}{
+		"AdminPage": "orgs",
+		"Orgs":      orgs,
+	}
+	if err := s.tmpl.ExecuteTemplate(w, "admin-orgs.html", s.addCommonData(r, data)); err != nil {
+		models.HandleError(w, r, models.NewInternalError("Failed to render template").WithError(err))
+	}
+}
+
+// handleAdminOrg shows the teams of an organization and changes them.
+// Members of teams must already be members of the organization, which are
+// managed on its page.
+//
+// Routes:
+//   - /admin/orgs/<org>: The teams of the organization.
+//   - /admin/orgs/<org>/teams: Create a team (POST).
+//   - /admin/orgs/<org>/teams/<team>: Change a team's permission (POST).
+//   - /admin/orgs/<org>/teams/<team>/delete: Delete a team (POST).
+//   - /admin/orgs/<org>/teams/<team>/members[/remove]: Add or remove a
+//     member (POST).
+//   - /admin/orgs/<org>/teams/<team>/repos[/remove]: Add or remove a
+//     repository (POST).
+func (s *Server) handleAdminOrg(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) < 3 {
+		http.Redirect(w, r, "/admin/orgs", http.StatusSeeOther)
+		return
+	}
+
+	org, err := s.orgs.Get(parts[2])
+	if err != nil {
+		models.HandleError(w, r, models.NewNotFoundError("Organization not found").WithDetail(fmt.Sprintf("Organization: %s", parts[2])).ShowInProduction())
+		return
+	}
+
+	if len(parts) == 3 {
+		s.renderAdminOrg(w, r, org)
+		return
+	}
+	if parts[3] != "teams" || len(parts) > 7 {
+		models.HandleError(w, r, models.NewNotFoundError("Page not found").WithDetail(fmt.Sprintf("Path: %s", r.URL.Path)))
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var appErr *models.AppError
+	if len(parts) == 4 {
+		appErr = s.createTeam(r, org)
+	} else {
+		team, err := s.teams.Get(org.ID, parts[4])
+		if err != nil {
+			models.HandleError(w, r, models.NewNotFoundError("Team not found").WithDetail(fmt.Sprintf("Team: %s", parts[4])).ShowInProduction())
+			return
+		}
+		appErr = s.changeTeam(r, org, team, parts[5:])
+	}
+	if appErr != nil {
+		models.HandleError(w, r, appErr)
+		return
+	}
+
+	http.Redirect(w, r, "/admin/orgs/"+org.Name, http.StatusSeeOther)
+}
+
+// renderAdminOrg renders the teams of an organization.
+func (s *Server) renderAdminOrg(w http.ResponseWriter, r *http.Request, org *models.Organization) {
+	teams, err := s.teams.List(org.ID)
+	if err != nil {
+		models.HandleError(w, r, models.NewInternalError("Failed to load teams").WithError(err))
+		return
+	}
+	views := make([]teamView, 0, len(teams))
+	for _, team := range teams {
+		view := teamView{Team: team}
+		if view.Members, err = s.teams.Members(team.ID); err == nil {
+			view.Repos, err = s.teams.Repos(team.ID)
+		}
+		if err != nil {
+			models.HandleError(w, r, models.NewInternalError("Failed to load teams").WithError(err))
+			return
+		}
+		views = append(views, view)
+	}
+
+	members, err := s.orgs.Members(org.ID)
+	if err != nil {
+		models.HandleError(w, r, models.NewInternalError("Failed to load members").WithError(err))
+		return
+	}
+	var repos []string
+	for _, repo := range s.reposOf(org.Name) {
+		repos = append(repos, repo.Name)
+	}
+
+	data := map[string]interface{}{
+		"AdminPage":   "orgs",
+		"Org":         org,
+		"Teams":       views,
+		"OrgMembers":  members,
+		"OrgRepos":    repos,
+		"Permissions": models.GrantablePermissions,
+	}
+	if err := s.tmpl.ExecuteTemplate(w, "admin-org.html", s.addCommonData(r, data)); err != nil {
+		models.HandleError(w, r, models.NewInternalError("Failed to render template").WithError(err))
+	}
+}
+
+// createTeam creates a team in an organization from a form.
+func (s *Server) createTeam(r *http.Request, org *models.Organization) *models.AppError {
+	perm, err := models.ParsePermission(r.FormValue("permission"))
+	if err != nil {
+		return models.NewBadRequestError(err.Error()).ShowInProduction()
+	}
+	if _, err := s.teams.Create(org.ID, strings.TrimSpace(r.FormValue("name")), strings.TrimSpace(r.FormValue("description")), perm); err != nil {
+		return models.NewBadRequestError(err.Error()).ShowInProduction()
+	}
+	return nil
+}
+
+// changeTeam applies the action in the rest of the path of a team, as
+// routed by handleAdminOrg.
+func (s *Server) changeTeam(r *http.Request, org *models.Organization, team *models.Team, action []string) *models.AppError {
+	switch strings.Join(action, "/") {
+	case "":
+		perm, err := models.ParsePermission(r.FormValue("permission"))
+		if err != nil {
+			return models.NewBadRequestError(err.Error()).ShowInProduction()
+		}
+		if err := s.teams.SetPermission(team.ID, perm); err != nil {
+			return models.NewInternalError("Failed to change the team").WithError(err)
+		}
+	case "delete":
+		if err := s.teams.Delete(team.ID); err != nil {
+			return models.NewInternalError("Failed to delete the team").WithError(err)
+		}
+	case "members", "members/remove":
+		member, err := s.userService.GetUserByUsername(strings.TrimSpace(r.FormValue("username")))
+		if err != nil {
+			return models.NewNotFoundError("User not found").ShowInProduction()
+		}
+		if len(action) == 2 {
+			err = s.teams.RemoveMember(team.ID, member.ID)
+		} else {
+			err = s.teams.AddMember(team, member.ID)
+		}
+		if errors.Is(err, models.ErrNotOrgMember) {
+			return models.NewBadRequestError(fmt.Sprintf("%s is not a member of %s", member.Username, org.Name)).ShowInProduction()
+		}
+		if err != nil {
+			return models.NewInternalError("Failed to change the team's members").WithError(err)
+		}
+	case "repos", "repos/remove":
+		repoName := r.FormValue("repo")
+		var err error
+		if len(action) == 2 {
+			err = s.teams.RemoveRepo(team.ID, repoName)
+		} else if _, ok := s.Repository(repoName); !ok {
+			return models.NewNotFoundError("Repository not found").WithDetail(fmt.Sprintf("Repository: %s", repoName)).ShowInProduction()
+		} else {
+			err = s.teams.AddRepo(team, org.Name, repoName)
+		}
+		if errors.Is(err, models.ErrNotOrgRepo) {
+			return models.NewBadRequestError(fmt.Sprintf("%s does not belong to %s", repoName, org.Name)).ShowInProduction()
+		}
+		if err != nil {
+			return models.NewInternalError("Failed to change the team's repositories").WithError(err)
+		}
+	default:
+		return models.NewNotFoundError("Page not found").WithDetail(fmt.Sprintf("Path: %s", r.URL.Path))
+	}
+	return nil
+}
+
+// handleAdminRepoAccess shows who has access to a repository and grants or
+// revokes the permissions of single users.
+//
+// Routes:
+//   - /admin/repos/<repo>/access: The direct and team grants (GET), or
+//     grant a user a permission (POST).
+//   - /admin/repos/<repo>/access/revoke: Revoke a user's grant (POST).
+func (s *Server) handleAdminRepoAccess(w http.ResponseWriter, r *http.Request, repo *models.Repository, revoke bool) {
+	if r.Method == http.MethodGet && !revoke {
+		s.renderAdminRepoAccess(w, r, repo)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, err := s.userService.GetUserByUsername(strings.TrimSpace(r.FormValue("username")))
+	if err != nil {
+		models.HandleError(w, r, models.NewNotFoundError("User not found").ShowInProduction())
+		return
+	}
+
+	if revoke {
+		err = s.permissions.Revoke(repo.Name, user.ID)
+	} else {
+		perm, parseErr := models.ParsePermission(r.FormValue("permission"))
+		if parseErr != nil {
+			models.HandleError(w, r, models.NewBadRequestError(parseErr.Error()).ShowInProduction())
+			return
+		}
+		err = s.permissions.Grant(repo.Name, user.ID, perm)
+	}
+	if err != nil {
+		models.HandleError(w, r, models.NewInternalError("Failed to change access").WithError(err))
+		return
+	}
+
+	http.Redirect(w, r, "/admin/repos/"+repo.Name+"/access", http.StatusSeeOther)
+}
+
+// renderAdminRepoAccess renders the grants of a repository.
+func (s *Server) renderAdminRepoAccess(w http.ResponseWriter, r *http.Request, repo *models.Repository) {
+	grants, err := s.permissions.Grants(repo.Name)
+	if err != nil {
+		models.HandleError(w, r, models.NewInternalError("Failed to load access grants").WithError(err))
+		return
+	}
+	teams, err := s.teams.ForRepo(repo.Name)
+	if err != nil {
+		models.HandleError(w, r, models.NewInternalError("Failed to load teams").WithError(err))
+		return
+	}
+
+	data := map[string]interface{}{
+		"AdminPage":   "repos",
+		"Repo":        repo,
+		"Grants":      grants,
+		"Teams":       teams,
+		"Permissions": models.GrantablePermissions,
+	}
+	if org, err := s.orgs.Get(repo.Owner); err == nil {
+		data["Org"] = org
+	}
+	if err := s.tmpl.ExecuteTemplate(w, "admin-repo-access.html", s.addCommonData(r, data)); err != nil {
+		models.HandleError(w, r, models.NewInternalError("Failed to render template").WithError(err))
+	}
+}
+
+// handleAdminRepo routes the admin actions on a repository.
+//
+// Routes:
+//   - DELETE /admin/repos/<repo>: Delete the repository.
+//   - /admin/repos/<repo>/access[/revoke]: Access grants.
+//   - /admin/repos/<repo>/import[/events]: The progress of an import.
+func (s *Server) handleAdminRepo(w http.ResponseWriter, r *http.Request) {
+	parts := repoPathParts(r.URL.Path, 2)
+	if len(parts) == 3 {
+		s.handleDeleteRepo(w, r)
+		return
+	}
+	if len(parts) < 3 {
+		models.HandleError(w, r, models.NewNotFoundError("Page not found").WithDetail(fmt.Sprintf("Path: %s", r.URL.Path)))
+		return
+	}
+
+	repo, ok := s.Repository(parts[2])
+	if !ok {
+		models.HandleError(w, r, models.NewNotFoundError("Repository not found").WithDetail(fmt.Sprintf("Repository: %s", parts[2])).ShowInProduction())
+		return
+	}
+
+	switch action := strings.Join(parts[3:], "/"); action {
+	case "access", "access/revoke":
+		s.handleAdminRepoAccess(w, r, repo, action == "access/revoke")
+	case "import", "import/events":
+		s.handleAdminRepoImport(w, r, repo, action == "import/events")
+	default:
+		models.HandleError(w, r, models.NewNotFoundError("Page not found").WithDetail(fmt.Sprintf("Path: %s", r.URL.Path)))
+	}
+}
@@ -0,0 +1,104 @@
+//handlers/tokens.go
+
+package handlers
+
+import (
+	"SimpleGit/models"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+type AccessTokenRequest struct {
+	Name string `json:"name"`
+}
+
+// AccessTokenResponse is a newly created token. Token is only ever returned
+// here.
+type AccessTokenResponse struct {
+	*models.AccessToken
+	Token string `json:"token"`
+}
+
+// handleListAccessTokens lists the access tokens of the logged in user.
+func (s *Server) handleListAccessTokens(w http.ResponseWriter, r *http.Request) {
+	user, err := s.getUserFromRequest(r)
+	if err != nil {
+		models.HandleError(w, r, models.NewUnauthorizedError("Not authenticated"))
+		return
+	}
+
+	tokens, err := s.userService.ListAccessTokens(user.ID)
+	if err != nil {
+		models.HandleError(w, r, models.NewInternalError("Failed to get access tokens").WithError(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tokens)
+}
+
+// handleCreateAccessToken creates an access token for the logged in user
+// and returns it once.
+func (s *Server) handleCreateAccessToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := getUserID(r)
+	if !ok {
+		models.HandleError(w, r, models.NewUnauthorizedError("Not authenticated"))
+		return
+	}
+
+	var req AccessTokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		models.HandleError(w, r, models.NewBadRequestError("Invalid request body"))
+		return
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		models.HandleError(w, r, models.NewBadRequestError("Name is required"))
+		return
+	}
+
+	token, accessToken, err := s.userService.CreateAccessToken(userID, req.Name)
+	if err != nil {
+		models.HandleError(w, r, models.NewInternalError("Failed to create access token").WithError(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(AccessTokenResponse{AccessToken: accessToken, Token: token})
+}
+
+// handleDeleteAccessToken revokes an access token of the logged in user.
+func (s *Server) handleDeleteAccessToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "DELETE" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, err := s.getUserFromRequest(r)
+	if err != nil {
+		models.HandleError(w, r, models.NewUnauthorizedError("Not authenticated"))
+		return
+	}
+
+	// Extract token ID from URL
+	parts := strings.Split(r.URL.Path, "/")
+	if len(parts) < 4 || parts[3] == "" {
+		models.HandleError(w, r, models.NewBadRequestError("Token ID is required"))
+		return
+	}
+
+	if err := s.userService.DeleteAccessToken(user.ID, parts[3]); err != nil {
+		models.HandleError(w, r, models.NewNotFoundError("Access token not found").WithError(err))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
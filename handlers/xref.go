@@ -0,0 +1,162 @@
+//handlers/xref.go
+
+package handlers
+
+import (
+	"SimpleGit/models"
+	"SimpleGit/services"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// xrefDefView is a definition as sent to the file view.
+type xrefDefView struct {
+	Name string `json:"name"`
+	Kind string `json:"kind"`
+	Path string `json:"path"`
+	Line int    `json:"line"`
+	URL  string `json:"url"`
+}
+
+// xrefView is the cross-reference data embedded in the file view: the
+// resolved identifiers of the file and the definitions they point to.
+type xrefView struct {
+	Commit string              `json:"commit"`
+	Defs   map[int]xrefDefView `json:"defs"`
+	Links  []services.XrefLink `json:"links"`
+}
+
+// xrefRefView is a single reference returned by the xref API.
+type xrefRefView struct {
+	Path string `json:"path"`
+	Line int    `json:"line"`
+	Text string `json:"text"`
+	URL  string `json:"url"`
+}
+
+// xrefResponse is a definition with its references as returned by the
+// cross-reference API.
+type xrefResponse struct {
+	Definition xrefDefView   `json:"definition"`
+	References []xrefRefView `json:"references"`
+}
+
+func fileLineURL(repoName, path, branch string, line int) string {
+	return fmt.Sprintf("/file/%s/%s?branch=%s#L%d", repoName, path, url.QueryEscape(branch), line)
+}
+
+// RefreshXref schedules cross-reference indexing for every branch tip of
+// every repository.
+func (s *Server) RefreshXref() {
+	for name, repo := range s.Repositories() {
+		if err := s.xref.Refresh(name, repo.Path); err != nil {
+			log.Printf("Xref: failed to refresh %s: %v", name, err)
+		}
+	}
+}
+
+// fileXref returns the cross references of a file at commit, or nil if the
+// index is still being built or the file has no resolved identifiers.
+func (s *Server) fileXref(repo *models.Repository, branch, commit, path string) *xrefView {
+	idx, ok := s.xref.Index(repo.Name, repo.Path, commit)
+	if !ok {
+		return nil
+	}
+
+	links := idx.Links[path]
+	if len(links) == 0 {
+		return nil
+	}
+
+	view := &xrefView{
+		Commit: commit,
+		Defs:   make(map[int]xrefDefView),
+		Links:  links,
+	}
+	for _, link := range links {
+		if _, seen := view.Defs[link.Def]; seen {
+			continue
+		}
+		def, ok := idx.Definition(link.Def)
+		if !ok {
+			continue
+		}
+		view.Defs[link.Def] = xrefDefView{
+			Name: def.Name,
+			Kind: def.Kind,
+			Path: def.Path,
+			Line: def.Line,
+			URL:  fileLineURL(repo.Name, def.Path, branch, def.Line),
+		}
+	}
+
+	return view
+}
+
+// handleXref returns a definition and its references as JSON.
+//
+// Query parameters:
+//   - branch: The branch the file view was rendered for.
+//   - commit: The commit the index was built for.
+//   - def: The definition ID from the file view.
+func (s *Server) handleXref(w http.ResponseWriter, r *http.Request) {
+	parts := repoPathParts(r.URL.Path, 2)
+	if len(parts) < 3 {
+		models.HandleError(w, r, models.NewBadRequestError("Invalid repository path"))
+		return
+	}
+
+	repo, ok := s.Repository(parts[2])
+	if !ok {
+		models.HandleError(w, r, models.NewNotFoundError("Repository not found"))
+		return
+	}
+
+	query := r.URL.Query()
+	branch := query.Get("branch")
+	commit := query.Get("commit")
+	defID, err := strconv.Atoi(query.Get("def"))
+	if err != nil || commit == "" {
+		models.HandleError(w, r, models.NewBadRequestError("commit and def are required").ShowInProduction())
+		return
+	}
+
+	idx, ready := s.xref.Index(repo.Name, repo.Path, commit)
+	if !ready {
+		models.HandleError(w, r, models.NewNotFoundError("Index is still being built").ShowInProduction())
+		return
+	}
+
+	def, ok := idx.Definition(defID)
+	if !ok {
+		models.HandleError(w, r, models.NewNotFoundError("Definition not found").ShowInProduction())
+		return
+	}
+
+	refs := idx.DefinitionReferences(defID)
+	refViews := make([]xrefRefView, 0, len(refs))
+	for _, ref := range refs {
+		refViews = append(refViews, xrefRefView{
+			Path: ref.Path,
+			Line: ref.Line,
+			Text: ref.Text,
+			URL:  fileLineURL(repo.Name, ref.Path, branch, ref.Line),
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(xrefResponse{
+		Definition: xrefDefView{
+			Name: def.Name,
+			Kind: def.Kind,
+			Path: def.Path,
+			Line: def.Line,
+			URL:  fileLineURL(repo.Name, def.Path, branch, def.Line),
+		},
+		References: refViews,
+	})
+}
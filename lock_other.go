@@ -0,0 +1,12 @@
+//lock_other.go
+
+//go:build !unix
+
+package main
+
+// lockDataDir is a no-op where file locks are not available: commands
+// cannot tell whether the server is running, so stop it before changing
+// repositories on the command line.
+func lockDataDir() (func(), error) {
+	return func() {}, nil
+}
@@ -0,0 +1,44 @@
+//lock_unix.go
+
+//go:build unix
+
+package main
+
+import (
+	"SimpleGit/config"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// lockDataDir takes the lock on the data directory that the server holds
+// while it runs, and returns the function releasing it. It fails with
+// errServerRunning if another process holds it. The lock is released when
+// the process exits, however it exits.
+func lockDataDir() (func(), error) {
+	if err := os.MkdirAll(config.GlobalConfig.DataDir, 0755); err != nil {
+		return nil, err
+	}
+	path := filepath.Join(config.GlobalConfig.DataDir, lockFileName)
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		// The holder wrote its process ID into the file
+		pid, _ := os.ReadFile(path)
+		f.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return nil, fmt.Errorf("%w (process %s)", errServerRunning, strings.TrimSpace(string(pid)))
+		}
+		return nil, fmt.Errorf("failed to lock %s: %w", path, err)
+	}
+
+	f.Truncate(0)
+	fmt.Fprintf(f, "%d\n", os.Getpid())
+	return func() { f.Close() }, nil
+}
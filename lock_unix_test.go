@@ -0,0 +1,73 @@
+//lock_unix_test.go
+
+//go:build unix
+
+package main
+
+import (
+	"SimpleGit/config"
+	"SimpleGit/models"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+)
+
+func TestStoppedCommandsRefuseWhileTheServerRuns(t *testing.T) {
+	setupCLI(t)
+	if err := config.Load(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The server holds the lock while it runs
+	unlock, err := lockDataDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := lockDataDir(); !errors.Is(err, errServerRunning) {
+		t.Fatalf("second lockDataDir() = %v, want %v", err, errServerRunning)
+	}
+
+	if code, _ := runCLI(t, "user", "create", "--admin", "--password", "secret", "alice", "alice@example.com"); code != 0 {
+		t.Errorf("user create while the server runs: exit status %d, want 0", code)
+	}
+	if code, _ := runCLI(t, "repo", "create", "alice/demo"); code != 1 {
+		t.Errorf("repo create while the server runs: exit status %d, want 1", code)
+	}
+	if _, err := os.Stat(models.RepoDir(config.GlobalConfig.RepoPath, "alice/demo")); !os.IsNotExist(err) {
+		t.Errorf("the refused command created the repository: %v", err)
+	}
+
+	// Deleting a user leaves the repositories of the server alone, even
+	// those it has yet to move under an owner
+	flat := filepath.Join(config.GlobalConfig.RepoPath, "flat")
+	if _, err := git.PlainInit(flat, true); err != nil {
+		t.Fatal(err)
+	}
+	if code, _ := runCLI(t, "user", "create", "--password", "secret", "bob", "bob@example.com"); code != 0 {
+		t.Fatalf("user create bob: exit status %d, want 0", code)
+	}
+	if code, _ := runCLI(t, "user", "delete", "bob"); code != 0 {
+		t.Errorf("user delete while the server runs: exit status %d, want 0", code)
+	}
+	if _, err := os.Stat(flat); err != nil {
+		t.Errorf("user delete moved a repository of the server: %v", err)
+	}
+
+	unlock()
+	if code, _ := runCLI(t, "repo", "create", "alice/demo"); code != 0 {
+		t.Errorf("repo create while the server is stopped: exit status %d, want 0", code)
+	}
+	if code, _ := runCLI(t, "user", "delete", "alice"); code != 1 {
+		t.Errorf("user delete of the owner of alice/demo: exit status %d, want 1", code)
+	}
+
+	// The command released the lock when it was done
+	unlock, err = lockDataDir()
+	if err != nil {
+		t.Fatalf("lockDataDir() after the command = %v", err)
+	}
+	unlock()
+}
@@ -7,16 +7,38 @@ import (
 	"SimpleGit/database"
 	"SimpleGit/handlers"
 	"SimpleGit/models"
+	"SimpleGit/services"
 	"SimpleGit/ssh"
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
 	"path/filepath"
 	"sync"
+	"syscall"
+	"time"
 )
 
 func main() {
-	config.Init()
+	os.Exit(run(os.Args[1:]))
+}
+
+// serve runs the HTTP and SSH servers until the process is asked to stop,
+// and returns the exit status.
+func serve() int {
+	if _, err := models.CheckGitVersion(); err != nil {
+		log.Fatal(err)
+	}
+
+	// Commands changing repositories wait until the server is stopped
+	unlock, err := lockDataDir()
+	if err != nil {
+		log.Fatalf("Cannot lock %s: %v", config.GlobalConfig.DataDir, err)
+	}
+	defer unlock()
 
 	// Initialize database
 	db, err := database.InitDB(config.GlobalConfig.DataDir)
@@ -32,16 +54,48 @@ func main() {
 		log.Fatal(err)
 	}
 
-	server.HighlightCache = handlers.NewHighlightCache(1000)
+	if err := server.LoadTemplates(); err != nil {
+		log.Fatal(err)
+	}
+
+	server.HighlightCache = services.NewHighlightCache(
+		config.GlobalConfig.HighlightCacheBytes,
+		filepath.Join(config.GlobalConfig.DataDir, "cache", "highlight"),
+		config.GlobalConfig.HighlightDiskCacheBytes,
+	)
+
+	server.LFS, err = services.NewLocalLFSStore(lfsDir())
+	if err != nil {
+		log.Fatal(err)
+	}
 
 	// Set database and user service
 	server.SetDB(db)
 	server.SetUserService(userService)
 
+	if err := server.MigrateFlatRepositories(); err != nil {
+		log.Fatal(err)
+	}
 	if err := server.ScanRepositories(); err != nil {
 		log.Fatal(err)
 	}
 
+	if config.GlobalConfig.CIEnabled {
+		server.EnableCI(
+			filepath.Join(config.GlobalConfig.DataDir, "ci"),
+			config.GlobalConfig.CIWorkers,
+			time.Duration(config.GlobalConfig.CIStepTimeout)*time.Second,
+		)
+	}
+
+	if err := server.EnableImports(filepath.Join(config.GlobalConfig.DataDir, "imports")); err != nil {
+		log.Fatal(err)
+	}
+
+	// Build cross-reference and search indexes in the background
+	server.RefreshXref()
+	server.RefreshSearch()
+
 	if err := server.InitAdminSetup(); err != nil {
 		log.Fatal(err)
 	}
@@ -49,53 +103,145 @@ func main() {
 	server.SetupRoutes()
 
 	// Create SSH server
-	repoPath := filepath.Join(".", "repositories")
-	absRepoPath, err := filepath.Abs(repoPath)
-	if err != nil {
-		log.Fatal("Failed to get absolute repository path:", err)
-	}
-	log.Printf("Using repository path: %s", absRepoPath)
+	log.Printf("Using repository path: %s", config.GlobalConfig.RepoPath)
 
 	sshServer, err := ssh.NewServer(
-		absRepoPath, // Use absolute path
+		config.GlobalConfig.RepoPath,
 		userService,
-		func() {
+		func(repoPath string, updates []models.RefUpdate) {
 			// This callback will be called after git-receive-pack operations
 			if err := server.ScanRepositories(); err != nil {
 				log.Printf("Error rescanning repositories after update: %v", err)
 			} else {
 				log.Printf("Successfully rescanned repositories after update")
+				server.HandlePush(repoPath, updates)
 			}
 		},
 	)
 	if err != nil {
 		log.Fatal("Failed to create SSH server:", err)
 	}
+	sshServer.SetReceiveEnv(server.ReceivePackEnv)
+	sshServer.SetLFSAuthenticate(server.LFSAuthenticate)
+	sshServer.SetAuthorize(server.GitAuthorize)
+	sshServer.SetResolveName(server.ResolveRepoName)
+
+	// SIGINT and SIGTERM start a graceful shutdown; a second one stops
+	// the process right away
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Periodic work stops with ctx; the shutdown waits for a run in progress
+	var background sync.WaitGroup
+	if config.GlobalConfig.BackupInterval > 0 {
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			newBackups(db).Schedule(ctx, time.Duration(config.GlobalConfig.BackupInterval)*time.Hour)
+		}()
+	}
 
-	// Use WaitGroup to keep the main function from exiting
-	var wg sync.WaitGroup
-	wg.Add(2)
+	// Remove deleted repositories once they can no longer be restored
+	background.Add(1)
+	go func() {
+		defer background.Done()
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+		for {
+			server.PurgeDeletedRepositories()
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	httpServer := &http.Server{Addr: fmt.Sprintf(":%d", config.GlobalConfig.Port)}
+	httpServer.RegisterOnShutdown(server.EndStreams)
+
+	// A server that stops on its own ends the process too
+	serveErrs := make(chan error, 2)
 
 	// Start HTTP server in a goroutine
 	go func() {
-		defer wg.Done()
-		addr := fmt.Sprintf(":%d", config.GlobalConfig.Port)
-		log.Printf("HTTP server starting on %s (dev mode: %v)", addr, config.GlobalConfig.DevMode)
-		if err := http.ListenAndServe(addr, nil); err != nil {
-			log.Fatal("HTTP server error:", err)
+		log.Printf("HTTP server starting on %s (dev mode: %v)", httpServer.Addr, config.GlobalConfig.DevMode)
+		if err := httpServer.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+			serveErrs <- fmt.Errorf("HTTP server error: %w", err)
 		}
 	}()
 
 	// Start SSH server in a goroutine
 	go func() {
-		defer wg.Done()
 		addr := fmt.Sprintf(":%d", config.GlobalConfig.SSHPort)
 		log.Printf("SSH server starting on %s", addr)
-		if err := sshServer.ListenAndServe(addr); err != nil {
-			log.Fatal("SSH server error:", err)
+		if err := sshServer.ListenAndServe(addr); !errors.Is(err, ssh.ErrServerClosed) {
+			serveErrs <- fmt.Errorf("SSH server error: %w", err)
 		}
 	}()
 
-	// Wait for both servers
+	status := 0
+	select {
+	case <-ctx.Done():
+		log.Printf("Shutting down; waiting up to %ds for git operations to finish", config.GlobalConfig.ShutdownTimeout)
+	case err := <-serveErrs:
+		log.Print(err)
+		status = 1
+	}
+	stop()
+
+	if !shutdown(httpServer, sshServer, server, &background) {
+		status = 1
+	}
+	if sqlDB, err := db.DB(); err == nil {
+		if err := sqlDB.Close(); err != nil {
+			log.Printf("Failed to close the database: %v", err)
+			status = 1
+		}
+	}
+	log.Printf("Server stopped")
+	return status
+}
+
+// shutdown stops accepting connections, waits for the requests and git
+// operations in progress until the shutdown timeout and then stops the
+// background work. It reports false if the timeout cut anything short.
+func shutdown(httpServer *http.Server, sshServer *ssh.Server, server *handlers.Server, background *sync.WaitGroup) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(config.GlobalConfig.ShutdownTimeout)*time.Second)
+	defer cancel()
+
+	var httpErr, sshErr error
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		if httpErr = httpServer.Shutdown(ctx); httpErr != nil {
+			log.Printf("HTTP server: %v; closing the remaining connections", httpErr)
+			httpServer.Close()
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		if sshErr = sshServer.Shutdown(ctx); sshErr != nil {
+			log.Printf("SSH server: %v; closed the remaining connections", sshErr)
+		}
+	}()
 	wg.Wait()
+	clean := httpErr == nil && sshErr == nil
+
+	// Pushes queue CI jobs, so the workers stop once no more can come in
+	server.Stop()
+
+	done := make(chan struct{})
+	go func() {
+		background.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		log.Printf("Background work did not finish in time")
+		clean = false
+	}
+	return clean
 }
@@ -0,0 +1,106 @@
+//models/access_token.go
+
+package models
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// accessTokenPrefix marks SimpleGit tokens so they are easy to recognise in
+// configuration files and secret scanners.
+const accessTokenPrefix = "sgp_"
+
+// AccessToken lets scripts and external systems such as CI servers call the
+// API as a user. Only a hash of the token is stored; the token itself is
+// shown once when it is created.
+type AccessToken struct {
+	ID         string     `gorm:"primarykey" json:"id"`
+	UserID     string     `gorm:"index;not null" json:"user_id"`
+	Name       string     `gorm:"not null" json:"name"`
+	Hash       string     `gorm:"uniqueIndex;not null" json:"-"`
+	Prefix     string     `json:"prefix"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// hashAccessToken returns the stored form of a token.
+func hashAccessToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateAccessToken creates a token for a user. The returned string is the
+// only copy of the token.
+func (s *UserService) CreateAccessToken(userID, name string) (string, *AccessToken, error) {
+	secret := make([]byte, 20)
+	if _, err := rand.Read(secret); err != nil {
+		return "", nil, fmt.Errorf("failed to generate token: %w", err)
+	}
+	token := accessTokenPrefix + hex.EncodeToString(secret)
+
+	accessToken := &AccessToken{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		Name:      name,
+		Hash:      hashAccessToken(token),
+		Prefix:    token[:len(accessTokenPrefix)+6],
+		CreatedAt: time.Now(),
+	}
+
+	if err := s.db.Create(accessToken).Error; err != nil {
+		return "", nil, fmt.Errorf("failed to create access token: %w", err)
+	}
+
+	return token, accessToken, nil
+}
+
+// ListAccessTokens returns the tokens of a user, newest first.
+func (s *UserService) ListAccessTokens(userID string) ([]AccessToken, error) {
+	var tokens []AccessToken
+	err := s.db.Where("user_id = ?", userID).Order("created_at DESC").Find(&tokens).Error
+	return tokens, err
+}
+
+// DeleteAccessToken revokes a token of a user.
+func (s *UserService) DeleteAccessToken(userID, tokenID string) error {
+	result := s.db.Where("id = ? AND user_id = ?", tokenID, userID).Delete(&AccessToken{})
+	if result.Error != nil {
+		return fmt.Errorf("failed to delete access token: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("access token not found")
+	}
+	return nil
+}
+
+// VerifyAccessToken returns the user a token belongs to and records that
+// the token was used.
+func (s *UserService) VerifyAccessToken(token string) (*User, error) {
+	if !strings.HasPrefix(token, accessTokenPrefix) {
+		return nil, fmt.Errorf("invalid access token")
+	}
+
+	var accessToken AccessToken
+	if err := s.db.Where("hash = ?", hashAccessToken(token)).First(&accessToken).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("invalid access token")
+		}
+		return nil, err
+	}
+
+	var user User
+	if err := s.db.First(&user, "id = ?", accessToken.UserID).Error; err != nil {
+		return nil, err
+	}
+
+	s.db.Model(&accessToken).Update("last_used_at", time.Now())
+	return &user, nil
+}
@@ -0,0 +1,76 @@
+//models/backup.go
+
+package models
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// configSection is the section of a repository's git config the server
+// keeps its own settings in, such as the parent of a fork.
+const configSection = "simplegit"
+
+// Bundle writes every reference of r, and the objects they need, to a git
+// bundle at path. Objects borrowed from a parent are included, so the
+// bundle stands on its own. It returns false without writing anything if
+// r has no references yet; git cannot bundle an empty repository.
+func (r *Repository) Bundle(path string) (bool, error) {
+	refs, err := r.runGit(nil, "for-each-ref", "--count=1")
+	if err != nil {
+		return false, err
+	}
+	if refs == "" {
+		return false, nil
+	}
+
+	if _, err := r.runGit(nil, "bundle", "create", "--quiet", path, "--all"); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Unbundle copies every reference of the bundle at path, and the objects
+// they need, into r.
+func (r *Repository) Unbundle(path string) error {
+	if _, err := r.runGit(nil, "fetch", "--quiet", "--no-write-fetch-head", path, "+refs/*:refs/*"); err != nil {
+		return err
+	}
+	r.git = nil
+	return nil
+}
+
+// ServerConfig returns the settings the server keeps in r's git config,
+// keyed by their name within the section.
+func (r *Repository) ServerConfig() (map[string]string, error) {
+	out, err := r.runGit(nil, "config", "--get-regexp", `^`+configSection+`\.`)
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+		// No key matched
+		return map[string]string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	settings := make(map[string]string)
+	for _, line := range strings.Split(out, "\n") {
+		key, value, _ := strings.Cut(line, " ")
+		settings[strings.TrimPrefix(key, configSection+".")] = value
+	}
+	return settings, nil
+}
+
+// SetServerConfig stores settings returned by ServerConfig in r's git
+// config.
+func (r *Repository) SetServerConfig(settings map[string]string) error {
+	for key, value := range settings {
+		if _, err := r.runGit(nil, "config", configSection+"."+key, value); err != nil {
+			return fmt.Errorf("failed to set %s: %w", key, err)
+		}
+	}
+	r.git = nil
+	return nil
+}
@@ -0,0 +1,92 @@
+//models/branch.go
+
+package models
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+// ErrBranchNotFound is returned for operations on a branch that does not
+// exist.
+var ErrBranchNotFound = errors.New("branch not found")
+
+// branchExists reports whether the branch exists.
+func (r *Repository) branchExists(name string) bool {
+	_, err := r.git.Reference(plumbing.NewBranchReferenceName(name), false)
+	return err == nil
+}
+
+// CreateBranch creates a branch at the commit rev names and returns it.
+func (r *Repository) CreateBranch(name, rev string) (plumbing.Hash, error) {
+	if err := r.initGit(); err != nil {
+		return plumbing.ZeroHash, err
+	}
+	if r.branchExists(name) {
+		return plumbing.ZeroHash, ErrBranchExists
+	}
+
+	commit, err := r.ResolveCommit(rev)
+	if err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("%w: %s is not a branch, tag or commit", ErrInvalidChange, rev)
+	}
+
+	// The zero old value makes update-ref fail if the branch appeared since
+	ref := plumbing.NewBranchReferenceName(name).String()
+	if _, err := r.runGit(nil, "update-ref", ref, commit.Hash.String(), plumbing.ZeroHash.String()); err != nil {
+		return plumbing.ZeroHash, ErrBranchExists
+	}
+	return commit.Hash, nil
+}
+
+// DeleteBranch deletes a branch and returns the commit it pointed to.
+func (r *Repository) DeleteBranch(name string) (plumbing.Hash, error) {
+	tip, err := r.ResolveBranch(name)
+	if err == plumbing.ErrReferenceNotFound {
+		return plumbing.ZeroHash, ErrBranchNotFound
+	}
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	ref := plumbing.NewBranchReferenceName(name).String()
+	if _, err := r.runGit(nil, "update-ref", "-d", ref, tip.String()); err != nil {
+		return plumbing.ZeroHash, ErrBranchMoved
+	}
+	return tip, nil
+}
+
+// RenameBranch renames a branch and returns the commit it points to. HEAD
+// follows the branch if it is the default branch.
+func (r *Repository) RenameBranch(oldName, newName string) (plumbing.Hash, error) {
+	tip, err := r.ResolveBranch(oldName)
+	if err == plumbing.ErrReferenceNotFound {
+		return plumbing.ZeroHash, ErrBranchNotFound
+	}
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	if r.branchExists(newName) {
+		return plumbing.ZeroHash, ErrBranchExists
+	}
+
+	if _, err := r.runGit(nil, "branch", "-m", "--", oldName, newName); err != nil {
+		return plumbing.ZeroHash, err
+	}
+	return tip, nil
+}
+
+// SetDefaultBranch points HEAD at an existing branch.
+func (r *Repository) SetDefaultBranch(name string) error {
+	if err := r.initGit(); err != nil {
+		return err
+	}
+	if !r.branchExists(name) {
+		return ErrBranchNotFound
+	}
+
+	_, err := r.runGit(nil, "symbolic-ref", "HEAD", plumbing.NewBranchReferenceName(name).String())
+	return err
+}
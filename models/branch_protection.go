@@ -0,0 +1,115 @@
+//models/branch_protection.go
+
+package models
+
+import (
+	"path"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// BranchProtection protects the branches of a repository matching Pattern
+// from being deleted, renamed or force pushed. Patterns are exact names or
+// globs where * does not match a slash, such as release/*.
+type BranchProtection struct {
+	ID        string    `gorm:"primarykey" json:"id"`
+	RepoName  string    `gorm:"uniqueIndex:idx_protection_repo_pattern;not null" json:"repo"`
+	Pattern   string    `gorm:"uniqueIndex:idx_protection_repo_pattern;not null" json:"pattern"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Matches reports whether the rule protects branch.
+func (p *BranchProtection) Matches(branch string) bool {
+	matched, err := path.Match(p.Pattern, branch)
+	return err == nil && matched
+}
+
+// ValidProtectionPattern reports whether pattern is a branch name in which
+// * may stand for any part of a name.
+func ValidProtectionPattern(pattern string) bool {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return false
+	}
+	return ValidBranchName(strings.ReplaceAll(pattern, "*", "x"))
+}
+
+// BranchProtectionService stores branch protection rules.
+type BranchProtectionService struct {
+	db *gorm.DB
+}
+
+func NewBranchProtectionService(db *gorm.DB) *BranchProtectionService {
+	return &BranchProtectionService{db: db}
+}
+
+// List returns the rules of a repository ordered by pattern.
+func (s *BranchProtectionService) List(repoName string) ([]BranchProtection, error) {
+	var rules []BranchProtection
+	err := s.db.Where("repo_name = ?", repoName).Order("pattern").Find(&rules).Error
+	return rules, err
+}
+
+// Add protects the branches of a repository matching pattern. Adding a
+// pattern twice is not an error.
+func (s *BranchProtectionService) Add(repoName, pattern string) (*BranchProtection, error) {
+	rule := &BranchProtection{}
+	err := s.db.Where("repo_name = ? AND pattern = ?", repoName, pattern).First(rule).Error
+	if err == nil {
+		return rule, nil
+	}
+	if err != gorm.ErrRecordNotFound {
+		return nil, err
+	}
+
+	rule = &BranchProtection{
+		ID:        uuid.New().String(),
+		RepoName:  repoName,
+		Pattern:   pattern,
+		CreatedAt: time.Now(),
+	}
+	return rule, s.db.Create(rule).Error
+}
+
+// Delete removes a rule of a repository.
+func (s *BranchProtectionService) Delete(repoName, id string) error {
+	return s.db.Where("repo_name = ? AND id = ?", repoName, id).Delete(&BranchProtection{}).Error
+}
+
+// DeleteByRepo removes every rule of a repository.
+func (s *BranchProtectionService) DeleteByRepo(repoName string) error {
+	return s.db.Where("repo_name = ?", repoName).Delete(&BranchProtection{}).Error
+}
+
+// RenameRepo moves every rule of a repository to its new name.
+func (s *BranchProtectionService) RenameRepo(oldName, newName string) error {
+	return s.db.Model(&BranchProtection{}).Where("repo_name = ?", oldName).Update("repo_name", newName).Error
+}
+
+// Protected returns the names among branches that a rule of the repository
+// protects.
+func (s *BranchProtectionService) Protected(repoName string, branches []string) (map[string]bool, error) {
+	rules, err := s.List(repoName)
+	if err != nil {
+		return nil, err
+	}
+
+	protected := make(map[string]bool)
+	for _, branch := range branches {
+		for i := range rules {
+			if rules[i].Matches(branch) {
+				protected[branch] = true
+				break
+			}
+		}
+	}
+	return protected, nil
+}
+
+// IsProtected reports whether a rule of the repository protects branch.
+func (s *BranchProtectionService) IsProtected(repoName, branch string) (bool, error) {
+	protected, err := s.Protected(repoName, []string{branch})
+	return protected[branch], err
+}
@@ -0,0 +1,63 @@
+//models/check.go
+
+package models
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Check reports the first problem found with r: git cannot read it, or it
+// borrows objects from a repository that no longer exists. With full set
+// it also checks that every object reachable from its references is
+// present, which reads the whole repository.
+func (r *Repository) Check(full bool) error {
+	alternates, err := r.alternates()
+	if err != nil {
+		return err
+	}
+	for _, dir := range alternates {
+		if _, err := os.Stat(dir); err != nil {
+			return fmt.Errorf("borrows objects from %s, which is missing", dir)
+		}
+	}
+
+	// Keep git from using a repository above r if r is broken
+	env := []string{"GIT_DIR=."}
+	if _, err := r.runGit(env, "for-each-ref", "--count=1"); err != nil {
+		return err
+	}
+
+	if full {
+		if _, err := r.runGit(env, "fsck", "--connectivity-only", "--no-progress"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// alternates returns the object directories r borrows objects from.
+func (r *Repository) alternates() ([]string, error) {
+	data, err := os.ReadFile(filepath.Join(r.Path, "objects", "info", "alternates"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var dirs []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if !filepath.IsAbs(line) {
+			line = filepath.Join(r.Path, "objects", line)
+		}
+		dirs = append(dirs, line)
+	}
+	return dirs, nil
+}
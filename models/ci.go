@@ -0,0 +1,200 @@
+//models/ci.go
+
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// CI job and step states. Jobs end as success, failure or error; steps
+// after a failed one are skipped.
+const (
+	CIQueued  = "queued"
+	CIRunning = "running"
+	CISuccess = "success"
+	CIFailure = "failure"
+	CIError   = "error"
+	CISkipped = "skipped"
+)
+
+// CIJob is one run of a repository's pipeline for a pushed commit.
+// Number is sequential per repository.
+type CIJob struct {
+	ID         string     `gorm:"primarykey" json:"id"`
+	RepoName   string     `gorm:"index;uniqueIndex:idx_ci_repo_number" json:"repo"`
+	Number     int        `gorm:"uniqueIndex:idx_ci_repo_number" json:"number"`
+	Pipeline   string     `json:"pipeline"`
+	Ref        string     `json:"ref"`
+	Commit     string     `gorm:"index" json:"commit"`
+	Status     string     `gorm:"index;not null" json:"status"`
+	Error      string     `json:"error,omitempty"`
+	Steps      []CIStep   `gorm:"foreignKey:JobID" json:"steps"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// CIStep is one shell command of a job.
+type CIStep struct {
+	ID         string        `gorm:"primarykey" json:"id"`
+	JobID      string        `gorm:"index;not null" json:"job_id"`
+	Index      int           `json:"index"`
+	Name       string        `json:"name"`
+	Command    string        `json:"command"`
+	Timeout    time.Duration `json:"timeout"`
+	Status     string        `gorm:"not null" json:"status"`
+	ExitCode   int           `json:"exit_code"`
+	StartedAt  *time.Time    `json:"started_at,omitempty"`
+	FinishedAt *time.Time    `json:"finished_at,omitempty"`
+}
+
+// Finished reports whether the job has completed.
+func (j *CIJob) Finished() bool {
+	return j.Status != CIQueued && j.Status != CIRunning
+}
+
+// Duration returns how long the job ran, or has been running.
+func (j *CIJob) Duration() time.Duration {
+	if j.StartedAt == nil {
+		return 0
+	}
+	end := time.Now()
+	if j.FinishedAt != nil {
+		end = *j.FinishedAt
+	}
+	return end.Sub(*j.StartedAt).Round(time.Second)
+}
+
+// CIService stores CI jobs and their steps.
+type CIService struct {
+	db *gorm.DB
+}
+
+func NewCIService(db *gorm.DB) *CIService {
+	return &CIService{db: db}
+}
+
+// CreateJob stores a new job with its steps and assigns it the next
+// number of its repository.
+func (s *CIService) CreateJob(job *CIJob) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var last int
+		if err := tx.Model(&CIJob{}).Where("repo_name = ?", job.RepoName).
+			Select("COALESCE(MAX(number), 0)").Scan(&last).Error; err != nil {
+			return err
+		}
+
+		job.ID = uuid.New().String()
+		job.Number = last + 1
+		if job.Status == "" {
+			job.Status = CIQueued
+		}
+		for i := range job.Steps {
+			job.Steps[i].ID = uuid.New().String()
+			job.Steps[i].Index = i
+			job.Steps[i].Status = CIQueued
+		}
+
+		if err := tx.Create(job).Error; err != nil {
+			return fmt.Errorf("failed to create job: %w", err)
+		}
+		return nil
+	})
+}
+
+// GetJob returns a job of a repository by number with its steps.
+func (s *CIService) GetJob(repoName string, number int) (*CIJob, error) {
+	return s.getJob(s.db.Where("repo_name = ? AND number = ?", repoName, number))
+}
+
+// GetJobByID returns a job by ID with its steps.
+func (s *CIService) GetJobByID(id string) (*CIJob, error) {
+	return s.getJob(s.db.Where("id = ?", id))
+}
+
+func (s *CIService) getJob(query *gorm.DB) (*CIJob, error) {
+	var job CIJob
+	err := query.Preload("Steps", func(db *gorm.DB) *gorm.DB { return db.Order("`index` ASC") }).First(&job).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("job not found")
+		}
+		return nil, err
+	}
+	return &job, nil
+}
+
+// ListJobs returns the most recent jobs of a repository, newest first.
+func (s *CIService) ListJobs(repoName string, limit int) ([]CIJob, error) {
+	var jobs []CIJob
+	err := s.db.Where("repo_name = ?", repoName).Order("number DESC").Limit(limit).Find(&jobs).Error
+	return jobs, err
+}
+
+// ListForCommit returns the jobs that ran for a commit, newest first.
+func (s *CIService) ListForCommit(repoName, commit string) ([]CIJob, error) {
+	var jobs []CIJob
+	err := s.db.Where("repo_name = ? AND `commit` = ?", repoName, commit).Order("number DESC").Find(&jobs).Error
+	return jobs, err
+}
+
+// StartJob marks a job running.
+func (s *CIService) StartJob(job *CIJob) error {
+	now := time.Now()
+	job.Status = CIRunning
+	job.StartedAt = &now
+	return s.db.Model(job).Select("status", "started_at").Updates(job).Error
+}
+
+// FinishJob records the final status of a job.
+func (s *CIService) FinishJob(job *CIJob, status, errMsg string) error {
+	now := time.Now()
+	job.Status = status
+	job.Error = errMsg
+	job.FinishedAt = &now
+	return s.db.Model(job).Select("status", "error", "finished_at").Updates(job).Error
+}
+
+// StartStep marks a step running.
+func (s *CIService) StartStep(step *CIStep) error {
+	now := time.Now()
+	step.Status = CIRunning
+	step.StartedAt = &now
+	return s.db.Model(step).Select("status", "started_at").Updates(step).Error
+}
+
+// FinishStep records the status and exit code of a step.
+func (s *CIService) FinishStep(step *CIStep, status string, exitCode int) error {
+	now := time.Now()
+	step.Status = status
+	step.ExitCode = exitCode
+	step.FinishedAt = &now
+	return s.db.Model(step).Select("status", "exit_code", "finished_at").Updates(step).Error
+}
+
+// Unfinished returns the jobs that are queued or running, oldest first.
+func (s *CIService) Unfinished() ([]CIJob, error) {
+	var jobs []CIJob
+	err := s.db.Preload("Steps").Where("status IN ?", []string{CIQueued, CIRunning}).Order("created_at ASC").Find(&jobs).Error
+	return jobs, err
+}
+
+// DeleteByRepo removes every job and step of a repository.
+func (s *CIService) DeleteByRepo(repoName string) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		jobs := tx.Model(&CIJob{}).Select("id").Where("repo_name = ?", repoName)
+		if err := tx.Where("job_id IN (?)", jobs).Delete(&CIStep{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("repo_name = ?", repoName).Delete(&CIJob{}).Error
+	})
+}
+
+// RenameRepo moves every job of a repository to its new name.
+func (s *CIService) RenameRepo(oldName, newName string) error {
+	return s.db.Model(&CIJob{}).Where("repo_name = ?", oldName).Update("repo_name", newName).Error
+}
@@ -0,0 +1,145 @@
+//models/commit_status.go
+
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Commit status states.
+const (
+	StatusPending = "pending"
+	StatusSuccess = "success"
+	StatusFailure = "failure"
+	StatusError   = "error"
+)
+
+// CommitStatus is the result a check such as a CI job reported for a
+// commit. There is one status per repository, commit and context; setting
+// it again replaces the previous state.
+type CommitStatus struct {
+	ID          string    `gorm:"primarykey" json:"id"`
+	RepoName    string    `gorm:"uniqueIndex:idx_status_repo_commit_context;not null" json:"repo"`
+	Commit      string    `gorm:"uniqueIndex:idx_status_repo_commit_context;not null" json:"commit"`
+	Context     string    `gorm:"uniqueIndex:idx_status_repo_commit_context;not null" json:"context"`
+	State       string    `gorm:"not null" json:"state"`
+	TargetURL   string    `json:"target_url,omitempty"`
+	Description string    `json:"description,omitempty"`
+	CreatorID   string    `json:"creator_id,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// CombinedStatus summarises every status of a commit: failure if any check
+// failed or errored, pending if any is still running, success otherwise.
+type CombinedStatus struct {
+	State    string         `json:"state"`
+	Statuses []CommitStatus `json:"statuses"`
+}
+
+// ValidStatusState reports whether state is one of the status constants.
+func ValidStatusState(state string) bool {
+	switch state {
+	case StatusPending, StatusSuccess, StatusFailure, StatusError:
+		return true
+	}
+	return false
+}
+
+// CommitStatusService stores commit statuses.
+type CommitStatusService struct {
+	db *gorm.DB
+}
+
+func NewCommitStatusService(db *gorm.DB) *CommitStatusService {
+	return &CommitStatusService{db: db}
+}
+
+// Set creates or replaces the status of a commit for status.Context.
+func (s *CommitStatusService) Set(status *CommitStatus) error {
+	if status.ID == "" {
+		status.ID = uuid.New().String()
+	}
+	now := time.Now()
+	status.CreatedAt = now
+	status.UpdatedAt = now
+
+	err := s.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "repo_name"}, {Name: "commit"}, {Name: "context"}},
+		DoUpdates: clause.AssignmentColumns([]string{"state", "target_url", "description", "creator_id", "updated_at"}),
+	}).Create(status).Error
+	if err != nil {
+		return err
+	}
+
+	// Reload so a replaced status keeps its original ID and creation time
+	var stored CommitStatus
+	if err := s.db.Where("repo_name = ? AND `commit` = ? AND context = ?", status.RepoName, status.Commit, status.Context).First(&stored).Error; err != nil {
+		return err
+	}
+	*status = stored
+	return nil
+}
+
+// List returns the statuses of a commit ordered by context.
+func (s *CommitStatusService) List(repoName, commit string) ([]CommitStatus, error) {
+	var statuses []CommitStatus
+	err := s.db.Where("repo_name = ? AND `commit` = ?", repoName, commit).Order("context ASC").Find(&statuses).Error
+	return statuses, err
+}
+
+// Combined returns the combined status of each of the given commits that
+// has at least one status.
+func (s *CommitStatusService) Combined(repoName string, commits []string) (map[string]*CombinedStatus, error) {
+	combined := make(map[string]*CombinedStatus)
+	if len(commits) == 0 {
+		return combined, nil
+	}
+
+	var statuses []CommitStatus
+	err := s.db.Where("repo_name = ? AND `commit` IN ?", repoName, commits).Order("context ASC").Find(&statuses).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, status := range statuses {
+		c, ok := combined[status.Commit]
+		if !ok {
+			c = &CombinedStatus{State: StatusSuccess}
+			combined[status.Commit] = c
+		}
+		c.Statuses = append(c.Statuses, status)
+
+		switch {
+		case status.State == StatusFailure || status.State == StatusError:
+			c.State = StatusFailure
+		case status.State == StatusPending && c.State != StatusFailure:
+			c.State = StatusPending
+		}
+	}
+	return combined, nil
+}
+
+// DeleteByRepo removes every status of a repository.
+func (s *CommitStatusService) DeleteByRepo(repoName string) error {
+	return s.db.Where("repo_name = ?", repoName).Delete(&CommitStatus{}).Error
+}
+
+// RenameRepo moves every status of a repository to its new name. Links
+// to the repository's CI jobs follow it.
+func (s *CommitStatusService) RenameRepo(oldName, newName string) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		oldPrefix, newPrefix := "/ci/"+oldName+"/", "/ci/"+newName+"/"
+		err := tx.Model(&CommitStatus{}).
+			Where("repo_name = ? AND SUBSTR(target_url, 1, ?) = ?", oldName, len(oldPrefix), oldPrefix).
+			Update("target_url", gorm.Expr("? || SUBSTR(target_url, ?)", newPrefix, len(oldPrefix)+1)).Error
+		if err != nil {
+			return err
+		}
+		return tx.Model(&CommitStatus{}).Where("repo_name = ?", oldName).Update("repo_name", newName).Error
+	})
+}
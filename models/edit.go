@@ -0,0 +1,318 @@
+//models/edit.go
+
+package models
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// Errors of commits made from the web.
+var (
+	ErrBranchMoved  = errors.New("the branch has moved since the change was started")
+	ErrBranchExists = errors.New("the branch already exists")
+	ErrFileExists   = errors.New("a file with that name already exists")
+	ErrNoChanges    = errors.New("the commit does not change any files")
+
+	// ErrInvalidChange wraps changes that cannot be applied to the tree,
+	// such as writing a file where a directory is.
+	ErrInvalidChange = errors.New("invalid change")
+)
+
+// FileChange is a file written or removed by a commit made from the web.
+//
+// Parameters:
+//   - Path: The slash separated path of the file.
+//   - Content: The new content of the file.
+//   - Delete: Remove the file instead of writing it.
+//   - Create: Fail with ErrFileExists if the file already exists.
+type FileChange struct {
+	Path    string
+	Content []byte
+	Delete  bool
+	Create  bool
+}
+
+// CommitFilesOptions describes a commit made from the web.
+//
+// Parameters:
+//   - Branch: The branch the change was started from.
+//   - Parent: The commit of Branch the change was started from. Empty for
+//     the first commit of an empty repository.
+//   - NewBranch: If set, the commit goes to this new branch instead of
+//     Branch.
+//   - Message: The commit message.
+//   - Author: Who the commit is authored and committed by.
+//   - Files: The files to write or remove.
+type CommitFilesOptions struct {
+	Branch    string
+	Parent    string
+	NewBranch string
+	Message   string
+	Author    MergeSignature
+	Files     []FileChange
+}
+
+// ValidFilePath reports whether p can be written by a web commit: a
+// relative path without empty, "." or ".." components that does not reach
+// into a .git directory.
+func ValidFilePath(p string) bool {
+	if p == "" || len(p) > 4096 || strings.ContainsRune(p, 0) {
+		return false
+	}
+	for _, part := range strings.Split(p, "/") {
+		if part == "" || part == "." || part == ".." || strings.EqualFold(part, ".git") {
+			return false
+		}
+	}
+	return true
+}
+
+// CommitFiles writes files to a branch without a worktree and returns the
+// new commit. The branch is only moved if it still points at Parent, so
+// edits based on an outdated version fail with ErrBranchMoved instead of
+// silently reverting newer commits.
+func (r *Repository) CommitFiles(opts CommitFilesOptions) (plumbing.Hash, error) {
+	if err := r.initGit(); err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	target := opts.Branch
+	if opts.NewBranch != "" {
+		target = opts.NewBranch
+		if _, err := r.git.Reference(plumbing.NewBranchReferenceName(target), false); err == nil {
+			return plumbing.ZeroHash, ErrBranchExists
+		}
+	}
+
+	// Commits to the branch itself must be based on its current tip
+	var parent *object.Commit
+	if opts.Parent != "" {
+		if !plumbing.IsHash(opts.Parent) {
+			return plumbing.ZeroHash, fmt.Errorf("%w: %q is not a commit", ErrInvalidChange, opts.Parent)
+		}
+		commit, err := r.git.CommitObject(plumbing.NewHash(opts.Parent))
+		if err != nil {
+			return plumbing.ZeroHash, fmt.Errorf("parent commit %s: %w", opts.Parent, err)
+		}
+		parent = commit
+	}
+	if opts.NewBranch == "" {
+		tip, err := r.ResolveBranch(opts.Branch)
+		switch {
+		case err == plumbing.ErrReferenceNotFound && parent == nil:
+		case err != nil && err != plumbing.ErrReferenceNotFound:
+			return plumbing.ZeroHash, err
+		case parent == nil || tip != parent.Hash:
+			return plumbing.ZeroHash, ErrBranchMoved
+		}
+	}
+
+	var baseTree *object.Tree
+	if parent != nil {
+		tree, err := parent.Tree()
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+		baseTree = tree
+	}
+
+	changes := make(map[string]FileChange, len(opts.Files))
+	for _, change := range opts.Files {
+		if !ValidFilePath(change.Path) {
+			return plumbing.ZeroHash, fmt.Errorf("%w: invalid file path %q", ErrInvalidChange, change.Path)
+		}
+		changes[change.Path] = change
+	}
+
+	treeHash, err := r.writeTree(baseTree, "", changes)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	if parent != nil && treeHash == parent.TreeHash {
+		return plumbing.ZeroHash, ErrNoChanges
+	}
+
+	now := time.Now()
+	sig := object.Signature{Name: opts.Author.Name, Email: opts.Author.Email, When: now}
+	commit := &object.Commit{
+		Author:    sig,
+		Committer: sig,
+		Message:   opts.Message,
+		TreeHash:  treeHash,
+	}
+	old := plumbing.ZeroHash
+	if parent != nil {
+		commit.ParentHashes = []plumbing.Hash{parent.Hash}
+		if opts.NewBranch == "" {
+			old = parent.Hash
+		}
+	}
+
+	commitHash, err := r.storeObject(commit)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	// update-ref checks the old value under the ref lock, which also covers
+	// pushes that land between the check above and here
+	ref := plumbing.NewBranchReferenceName(target).String()
+	if _, err := r.runGit(nil, "update-ref", ref, commitHash.String(), old.String()); err != nil {
+		if opts.NewBranch != "" {
+			return plumbing.ZeroHash, ErrBranchExists
+		}
+		return plumbing.ZeroHash, ErrBranchMoved
+	}
+
+	return commitHash, nil
+}
+
+// encodable is an object that can be written to the object store.
+type encodable interface {
+	Encode(plumbing.EncodedObject) error
+}
+
+// storeObject writes an object to the repository and returns its hash.
+func (r *Repository) storeObject(obj encodable) (plumbing.Hash, error) {
+	encoded := r.git.Storer.NewEncodedObject()
+	if err := obj.Encode(encoded); err != nil {
+		return plumbing.ZeroHash, err
+	}
+	return r.git.Storer.SetEncodedObject(encoded)
+}
+
+// storeBlob writes a blob to the repository and returns its hash.
+func (r *Repository) storeBlob(content []byte) (plumbing.Hash, error) {
+	obj := r.git.Storer.NewEncodedObject()
+	obj.SetType(plumbing.BlobObject)
+	obj.SetSize(int64(len(content)))
+
+	w, err := obj.Writer()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	if _, err := w.Write(content); err != nil {
+		w.Close()
+		return plumbing.ZeroHash, err
+	}
+	if err := w.Close(); err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	return r.git.Storer.SetEncodedObject(obj)
+}
+
+// writeTree applies changes below dir to base, which may be nil for an
+// empty directory, and writes the resulting tree. Directories left empty
+// are dropped, so the returned hash is zero when nothing remains.
+func (r *Repository) writeTree(base *object.Tree, dir string, changes map[string]FileChange) (plumbing.Hash, error) {
+	entries := make(map[string]object.TreeEntry)
+	if base != nil {
+		for _, entry := range base.Entries {
+			entries[entry.Name] = entry
+		}
+	}
+
+	// Group the changes by the entry of this directory they fall under
+	files := make(map[string]FileChange)
+	subdirs := make(map[string]map[string]FileChange)
+	prefix := ""
+	if dir != "" {
+		prefix = dir + "/"
+	}
+	for p, change := range changes {
+		name, rest, nested := strings.Cut(strings.TrimPrefix(p, prefix), "/")
+		if !nested {
+			files[name] = change
+			continue
+		}
+		if subdirs[name] == nil {
+			subdirs[name] = make(map[string]FileChange)
+		}
+		subdirs[name][prefix+name+"/"+rest] = change
+	}
+
+	for name, change := range files {
+		existing, exists := entries[name]
+		if exists && existing.Mode == filemode.Dir {
+			return plumbing.ZeroHash, fmt.Errorf("%w: %s is a directory", ErrInvalidChange, change.Path)
+		}
+
+		if change.Delete {
+			if !exists {
+				return plumbing.ZeroHash, fmt.Errorf("%w: %s does not exist", ErrInvalidChange, change.Path)
+			}
+			delete(entries, name)
+			continue
+		}
+		if change.Create && exists {
+			return plumbing.ZeroHash, ErrFileExists
+		}
+
+		hash, err := r.storeBlob(change.Content)
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+
+		// Keep the executable bit of edited files
+		mode := filemode.Regular
+		if exists && existing.Mode == filemode.Executable {
+			mode = filemode.Executable
+		}
+		entries[name] = object.TreeEntry{Name: name, Mode: mode, Hash: hash}
+	}
+
+	for name, subChanges := range subdirs {
+		var subtree *object.Tree
+		if existing, exists := entries[name]; exists {
+			if existing.Mode != filemode.Dir {
+				return plumbing.ZeroHash, fmt.Errorf("%w: %s is a file", ErrInvalidChange, path.Join(dir, name))
+			}
+			tree, err := r.git.TreeObject(existing.Hash)
+			if err != nil {
+				return plumbing.ZeroHash, err
+			}
+			subtree = tree
+		}
+
+		hash, err := r.writeTree(subtree, path.Join(dir, name), subChanges)
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+		if hash.IsZero() {
+			delete(entries, name)
+		} else {
+			entries[name] = object.TreeEntry{Name: name, Mode: filemode.Dir, Hash: hash}
+		}
+	}
+
+	if len(entries) == 0 && dir != "" {
+		return plumbing.ZeroHash, nil
+	}
+
+	tree := &object.Tree{Entries: make([]object.TreeEntry, 0, len(entries))}
+	for _, entry := range entries {
+		tree.Entries = append(tree.Entries, entry)
+	}
+
+	// Git orders tree entries as if directory names ended with a slash
+	sortKey := func(e object.TreeEntry) string {
+		if e.Mode == filemode.Dir {
+			return e.Name + "/"
+		}
+		return e.Name
+	}
+	sort.Slice(tree.Entries, func(i, j int) bool {
+		return sortKey(tree.Entries[i]) < sortKey(tree.Entries[j])
+	})
+
+	return r.storeObject(tree)
+}
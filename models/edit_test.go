@@ -0,0 +1,204 @@
+//models/edit_test.go
+
+package models
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+var testAuthor = MergeSignature{Name: "Alice", Email: "alice@example.com"}
+
+// newTestRepo creates a bare repository in a temporary directory whose
+// branch main has a README.
+func newTestRepo(t *testing.T) *Repository {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "demo.git")
+	if _, err := git.PlainInit(path, true); err != nil {
+		t.Fatal(err)
+	}
+	repo := &Repository{Name: "alice/demo", Path: path}
+	if _, err := repo.CommitFiles(CommitFilesOptions{
+		Branch:  "main",
+		Message: "Add README",
+		Author:  testAuthor,
+		Files:   []FileChange{{Path: "README.md", Content: []byte("# Demo\n")}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return repo
+}
+
+// commitFile commits one file to branch, creating it from base if base is
+// set, and returns the commit.
+func commitFile(t *testing.T, repo *Repository, branch, base, path, content string) string {
+	t.Helper()
+	opts := CommitFilesOptions{Branch: branch, Message: "Change " + path, Author: testAuthor, Files: []FileChange{{Path: path, Content: []byte(content)}}}
+	if base != "" {
+		opts.Branch, opts.NewBranch = base, branch
+	}
+	tip, err := repo.ResolveBranch(opts.Branch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts.Parent = tip.String()
+	commit, err := repo.CommitFiles(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return commit.String()
+}
+
+func TestValidFilePath(t *testing.T) {
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"README.md", true},
+		{"docs/guide/install.md", true},
+		{".github/workflows/ci.yml", true},
+		{".gitignore", true},
+		{"", false},
+		{"/etc/passwd", false},
+		{"docs/", false},
+		{"docs//install.md", false},
+		{"./README.md", false},
+		{"docs/../README.md", false},
+		{"..", false},
+		{".git/config", false},
+		{"sub/.GIT/hooks/pre-receive", false},
+		{"nul\x00byte", false},
+	}
+	for _, tt := range tests {
+		if got := ValidFilePath(tt.path); got != tt.want {
+			t.Errorf("ValidFilePath(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
+
+// treeFiles returns the paths and contents of the files on branch.
+func treeFiles(t *testing.T, repo *Repository, branch string) map[string]string {
+	t.Helper()
+	tip, err := repo.ResolveBranch(branch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	commit, err := repo.git.CommitObject(tip)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree, err := commit.Tree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := make(map[string]string)
+	err = tree.Files().ForEach(func(f *object.File) error {
+		content, err := f.Contents()
+		files[f.Name] = content
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+func TestCommitFiles(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   []FileChange
+		want    map[string]string
+		wantErr error
+	}{
+		{
+			name:  "edit a nested file",
+			files: []FileChange{{Path: "src/app/main.go", Content: []byte("package app\n")}},
+			want:  map[string]string{"README.md": "# Demo\n", "src/app/main.go": "package app\n", "src/util.go": "package src\n"},
+		},
+		{
+			name:  "create a file in a new directory",
+			files: []FileChange{{Path: "docs/guide.md", Content: []byte("Guide\n"), Create: true}},
+			want:  map[string]string{"README.md": "# Demo\n", "docs/guide.md": "Guide\n", "src/app/main.go": "package main\n", "src/util.go": "package src\n"},
+		},
+		{
+			name:  "delete the last file of a directory",
+			files: []FileChange{{Path: "src/app/main.go", Delete: true}},
+			want:  map[string]string{"README.md": "# Demo\n", "src/util.go": "package src\n"},
+		},
+		{
+			name:  "move a file",
+			files: []FileChange{{Path: "src/util.go", Delete: true}, {Path: "util.go", Content: []byte("package src\n"), Create: true}},
+			want:  map[string]string{"README.md": "# Demo\n", "src/app/main.go": "package main\n", "util.go": "package src\n"},
+		},
+		{"create an existing file", []FileChange{{Path: "README.md", Content: []byte("x"), Create: true}}, nil, ErrFileExists},
+		{"delete a missing file", []FileChange{{Path: "src/missing.go", Delete: true}}, nil, ErrInvalidChange},
+		{"write over a directory", []FileChange{{Path: "src", Content: []byte("x")}}, nil, ErrInvalidChange},
+		{"write below a file", []FileChange{{Path: "README.md/x", Content: []byte("x")}}, nil, ErrInvalidChange},
+		{"write into .git", []FileChange{{Path: ".git/config", Content: []byte("x")}}, nil, ErrInvalidChange},
+		{"leave the tree unchanged", []FileChange{{Path: "README.md", Content: []byte("# Demo\n")}}, nil, ErrNoChanges},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newTestRepo(t)
+			commitFile(t, repo, "main", "", "src/app/main.go", "package main\n")
+			tip := commitFile(t, repo, "main", "", "src/util.go", "package src\n")
+
+			_, err := repo.CommitFiles(CommitFilesOptions{Branch: "main", Parent: tip, Message: tt.name, Author: testAuthor, Files: tt.files})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("CommitFiles() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				if head, _ := repo.ResolveBranch("main"); head.String() != tip {
+					t.Errorf("main moved to %s after a failed commit", head)
+				}
+				return
+			}
+			if got := treeFiles(t, repo, "main"); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("files = %v, want %v", keys(got), keys(tt.want))
+			}
+		})
+	}
+}
+
+func TestCommitFilesRequiresTheCurrentTip(t *testing.T) {
+	repo := newTestRepo(t)
+	old, err := repo.ResolveBranch("main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	commitFile(t, repo, "main", "", "newer.txt", "newer\n")
+
+	_, err = repo.CommitFiles(CommitFilesOptions{Branch: "main", Parent: old.String(), Message: "Stale", Author: testAuthor,
+		Files: []FileChange{{Path: "README.md", Content: []byte("stale\n")}}})
+	if err != ErrBranchMoved {
+		t.Errorf("committing on an old tip: error = %v, want %v", err, ErrBranchMoved)
+	}
+
+	// A new branch may start from any commit but not replace a branch
+	opts := CommitFilesOptions{Branch: "main", Parent: old.String(), NewBranch: "fix", Message: "Fix", Author: testAuthor,
+		Files: []FileChange{{Path: "README.md", Content: []byte("fixed\n")}}}
+	if _, err := repo.CommitFiles(opts); err != nil {
+		t.Fatal(err)
+	}
+	if files := treeFiles(t, repo, "fix"); files["README.md"] != "fixed\n" || files["newer.txt"] != "" {
+		t.Errorf("fix has %v, want only README.md", keys(files))
+	}
+	if _, err := repo.CommitFiles(opts); err != ErrBranchExists {
+		t.Errorf("creating fix twice: error = %v, want %v", err, ErrBranchExists)
+	}
+}
+
+func keys(m map[string]string) []string {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -20,7 +20,10 @@ type ErrorType string
 const (
 	ErrorTypeNotFound      ErrorType = "NOT_FOUND"
 	ErrorTypeUnauthorized  ErrorType = "UNAUTHORIZED"
+	ErrorTypeForbidden     ErrorType = "FORBIDDEN"
 	ErrorTypeBadRequest    ErrorType = "BAD_REQUEST"
+	ErrorTypeConflict      ErrorType = "CONFLICT"
+	ErrorTypeMethod        ErrorType = "METHOD_NOT_ALLOWED"
 	ErrorTypeInternal      ErrorType = "INTERNAL"
 	ErrorTypeGit           ErrorType = "GIT_ERROR"
 	ErrorTypeInvalidPath   ErrorType = "INVALID_PATH"
@@ -100,10 +103,22 @@ func NewUnauthorizedError(message string) *AppError {
 	return NewError(ErrorTypeUnauthorized, message, http.StatusUnauthorized)
 }
 
+func NewForbiddenError(message string) *AppError {
+	return NewError(ErrorTypeForbidden, message, http.StatusForbidden)
+}
+
 func NewBadRequestError(message string) *AppError {
 	return NewError(ErrorTypeBadRequest, message, http.StatusBadRequest)
 }
 
+func NewConflictError(message string) *AppError {
+	return NewError(ErrorTypeConflict, message, http.StatusConflict)
+}
+
+func NewMethodNotAllowedError(message string) *AppError {
+	return NewError(ErrorTypeMethod, message, http.StatusMethodNotAllowed)
+}
+
 func NewInternalError(message string) *AppError {
 	return NewError(ErrorTypeInternal, message, http.StatusInternalServerError)
 }
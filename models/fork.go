@@ -0,0 +1,172 @@
+//models/fork.go
+
+package models
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-git/go-billy/v5/osfs"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/cache"
+	"github.com/go-git/go-git/v5/storage/filesystem"
+)
+
+// upstreamRefPrefix is where a fork keeps the branches of its parent that
+// it was compared with. Clients only fetch refs/heads and refs/tags, so
+// these refs stay on the server.
+const upstreamRefPrefix = "refs/upstream/heads/"
+
+// OpenGit opens the bare repository at path with go-git. Unlike
+// git.PlainOpen it follows objects/info/alternates to other repositories
+// on the server, which forks borrow their parent's objects from.
+func OpenGit(path string) (*git.Repository, error) {
+	if _, err := os.Stat(path); err != nil {
+		if os.IsNotExist(err) {
+			return nil, git.ErrRepositoryNotExists
+		}
+		return nil, err
+	}
+	storage := filesystem.NewStorageWithOptions(osfs.New(path), cache.NewObjectLRUDefault(), filesystem.Options{
+		AlternatesFS: osfs.New(string(filepath.Separator)),
+	})
+	return git.Open(storage, nil)
+}
+
+// Fork creates a bare repository at path that starts as a copy of r and
+// records r as its parent. The fork borrows r's objects through
+// objects/info/alternates instead of copying them, so r is told to keep
+// objects it no longer references: a fork may still need them.
+func (r *Repository) Fork(path string) (*Repository, error) {
+	cmd := exec.Command("git", "clone", "--bare", "--shared", "--quiet", "--", r.Path, path)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		os.RemoveAll(path)
+		return nil, fmt.Errorf("git clone: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+
+	if _, err := r.runGit(nil, "config", "gc.pruneExpire", "never"); err != nil {
+		os.RemoveAll(path)
+		return nil, err
+	}
+
+	fork := &Repository{Path: path}
+	// The clone's origin remote would point at a path on the server
+	if _, err := fork.runGit(nil, "remote", "remove", "origin"); err != nil {
+		os.RemoveAll(path)
+		return nil, err
+	}
+	if err := fork.SetParent(r.Name); err != nil {
+		os.RemoveAll(path)
+		return nil, err
+	}
+	if r.Description != "" {
+		if err := fork.SetDescription(r.Description); err != nil {
+			os.RemoveAll(path)
+			return nil, err
+		}
+	}
+	return fork, nil
+}
+
+// LoadParent reads the name of the repository r was forked from.
+func (r *Repository) LoadParent() {
+	if err := r.initGit(); err != nil {
+		return
+	}
+	if cfg, err := r.git.Config(); err == nil {
+		r.Parent = cfg.Raw.Section("simplegit").Option("parent")
+	}
+}
+
+// SetParent records the repository r was forked from. An empty parent
+// removes the record.
+func (r *Repository) SetParent(parent string) error {
+	args := []string{"config", "simplegit.parent", parent}
+	if parent == "" {
+		if r.Parent == "" {
+			return nil
+		}
+		args = []string{"config", "--unset", "simplegit.parent"}
+	}
+	if _, err := r.runGit(nil, args...); err != nil {
+		return fmt.Errorf("failed to record parent: %w", err)
+	}
+	r.Parent = parent
+	return nil
+}
+
+// Dissociate copies the objects r borrows from other repositories into r
+// and stops borrowing, so r keeps working when they are deleted.
+func (r *Repository) Dissociate() error {
+	alternates := filepath.Join(r.Path, "objects", "info", "alternates")
+	if _, err := os.Stat(alternates); os.IsNotExist(err) {
+		return nil
+	}
+
+	if _, err := r.runGit(nil, "repack", "-a", "-d", "-q"); err != nil {
+		return err
+	}
+	if err := os.Remove(alternates); err != nil {
+		return err
+	}
+
+	// go-git caches the object storage, including the alternates
+	r.git = nil
+	return nil
+}
+
+// MoveAlternate points r at the new location of object storage it borrows
+// from after the repository owning it moved from oldPath to newPath.
+func (r *Repository) MoveAlternate(oldPath, newPath string) error {
+	alternates := filepath.Join(r.Path, "objects", "info", "alternates")
+	content, err := os.ReadFile(alternates)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	oldObjects := filepath.Join(oldPath, "objects")
+	lines := strings.Split(strings.TrimRight(string(content), "\n"), "\n")
+	changed := false
+	for i, line := range lines {
+		if filepath.Clean(line) == oldObjects {
+			lines[i] = filepath.Join(newPath, "objects")
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+
+	if err := os.WriteFile(alternates, []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+		return err
+	}
+	r.git = nil
+	return nil
+}
+
+// FetchUpstream copies a branch of parent into r under refs/upstream so it
+// can be compared with r's branches, and returns its commit. Objects r
+// already borrows from parent are not copied again.
+func (r *Repository) FetchUpstream(parent *Repository, branch string) (plumbing.Hash, error) {
+	tip, err := parent.ResolveBranch(branch)
+	if err == plumbing.ErrReferenceNotFound {
+		return plumbing.ZeroHash, ErrBranchNotFound
+	}
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	source := plumbing.NewBranchReferenceName(branch).String()
+	refspec := fmt.Sprintf("+%s:%s%s", source, upstreamRefPrefix, branch)
+	if _, err := r.runGit(nil, "fetch", "--quiet", "--no-tags", "--no-write-fetch-head", parent.Path, refspec); err != nil {
+		return plumbing.ZeroHash, err
+	}
+	return tip, nil
+}
@@ -0,0 +1,96 @@
+//models/hooks.go
+
+package models
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// preReceiveHook rejects pushes that delete the default branch or delete
+// or rewrite a protected branch. The server passes the branch names in the
+// environment of receive-pack; branch names cannot contain whitespace.
+// When the server could not work out what to protect it sets
+// SIMPLEGIT_REJECT_PUSH instead, and every push is rejected.
+const preReceiveHook = `#!/bin/sh
+# Installed by SimpleGit, do not edit.
+if [ -n "$SIMPLEGIT_REJECT_PUSH" ]; then
+	cat >/dev/null
+	echo "error: $SIMPLEGIT_REJECT_PUSH" >&2
+	exit 1
+fi
+
+zero=0000000000000000000000000000000000000000
+status=0
+while read -r old new ref; do
+	case "$ref" in
+	refs/heads/*) branch=${ref#refs/heads/} ;;
+	*) continue ;;
+	esac
+
+	if [ "$new" = "$zero" ] && [ "$branch" = "$SIMPLEGIT_DEFAULT_BRANCH" ]; then
+		echo "error: $branch is the default branch and cannot be deleted" >&2
+		status=1
+		continue
+	fi
+
+	protected=
+	for name in $SIMPLEGIT_PROTECTED_BRANCHES; do
+		[ "$name" = "$branch" ] && protected=1
+	done
+	[ -z "$protected" ] && continue
+
+	if [ "$new" = "$zero" ]; then
+		echo "error: $branch is protected and cannot be deleted" >&2
+		status=1
+	elif [ "$old" != "$zero" ] && ! git merge-base --is-ancestor "$old" "$new"; then
+		echo "error: $branch is protected and cannot be force pushed" >&2
+		status=1
+	fi
+done
+exit $status
+`
+
+// InstallHooks writes the server's git hooks to dir, replacing hooks of
+// older versions. Current hooks are left alone, so a command line run
+// next to the server does not rewrite a hook while a push runs it.
+func InstallHooks(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create hooks directory: %w", err)
+	}
+	path := filepath.Join(dir, "pre-receive")
+	if current, err := os.ReadFile(path); err == nil && string(current) == preReceiveHook {
+		return nil
+	}
+	if err := os.WriteFile(path, []byte(preReceiveHook), 0755); err != nil {
+		return fmt.Errorf("failed to write pre-receive hook: %w", err)
+	}
+	return nil
+}
+
+// ReceivePackEnv returns the environment that makes git receive-pack run
+// the hooks in hooksDir and tells them which branches to protect. Config
+// is passed through the environment so it works for any receive-pack
+// command line.
+func ReceivePackEnv(hooksDir, defaultBranch string, protected []string) []string {
+	return []string{
+		"GIT_CONFIG_COUNT=1",
+		"GIT_CONFIG_KEY_0=core.hooksPath",
+		"GIT_CONFIG_VALUE_0=" + hooksDir,
+		"SIMPLEGIT_DEFAULT_BRANCH=" + defaultBranch,
+		"SIMPLEGIT_PROTECTED_BRANCHES=" + strings.Join(protected, "\n"),
+	}
+}
+
+// RejectPushEnv returns the environment that makes git receive-pack run
+// the hooks in hooksDir and reject the push with reason.
+func RejectPushEnv(hooksDir, reason string) []string {
+	return []string{
+		"GIT_CONFIG_COUNT=1",
+		"GIT_CONFIG_KEY_0=core.hooksPath",
+		"GIT_CONFIG_VALUE_0=" + hooksDir,
+		"SIMPLEGIT_REJECT_PUSH=" + reason,
+	}
+}
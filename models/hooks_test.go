@@ -0,0 +1,93 @@
+//models/hooks_test.go
+
+package models
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// push pushes refspec from the clone in dir to repo and returns the output
+// and whether it succeeded. git clears configuration from the environment
+// of a local receive-pack, so a script sets env for it like the server
+// does.
+func push(t *testing.T, dir string, repo *Repository, env []string, refspec string) (string, bool) {
+	t.Helper()
+	script := "#!/bin/sh\nexec env"
+	for _, v := range env {
+		script += " '" + v + "'"
+	}
+	script += " git receive-pack \"$@\"\n"
+	receivePack := filepath.Join(t.TempDir(), "receive-pack")
+	if err := os.WriteFile(receivePack, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := exec.Command("git", "-C", dir, "push", "--porcelain", "--receive-pack="+receivePack, repo.Path, refspec).CombinedOutput()
+	return string(out), err == nil
+}
+
+// cloneAndCommit clones repo and adds the branches rewrite, which shares
+// no history with main, and feature, which main can fast-forward to. It
+// returns the directory of the clone.
+func cloneAndCommit(t *testing.T, repo *Repository) string {
+	t.Helper()
+	dir := filepath.Join(t.TempDir(), "clone")
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(), "GIT_AUTHOR_NAME=Alice", "GIT_AUTHOR_EMAIL=alice@example.com", "GIT_COMMITTER_NAME=Alice", "GIT_COMMITTER_EMAIL=alice@example.com")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %s: %v: %s", strings.Join(args, " "), err, out)
+		}
+	}
+	if out, err := exec.Command("git", "clone", "--quiet", repo.Path, dir).CombinedOutput(); err != nil {
+		t.Fatalf("git clone: %v: %s", err, out)
+	}
+	git("checkout", "--quiet", "--orphan", "rewrite")
+	git("commit", "--quiet", "--allow-empty", "-m", "Rewrite history")
+	git("checkout", "--quiet", "-b", "feature", "origin/main")
+	git("commit", "--quiet", "--allow-empty", "-m", "Add feature")
+	return dir
+}
+
+func TestPreReceiveHook(t *testing.T) {
+	hooks := filepath.Join(t.TempDir(), "hooks")
+	if err := InstallHooks(hooks); err != nil {
+		t.Fatal(err)
+	}
+	repo := newTestRepo(t)
+	dir := cloneAndCommit(t, repo)
+
+	tests := []struct {
+		name    string
+		env     []string
+		refspec string
+		ok      bool
+	}{
+		{"new branch", ReceivePackEnv(hooks, "main", []string{"main"}), "feature:feature", true},
+		{"force push to a protected branch", ReceivePackEnv(hooks, "main", []string{"main"}), "+rewrite:main", false},
+		{"delete a protected branch", ReceivePackEnv(hooks, "other", []string{"main"}), ":main", false},
+		{"delete the default branch", ReceivePackEnv(hooks, "main", nil), ":main", false},
+		{"fast-forward a protected branch", ReceivePackEnv(hooks, "main", []string{"main"}), "feature:main", true},
+		{"delete an unprotected branch", ReceivePackEnv(hooks, "main", []string{"main"}), ":feature", true},
+		{"protection unknown", RejectPushEnv(hooks, "branch protection could not be checked"), "feature:other", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, ok := push(t, dir, repo, tt.env, tt.refspec)
+			if ok != tt.ok {
+				t.Errorf("push %s succeeded = %v, want %v: %s", tt.refspec, ok, tt.ok, out)
+			}
+		})
+	}
+
+	out, _ := push(t, dir, repo, RejectPushEnv(hooks, "branch protection could not be checked"), "feature:other")
+	if !strings.Contains(out, "branch protection could not be checked") {
+		t.Errorf("rejected push does not give the reason: %s", out)
+	}
+}
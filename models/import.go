@@ -0,0 +1,279 @@
+//models/import.go
+
+package models
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Import states.
+const (
+	ImportQueued  = "queued"
+	ImportRunning = "running"
+	ImportSuccess = "success"
+	ImportFailure = "failure"
+)
+
+// Import sources.
+const (
+	ImportFromURL    = "url"
+	ImportFromBundle = "bundle"
+)
+
+// importProtocols are the transports git may use to import from a URL, so
+// an import cannot read the server's own files.
+const importProtocols = "http:https:git:ssh"
+
+// RepoImport is a one-time import of the branches and tags of a git
+// remote or an uploaded bundle into a new repository. Source is the URL
+// without credentials or the name of the uploaded file.
+type RepoImport struct {
+	ID         string     `gorm:"primarykey" json:"id"`
+	RepoName   string     `gorm:"index;not null" json:"repo"`
+	Kind       string     `gorm:"not null" json:"kind"`
+	Source     string     `json:"source"`
+	CreatedBy  string     `json:"created_by"`
+	Status     string     `gorm:"index;not null" json:"status"`
+	Progress   string     `json:"progress,omitempty"`
+	Percent    int        `json:"percent"`
+	Error      string     `json:"error,omitempty"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// Finished reports whether the import has completed.
+func (i *RepoImport) Finished() bool {
+	return i.Status != ImportQueued && i.Status != ImportRunning
+}
+
+// ImportService stores repository imports.
+type ImportService struct {
+	db *gorm.DB
+}
+
+func NewImportService(db *gorm.DB) *ImportService {
+	return &ImportService{db: db}
+}
+
+// Create stores a new queued import.
+func (s *ImportService) Create(imp *RepoImport) error {
+	imp.ID = uuid.New().String()
+	imp.Status = ImportQueued
+	if err := s.db.Create(imp).Error; err != nil {
+		return fmt.Errorf("failed to create import: %w", err)
+	}
+	return nil
+}
+
+// Get returns an import by ID.
+func (s *ImportService) Get(id string) (*RepoImport, error) {
+	var imp RepoImport
+	if err := s.db.First(&imp, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &imp, nil
+}
+
+// Latest returns the most recent import of a repository.
+func (s *ImportService) Latest(repoName string) (*RepoImport, error) {
+	var imp RepoImport
+	if err := s.db.Where("repo_name = ?", repoName).Order("created_at DESC").First(&imp).Error; err != nil {
+		return nil, err
+	}
+	return &imp, nil
+}
+
+// Start marks an import running.
+func (s *ImportService) Start(imp *RepoImport) error {
+	now := time.Now()
+	imp.Status = ImportRunning
+	imp.StartedAt = &now
+	return s.db.Model(imp).Select("status", "started_at").Updates(imp).Error
+}
+
+// SetProgress records the latest progress line of a running import.
+func (s *ImportService) SetProgress(imp *RepoImport, progress string, percent int) error {
+	imp.Progress = progress
+	imp.Percent = percent
+	return s.db.Model(imp).Select("progress", "percent").Updates(imp).Error
+}
+
+// Finish records the final status of an import.
+func (s *ImportService) Finish(imp *RepoImport, status, errMsg string) error {
+	now := time.Now()
+	imp.Status = status
+	imp.Error = errMsg
+	imp.FinishedAt = &now
+	return s.db.Model(imp).Select("status", "error", "finished_at").Updates(imp).Error
+}
+
+// Unfinished returns the imports that are queued or running.
+func (s *ImportService) Unfinished() ([]RepoImport, error) {
+	var imports []RepoImport
+	err := s.db.Where("status IN ?", []string{ImportQueued, ImportRunning}).Order("created_at ASC").Find(&imports).Error
+	return imports, err
+}
+
+// DeleteByRepo removes the imports of a repository.
+func (s *ImportService) DeleteByRepo(repoName string) error {
+	return s.db.Where("repo_name = ?", repoName).Delete(&RepoImport{}).Error
+}
+
+// RenameRepo moves the imports of a repository to its new name.
+func (s *ImportService) RenameRepo(oldName, newName string) error {
+	return s.db.Model(&RepoImport{}).Where("repo_name = ?", oldName).Update("repo_name", newName).Error
+}
+
+// Import fetches every branch and tag of source into r and points HEAD at
+// the branch source's HEAD points to. Source is a URL, or the absolute path
+// of a bundle file. Git's progress output is written to progress.
+func (r *Repository) Import(ctx context.Context, source string, progress io.Writer) error {
+	protocols := importProtocols
+	if filepath.IsAbs(source) {
+		protocols = "file"
+	}
+	env := []string{"GIT_TERMINAL_PROMPT=0", "GIT_ALLOW_PROTOCOL=" + protocols}
+
+	cmd := exec.CommandContext(ctx, "git", "fetch", "--progress", "--no-write-fetch-head", "--", source,
+		"+refs/heads/*:refs/heads/*", "+refs/tags/*:refs/tags/*")
+	cmd.Dir = r.Path
+	cmd.Env = append(os.Environ(), env...)
+
+	// Keep the end of the output for the error message
+	var tail strings.Builder
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	scanner := bufio.NewScanner(stderr)
+	scanner.Split(scanProgressLines)
+	for scanner.Scan() {
+		line := scanner.Text()
+		fmt.Fprintln(progress, line)
+		if !strings.Contains(line, "%") {
+			tail.Reset()
+			tail.WriteString(strings.TrimSpace(line))
+		}
+	}
+	if err := cmd.Wait(); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if tail.Len() == 0 {
+			return fmt.Errorf("git fetch: %w", err)
+		}
+		return errors.New(tail.String())
+	}
+
+	// go-git caches the references and packs it has seen
+	r.git = nil
+	branches, err := r.GetBranches()
+	if err != nil {
+		return err
+	}
+	if len(branches) == 0 {
+		return fmt.Errorf("the source has no branches")
+	}
+
+	head := r.remoteHead(source, env)
+	if head == "" {
+		head = branches[0]
+		for _, name := range []string{"main", "master"} {
+			if r.branchExists(name) {
+				head = name
+				break
+			}
+		}
+	}
+	return r.SetDefaultBranch(head)
+}
+
+// remoteHead returns the branch HEAD of a remote points to, or "" if it
+// cannot tell. Bundles record the commit of HEAD but not its branch, so
+// the first branch at that commit is taken.
+func (r *Repository) remoteHead(source string, env []string) string {
+	out, err := r.runGit(env, "ls-remote", "--symref", "--", source, "HEAD")
+	if err != nil {
+		return ""
+	}
+
+	var commit string
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 3 && fields[0] == "ref:" && fields[2] == "HEAD" {
+			return strings.TrimPrefix(fields[1], "refs/heads/")
+		}
+		if len(fields) == 2 && fields[1] == "HEAD" {
+			commit = fields[0]
+		}
+	}
+	if commit == "" {
+		return ""
+	}
+
+	out, err = r.runGit(nil, "for-each-ref", "--points-at", commit, "--format=%(refname:short)", "refs/heads/")
+	if err != nil || out == "" {
+		return ""
+	}
+	return strings.SplitN(out, "\n", 2)[0]
+}
+
+// VerifyBundle checks that path is a git bundle that can be imported on
+// its own, without commits from another repository.
+func VerifyBundle(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	// The header lists the prerequisites, lines starting with "-", and the
+	// references up to an empty line
+	reader := bufio.NewReader(f)
+	signature, err := reader.ReadString('\n')
+	if err != nil || (!strings.HasPrefix(signature, "# v2 git bundle") && !strings.HasPrefix(signature, "# v3 git bundle")) {
+		return fmt.Errorf("not a git bundle")
+	}
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return fmt.Errorf("not a git bundle")
+		}
+		switch {
+		case line == "\n":
+			return nil
+		case strings.HasPrefix(line, "-"):
+			return fmt.Errorf("the bundle needs commits it does not contain; create it with git bundle create <file> --all")
+		}
+	}
+}
+
+// scanProgressLines is a bufio.SplitFunc that splits git's progress output
+// into lines ending in a newline or a carriage return.
+func scanProgressLines(data []byte, atEOF bool) (int, []byte, error) {
+	for i, b := range data {
+		if b == '\n' || b == '\r' {
+			return i + 1, data[:i], nil
+		}
+	}
+	if atEOF && len(data) > 0 {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
@@ -0,0 +1,101 @@
+//models/import_test.go
+
+package models
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+)
+
+func TestImportBundle(t *testing.T) {
+	source := newTestRepo(t)
+	feature := commitFile(t, source, "feature", "main", "feature.txt", "feature\n")
+	if _, err := source.runGit(nil, "tag", "v1", "main"); err != nil {
+		t.Fatal(err)
+	}
+	if err := source.SetDefaultBranch("feature"); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	bundle := filepath.Join(dir, "demo.bundle")
+	if _, err := source.runGit(nil, "bundle", "create", bundle, "--all"); err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyBundle(bundle); err != nil {
+		t.Fatalf("VerifyBundle(full bundle) = %v", err)
+	}
+
+	// Bundles that need commits they do not contain cannot be imported
+	partial := filepath.Join(dir, "partial.bundle")
+	if _, err := source.runGit(nil, "bundle", "create", partial, "main..feature"); err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyBundle(partial); err == nil {
+		t.Error("VerifyBundle(partial bundle) = nil, want an error")
+	}
+	if err := VerifyBundle(filepath.Join(source.Path, "HEAD")); err == nil {
+		t.Error("VerifyBundle(not a bundle) = nil, want an error")
+	}
+
+	path := filepath.Join(dir, "imported.git")
+	if _, err := git.PlainInit(path, true); err != nil {
+		t.Fatal(err)
+	}
+	repo := &Repository{Name: "alice/imported", Path: path}
+	if err := repo.Import(context.Background(), bundle, io.Discard); err != nil {
+		t.Fatal(err)
+	}
+
+	branches, err := repo.GetBranches()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"feature", "main"}; !reflect.DeepEqual(branches, want) {
+		t.Errorf("branches = %v, want %v", branches, want)
+	}
+	if head, err := repo.DefaultBranch(); err != nil || head != "feature" {
+		t.Errorf("default branch = %q, %v; want the branch HEAD of the bundle is at", head, err)
+	}
+	if tip, err := repo.ResolveBranch("feature"); err != nil || tip.String() != feature {
+		t.Errorf("feature = %v, %v; want %s", tip, err, feature)
+	}
+	if _, err := repo.ResolveCommit("v1"); err != nil {
+		t.Errorf("tag v1 was not imported: %v", err)
+	}
+}
+
+func TestImportRefusesLocalURLs(t *testing.T) {
+	source := newTestRepo(t)
+	path := filepath.Join(t.TempDir(), "imported.git")
+	if _, err := git.PlainInit(path, true); err != nil {
+		t.Fatal(err)
+	}
+	repo := &Repository{Name: "alice/imported", Path: path}
+
+	if err := repo.Import(context.Background(), "file://"+source.Path, io.Discard); err == nil {
+		t.Fatal("importing a file:// URL succeeded")
+	}
+	if branches, _ := repo.GetBranches(); len(branches) != 0 {
+		t.Errorf("the import fetched %v", branches)
+	}
+}
+
+func TestScanProgressLines(t *testing.T) {
+	scanner := bufio.NewScanner(strings.NewReader("Counting:  50%\rCounting: 100%, done.\nReceiving"))
+	scanner.Split(scanProgressLines)
+	var lines []string
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	if want := []string{"Counting:  50%", "Counting: 100%, done.", "Receiving"}; !reflect.DeepEqual(lines, want) {
+		t.Errorf("lines = %q, want %q", lines, want)
+	}
+}
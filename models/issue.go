@@ -0,0 +1,334 @@
+//models/issue.go
+
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Issue states.
+const (
+	IssueOpen   = "open"
+	IssueClosed = "closed"
+)
+
+// Issue is a tracked task or bug of a repository. Number is sequential
+// per repository and is what #123 references point at.
+type Issue struct {
+	ID         string         `gorm:"primarykey" json:"id"`
+	RepoName   string         `gorm:"index;uniqueIndex:idx_issue_repo_number" json:"repo"`
+	Number     int            `gorm:"uniqueIndex:idx_issue_repo_number" json:"number"`
+	Title      string         `gorm:"not null" json:"title"`
+	Body       string         `json:"body"`
+	State      string         `gorm:"index;not null" json:"state"`
+	AuthorID   string         `gorm:"index" json:"author_id"`
+	Author     User           `gorm:"foreignKey:AuthorID" json:"author"`
+	Labels     []Label        `gorm:"many2many:issue_labels" json:"labels"`
+	Assignees  []User         `gorm:"many2many:issue_assignees" json:"assignees"`
+	Comments   []IssueComment `gorm:"foreignKey:IssueID" json:"comments,omitempty"`
+	ClosedByID string         `json:"closed_by_id,omitempty"`
+	ClosedAt   *time.Time     `json:"closed_at,omitempty"`
+	CreatedAt  time.Time      `json:"created_at"`
+	UpdatedAt  time.Time      `json:"updated_at"`
+}
+
+// Label is a named tag issues of a repository can carry.
+type Label struct {
+	ID       string `gorm:"primarykey" json:"id"`
+	RepoName string `gorm:"uniqueIndex:idx_label_repo_name" json:"repo"`
+	Name     string `gorm:"uniqueIndex:idx_label_repo_name;not null" json:"name"`
+	Color    string `json:"color"`
+}
+
+// IssueComment is a comment on an issue. Comments with a CommitHash record
+// that the issue was closed by a pushed commit; AuthorID is empty when the
+// commit author is not a known user.
+type IssueComment struct {
+	ID         string    `gorm:"primarykey" json:"id"`
+	IssueID    string    `gorm:"index;not null" json:"issue_id"`
+	AuthorID   string    `gorm:"index" json:"author_id,omitempty"`
+	Author     *User     `gorm:"foreignKey:AuthorID" json:"author,omitempty"`
+	Body       string    `json:"body"`
+	CommitHash string    `json:"commit,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// IsOpen reports whether the issue is open.
+func (i *Issue) IsOpen() bool {
+	return i.State == IssueOpen
+}
+
+// HasAssignee reports whether userID is assigned to the issue.
+func (i *Issue) HasAssignee(userID string) bool {
+	for _, user := range i.Assignees {
+		if user.ID == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// LabelNames returns the issue's labels as a comma separated list.
+func (i *Issue) LabelNames() string {
+	names := make([]string, len(i.Labels))
+	for n, label := range i.Labels {
+		names[n] = label.Name
+	}
+	return strings.Join(names, ", ")
+}
+
+// labelColors are assigned to new labels in turn.
+var labelColors = []string{"#61AFEF", "#98C379", "#E5C07B", "#E06C75", "#C678DD", "#56B6C2", "#D19A66"}
+
+// IssueFilter selects issues to list. Empty fields do not filter.
+type IssueFilter struct {
+	State    string
+	Label    string
+	Assignee string
+}
+
+// IssueService stores issues, their labels and comments.
+type IssueService struct {
+	db *gorm.DB
+}
+
+func NewIssueService(db *gorm.DB) *IssueService {
+	return &IssueService{db: db}
+}
+
+// Create stores a new open issue and assigns it the next number of its
+// repository.
+func (s *IssueService) Create(issue *Issue) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var last int
+		if err := tx.Model(&Issue{}).Where("repo_name = ?", issue.RepoName).
+			Select("COALESCE(MAX(number), 0)").Scan(&last).Error; err != nil {
+			return err
+		}
+
+		issue.ID = uuid.New().String()
+		issue.Number = last + 1
+		issue.State = IssueOpen
+
+		if err := tx.Omit("Labels.*", "Assignees.*").Create(issue).Error; err != nil {
+			return fmt.Errorf("failed to create issue: %w", err)
+		}
+		return nil
+	})
+}
+
+// Get returns an issue with its labels, assignees and comments.
+func (s *IssueService) Get(repoName string, number int) (*Issue, error) {
+	var issue Issue
+	err := s.db.
+		Preload("Author").
+		Preload("Labels").
+		Preload("Assignees").
+		Preload("Comments", func(db *gorm.DB) *gorm.DB { return db.Order("created_at ASC") }).
+		Preload("Comments.Author").
+		Where("repo_name = ? AND number = ?", repoName, number).
+		First(&issue).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("issue not found")
+		}
+		return nil, err
+	}
+	return &issue, nil
+}
+
+// List returns the issues of a repository matching filter, newest first.
+func (s *IssueService) List(repoName string, filter IssueFilter) ([]Issue, error) {
+	query := s.db.Preload("Author").Preload("Labels").Preload("Assignees").
+		Where("repo_name = ?", repoName)
+
+	if filter.State != "" {
+		query = query.Where("state = ?", filter.State)
+	}
+	if filter.Label != "" {
+		query = query.Where("id IN (?)", s.db.Table("issue_labels").
+			Select("issue_labels.issue_id").
+			Joins("JOIN labels ON labels.id = issue_labels.label_id").
+			Where("labels.repo_name = ? AND labels.name = ?", repoName, filter.Label))
+	}
+	if filter.Assignee != "" {
+		query = query.Where("id IN (?)", s.db.Table("issue_assignees").
+			Select("issue_assignees.issue_id").
+			Joins("JOIN users ON users.id = issue_assignees.user_id").
+			Where("users.username = ?", filter.Assignee))
+	}
+
+	var issues []Issue
+	if err := query.Order("number DESC").Find(&issues).Error; err != nil {
+		return nil, err
+	}
+	return issues, nil
+}
+
+// CountOpen returns the number of open issues of a repository.
+func (s *IssueService) CountOpen(repoName string) (int64, error) {
+	var count int64
+	err := s.db.Model(&Issue{}).Where("repo_name = ? AND state = ?", repoName, IssueOpen).Count(&count).Error
+	return count, err
+}
+
+// Numbers returns which of the given issue numbers exist in a repository.
+func (s *IssueService) Numbers(repoName string, numbers []int) (map[int]bool, error) {
+	found := make(map[int]bool)
+	if len(numbers) == 0 {
+		return found, nil
+	}
+
+	var existing []int
+	err := s.db.Model(&Issue{}).Where("repo_name = ? AND number IN ?", repoName, numbers).
+		Pluck("number", &existing).Error
+	for _, n := range existing {
+		found[n] = true
+	}
+	return found, err
+}
+
+// Update saves a new title and body.
+func (s *IssueService) Update(issue *Issue, title, body string) error {
+	issue.Title = title
+	issue.Body = body
+	return s.db.Model(issue).Select("title", "body").Updates(issue).Error
+}
+
+// SetState closes or reopens an issue. A closing commit, if any, is
+// recorded as a comment by userID.
+func (s *IssueService) SetState(issue *Issue, closed bool, userID, commitHash string) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if closed {
+			now := time.Now()
+			issue.State = IssueClosed
+			issue.ClosedByID = userID
+			issue.ClosedAt = &now
+		} else {
+			issue.State = IssueOpen
+			issue.ClosedByID = ""
+			issue.ClosedAt = nil
+		}
+		if err := tx.Model(issue).Select("state", "closed_by_id", "closed_at").Updates(issue).Error; err != nil {
+			return err
+		}
+
+		if commitHash == "" {
+			return nil
+		}
+		return tx.Create(&IssueComment{
+			ID:         uuid.New().String(),
+			IssueID:    issue.ID,
+			AuthorID:   userID,
+			CommitHash: commitHash,
+		}).Error
+	})
+}
+
+// AddComment adds a comment by userID to an issue.
+func (s *IssueService) AddComment(issue *Issue, userID, body string) error {
+	comment := IssueComment{
+		ID:       uuid.New().String(),
+		IssueID:  issue.ID,
+		AuthorID: userID,
+		Body:     body,
+	}
+	if err := s.db.Create(&comment).Error; err != nil {
+		return fmt.Errorf("failed to add comment: %w", err)
+	}
+	return s.db.Model(issue).Update("updated_at", time.Now()).Error
+}
+
+// SetLabels replaces the labels of an issue. Labels that do not exist in
+// the repository yet are created.
+func (s *IssueService) SetLabels(issue *Issue, names []string) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		labels := []Label{}
+		seen := make(map[string]bool)
+		for _, name := range names {
+			name = strings.TrimSpace(name)
+			if name == "" || seen[strings.ToLower(name)] {
+				continue
+			}
+			seen[strings.ToLower(name)] = true
+
+			var label Label
+			err := tx.Where("repo_name = ? AND name = ?", issue.RepoName, name).First(&label).Error
+			if err == gorm.ErrRecordNotFound {
+				var count int64
+				tx.Model(&Label{}).Where("repo_name = ?", issue.RepoName).Count(&count)
+				label = Label{
+					ID:       uuid.New().String(),
+					RepoName: issue.RepoName,
+					Name:     name,
+					Color:    labelColors[int(count)%len(labelColors)],
+				}
+				err = tx.Create(&label).Error
+			}
+			if err != nil {
+				return err
+			}
+			labels = append(labels, label)
+		}
+
+		issue.Labels = labels
+		return tx.Model(issue).Association("Labels").Replace(labels)
+	})
+}
+
+// SetAssignees replaces the users assigned to an issue.
+func (s *IssueService) SetAssignees(issue *Issue, userIDs []string) error {
+	users := []User{}
+	if len(userIDs) > 0 {
+		if err := s.db.Where("id IN ?", userIDs).Find(&users).Error; err != nil {
+			return err
+		}
+	}
+
+	issue.Assignees = users
+	return s.db.Model(issue).Association("Assignees").Replace(users)
+}
+
+// ListLabels returns the labels of a repository.
+func (s *IssueService) ListLabels(repoName string) ([]Label, error) {
+	var labels []Label
+	err := s.db.Where("repo_name = ?", repoName).Order("name ASC").Find(&labels).Error
+	return labels, err
+}
+
+// DeleteByRepo removes every issue, comment and label of a repository.
+func (s *IssueService) DeleteByRepo(repoName string) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		issues := tx.Model(&Issue{}).Select("id").Where("repo_name = ?", repoName)
+		labels := tx.Model(&Label{}).Select("id").Where("repo_name = ?", repoName)
+
+		if err := tx.Where("issue_id IN (?)", issues).Delete(&IssueComment{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Exec("DELETE FROM issue_labels WHERE issue_id IN (?) OR label_id IN (?)", issues, labels).Error; err != nil {
+			return err
+		}
+		if err := tx.Exec("DELETE FROM issue_assignees WHERE issue_id IN (?)", issues).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("repo_name = ?", repoName).Delete(&Label{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("repo_name = ?", repoName).Delete(&Issue{}).Error
+	})
+}
+
+// RenameRepo moves every issue and label of a repository to its new name.
+func (s *IssueService) RenameRepo(oldName, newName string) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&Label{}).Where("repo_name = ?", oldName).Update("repo_name", newName).Error; err != nil {
+			return err
+		}
+		return tx.Model(&Issue{}).Where("repo_name = ?", oldName).Update("repo_name", newName).Error
+	})
+}
@@ -0,0 +1,135 @@
+//models/lifecycle.go
+
+package models
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ErrDeletedRepoNotFound is returned for deleted repositories that do not
+// exist or were already removed for good.
+var ErrDeletedRepoNotFound = errors.New("deleted repository not found")
+
+// deletedRepoPrefix is the owner part of the name deleted repositories are
+// kept under. It is not a valid owner name, so it never clashes with a
+// repository and is skipped when scanning.
+const deletedRepoPrefix = ".deleted/"
+
+// LoadArchived reads whether the repository is archived.
+func (r *Repository) LoadArchived() {
+	if err := r.initGit(); err != nil {
+		return
+	}
+	if cfg, err := r.git.Config(); err == nil {
+		r.Archived = cfg.Raw.Section("simplegit").Option("archived") == "true"
+	}
+}
+
+// SetArchived archives the repository, making it read-only, or unarchives
+// it.
+func (r *Repository) SetArchived(archived bool) error {
+	args := []string{"config", "--bool", "simplegit.archived", "true"}
+	if !archived {
+		if !r.Archived {
+			return nil
+		}
+		args = []string{"config", "--unset", "simplegit.archived"}
+	}
+	if _, err := r.runGit(nil, args...); err != nil {
+		return err
+	}
+	r.Archived = archived
+	// go-git caches the configuration
+	r.git = nil
+	return nil
+}
+
+// Move changes the full name and location of the repository after its
+// directory was moved to path.
+func (r *Repository) Move(name, path string) {
+	r.ID = name
+	r.Name = name
+	r.Owner, _, _ = strings.Cut(name, "/")
+	r.Path = path
+	r.git = nil
+}
+
+// DeletedRepo is a repository that was deleted but can still be restored.
+// Until it is purged its directory and everything stored about it are
+// kept under TrashName.
+type DeletedRepo struct {
+	ID        string    `gorm:"primarykey" json:"id"`
+	Name      string    `gorm:"index;not null" json:"name"`
+	DeletedBy string    `json:"deleted_by"`
+	DeletedAt time.Time `json:"deleted_at"`
+	PurgeAt   time.Time `gorm:"index" json:"purge_at"`
+}
+
+// TrashName is the name the repository and its records are kept under
+// while it is deleted.
+func (d *DeletedRepo) TrashName() string {
+	return deletedRepoPrefix + d.ID
+}
+
+// DeletedRepoService stores the deleted repositories waiting to be purged.
+type DeletedRepoService struct {
+	db *gorm.DB
+}
+
+func NewDeletedRepoService(db *gorm.DB) *DeletedRepoService {
+	return &DeletedRepoService{db: db}
+}
+
+// Add records that a repository was deleted by the user with username and
+// is purged after retention.
+func (s *DeletedRepoService) Add(name, username string, retention time.Duration) (*DeletedRepo, error) {
+	now := time.Now()
+	deleted := &DeletedRepo{
+		ID:        uuid.New().String(),
+		Name:      name,
+		DeletedBy: username,
+		DeletedAt: now,
+		PurgeAt:   now.Add(retention),
+	}
+	if err := s.db.Create(deleted).Error; err != nil {
+		return nil, err
+	}
+	return deleted, nil
+}
+
+// Get returns a deleted repository.
+func (s *DeletedRepoService) Get(id string) (*DeletedRepo, error) {
+	var deleted DeletedRepo
+	err := s.db.Where("id = ?", id).First(&deleted).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, ErrDeletedRepoNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &deleted, nil
+}
+
+// List returns the deleted repositories, most recently deleted first.
+func (s *DeletedRepoService) List() ([]DeletedRepo, error) {
+	var deleted []DeletedRepo
+	err := s.db.Order("deleted_at DESC").Find(&deleted).Error
+	return deleted, err
+}
+
+// Expired returns the deleted repositories due to be purged at now.
+func (s *DeletedRepoService) Expired(now time.Time) ([]DeletedRepo, error) {
+	var deleted []DeletedRepo
+	err := s.db.Where("purge_at <= ?", now).Find(&deleted).Error
+	return deleted, err
+}
+
+// Remove forgets a deleted repository once it was restored or purged.
+func (s *DeletedRepoService) Remove(id string) error {
+	return s.db.Where("id = ?", id).Delete(&DeletedRepo{}).Error
+}
@@ -0,0 +1,269 @@
+//models/merge.go
+
+package models
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+// Merge strategies supported on the server.
+const (
+	MergeStrategyMerge       = "merge"
+	MergeStrategySquash      = "squash"
+	MergeStrategyFastForward = "fast-forward"
+)
+
+// MergeStatus describes whether a source branch can be merged into a
+// target branch.
+//
+// Parameters:
+//   - Mergeable: A merge commit or squash can be created without conflicts.
+//   - FastForward: The target is an ancestor of the source.
+//   - UpToDate: The source has no commits that are not in the target.
+//   - Conflicts: The paths that conflict, if any.
+//   - Reason: A human readable explanation when not mergeable.
+type MergeStatus struct {
+	Mergeable   bool     `json:"mergeable"`
+	FastForward bool     `json:"fast_forward"`
+	UpToDate    bool     `json:"up_to_date"`
+	Conflicts   []string `json:"conflicts,omitempty"`
+	Reason      string   `json:"reason,omitempty"`
+	BaseCommit  string   `json:"base_commit"`
+	HeadCommit  string   `json:"head_commit"`
+	MergeBase   string   `json:"merge_base"`
+}
+
+// MergeSignature identifies who a server-side merge commit is made by.
+type MergeSignature struct {
+	Name  string
+	Email string
+}
+
+// runGit runs a git command in the repository and returns its trimmed
+// standard output.
+func (r *Repository) runGit(env []string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = r.Path
+	cmd.Env = append(os.Environ(), env...)
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	if err != nil {
+		return strings.TrimSpace(stdout.String()), fmt.Errorf("git %s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// MergeBase returns the best common ancestor of two commits.
+func (r *Repository) MergeBase(a, b string) (string, error) {
+	return r.runGit(nil, "merge-base", a, b)
+}
+
+// MinGitVersion is the oldest git the server works with: merging without a
+// worktree needs git merge-tree --write-tree, which git 2.38 added.
+var MinGitVersion = [2]int{2, 38}
+
+// CheckGitVersion returns the version of the git on the PATH, and an error
+// if git cannot be run or is older than MinGitVersion.
+func CheckGitVersion() (string, error) {
+	out, err := exec.Command("git", "version").Output()
+	if err != nil {
+		return "", fmt.Errorf("git cannot be run: %w", err)
+	}
+	version := strings.TrimPrefix(strings.TrimSpace(string(out)), "git version ")
+
+	var major, minor int
+	if _, err := fmt.Sscanf(version, "%d.%d", &major, &minor); err != nil {
+		return version, fmt.Errorf("unexpected git version %q", version)
+	}
+	if major < MinGitVersion[0] || (major == MinGitVersion[0] && minor < MinGitVersion[1]) {
+		return version, fmt.Errorf("git %s is too old; pull requests need git %d.%d or later", version, MinGitVersion[0], MinGitVersion[1])
+	}
+	return version, nil
+}
+
+// mergeTree merges two commits without a worktree and returns the
+// resulting tree, or the conflicting paths. It needs git 2.38.
+func (r *Repository) mergeTree(base, head string) (string, []string, error) {
+	out, err := r.runGit(nil, "merge-tree", "--write-tree", "--name-only", "--no-messages", base, head)
+	if err == nil {
+		return out, nil, nil
+	}
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+		lines := strings.Split(out, "\n")
+		var conflicts []string
+		for _, line := range lines[1:] {
+			if line = strings.TrimSpace(line); line != "" {
+				conflicts = append(conflicts, line)
+			}
+		}
+		return "", conflicts, nil
+	}
+	return "", nil, err
+}
+
+// CheckMerge works out whether source can be merged into target.
+func (r *Repository) CheckMerge(target, source string) (*MergeStatus, error) {
+	if err := r.initGit(); err != nil {
+		return nil, err
+	}
+
+	targetRef, err := r.git.Reference(plumbing.NewBranchReferenceName(target), true)
+	if err != nil {
+		return &MergeStatus{Reason: fmt.Sprintf("Target branch %s does not exist", target)}, nil
+	}
+	sourceRef, err := r.git.Reference(plumbing.NewBranchReferenceName(source), true)
+	if err != nil {
+		return &MergeStatus{Reason: fmt.Sprintf("Source branch %s does not exist", source)}, nil
+	}
+
+	status := &MergeStatus{
+		BaseCommit: targetRef.Hash().String(),
+		HeadCommit: sourceRef.Hash().String(),
+	}
+
+	mergeBase, err := r.MergeBase(status.BaseCommit, status.HeadCommit)
+	if err != nil {
+		status.Reason = "The branches have no common history"
+		return status, nil
+	}
+	status.MergeBase = mergeBase
+
+	if mergeBase == status.HeadCommit {
+		status.UpToDate = true
+		status.Reason = fmt.Sprintf("%s is already merged into %s", source, target)
+		return status, nil
+	}
+	status.FastForward = mergeBase == status.BaseCommit
+
+	_, conflicts, err := r.mergeTree(status.BaseCommit, status.HeadCommit)
+	if err != nil {
+		return nil, err
+	}
+	if len(conflicts) > 0 {
+		status.Conflicts = conflicts
+		status.Reason = "The branches have conflicting changes"
+		return status, nil
+	}
+
+	status.Mergeable = true
+	return status, nil
+}
+
+// Merge merges source into target on the bare repository using one of the
+// MergeStrategy constants. It returns the new tip of target and the status
+// the merge was based on, whose BaseCommit and HeadCommit are the commits
+// merged. The target ref is only moved if it still points at BaseCommit,
+// so a concurrent push makes the merge fail instead of being lost.
+func (r *Repository) Merge(target, source, strategy, message string, sig MergeSignature) (string, *MergeStatus, error) {
+	status, err := r.CheckMerge(target, source)
+	if err != nil {
+		return "", nil, err
+	}
+	if status.UpToDate || (!status.Mergeable && !status.FastForward) {
+		return "", nil, errors.New(status.Reason)
+	}
+
+	var newTip string
+	switch strategy {
+	case MergeStrategyFastForward:
+		if !status.FastForward {
+			return "", nil, fmt.Errorf("%s cannot be fast-forwarded to %s", target, source)
+		}
+		newTip = status.HeadCommit
+
+	case MergeStrategyMerge, MergeStrategySquash:
+		tree, _, err := r.mergeTree(status.BaseCommit, status.HeadCommit)
+		if err != nil {
+			return "", nil, err
+		}
+		if tree == "" {
+			return "", nil, errors.New("the branches have conflicting changes")
+		}
+
+		args := []string{"commit-tree", tree, "-p", status.BaseCommit}
+		if strategy == MergeStrategyMerge {
+			args = append(args, "-p", status.HeadCommit)
+		}
+		args = append(args, "-m", message)
+
+		env := []string{
+			"GIT_AUTHOR_NAME=" + sig.Name,
+			"GIT_AUTHOR_EMAIL=" + sig.Email,
+			"GIT_COMMITTER_NAME=" + sig.Name,
+			"GIT_COMMITTER_EMAIL=" + sig.Email,
+		}
+		if newTip, err = r.runGit(env, args...); err != nil {
+			return "", nil, err
+		}
+
+	default:
+		return "", nil, fmt.Errorf("unknown merge strategy %q", strategy)
+	}
+
+	ref := plumbing.NewBranchReferenceName(target).String()
+	if _, err := r.runGit(nil, "update-ref", ref, newTip, status.BaseCommit); err != nil {
+		return "", nil, fmt.Errorf("failed to update %s: %w", target, err)
+	}
+
+	return newTip, status, nil
+}
+
+// CommitsBetween returns the commits reachable from head but not from
+// base, newest first. An empty base lists the history of head.
+func (r *Repository) CommitsBetween(base, head string, limit int) ([]CommitInfo, error) {
+	if err := r.initGit(); err != nil {
+		return nil, err
+	}
+
+	revs := head
+	if base != "" {
+		revs = base + ".." + head
+	}
+
+	out, err := r.runGit(nil, "rev-list", fmt.Sprintf("--max-count=%d", limit), revs)
+	if err != nil {
+		return nil, err
+	}
+
+	commits := []CommitInfo{}
+	for _, line := range strings.Fields(out) {
+		c, err := r.git.CommitObject(plumbing.NewHash(line))
+		if err != nil {
+			return nil, err
+		}
+		commits = append(commits, CommitInfo{
+			Hash:      c.Hash.String(),
+			Author:    c.Author.Name,
+			Email:     c.Author.Email,
+			Message:   c.Message,
+			Timestamp: c.Author.When,
+		})
+	}
+	return commits, nil
+}
+
+// AheadBehind counts the commits head has that base does not (ahead) and
+// the commits base has that head does not (behind).
+func (r *Repository) AheadBehind(base, head string) (ahead, behind int, err error) {
+	out, err := r.runGit(nil, "rev-list", "--count", "--left-right", base+"..."+head)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if _, err := fmt.Sscanf(out, "%d\t%d", &behind, &ahead); err != nil {
+		return 0, 0, fmt.Errorf("unexpected rev-list output %q: %w", out, err)
+	}
+	return ahead, behind, nil
+}
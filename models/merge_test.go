@@ -0,0 +1,64 @@
+//models/merge_test.go
+
+package models
+
+import "testing"
+
+func TestMergeReturnsTheStatusItMerged(t *testing.T) {
+	if _, err := CheckGitVersion(); err != nil {
+		t.Skip(err)
+	}
+
+	repo := newTestRepo(t)
+	head := commitFile(t, repo, "feature", "main", "feature.txt", "feature\n")
+	// main moves after the pull request was opened
+	base := commitFile(t, repo, "main", "", "main.txt", "main\n")
+
+	newTip, status, err := repo.Merge("main", "feature", MergeStrategyMerge, "Merge feature", testAuthor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.BaseCommit != base || status.HeadCommit != head {
+		t.Errorf("merged %s into %s, want %s into %s", status.HeadCommit, status.BaseCommit, head, base)
+	}
+
+	if tip, err := repo.ResolveBranch("main"); err != nil || tip.String() != newTip {
+		t.Errorf("main = %v, %v; want %s", tip, err, newTip)
+	}
+	parents, err := repo.runGit(nil, "rev-list", "--parents", "-n", "1", newTip)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := newTip + " " + base + " " + head; parents != want {
+		t.Errorf("parents = %q, want %q", parents, want)
+	}
+
+	// Nothing is left to merge
+	if _, _, err := repo.Merge("main", "feature", MergeStrategyMerge, "Merge feature", testAuthor); err == nil {
+		t.Error("merging an up to date branch succeeded")
+	}
+}
+
+func TestMergeConflict(t *testing.T) {
+	if _, err := CheckGitVersion(); err != nil {
+		t.Skip(err)
+	}
+
+	repo := newTestRepo(t)
+	commitFile(t, repo, "feature", "main", "README.md", "# Feature\n")
+	base := commitFile(t, repo, "main", "", "README.md", "# Main\n")
+
+	status, err := repo.CheckMerge("main", "feature")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Mergeable || len(status.Conflicts) != 1 || status.Conflicts[0] != "README.md" {
+		t.Errorf("status = %+v, want a conflict in README.md", status)
+	}
+	if _, _, err := repo.Merge("main", "feature", MergeStrategySquash, "Squash", testAuthor); err == nil {
+		t.Error("merging conflicting branches succeeded")
+	}
+	if tip, _ := repo.ResolveBranch("main"); tip.String() != base {
+		t.Errorf("main moved to %s after a failed merge", tip)
+	}
+}
@@ -0,0 +1,266 @@
+//models/objects.go
+
+package models
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	fdiff "github.com/go-git/go-git/v5/plumbing/format/diff"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// maxPatchBytes bounds the patch text returned for a single file. Larger
+// patches are left out and the file is marked truncated.
+const maxPatchBytes = 512 * 1024
+
+// Tag is a tag with the commit it points to. Annotated tags also carry
+// their tagger and message.
+type Tag struct {
+	Name      string     `json:"name"`
+	Commit    CommitInfo `json:"commit"`
+	Annotated bool       `json:"annotated"`
+	Message   string     `json:"message,omitempty"`
+	Tagger    string     `json:"tagger,omitempty"`
+	TaggedAt  *time.Time `json:"tagged_at,omitempty"`
+}
+
+// TreeItem is an entry of a directory without the last commit lookup
+// GetTree does, for listings that need to be cheap.
+type TreeItem struct {
+	Name string `json:"name"`
+	Path string `json:"path"`
+	Type string `json:"type"`
+	Mode string `json:"mode"`
+	SHA  string `json:"sha"`
+	Size int64  `json:"size,omitempty"`
+}
+
+// FileDiff is the change to one file between two commits as a unified
+// patch.
+type FileDiff struct {
+	Path      string `json:"path"`
+	OldPath   string `json:"old_path,omitempty"`
+	Status    string `json:"status"`
+	Additions int    `json:"additions"`
+	Deletions int    `json:"deletions"`
+	Binary    bool   `json:"binary"`
+	Truncated bool   `json:"truncated,omitempty"`
+	Patch     string `json:"patch,omitempty"`
+}
+
+// commitInfo converts a commit object to its summary.
+func commitInfo(c *object.Commit) CommitInfo {
+	return CommitInfo{
+		Hash:      c.Hash.String(),
+		Author:    c.Author.Name,
+		Email:     c.Author.Email,
+		Message:   c.Message,
+		Timestamp: c.Author.When,
+	}
+}
+
+// ListTags returns every tag with the commit it points to, sorted by
+// name. Tags that do not point to a commit are left out.
+func (r *Repository) ListTags() ([]Tag, error) {
+	if err := r.initGit(); err != nil {
+		return nil, err
+	}
+
+	tagIter, err := r.git.Tags()
+	if err != nil {
+		return nil, err
+	}
+
+	tags := []Tag{}
+	err = tagIter.ForEach(func(ref *plumbing.Reference) error {
+		tag := Tag{Name: ref.Name().Short()}
+
+		hash := ref.Hash()
+		if annotated, err := r.git.TagObject(hash); err == nil {
+			tag.Annotated = true
+			tag.Message = annotated.Message
+			tag.Tagger = annotated.Tagger.Name
+			when := annotated.Tagger.When
+			tag.TaggedAt = &when
+
+			commit, err := annotated.Commit()
+			if err != nil {
+				return nil
+			}
+			tag.Commit = commitInfo(commit)
+		} else {
+			commit, err := r.git.CommitObject(hash)
+			if err != nil {
+				return nil
+			}
+			tag.Commit = commitInfo(commit)
+		}
+
+		tags = append(tags, tag)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(tags, func(i, j int) bool { return tags[i].Name < tags[j].Name })
+	return tags, nil
+}
+
+// ListTree returns the entries of the directory at dirPath in a commit,
+// directories first. An empty dirPath lists the root.
+func (r *Repository) ListTree(commit *object.Commit, dirPath string) ([]TreeItem, error) {
+	tree, err := commit.Tree()
+	if err != nil {
+		return nil, err
+	}
+
+	dirPath = strings.Trim(dirPath, "/")
+	if dirPath != "" {
+		tree, err = tree.Tree(dirPath)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	items := make([]TreeItem, 0, len(tree.Entries))
+	for _, entry := range tree.Entries {
+		item := TreeItem{
+			Name: entry.Name,
+			Path: path.Join(dirPath, entry.Name),
+			Mode: entry.Mode.String(),
+			SHA:  entry.Hash.String(),
+		}
+
+		switch entry.Mode {
+		case filemode.Dir:
+			item.Type = "tree"
+		case filemode.Submodule:
+			item.Type = "commit"
+		default:
+			item.Type = "blob"
+			if size, err := r.git.Storer.EncodedObjectSize(entry.Hash); err == nil {
+				item.Size = size
+			}
+		}
+
+		items = append(items, item)
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		if (items[i].Type == "tree") != (items[j].Type == "tree") {
+			return items[i].Type == "tree"
+		}
+		return items[i].Name < items[j].Name
+	})
+
+	return items, nil
+}
+
+// ListCommits returns the history of a commit, newest first, skipping the
+// first skip commits. A non-empty filePath only lists commits that touched
+// it.
+func (r *Repository) ListCommits(commit *object.Commit, filePath string, skip, limit int) ([]CommitInfo, error) {
+	if err := r.initGit(); err != nil {
+		return nil, err
+	}
+
+	args := []string{"rev-list", fmt.Sprintf("--skip=%d", skip), fmt.Sprintf("--max-count=%d", limit), commit.Hash.String()}
+	if filePath != "" {
+		args = append(args, "--", filePath)
+	}
+
+	out, err := r.runGit(nil, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	commits := []CommitInfo{}
+	for _, line := range strings.Fields(out) {
+		c, err := r.git.CommitObject(plumbing.NewHash(line))
+		if err != nil {
+			return nil, err
+		}
+		commits = append(commits, commitInfo(c))
+	}
+	return commits, nil
+}
+
+// DiffCommits returns the unified diff of every file that changed between
+// two commits. A nil from compares against an empty tree.
+func (r *Repository) DiffCommits(from, to *object.Commit) ([]FileDiff, error) {
+	var fromTree *object.Tree
+	if from != nil {
+		tree, err := from.Tree()
+		if err != nil {
+			return nil, err
+		}
+		fromTree = tree
+	}
+
+	toTree, err := to.Tree()
+	if err != nil {
+		return nil, err
+	}
+
+	changes, err := object.DiffTree(fromTree, toTree)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]FileDiff, 0, len(changes))
+	for _, change := range changes {
+		file := FileDiff{Path: change.To.Name, OldPath: change.From.Name, Status: "modified"}
+		switch {
+		case change.From.Name == "":
+			file.Status = "added"
+			file.OldPath = ""
+		case change.To.Name == "":
+			file.Status = "deleted"
+			file.Path = change.From.Name
+			file.OldPath = ""
+		case change.From.Name != change.To.Name:
+			file.Status = "renamed"
+		default:
+			file.OldPath = ""
+		}
+
+		patch, err := change.Patch()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, stat := range patch.Stats() {
+			file.Additions += stat.Addition
+			file.Deletions += stat.Deletion
+		}
+		for _, fp := range patch.FilePatches() {
+			if fp.IsBinary() {
+				file.Binary = true
+			}
+		}
+
+		if !file.Binary {
+			var buf bytes.Buffer
+			if err := fdiff.NewUnifiedEncoder(&buf, fdiff.DefaultContextLines).Encode(patch); err != nil {
+				return nil, err
+			}
+			if buf.Len() > maxPatchBytes {
+				file.Truncated = true
+			} else {
+				file.Patch = buf.String()
+			}
+		}
+
+		files = append(files, file)
+	}
+
+	return files, nil
+}
@@ -0,0 +1,276 @@
+//models/organization.go
+
+package models
+
+import (
+	"SimpleGit/config"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Roles of organization members. Owners manage the members; every member
+// can create repositories in the organization.
+const (
+	OrgRoleOwner  = "owner"
+	OrgRoleMember = "member"
+)
+
+var (
+	// ErrOrganizationNotFound is returned for names no organization has.
+	ErrOrganizationNotFound = errors.New("organization not found")
+	// ErrOwnerNameTaken is returned when a user or organization already
+	// has a name.
+	ErrOwnerNameTaken = errors.New("the name is already taken by a user or organization")
+	// ErrLastOrgOwner is returned when a change would leave an
+	// organization without an owner.
+	ErrLastOrgOwner = errors.New("an organization needs at least one owner")
+)
+
+var ownerNamePattern = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+// ValidOwnerName reports whether name can be used as the name of a user or
+// organization.
+func ValidOwnerName(name string) bool {
+	return len(name) >= 3 && len(name) <= 39 && ownerNamePattern.MatchString(name)
+}
+
+// SplitRepoName splits the full name of a repository, <owner>/<name>, and
+// reports whether both parts are valid.
+func SplitRepoName(fullName string) (owner, name string, ok bool) {
+	owner, name, ok = strings.Cut(fullName, "/")
+	return owner, name, ok && ValidOwnerName(owner) && ValidRepoName(name)
+}
+
+// Organization is an owner of repositories shared by a group of users.
+type Organization struct {
+	ID          string    `gorm:"primarykey" json:"id"`
+	Name        string    `gorm:"unique;not null" json:"name"`
+	Description string    `json:"description"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// OrgMember makes a user a member or owner of an organization.
+type OrgMember struct {
+	ID        string    `gorm:"primarykey" json:"-"`
+	OrgID     string    `gorm:"uniqueIndex:idx_org_member;not null" json:"-"`
+	UserID    string    `gorm:"uniqueIndex:idx_org_member;not null" json:"-"`
+	Role      string    `gorm:"not null" json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+	User      User      `gorm:"foreignKey:UserID" json:"user"`
+}
+
+// OrganizationService stores organizations and their members.
+type OrganizationService struct {
+	db *gorm.DB
+}
+
+func NewOrganizationService(db *gorm.DB) *OrganizationService {
+	return &OrganizationService{db: db}
+}
+
+// NameTaken reports whether a user or organization has name, ignoring
+// case so owner directories stay distinct on case-insensitive filesystems.
+// A name whose owner directory exists is taken too, so nobody inherits the
+// repositories left behind by a deleted owner.
+func NameTaken(db *gorm.DB, name string) (bool, error) {
+	var count int64
+	if err := db.Model(&User{}).Where("LOWER(username) = LOWER(?)", name).Count(&count).Error; err != nil || count > 0 {
+		return count > 0, err
+	}
+	if err := db.Model(&Organization{}).Where("LOWER(name) = LOWER(?)", name).Count(&count).Error; err != nil || count > 0 {
+		return count > 0, err
+	}
+
+	entries, err := os.ReadDir(config.GlobalConfig.RepoPath)
+	if err != nil && !os.IsNotExist(err) {
+		return false, err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() && strings.EqualFold(entry.Name(), name) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Create creates an organization owned by the user with ID ownerID.
+func (s *OrganizationService) Create(name, description, ownerID string) (*Organization, error) {
+	if !ValidOwnerName(name) {
+		return nil, fmt.Errorf("invalid organization name: must be 3 to 39 letters, numbers, hyphens and underscores")
+	}
+
+	org := &Organization{
+		ID:          uuid.New().String(),
+		Name:        name,
+		Description: description,
+		CreatedAt:   time.Now(),
+	}
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		taken, err := NameTaken(tx, name)
+		if err != nil {
+			return err
+		}
+		if taken {
+			return ErrOwnerNameTaken
+		}
+		if err := tx.Create(org).Error; err != nil {
+			return err
+		}
+		return tx.Create(&OrgMember{
+			ID:        uuid.New().String(),
+			OrgID:     org.ID,
+			UserID:    ownerID,
+			Role:      OrgRoleOwner,
+			CreatedAt: time.Now(),
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return org, nil
+}
+
+// Get returns the organization with name.
+func (s *OrganizationService) Get(name string) (*Organization, error) {
+	var org Organization
+	err := s.db.Where("name = ?", name).First(&org).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, ErrOrganizationNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &org, nil
+}
+
+// List returns every organization ordered by name.
+func (s *OrganizationService) List() ([]Organization, error) {
+	var orgs []Organization
+	err := s.db.Order("name").Find(&orgs).Error
+	return orgs, err
+}
+
+// ListForUser returns the organizations a user is a member of, ordered by
+// name.
+func (s *OrganizationService) ListForUser(userID string) ([]Organization, error) {
+	var orgs []Organization
+	err := s.db.Joins("JOIN org_members ON org_members.org_id = organizations.id").
+		Where("org_members.user_id = ?", userID).Order("organizations.name").Find(&orgs).Error
+	return orgs, err
+}
+
+// Members returns the members of an organization with their users, owners
+// first.
+func (s *OrganizationService) Members(orgID string) ([]OrgMember, error) {
+	var members []OrgMember
+	err := s.db.Preload("User").Where("org_id = ?", orgID).
+		Order(fmt.Sprintf("CASE role WHEN '%s' THEN 0 ELSE 1 END, created_at", OrgRoleOwner)).Find(&members).Error
+	return members, err
+}
+
+// Role returns the role of a user in an organization, or "" if the user
+// is not a member.
+func (s *OrganizationService) Role(orgID, userID string) (string, error) {
+	var member OrgMember
+	err := s.db.Where("org_id = ? AND user_id = ?", orgID, userID).First(&member).Error
+	if err == gorm.ErrRecordNotFound {
+		return "", nil
+	}
+	return member.Role, err
+}
+
+// SetMember adds a user to an organization or changes the user's role.
+func (s *OrganizationService) SetMember(orgID, userID, role string) error {
+	if role != OrgRoleOwner && role != OrgRoleMember {
+		return fmt.Errorf("invalid role: %s", role)
+	}
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var member OrgMember
+		err := tx.Where("org_id = ? AND user_id = ?", orgID, userID).First(&member).Error
+		if err == gorm.ErrRecordNotFound {
+			return tx.Create(&OrgMember{
+				ID:        uuid.New().String(),
+				OrgID:     orgID,
+				UserID:    userID,
+				Role:      role,
+				CreatedAt: time.Now(),
+			}).Error
+		}
+		if err != nil {
+			return err
+		}
+
+		if member.Role == OrgRoleOwner && role != OrgRoleOwner {
+			if err := checkOtherOwners(tx, orgID, userID); err != nil {
+				return err
+			}
+		}
+		return tx.Model(&member).Update("role", role).Error
+	})
+}
+
+// RemoveMember removes a user from an organization and its teams.
+func (s *OrganizationService) RemoveMember(orgID, userID string) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := checkOtherOwners(tx, orgID, userID); err != nil {
+			return err
+		}
+		teams := tx.Model(&Team{}).Select("id").Where("org_id = ?", orgID)
+		if err := tx.Where("user_id = ? AND team_id IN (?)", userID, teams).Delete(&TeamMember{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("org_id = ? AND user_id = ?", orgID, userID).Delete(&OrgMember{}).Error
+	})
+}
+
+// checkOtherOwners returns ErrLastOrgOwner if userID is the only owner of
+// an organization.
+func checkOtherOwners(tx *gorm.DB, orgID, userID string) error {
+	var others int64
+	err := tx.Model(&OrgMember{}).Where("org_id = ? AND role = ? AND user_id <> ?", orgID, OrgRoleOwner, userID).Count(&others).Error
+	if err != nil {
+		return err
+	}
+	var isOwner int64
+	err = tx.Model(&OrgMember{}).Where("org_id = ? AND role = ? AND user_id = ?", orgID, OrgRoleOwner, userID).Count(&isOwner).Error
+	if err != nil {
+		return err
+	}
+	if isOwner > 0 && others == 0 {
+		return ErrLastOrgOwner
+	}
+	return nil
+}
+
+// DeleteByUser removes a deleted user from every organization.
+func (s *OrganizationService) DeleteByUser(userID string) error {
+	return s.db.Where("user_id = ?", userID).Delete(&OrgMember{}).Error
+}
+
+// Delete removes an organization with its memberships and teams.
+func (s *OrganizationService) Delete(orgID string) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("org_id = ?", orgID).Delete(&OrgMember{}).Error; err != nil {
+			return err
+		}
+		teams := tx.Model(&Team{}).Select("id").Where("org_id = ?", orgID)
+		if err := tx.Where("team_id IN (?)", teams).Delete(&TeamMember{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("team_id IN (?)", teams).Delete(&TeamRepo{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("org_id = ?", orgID).Delete(&Team{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("id = ?", orgID).Delete(&Organization{}).Error
+	})
+}
@@ -0,0 +1,192 @@
+//models/permission.go
+
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Permission is what a user may do in a repository. Each level includes
+// the ones below it: read to clone and browse, write to push and change
+// branches, files and pull requests, admin to change the repository's
+// settings, branch protection and access.
+type Permission int
+
+const (
+	PermissionNone Permission = iota
+	PermissionRead
+	PermissionWrite
+	PermissionAdmin
+)
+
+var permissionNames = []string{"none", "read", "write", "admin"}
+
+// String returns the name of the permission as stored and shown.
+func (p Permission) String() string {
+	if p < PermissionNone || p > PermissionAdmin {
+		return permissionNames[PermissionNone]
+	}
+	return permissionNames[p]
+}
+
+// CanWrite reports whether the permission allows writing.
+func (p Permission) CanWrite() bool {
+	return p >= PermissionWrite
+}
+
+// CanAdmin reports whether the permission allows administering.
+func (p Permission) CanAdmin() bool {
+	return p >= PermissionAdmin
+}
+
+// ParsePermission parses the name of a permission that can be granted:
+// read, write or admin.
+func ParsePermission(name string) (Permission, error) {
+	for p := PermissionRead; p <= PermissionAdmin; p++ {
+		if p.String() == name {
+			return p, nil
+		}
+	}
+	return PermissionNone, fmt.Errorf("invalid permission: %q (expected read, write or admin)", name)
+}
+
+// GrantablePermissions are the permissions grants and teams can give.
+var GrantablePermissions = []Permission{PermissionRead, PermissionWrite, PermissionAdmin}
+
+// RepoGrant gives a user a permission on a repository directly.
+type RepoGrant struct {
+	ID         string    `gorm:"primarykey" json:"-"`
+	RepoName   string    `gorm:"uniqueIndex:idx_repo_grant;not null" json:"-"`
+	UserID     string    `gorm:"uniqueIndex:idx_repo_grant;not null" json:"-"`
+	Permission string    `gorm:"not null" json:"permission"`
+	CreatedAt  time.Time `json:"created_at"`
+	User       User      `gorm:"foreignKey:UserID" json:"user"`
+}
+
+// PermissionService stores direct grants and resolves the effective
+// permission of users.
+type PermissionService struct {
+	db *gorm.DB
+}
+
+func NewPermissionService(db *gorm.DB) *PermissionService {
+	return &PermissionService{db: db}
+}
+
+// Effective returns the permission user has on the repository with the
+// full name repoName. Repositories are public, so everyone, including
+// anonymous users (nil), can read. On top of that:
+//   - Admins and the user owning a repository administer it.
+//   - Owners of the organization owning a repository administer it.
+//   - Direct grants and the teams a user is on give their permission.
+//
+// The highest of these applies.
+func (s *PermissionService) Effective(user *User, repoName string) (Permission, error) {
+	if user == nil {
+		return PermissionRead, nil
+	}
+	if user.IsAdmin {
+		return PermissionAdmin, nil
+	}
+
+	owner, _, _ := strings.Cut(repoName, "/")
+	if owner == user.Username {
+		return PermissionAdmin, nil
+	}
+
+	var roles []string
+	err := s.db.Model(&OrgMember{}).
+		Joins("JOIN organizations ON organizations.id = org_members.org_id").
+		Where("organizations.name = ? AND org_members.user_id = ?", owner, user.ID).
+		Pluck("org_members.role", &roles).Error
+	if err != nil {
+		return PermissionRead, err
+	}
+	if len(roles) > 0 && roles[0] == OrgRoleOwner {
+		return PermissionAdmin, nil
+	}
+
+	var granted []string
+	err = s.db.Model(&RepoGrant{}).Where("repo_name = ? AND user_id = ?", repoName, user.ID).Pluck("permission", &granted).Error
+	if err != nil {
+		return PermissionRead, err
+	}
+
+	var teamGranted []string
+	err = s.db.Model(&Team{}).
+		Joins("JOIN team_members ON team_members.team_id = teams.id").
+		Joins("JOIN team_repos ON team_repos.team_id = teams.id").
+		Where("team_members.user_id = ? AND team_repos.repo_name = ?", user.ID, repoName).
+		Pluck("teams.permission", &teamGranted).Error
+	if err != nil {
+		return PermissionRead, err
+	}
+
+	effective := PermissionRead
+	for _, name := range append(granted, teamGranted...) {
+		if p, err := ParsePermission(name); err == nil && p > effective {
+			effective = p
+		}
+	}
+	return effective, nil
+}
+
+// Grants returns the direct grants of a repository with their users,
+// highest permission first.
+func (s *PermissionService) Grants(repoName string) ([]RepoGrant, error) {
+	var grants []RepoGrant
+	err := s.db.Preload("User").Where("repo_name = ?", repoName).
+		Order("CASE permission WHEN 'admin' THEN 0 WHEN 'write' THEN 1 ELSE 2 END, created_at").Find(&grants).Error
+	return grants, err
+}
+
+// Grant gives a user a permission on a repository, replacing the user's
+// previous grant.
+func (s *PermissionService) Grant(repoName, userID string, permission Permission) error {
+	if permission < PermissionRead || permission > PermissionAdmin {
+		return fmt.Errorf("invalid permission: %s", permission)
+	}
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var grant RepoGrant
+		err := tx.Where("repo_name = ? AND user_id = ?", repoName, userID).First(&grant).Error
+		if err == gorm.ErrRecordNotFound {
+			return tx.Create(&RepoGrant{
+				ID:         uuid.New().String(),
+				RepoName:   repoName,
+				UserID:     userID,
+				Permission: permission.String(),
+				CreatedAt:  time.Now(),
+			}).Error
+		}
+		if err != nil {
+			return err
+		}
+		return tx.Model(&grant).Update("permission", permission.String()).Error
+	})
+}
+
+// Revoke removes the direct grant of a user on a repository.
+func (s *PermissionService) Revoke(repoName, userID string) error {
+	return s.db.Where("repo_name = ? AND user_id = ?", repoName, userID).Delete(&RepoGrant{}).Error
+}
+
+// RenameRepo moves the grants of a repository to its new name.
+func (s *PermissionService) RenameRepo(oldName, newName string) error {
+	return s.db.Model(&RepoGrant{}).Where("repo_name = ?", oldName).Update("repo_name", newName).Error
+}
+
+// DeleteByRepo removes the grants of a deleted repository.
+func (s *PermissionService) DeleteByRepo(repoName string) error {
+	return s.db.Where("repo_name = ?", repoName).Delete(&RepoGrant{}).Error
+}
+
+// DeleteByUser removes the grants of a deleted user.
+func (s *PermissionService) DeleteByUser(userID string) error {
+	return s.db.Where("user_id = ?", userID).Delete(&RepoGrant{}).Error
+}
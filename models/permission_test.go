@@ -0,0 +1,105 @@
+//models/permission_test.go
+
+package models
+
+import (
+	"path/filepath"
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// openTestDB opens a database in a temporary directory with the tables of
+// users, organizations, teams and grants.
+func openTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "githost.db")), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	if err := db.AutoMigrate(&User{}, &Organization{}, &OrgMember{}, &Team{}, &TeamMember{}, &TeamRepo{}, &RepoGrant{}); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestEffectivePermission(t *testing.T) {
+	db := openTestDB(t)
+	users := NewUserService(db, []byte("test"))
+	orgs, teams, perms := NewOrganizationService(db), NewTeamService(db), NewPermissionService(db)
+
+	user := func(name string, admin bool) *User {
+		t.Helper()
+		u, err := users.CreateUser(name, name+"@example.com", "password", admin)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return u
+	}
+	must := func(err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	admin, owner, member, outsider := user("root", true), user("olivia", false), user("mallory", false), user("eve", false)
+
+	org, err := orgs.Create("acme", "", owner.ID)
+	must(err)
+	must(orgs.SetMember(org.ID, member.ID, OrgRoleMember))
+
+	readers, err := teams.Create(org.ID, "readers", "", PermissionRead)
+	must(err)
+	ops, err := teams.Create(org.ID, "ops", "", PermissionAdmin)
+	must(err)
+	for _, team := range []*Team{readers, ops} {
+		must(teams.AddMember(team, member.ID))
+	}
+	must(teams.AddRepo(readers, "acme", "acme/web"))
+	must(teams.AddRepo(ops, "acme", "acme/ops"))
+	must(perms.Grant("acme/web", member.ID, PermissionWrite))
+	must(perms.Grant("eve/tools", member.ID, PermissionRead))
+	must(perms.Grant("olivia/site", outsider.ID, PermissionWrite))
+
+	tests := []struct {
+		name string
+		user *User
+		repo string
+		want Permission
+	}{
+		{"anonymous", nil, "acme/web", PermissionRead},
+		{"site admin", admin, "eve/tools", PermissionAdmin},
+		{"user owning the repository", outsider, "eve/tools", PermissionAdmin},
+		{"organization owner", owner, "acme/web", PermissionAdmin},
+		{"highest of grant and team", member, "acme/web", PermissionWrite},
+		{"team", member, "acme/ops", PermissionAdmin},
+		{"organization member without grants", member, "acme/other", PermissionRead},
+		{"read grant", member, "eve/tools", PermissionRead},
+		{"grant on a user's repository", outsider, "olivia/site", PermissionWrite},
+		{"no grant", outsider, "acme/web", PermissionRead},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := perms.Effective(tt.user, tt.repo)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("Effective(%s) = %s, want %s", tt.repo, got, tt.want)
+			}
+		})
+	}
+
+	// Revoking a grant leaves what the teams give
+	must(perms.Revoke("acme/web", member.ID))
+	if got, err := perms.Effective(member, "acme/web"); err != nil || got != PermissionRead {
+		t.Errorf("after revoking, Effective(acme/web) = %s, %v; want read", got, err)
+	}
+}
@@ -0,0 +1,195 @@
+//models/pull_request.go
+
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Pull request states.
+const (
+	PullRequestOpen   = "open"
+	PullRequestClosed = "closed"
+	PullRequestMerged = "merged"
+)
+
+// PullRequest asks for SourceBranch to be merged into TargetBranch of the
+// same repository. Number is sequential per repository. BaseCommit and
+// HeadCommit record the branch tips when the pull request was merged or
+// closed, so it can still be shown after the branches move or go away.
+type PullRequest struct {
+	ID           string     `gorm:"primarykey" json:"id"`
+	RepoName     string     `gorm:"index;uniqueIndex:idx_pull_repo_number" json:"repo"`
+	Number       int        `gorm:"uniqueIndex:idx_pull_repo_number" json:"number"`
+	Title        string     `gorm:"not null" json:"title"`
+	Description  string     `json:"description"`
+	SourceBranch string     `gorm:"not null" json:"source_branch"`
+	TargetBranch string     `gorm:"not null" json:"target_branch"`
+	AuthorID     string     `gorm:"index" json:"author_id"`
+	Author       User       `gorm:"foreignKey:AuthorID" json:"author"`
+	State        string     `gorm:"index;not null" json:"state"`
+	BaseCommit   string     `json:"base_commit,omitempty"`
+	HeadCommit   string     `json:"head_commit,omitempty"`
+	MergeCommit  string     `json:"merge_commit,omitempty"`
+	MergedByID   string     `json:"merged_by_id,omitempty"`
+	MergedAt     *time.Time `json:"merged_at,omitempty"`
+	ClosedAt     *time.Time `json:"closed_at,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+// IsOpen reports whether the pull request can still be merged or closed.
+func (pr *PullRequest) IsOpen() bool {
+	return pr.State == PullRequestOpen
+}
+
+// PullRequestService stores pull requests.
+type PullRequestService struct {
+	db *gorm.DB
+}
+
+func NewPullRequestService(db *gorm.DB) *PullRequestService {
+	return &PullRequestService{db: db}
+}
+
+// Create stores a new open pull request and assigns it the next number of
+// its repository.
+func (s *PullRequestService) Create(pr *PullRequest) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var last int
+		if err := tx.Model(&PullRequest{}).Where("repo_name = ?", pr.RepoName).
+			Select("COALESCE(MAX(number), 0)").Scan(&last).Error; err != nil {
+			return err
+		}
+
+		pr.ID = uuid.New().String()
+		pr.Number = last + 1
+		pr.State = PullRequestOpen
+		pr.CreatedAt = time.Now()
+		pr.UpdatedAt = pr.CreatedAt
+
+		if err := tx.Create(pr).Error; err != nil {
+			return fmt.Errorf("failed to create pull request: %w", err)
+		}
+		return nil
+	})
+}
+
+// Get returns a pull request by repository and number.
+func (s *PullRequestService) Get(repoName string, number int) (*PullRequest, error) {
+	var pr PullRequest
+	err := s.db.Preload("Author").Where("repo_name = ? AND number = ?", repoName, number).First(&pr).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("pull request not found")
+		}
+		return nil, err
+	}
+	return &pr, nil
+}
+
+// List returns the pull requests of a repository, newest first. An empty
+// state returns pull requests in every state.
+func (s *PullRequestService) List(repoName, state string) ([]PullRequest, error) {
+	var prs []PullRequest
+	query := s.db.Preload("Author").Where("repo_name = ?", repoName)
+	if state != "" {
+		query = query.Where("state = ?", state)
+	}
+	if err := query.Order("number DESC").Find(&prs).Error; err != nil {
+		return nil, err
+	}
+	return prs, nil
+}
+
+// CountOpen returns the number of open pull requests of a repository.
+func (s *PullRequestService) CountOpen(repoName string) (int64, error) {
+	var count int64
+	err := s.db.Model(&PullRequest{}).Where("repo_name = ? AND state = ?", repoName, PullRequestOpen).Count(&count).Error
+	return count, err
+}
+
+// MarkMerged records that the pull request was merged by userID.
+func (s *PullRequestService) MarkMerged(pr *PullRequest, userID, baseCommit, headCommit, mergeCommit string) error {
+	now := time.Now()
+	pr.State = PullRequestMerged
+	pr.MergedByID = userID
+	pr.MergedAt = &now
+	pr.ClosedAt = &now
+	pr.BaseCommit = baseCommit
+	pr.HeadCommit = headCommit
+	pr.MergeCommit = mergeCommit
+	return s.db.Save(pr).Error
+}
+
+// SetClosed closes or reopens a pull request. Closing records the branch
+// tips so the pull request can still be displayed later.
+func (s *PullRequestService) SetClosed(pr *PullRequest, closed bool, baseCommit, headCommit string) error {
+	if closed {
+		now := time.Now()
+		pr.State = PullRequestClosed
+		pr.ClosedAt = &now
+		pr.BaseCommit = baseCommit
+		pr.HeadCommit = headCommit
+	} else {
+		pr.State = PullRequestOpen
+		pr.ClosedAt = nil
+		pr.BaseCommit = ""
+		pr.HeadCommit = ""
+	}
+	return s.db.Save(pr).Error
+}
+
+// DeleteByRepo removes every pull request of a repository.
+func (s *PullRequestService) DeleteByRepo(repoName string) error {
+	return s.db.Where("repo_name = ?", repoName).Delete(&PullRequest{}).Error
+}
+
+// RenameRepo moves every pull request of a repository to its new name.
+func (s *PullRequestService) RenameRepo(oldName, newName string) error {
+	return s.db.Model(&PullRequest{}).Where("repo_name = ?", oldName).Update("repo_name", newName).Error
+}
+
+// ListOpenForBranch returns the open pull requests of a repository that
+// have branch as their source or target.
+func (s *PullRequestService) ListOpenForBranch(repoName, branch string) ([]PullRequest, error) {
+	var prs []PullRequest
+	err := s.db.Where("repo_name = ? AND state = ? AND (source_branch = ? OR target_branch = ?)",
+		repoName, PullRequestOpen, branch, branch).Find(&prs).Error
+	return prs, err
+}
+
+// MergedHeads returns the head commits of the pull requests of a
+// repository that were merged into target. A branch whose tip is one of
+// them was merged even if the merge squashed or rebased its commits.
+func (s *PullRequestService) MergedHeads(repoName, target string) (map[string]bool, error) {
+	var heads []string
+	err := s.db.Model(&PullRequest{}).Where("repo_name = ? AND state = ? AND target_branch = ?",
+		repoName, PullRequestMerged, target).Pluck("head_commit", &heads).Error
+	if err != nil {
+		return nil, err
+	}
+
+	merged := make(map[string]bool, len(heads))
+	for _, head := range heads {
+		merged[head] = true
+	}
+	return merged, nil
+}
+
+// RenameBranch points the open pull requests of a repository that use a
+// renamed branch at its new name.
+func (s *PullRequestService) RenameBranch(repoName, oldName, newName string) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		open := tx.Model(&PullRequest{}).Where("repo_name = ? AND state = ?", repoName, PullRequestOpen)
+		if err := open.Where("source_branch = ?", oldName).Update("source_branch", newName).Error; err != nil {
+			return err
+		}
+		open = tx.Model(&PullRequest{}).Where("repo_name = ? AND state = ?", repoName, PullRequestOpen)
+		return open.Where("target_branch = ?", oldName).Update("target_branch", newName).Error
+	})
+}
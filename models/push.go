@@ -0,0 +1,77 @@
+//models/push.go
+
+package models
+
+import (
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+)
+
+// RefUpdate describes a reference changed by a push. Old is the zero hash
+// for created references and New is the zero hash for deleted ones.
+type RefUpdate struct {
+	Name plumbing.ReferenceName
+	Old  plumbing.Hash
+	New  plumbing.Hash
+}
+
+// IsBranch reports whether the update is to a branch.
+func (u RefUpdate) IsBranch() bool {
+	return u.Name.IsBranch()
+}
+
+// IsDelete reports whether the push removed the reference.
+func (u RefUpdate) IsDelete() bool {
+	return u.New.IsZero()
+}
+
+// SnapshotRefs returns the hashes of every branch and tag of the
+// repository at repoPath, used to work out what a push changed.
+func SnapshotRefs(repoPath string) (map[plumbing.ReferenceName]plumbing.Hash, error) {
+	repo, err := OpenGit(repoPath)
+	if err != nil {
+		return nil, err
+	}
+
+	refs, err := repo.References()
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := make(map[plumbing.ReferenceName]plumbing.Hash)
+	err = refs.ForEach(func(ref *plumbing.Reference) error {
+		if ref.Type() != plumbing.HashReference {
+			return nil
+		}
+		if ref.Name().IsBranch() || ref.Name().IsTag() {
+			snapshot[ref.Name()] = ref.Hash()
+		}
+		return nil
+	})
+	if err != nil && err != storer.ErrStop {
+		return nil, err
+	}
+
+	return snapshot, nil
+}
+
+// DiffRefs returns the references that differ between two snapshots. A
+// nil snapshot, one SnapshotRefs failed to take, gives no updates rather
+// than reporting every reference as created or deleted.
+func DiffRefs(before, after map[plumbing.ReferenceName]plumbing.Hash) []RefUpdate {
+	if before == nil || after == nil {
+		return nil
+	}
+	var updates []RefUpdate
+	for name, hash := range after {
+		if old := before[name]; old != hash {
+			updates = append(updates, RefUpdate{Name: name, Old: old, New: hash})
+		}
+	}
+	for name, hash := range before {
+		if _, ok := after[name]; !ok {
+			updates = append(updates, RefUpdate{Name: name, Old: hash, New: plumbing.ZeroHash})
+		}
+	}
+	return updates
+}
@@ -0,0 +1,36 @@
+//models/push_test.go
+
+package models
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+func TestDiffRefs(t *testing.T) {
+	a, b := plumbing.NewHash("aaaa"), plumbing.NewHash("bbbb")
+	main, tag := plumbing.NewBranchReferenceName("main"), plumbing.NewTagReferenceName("v1")
+	type refs = map[plumbing.ReferenceName]plumbing.Hash
+
+	tests := []struct {
+		name          string
+		before, after refs
+		want          []RefUpdate
+	}{
+		{"unchanged", refs{main: a}, refs{main: a}, nil},
+		{"created", refs{}, refs{tag: a}, []RefUpdate{{Name: tag, New: a}}},
+		{"moved", refs{main: a}, refs{main: b}, []RefUpdate{{Name: main, Old: a, New: b}}},
+		{"deleted", refs{main: a}, refs{}, []RefUpdate{{Name: main, Old: a}}},
+		{"failed before snapshot", nil, refs{main: a}, nil},
+		{"failed after snapshot", refs{main: a}, nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DiffRefs(tt.before, tt.after); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DiffRefs() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,67 @@
+//models/redirect.go
+
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// RepoRedirect sends requests for the former name of a renamed or
+// transferred repository to its current name.
+type RepoRedirect struct {
+	ID        string `gorm:"primarykey"`
+	OldName   string `gorm:"uniqueIndex;not null"`
+	NewName   string `gorm:"index;not null"`
+	CreatedAt time.Time
+}
+
+// RedirectService stores the former names of repositories.
+type RedirectService struct {
+	db *gorm.DB
+}
+
+func NewRedirectService(db *gorm.DB) *RedirectService {
+	return &RedirectService{db: db}
+}
+
+// Add redirects oldName to newName. Redirects to oldName are pointed at
+// newName too so a chain of renames takes one hop, and a redirect away
+// from newName is dropped since that name is in use again.
+func (s *RedirectService) Add(oldName, newName string) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("old_name IN ?", []string{oldName, newName}).Delete(&RepoRedirect{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&RepoRedirect{}).Where("new_name = ?", oldName).Update("new_name", newName).Error; err != nil {
+			return err
+		}
+		return tx.Create(&RepoRedirect{
+			ID:        uuid.New().String(),
+			OldName:   oldName,
+			NewName:   newName,
+			CreatedAt: time.Now(),
+		}).Error
+	})
+}
+
+// Lookup returns the name a former name redirects to.
+func (s *RedirectService) Lookup(oldName string) (string, bool) {
+	var redirect RepoRedirect
+	if err := s.db.Where("old_name = ?", oldName).First(&redirect).Error; err != nil {
+		return "", false
+	}
+	return redirect.NewName, true
+}
+
+// Release drops the redirect away from name, which a new repository took.
+func (s *RedirectService) Release(name string) error {
+	return s.db.Where("old_name = ?", name).Delete(&RepoRedirect{}).Error
+}
+
+// DeleteByRepo drops the redirects to a repository that was removed.
+func (s *RedirectService) DeleteByRepo(name string) error {
+	return s.db.Where("new_name = ?", name).Delete(&RepoRedirect{}).Error
+}
@@ -6,8 +6,10 @@ import (
 	config "SimpleGit/config"
 	"errors"
 	"fmt"
+	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
 	"sort"
 	"strings"
 	"time"
@@ -17,11 +19,17 @@ import (
 	"github.com/go-git/go-git/v5/plumbing/object"
 )
 
+// Repository is a bare repository stored at <RepoPath>/<owner>/<name>.git.
+// Name is the full name, <owner>/<name>, which identifies the repository
+// in URLs and in everything stored about it.
 type Repository struct {
 	ID          string    `json:"id"`
 	Name        string    `json:"name"`
+	Owner       string    `json:"owner"`
 	Path        string    `json:"path"`
 	Description string    `json:"description"`
+	Parent      string    `json:"parent,omitempty"`
+	Archived    bool      `json:"archived"`
 	CreatedAt   time.Time `json:"created_at"`
 	Size        int64     `json:"size"`
 	git         *git.Repository
@@ -58,7 +66,7 @@ func (r *Repository) initGit() error {
 		return nil
 	}
 
-	repo, err := git.PlainOpen(r.Path)
+	repo, err := OpenGit(r.Path)
 	if err != nil {
 		return err
 	}
@@ -175,8 +183,7 @@ func (r *Repository) GetTree(path, ref string) ([]TreeEntry, error) {
 		return nil, err
 	}
 
-	hash := plumbing.NewHash(ref)
-	commit, err := r.git.CommitObject(hash)
+	commit, err := r.ResolveCommit(ref)
 	if err != nil {
 		return nil, err
 	}
@@ -295,8 +302,12 @@ func (r *Repository) GetCommits(ref string, limit int) ([]CommitInfo, error) {
 
 	var commits []CommitInfo
 
-	hash := plumbing.NewHash(ref)
-	cIter, err := r.git.Log(&git.LogOptions{From: hash, Order: git.LogOrderCommitterTime})
+	commit, err := r.ResolveCommit(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	cIter, err := r.git.Log(&git.LogOptions{From: commit.Hash, Order: git.LogOrderCommitterTime})
 	if err != nil {
 		return nil, err
 	}
@@ -324,6 +335,22 @@ func (r *Repository) GetCommits(ref string, limit int) ([]CommitInfo, error) {
 }
 
 func (r *Repository) GetFile(path, branch string) ([]byte, error) {
+	file, err := r.GetFileObject(path, branch)
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := file.Contents()
+	if err != nil {
+		return nil, NewGitError("Failed to read file contents", err)
+	}
+
+	return []byte(content), nil
+}
+
+// GetFileObject returns the blob for path at the tip of branch. The blob
+// hash identifies the file contents and is used as a cache key.
+func (r *Repository) GetFileObject(path, branch string) (*object.File, error) {
 	if err := r.initGit(); err != nil {
 		return nil, NewGitError("Failed to open repository", err)
 	}
@@ -356,12 +383,162 @@ func (r *Repository) GetFile(path, branch string) ([]byte, error) {
 		return nil, NewGitError("Failed to get file", err)
 	}
 
-	content, err := file.Contents()
+	return file, nil
+}
+
+// DefaultBranch returns the branch HEAD points to, falling back to the
+// first branch when HEAD is dangling. It returns an empty string for
+// repositories without branches.
+func (r *Repository) DefaultBranch() (string, error) {
+	if err := r.initGit(); err != nil {
+		return "", err
+	}
+
+	head, err := r.git.Storer.Reference(plumbing.HEAD)
+	if err == nil && head.Type() == plumbing.SymbolicReference {
+		if _, err := r.git.Reference(head.Target(), false); err == nil {
+			return head.Target().Short(), nil
+		}
+	}
+
+	branches, err := r.GetBranches()
+	if err != nil || len(branches) == 0 {
+		return "", err
+	}
+	return branches[0], nil
+}
+
+// ResolveBranch returns the commit hash at the tip of branch.
+func (r *Repository) ResolveBranch(branch string) (plumbing.Hash, error) {
+	if err := r.initGit(); err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	ref, err := r.git.Reference(plumbing.NewBranchReferenceName(branch), true)
 	if err != nil {
-		return nil, NewGitError("Failed to read file contents", err)
+		return plumbing.ZeroHash, err
 	}
 
-	return []byte(content), nil
+	return ref.Hash(), nil
+}
+
+// BranchTree returns the root tree of the commit at the tip of branch.
+func (r *Repository) BranchTree(branch string) (*object.Tree, error) {
+	if err := r.initGit(); err != nil {
+		return nil, err
+	}
+
+	ref, err := r.git.Reference(plumbing.NewBranchReferenceName(branch), true)
+	if err != nil {
+		return nil, err
+	}
+
+	commit, err := r.git.CommitObject(ref.Hash())
+	if err != nil {
+		return nil, err
+	}
+
+	return commit.Tree()
+}
+
+// ResolveCommit returns the commit a revision such as a branch, tag or
+// (abbreviated) hash names. Like git, branches and tags win over hashes,
+// so a branch named "c1" is not mistaken for an abbreviated hash.
+func (r *Repository) ResolveCommit(rev string) (*object.Commit, error) {
+	if err := r.initGit(); err != nil {
+		return nil, err
+	}
+
+	for _, name := range []plumbing.ReferenceName{plumbing.NewBranchReferenceName(rev), plumbing.NewTagReferenceName(rev)} {
+		ref, err := r.git.Reference(name, true)
+		if err != nil {
+			continue
+		}
+		if tag, err := r.git.TagObject(ref.Hash()); err == nil {
+			return tag.Commit()
+		}
+		return r.git.CommitObject(ref.Hash())
+	}
+
+	hash, err := r.git.ResolveRevision(plumbing.Revision(rev))
+	if err != nil {
+		return nil, err
+	}
+
+	return r.git.CommitObject(*hash)
+}
+
+// StaleBranchAge is how long ago the last commit of a stale branch was
+// made.
+const StaleBranchAge = 90 * 24 * time.Hour
+
+// Branch is a branch with the commit at its tip and how it compares to the
+// default branch. Ahead and Behind count the commits only the branch and
+// only the default branch have. A branch is merged when the default branch
+// contains all its commits, and stale when its last commit is older than
+// StaleBranchAge. The default branch itself is neither.
+type Branch struct {
+	Name    string     `json:"name"`
+	Commit  CommitInfo `json:"commit"`
+	Default bool       `json:"default"`
+	Ahead   int        `json:"ahead"`
+	Behind  int        `json:"behind"`
+	Merged  bool       `json:"merged"`
+	Stale   bool       `json:"stale"`
+}
+
+// ListBranches returns every branch with its tip commit and comparison to
+// the default branch, the default branch first and the others by most
+// recent commit.
+func (r *Repository) ListBranches() ([]Branch, error) {
+	if err := r.initGit(); err != nil {
+		return nil, err
+	}
+
+	defaultBranch, _ := r.DefaultBranch()
+
+	branchIter, err := r.git.Branches()
+	if err != nil {
+		return nil, err
+	}
+
+	var branches []Branch
+	err = branchIter.ForEach(func(ref *plumbing.Reference) error {
+		commit, err := r.git.CommitObject(ref.Hash())
+		if err != nil {
+			return nil
+		}
+		branch := Branch{
+			Name:    ref.Name().Short(),
+			Commit:  commitInfo(commit),
+			Default: ref.Name().Short() == defaultBranch,
+		}
+		if !branch.Default {
+			branch.Stale = time.Since(commit.Committer.When) > StaleBranchAge
+			if defaultBranch != "" {
+				base := plumbing.NewBranchReferenceName(defaultBranch).String()
+				ahead, behind, err := r.AheadBehind(base, ref.Name().String())
+				if err == nil {
+					branch.Ahead, branch.Behind = ahead, behind
+					branch.Merged = ahead == 0
+				}
+			}
+		}
+		branches = append(branches, branch)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(branches, func(i, j int) bool {
+		if branches[i].Default != branches[j].Default {
+			return branches[i].Default
+		}
+		return branches[i].Commit.Timestamp.After(branches[j].Commit.Timestamp)
+	})
+
+	return branches, nil
 }
 
 func (r *Repository) GetBranches() ([]string, error) {
@@ -406,6 +583,19 @@ func (r *Repository) CloneURL() string {
 		r.Name)
 }
 
+// ShortName returns the name of the repository without its owner.
+func (r *Repository) ShortName() string {
+	_, name, _ := strings.Cut(r.Name, "/")
+	return name
+}
+
+// RepoDir returns the directory below root that stores the repository with
+// the full name fullName.
+func RepoDir(root, fullName string) string {
+	owner, name, _ := strings.Cut(fullName, "/")
+	return filepath.Join(root, owner, name+".git")
+}
+
 // EnsureBare is a function that ensures that the repository is bare
 // TODO: Implement a way to do this without using exec.Command
 func (r *Repository) EnsureBare() error {
@@ -424,3 +614,79 @@ func (r *Repository) EnsureBare() error {
 	}
 	return nil
 }
+
+// repoNamePattern limits repository names to characters that are safe in
+// paths and URLs.
+var repoNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// defaultDescription is the placeholder git writes to new repositories.
+const defaultDescription = "Unnamed repository; edit this file 'description' to name the repository."
+
+// ValidRepoName reports whether name can be used as a repository name.
+func ValidRepoName(name string) bool {
+	return len(name) <= 100 && repoNamePattern.MatchString(name) && !strings.HasSuffix(name, ".git")
+}
+
+// ValidBranchName reports whether name can be used as a branch name,
+// following the rules of git check-ref-format.
+func ValidBranchName(name string) bool {
+	if name == "" || len(name) > 255 || name == "@" || strings.HasPrefix(name, "-") {
+		return false
+	}
+	if strings.Contains(name, "..") || strings.Contains(name, "@{") || strings.Contains(name, "//") {
+		return false
+	}
+	if strings.HasPrefix(name, "/") || strings.HasSuffix(name, "/") || strings.HasSuffix(name, ".") {
+		return false
+	}
+	for _, c := range name {
+		if c < 0x20 || c == 0x7f || strings.ContainsRune(" ~^:?*[\\", c) {
+			return false
+		}
+	}
+	for _, part := range strings.Split(name, "/") {
+		if strings.HasPrefix(part, ".") || strings.HasSuffix(part, ".lock") {
+			return false
+		}
+	}
+	return true
+}
+
+// HeadBranch returns the branch HEAD points to, even if it has no commits
+// yet, so the first commit of an empty repository lands on it.
+func (r *Repository) HeadBranch() (string, error) {
+	if err := r.initGit(); err != nil {
+		return "", err
+	}
+
+	head, err := r.git.Storer.Reference(plumbing.HEAD)
+	if err != nil {
+		return "", err
+	}
+	if head.Type() != plumbing.SymbolicReference || !head.Target().IsBranch() {
+		return "", fmt.Errorf("HEAD does not point to a branch")
+	}
+	return head.Target().Short(), nil
+}
+
+// LoadDescription reads the description from the repository's description
+// file, the place git and gitweb keep it.
+func (r *Repository) LoadDescription() {
+	data, err := os.ReadFile(filepath.Join(r.Path, "description"))
+	if err != nil {
+		return
+	}
+	if description := strings.TrimSpace(string(data)); description != defaultDescription {
+		r.Description = description
+	}
+}
+
+// SetDescription updates the description and stores it in the
+// repository's description file.
+func (r *Repository) SetDescription(description string) error {
+	if err := os.WriteFile(filepath.Join(r.Path, "description"), []byte(description+"\n"), 0644); err != nil {
+		return fmt.Errorf("failed to write description: %w", err)
+	}
+	r.Description = description
+	return nil
+}
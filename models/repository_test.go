@@ -0,0 +1,50 @@
+//models/repository_test.go
+
+package models
+
+import "testing"
+
+func TestListBranchesComparesToTheDefaultBranch(t *testing.T) {
+	repo := newTestRepo(t)
+	if err := repo.SetDefaultBranch("main"); err != nil {
+		t.Fatal(err)
+	}
+	commitFile(t, repo, "feature", "main", "feature.txt", "feature\n")
+	if _, err := repo.runGit(nil, "branch", "done", "main"); err != nil {
+		t.Fatal(err)
+	}
+	commitFile(t, repo, "main", "", "main.txt", "main\n")
+
+	// old has one commit of its own, made long ago
+	env := []string{"GIT_AUTHOR_NAME=Alice", "GIT_AUTHOR_EMAIL=alice@example.com", "GIT_COMMITTER_NAME=Alice",
+		"GIT_COMMITTER_EMAIL=alice@example.com", "GIT_AUTHOR_DATE=2001-01-01T00:00:00Z", "GIT_COMMITTER_DATE=2001-01-01T00:00:00Z"}
+	old, err := repo.runGit(env, "commit-tree", "main^{tree}", "-p", "main", "-m", "Old")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.runGit(nil, "update-ref", "refs/heads/old", old); err != nil {
+		t.Fatal(err)
+	}
+
+	branches, err := repo.ListBranches()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(branches) != 4 || branches[0].Name != "main" || !branches[0].Default {
+		t.Fatalf("ListBranches() = %+v, want main first of 4", branches)
+	}
+
+	want := map[string]Branch{
+		"main":    {Default: true},
+		"feature": {Ahead: 1, Behind: 1},
+		"done":    {Behind: 1, Merged: true},
+		"old":     {Ahead: 1, Stale: true},
+	}
+	for _, b := range branches {
+		w := want[b.Name]
+		if b.Default != w.Default || b.Ahead != w.Ahead || b.Behind != w.Behind || b.Merged != w.Merged || b.Stale != w.Stale {
+			t.Errorf("%s: ahead %d, behind %d, merged %v, stale %v; want ahead %d, behind %d, merged %v, stale %v",
+				b.Name, b.Ahead, b.Behind, b.Merged, b.Stale, w.Ahead, w.Behind, w.Merged, w.Stale)
+		}
+	}
+}
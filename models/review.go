@@ -0,0 +1,244 @@
+//models/review.go
+
+package models
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Diff sides a review thread can be anchored to.
+const (
+	ReviewSideOld = "old"
+	ReviewSideNew = "new"
+)
+
+// ReviewThread is a discussion anchored to one line of a diff. A thread
+// belongs either to the diff of a single commit (CommitHash) or to the
+// diff of a pull request (PullNumber).
+//
+// Line always refers to Path as it is in AnchorCommit: the commit itself
+// or its parent for commit diffs, the head or the merge base for pull
+// requests. When a pull request moves, the thread is re-anchored to the
+// new commits, or marked outdated if its line changed.
+type ReviewThread struct {
+	ID             string          `gorm:"primarykey" json:"id"`
+	RepoName       string          `gorm:"index;not null" json:"repo"`
+	CommitHash     string          `gorm:"index" json:"commit,omitempty"`
+	PullNumber     int             `gorm:"index" json:"pull,omitempty"`
+	Path           string          `gorm:"not null" json:"path"`
+	Side           string          `gorm:"not null" json:"side"`
+	Line           int             `json:"line"`
+	AnchorCommit   string          `json:"anchor_commit"`
+	OriginalLine   int             `json:"original_line"`
+	OriginalCommit string          `json:"original_commit"`
+	Outdated       bool            `json:"outdated"`
+	ResolvedByID   string          `json:"resolved_by_id,omitempty"`
+	ResolvedBy     *User           `gorm:"foreignKey:ResolvedByID" json:"resolved_by,omitempty"`
+	ResolvedAt     *time.Time      `json:"resolved_at,omitempty"`
+	Comments       []ReviewComment `gorm:"foreignKey:ThreadID" json:"comments"`
+	CreatedAt      time.Time       `json:"created_at"`
+	UpdatedAt      time.Time       `json:"updated_at"`
+}
+
+// ReviewComment is one message in a review thread.
+type ReviewComment struct {
+	ID        string    `gorm:"primarykey" json:"id"`
+	ThreadID  string    `gorm:"index;not null" json:"thread_id"`
+	AuthorID  string    `gorm:"index" json:"author_id"`
+	Author    User      `gorm:"foreignKey:AuthorID" json:"author"`
+	Body      string    `gorm:"not null" json:"body"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Resolved reports whether the thread has been marked resolved.
+func (t *ReviewThread) Resolved() bool {
+	return t.ResolvedAt != nil
+}
+
+// ReviewService stores review threads and their comments.
+type ReviewService struct {
+	db *gorm.DB
+}
+
+func NewReviewService(db *gorm.DB) *ReviewService {
+	return &ReviewService{db: db}
+}
+
+// CreateThread stores a new thread together with its first comment.
+func (s *ReviewService) CreateThread(thread *ReviewThread, authorID, body string) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		thread.ID = uuid.New().String()
+		thread.OriginalLine = thread.Line
+		thread.OriginalCommit = thread.AnchorCommit
+		if err := tx.Create(thread).Error; err != nil {
+			return fmt.Errorf("failed to create review thread: %w", err)
+		}
+
+		comment := ReviewComment{
+			ID:       uuid.New().String(),
+			ThreadID: thread.ID,
+			AuthorID: authorID,
+			Body:     body,
+		}
+		if err := tx.Create(&comment).Error; err != nil {
+			return fmt.Errorf("failed to create review comment: %w", err)
+		}
+		return nil
+	})
+}
+
+// Reply adds a comment to an existing thread.
+func (s *ReviewService) Reply(thread *ReviewThread, authorID, body string) error {
+	comment := ReviewComment{
+		ID:       uuid.New().String(),
+		ThreadID: thread.ID,
+		AuthorID: authorID,
+		Body:     body,
+	}
+	if err := s.db.Create(&comment).Error; err != nil {
+		return fmt.Errorf("failed to create review comment: %w", err)
+	}
+	return s.db.Model(thread).Update("updated_at", time.Now()).Error
+}
+
+// GetThread returns a thread of a repository by ID.
+func (s *ReviewService) GetThread(repoName, id string) (*ReviewThread, error) {
+	var thread ReviewThread
+	err := s.db.Where("repo_name = ? AND id = ?", repoName, id).First(&thread).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("review thread not found")
+		}
+		return nil, err
+	}
+	return &thread, nil
+}
+
+// ListForCommit returns the threads on the diff of a commit.
+func (s *ReviewService) ListForCommit(repoName, commitHash string) ([]ReviewThread, error) {
+	return s.list(s.db.Where("repo_name = ? AND commit_hash = ?", repoName, commitHash))
+}
+
+// ListForPull returns the threads on the diff of a pull request.
+func (s *ReviewService) ListForPull(repoName string, number int) ([]ReviewThread, error) {
+	return s.list(s.db.Where("repo_name = ? AND pull_number = ?", repoName, number))
+}
+
+func (s *ReviewService) list(query *gorm.DB) ([]ReviewThread, error) {
+	var threads []ReviewThread
+	err := query.
+		Preload("ResolvedBy").
+		Preload("Comments", func(db *gorm.DB) *gorm.DB { return db.Order("created_at ASC") }).
+		Preload("Comments.Author").
+		Order("path ASC, line ASC, created_at ASC").
+		Find(&threads).Error
+	return threads, err
+}
+
+// SetResolved marks a thread resolved by userID, or unresolved.
+func (s *ReviewService) SetResolved(thread *ReviewThread, userID string, resolved bool) error {
+	if resolved {
+		now := time.Now()
+		thread.ResolvedByID = userID
+		thread.ResolvedAt = &now
+	} else {
+		thread.ResolvedByID = ""
+		thread.ResolvedAt = nil
+	}
+	return s.db.Model(thread).Select("resolved_by_id", "resolved_at").Updates(thread).Error
+}
+
+// Reanchor moves a thread to a line of another commit.
+func (s *ReviewService) Reanchor(thread *ReviewThread, line int, anchorCommit string) error {
+	thread.Line = line
+	thread.AnchorCommit = anchorCommit
+	return s.db.Model(thread).Select("line", "anchor_commit").Updates(thread).Error
+}
+
+// MarkOutdated records that the line a thread was anchored to has changed.
+func (s *ReviewService) MarkOutdated(thread *ReviewThread) error {
+	thread.Outdated = true
+	return s.db.Model(thread).Update("outdated", true).Error
+}
+
+// DeleteByRepo removes every review thread and comment of a repository.
+func (s *ReviewService) DeleteByRepo(repoName string) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		threads := tx.Model(&ReviewThread{}).Select("id").Where("repo_name = ?", repoName)
+		if err := tx.Where("thread_id IN (?)", threads).Delete(&ReviewComment{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("repo_name = ?", repoName).Delete(&ReviewThread{}).Error
+	})
+}
+
+// RenameRepo moves every review thread of a repository to its new name.
+func (s *ReviewService) RenameRepo(oldName, newName string) error {
+	return s.db.Model(&ReviewThread{}).Where("repo_name = ?", oldName).Update("repo_name", newName).Error
+}
+
+// MapLine follows a line of path from one commit to another. It returns
+// false if the line was changed or removed in between.
+//
+// Parameters:
+//   - path: The file the line is in.
+//   - from: The commit the line number refers to.
+//   - to: The commit to map the line to.
+//   - line: The 1-based line number in from.
+func (r *Repository) MapLine(path, from, to string, line int) (int, bool, error) {
+	if from == to {
+		return line, true, nil
+	}
+
+	out, err := r.runGit(nil, "diff", "--no-color", "--no-ext-diff", "--no-renames", "-U0", from, to, "--", path)
+	if err != nil {
+		return 0, false, err
+	}
+
+	offset := 0
+	for _, l := range strings.Split(out, "\n") {
+		if !strings.HasPrefix(l, "@@ ") {
+			continue
+		}
+
+		// @@ -oldStart[,oldCount] +newStart[,newCount] @@
+		fields := strings.Fields(l)
+		if len(fields) < 3 {
+			continue
+		}
+		oldStart, oldCount := parseHunkRange(strings.TrimPrefix(fields[1], "-"))
+		_, newCount := parseHunkRange(strings.TrimPrefix(fields[2], "+"))
+
+		// A pure insertion is reported after the line it follows
+		if oldCount == 0 {
+			oldStart++
+		}
+		if line < oldStart {
+			break
+		}
+		if line < oldStart+oldCount {
+			return 0, false, nil
+		}
+		offset += newCount - oldCount
+	}
+
+	return line + offset, true, nil
+}
+
+// parseHunkRange parses the "start,count" part of a unified diff hunk
+// header. A missing count means one line.
+func parseHunkRange(s string) (int, int) {
+	start, count, found := strings.Cut(s, ",")
+	n, _ := strconv.Atoi(start)
+	if !found {
+		return n, 1
+	}
+	c, _ := strconv.Atoi(count)
+	return n, c
+}
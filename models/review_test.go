@@ -0,0 +1,83 @@
+//models/review_test.go
+
+package models
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// commitWorkFile writes path in the working tree of repo and commits it.
+func commitWorkFile(t *testing.T, repo *Repository, path, content string) string {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(repo.Path, path), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	env := []string{
+		"GIT_AUTHOR_NAME=Alice", "GIT_AUTHOR_EMAIL=alice@example.com",
+		"GIT_COMMITTER_NAME=Alice", "GIT_COMMITTER_EMAIL=alice@example.com",
+	}
+	if _, err := repo.runGit(env, "add", path); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.runGit(env, "commit", "-q", "-m", "Change "+path); err != nil {
+		t.Fatal(err)
+	}
+	commit, err := repo.runGit(nil, "rev-parse", "HEAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return commit
+}
+
+func TestMapLine(t *testing.T) {
+	repo := &Repository{Name: "alice/demo", Path: t.TempDir()}
+	if _, err := repo.runGit(nil, "init", "-q"); err != nil {
+		t.Fatal(err)
+	}
+	before := commitWorkFile(t, repo, "notes.txt", "a\nb\nc\nd\ne\n")
+	// x is inserted after a, d is changed and e removed
+	after := commitWorkFile(t, repo, "notes.txt", "a\nx\nb\nc\nD\n")
+
+	tests := []struct {
+		from, to string
+		line     int
+		want     int
+		ok       bool
+	}{
+		{before, before, 4, 4, true},
+		{before, after, 1, 1, true},
+		{before, after, 2, 3, true},
+		{before, after, 3, 4, true},
+		{before, after, 4, 0, false},
+		{before, after, 5, 0, false},
+		{after, before, 2, 0, false},
+		{after, before, 3, 2, true},
+	}
+	for _, tt := range tests {
+		got, ok, err := repo.MapLine("notes.txt", tt.from, tt.to, tt.line)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("MapLine(%s..%s, %d) = %d, %v; want %d, %v", tt.from[:7], tt.to[:7], tt.line, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestParseHunkRange(t *testing.T) {
+	tests := []struct {
+		in           string
+		start, count int
+	}{
+		{"4", 4, 1},
+		{"4,2", 4, 2},
+		{"3,0", 3, 0},
+	}
+	for _, tt := range tests {
+		if start, count := parseHunkRange(tt.in); start != tt.start || count != tt.count {
+			t.Errorf("parseHunkRange(%q) = %d, %d; want %d, %d", tt.in, start, count, tt.start, tt.count)
+		}
+	}
+}
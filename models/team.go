@@ -0,0 +1,226 @@
+//models/team.go
+
+package models
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrTeamNotFound is returned for names no team of an organization has.
+	ErrTeamNotFound = errors.New("team not found")
+	// ErrNotOrgMember is returned when a user who is not a member of an
+	// organization is added to one of its teams.
+	ErrNotOrgMember = errors.New("the user is not a member of the organization")
+	// ErrNotOrgRepo is returned when a repository another owner owns is
+	// added to a team.
+	ErrNotOrgRepo = errors.New("the repository does not belong to the organization")
+)
+
+var teamNamePattern = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,39}$`)
+
+// Team is a group of members of an organization with a permission on a
+// set of the organization's repositories.
+type Team struct {
+	ID          string    `gorm:"primarykey" json:"id"`
+	OrgID       string    `gorm:"uniqueIndex:idx_team_name;not null" json:"-"`
+	Name        string    `gorm:"uniqueIndex:idx_team_name;not null" json:"name"`
+	Description string    `json:"description"`
+	Permission  string    `gorm:"not null" json:"permission"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// TeamMember puts a user on a team.
+type TeamMember struct {
+	ID        string    `gorm:"primarykey" json:"-"`
+	TeamID    string    `gorm:"uniqueIndex:idx_team_member;not null" json:"-"`
+	UserID    string    `gorm:"uniqueIndex:idx_team_member;not null" json:"-"`
+	CreatedAt time.Time `json:"created_at"`
+	User      User      `gorm:"foreignKey:UserID" json:"user"`
+}
+
+// TeamRepo gives a team its permission on a repository.
+type TeamRepo struct {
+	ID        string    `gorm:"primarykey" json:"-"`
+	TeamID    string    `gorm:"uniqueIndex:idx_team_repo;not null" json:"-"`
+	RepoName  string    `gorm:"uniqueIndex:idx_team_repo;not null" json:"repo"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TeamService stores the teams of organizations.
+type TeamService struct {
+	db *gorm.DB
+}
+
+func NewTeamService(db *gorm.DB) *TeamService {
+	return &TeamService{db: db}
+}
+
+// Create creates a team in an organization.
+func (s *TeamService) Create(orgID, name, description string, permission Permission) (*Team, error) {
+	if !teamNamePattern.MatchString(name) {
+		return nil, fmt.Errorf("invalid team name: must be 1 to 39 letters, numbers, hyphens and underscores")
+	}
+	if permission < PermissionRead || permission > PermissionAdmin {
+		return nil, fmt.Errorf("invalid permission: %s", permission)
+	}
+
+	var count int64
+	if err := s.db.Model(&Team{}).Where("org_id = ? AND LOWER(name) = LOWER(?)", orgID, name).Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		return nil, fmt.Errorf("the organization already has a team named %s", name)
+	}
+
+	team := &Team{
+		ID:          uuid.New().String(),
+		OrgID:       orgID,
+		Name:        name,
+		Description: description,
+		Permission:  permission.String(),
+		CreatedAt:   time.Now(),
+	}
+	if err := s.db.Create(team).Error; err != nil {
+		return nil, err
+	}
+	return team, nil
+}
+
+// Get returns the team of an organization with name.
+func (s *TeamService) Get(orgID, name string) (*Team, error) {
+	var team Team
+	err := s.db.Where("org_id = ? AND name = ?", orgID, name).First(&team).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, ErrTeamNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &team, nil
+}
+
+// List returns the teams of an organization ordered by name.
+func (s *TeamService) List(orgID string) ([]Team, error) {
+	var teams []Team
+	err := s.db.Where("org_id = ?", orgID).Order("name").Find(&teams).Error
+	return teams, err
+}
+
+// SetPermission changes the permission a team has on its repositories.
+func (s *TeamService) SetPermission(teamID string, permission Permission) error {
+	if permission < PermissionRead || permission > PermissionAdmin {
+		return fmt.Errorf("invalid permission: %s", permission)
+	}
+	return s.db.Model(&Team{}).Where("id = ?", teamID).Update("permission", permission.String()).Error
+}
+
+// Delete removes a team with its members and repositories.
+func (s *TeamService) Delete(teamID string) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("team_id = ?", teamID).Delete(&TeamMember{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("team_id = ?", teamID).Delete(&TeamRepo{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("id = ?", teamID).Delete(&Team{}).Error
+	})
+}
+
+// Members returns the members of a team with their users, ordered by
+// username.
+func (s *TeamService) Members(teamID string) ([]TeamMember, error) {
+	var members []TeamMember
+	err := s.db.Preload("User").Joins("JOIN users ON users.id = team_members.user_id").
+		Where("team_members.team_id = ?", teamID).Order("users.username").Find(&members).Error
+	return members, err
+}
+
+// AddMember puts a member of the team's organization on a team.
+func (s *TeamService) AddMember(team *Team, userID string) error {
+	var count int64
+	if err := s.db.Model(&OrgMember{}).Where("org_id = ? AND user_id = ?", team.OrgID, userID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrNotOrgMember
+	}
+
+	if err := s.db.Model(&TeamMember{}).Where("team_id = ? AND user_id = ?", team.ID, userID).Count(&count).Error; err != nil || count > 0 {
+		return err
+	}
+	return s.db.Create(&TeamMember{
+		ID:        uuid.New().String(),
+		TeamID:    team.ID,
+		UserID:    userID,
+		CreatedAt: time.Now(),
+	}).Error
+}
+
+// RemoveMember takes a user off a team.
+func (s *TeamService) RemoveMember(teamID, userID string) error {
+	return s.db.Where("team_id = ? AND user_id = ?", teamID, userID).Delete(&TeamMember{}).Error
+}
+
+// Repos returns the names of the repositories of a team, sorted.
+func (s *TeamService) Repos(teamID string) ([]string, error) {
+	var names []string
+	err := s.db.Model(&TeamRepo{}).Where("team_id = ?", teamID).Order("repo_name").Pluck("repo_name", &names).Error
+	return names, err
+}
+
+// AddRepo gives a team its permission on a repository of the team's
+// organization, named org.
+func (s *TeamService) AddRepo(team *Team, org, repoName string) error {
+	if owner, _, _ := strings.Cut(repoName, "/"); owner != org {
+		return ErrNotOrgRepo
+	}
+
+	var count int64
+	if err := s.db.Model(&TeamRepo{}).Where("team_id = ? AND repo_name = ?", team.ID, repoName).Count(&count).Error; err != nil || count > 0 {
+		return err
+	}
+	return s.db.Create(&TeamRepo{
+		ID:        uuid.New().String(),
+		TeamID:    team.ID,
+		RepoName:  repoName,
+		CreatedAt: time.Now(),
+	}).Error
+}
+
+// RemoveRepo takes a repository away from a team.
+func (s *TeamService) RemoveRepo(teamID, repoName string) error {
+	return s.db.Where("team_id = ? AND repo_name = ?", teamID, repoName).Delete(&TeamRepo{}).Error
+}
+
+// ForRepo returns the teams with a permission on a repository, ordered by
+// name.
+func (s *TeamService) ForRepo(repoName string) ([]Team, error) {
+	var teams []Team
+	err := s.db.Joins("JOIN team_repos ON team_repos.team_id = teams.id").
+		Where("team_repos.repo_name = ?", repoName).Order("teams.name").Find(&teams).Error
+	return teams, err
+}
+
+// RenameRepo moves the team grants of a repository to its new name.
+func (s *TeamService) RenameRepo(oldName, newName string) error {
+	return s.db.Model(&TeamRepo{}).Where("repo_name = ?", oldName).Update("repo_name", newName).Error
+}
+
+// DeleteByRepo removes a deleted repository from every team.
+func (s *TeamService) DeleteByRepo(repoName string) error {
+	return s.db.Where("repo_name = ?", repoName).Delete(&TeamRepo{}).Error
+}
+
+// DeleteByUser removes a deleted user from every team.
+func (s *TeamService) DeleteByUser(userID string) error {
+	return s.db.Where("user_id = ?", userID).Delete(&TeamMember{}).Error
+}
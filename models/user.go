@@ -6,7 +6,6 @@ import (
 	"crypto/sha256"
 	"encoding/base64"
 	"fmt"
-	"regexp"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -107,6 +106,15 @@ func (s *UserService) CreateUser(username, email, password string, isAdmin bool)
 		return nil, fmt.Errorf("invalid username: must contain only letters, numbers, hyphens, and underscores")
 	}
 
+	// Users and organizations share the namespace of repository owners
+	taken, err := NameTaken(s.db, username)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create user: %w", err)
+	}
+	if taken {
+		return nil, ErrOwnerNameTaken
+	}
+
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	if err != nil {
 		return nil, fmt.Errorf("failed to hash password: %w", err)
@@ -130,8 +138,7 @@ func (s *UserService) CreateUser(username, email, password string, isAdmin bool)
 }
 
 func validateUsername(username string) bool {
-	matched, _ := regexp.MatchString("^[a-zA-Z0-9_-]+$", username)
-	return matched && len(username) >= 3 && len(username) <= 39
+	return ValidOwnerName(username)
 }
 
 func (s *UserService) GetUserByUsername(username string) (*User, error) {
@@ -186,6 +193,10 @@ func (s *UserService) VerifyToken(tokenString string) (*User, error) {
 	if !ok {
 		return nil, err
 	}
+	// Scoped tokens such as LFS tokens are not sessions
+	if _, scoped := claims["scope"]; scoped {
+		return nil, fmt.Errorf("invalid session token")
+	}
 
 	var user User
 	if err := s.db.First(&user, "id = ?", claims["user_id"]).Error; err != nil {
@@ -195,8 +206,77 @@ func (s *UserService) VerifyToken(tokenString string) (*User, error) {
 	return &user, nil
 }
 
+// IssueLFSToken returns a token that lets user download, or with write
+// also upload, the LFS objects of one repository until it expires.
+func (s *UserService) IssueLFSToken(user *User, repoName string, write bool, ttl time.Duration) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"user_id": user.ID,
+		"scope":   "lfs",
+		"repo":    repoName,
+		"write":   write,
+		"exp":     time.Now().Add(ttl).Unix(),
+	})
+	return token.SignedString(s.jwtKey)
+}
+
+// VerifyLFSToken checks a token issued by IssueLFSToken for repoName and
+// returns its user and whether it allows uploads.
+func (s *UserService) VerifyLFSToken(tokenString, repoName string) (*User, bool, error) {
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		return s.jwtKey, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil || !token.Valid {
+		return nil, false, fmt.Errorf("invalid LFS token")
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || claims["scope"] != "lfs" || claims["repo"] != repoName {
+		return nil, false, fmt.Errorf("invalid LFS token")
+	}
+	write, _ := claims["write"].(bool)
+
+	var user User
+	if err := s.db.First(&user, "id = ?", claims["user_id"]).Error; err != nil {
+		return nil, false, err
+	}
+	return &user, write, nil
+}
+
 func (s *UserService) GetAdminCount() (int64, error) {
 	var count int64
 	err := s.db.Model(&User{}).Where("is_admin = ?", true).Count(&count).Error
 	return count, err
 }
+
+// FirstAdmin returns the admin that was created first.
+func (s *UserService) FirstAdmin() (*User, error) {
+	var user User
+	if err := s.db.Where("is_admin = ?", true).Order("created_at").First(&user).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("no admin user")
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
+// ListUsers returns every user ordered by username.
+func (s *UserService) ListUsers() ([]User, error) {
+	var users []User
+	err := s.db.Order("username").Find(&users).Error
+	return users, err
+}
+
+// SetAdmin grants or revokes a user's site admin rights.
+func (s *UserService) SetAdmin(userID string, isAdmin bool) error {
+	return s.db.Model(&User{}).Where("id = ?", userID).Update("is_admin", isAdmin).Error
+}
+
+// SetPassword replaces a user's password.
+func (s *UserService) SetPassword(userID, password string) error {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+	return s.db.Model(&User{}).Where("id = ?", userID).Update("password", string(hashedPassword)).Error
+}
@@ -0,0 +1,468 @@
+//services/backup.go
+
+package services
+
+import (
+	"SimpleGit/models"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"gorm.io/gorm"
+)
+
+// Files and directories of a backup set.
+const (
+	backupManifestFile = "manifest.json"
+	backupDatabaseFile = "simplegit.db"
+	backupHostKeyFile  = "ssh_host_key"
+	backupReposDir     = "repositories"
+	backupLFSDir       = "lfs"
+)
+
+// backupPrefix starts the name of every backup set, followed by the time
+// it was taken in backupTimeFormat.
+const backupPrefix = "simplegit-"
+
+const backupTimeFormat = "20060102-150405"
+
+// backupPartialSuffix marks a backup set that is still being written, or
+// whose writing was interrupted.
+const backupPartialSuffix = ".partial"
+
+// backupVersion is the layout version of the backup sets written.
+const backupVersion = 1
+
+// BackupManifest describes a backup set. It is stored as manifest.json
+// next to the database snapshot and the bundles.
+type BackupManifest struct {
+	Version      int          `json:"version"`
+	CreatedAt    time.Time    `json:"created_at"`
+	Database     string       `json:"database"`
+	SSHHostKey   string       `json:"ssh_host_key,omitempty"`
+	LFS          string       `json:"lfs,omitempty"`
+	LFSObjects   int          `json:"lfs_objects,omitempty"`
+	Repositories []BackupRepo `json:"repositories"`
+}
+
+// BackupRepo is a repository in a backup set. Bundle is empty for
+// repositories without any references.
+type BackupRepo struct {
+	Name        string            `json:"name"`
+	Bundle      string            `json:"bundle,omitempty"`
+	Head        string            `json:"head,omitempty"`
+	Description string            `json:"description,omitempty"`
+	Config      map[string]string `json:"config,omitempty"`
+}
+
+// Backups writes backup sets of the database and every repository into a
+// directory and keeps the most recent ones. A backup set is a directory
+// holding a snapshot of the SQLite database, a git bundle per repository,
+// the LFS objects, the SSH host key and a manifest; it is renamed into
+// place only once complete.
+type Backups struct {
+	db       *gorm.DB
+	repoRoot string
+	lfsDir   string
+	hostKey  string
+	dir      string
+	keep     int
+	mu       sync.Mutex
+}
+
+// NewBackups creates the backup writer.
+//
+// Parameters:
+//   - db: The database to snapshot.
+//   - repoRoot: The directory repositories are stored in.
+//   - lfsDir: The directory of the local LFS store.
+//   - hostKey: The SSH host key to include, if it exists.
+//   - dir: The directory backup sets are written to.
+//   - keep: How many backup sets to keep; older ones are removed.
+func NewBackups(db *gorm.DB, repoRoot, lfsDir, hostKey, dir string, keep int) *Backups {
+	return &Backups{
+		db:       db,
+		repoRoot: repoRoot,
+		lfsDir:   lfsDir,
+		hostKey:  hostKey,
+		dir:      dir,
+		keep:     max(keep, 1),
+	}
+}
+
+// Run writes a backup set and returns its path.
+func (b *Backups) Run() (string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	start := time.Now()
+	name := backupPrefix + start.UTC().Format(backupTimeFormat)
+	final := filepath.Join(b.dir, name)
+	partial := final + backupPartialSuffix
+	if err := os.MkdirAll(filepath.Join(partial, backupReposDir), 0700); err != nil {
+		return "", fmt.Errorf("failed to create backup directory: %w", err)
+	}
+
+	manifest, err := b.write(partial)
+	if err == nil {
+		manifest.CreatedAt = start
+		err = writeManifest(partial, manifest)
+	}
+	if err == nil {
+		err = os.Rename(partial, final)
+	}
+	if err != nil {
+		os.RemoveAll(partial)
+		return "", err
+	}
+
+	log.Printf("Backup: wrote %s with %d repositories and %d LFS objects in %s", final, len(manifest.Repositories), manifest.LFSObjects, time.Since(start).Round(time.Millisecond))
+	b.prune()
+	return final, nil
+}
+
+// write fills the backup set in dir and returns its manifest.
+func (b *Backups) write(dir string) (*BackupManifest, error) {
+	manifest := &BackupManifest{Version: backupVersion, Database: backupDatabaseFile}
+
+	// VACUUM INTO writes a consistent copy while the server keeps running
+	if err := b.db.Exec("VACUUM INTO ?", filepath.Join(dir, backupDatabaseFile)).Error; err != nil {
+		return nil, fmt.Errorf("failed to snapshot the database: %w", err)
+	}
+
+	if b.hostKey != "" {
+		switch err := copyFileMode(b.hostKey, filepath.Join(dir, backupHostKeyFile), 0600); {
+		case err == nil:
+			manifest.SSHHostKey = backupHostKeyFile
+		case !os.IsNotExist(err):
+			return nil, fmt.Errorf("failed to copy the SSH host key: %w", err)
+		}
+	}
+
+	names, err := findRepositories(b.repoRoot)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list repositories: %w", err)
+	}
+	for _, name := range names {
+		entry, err := backupRepository(b.repoRoot, name, dir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to back up %s: %w", name, err)
+		}
+		manifest.Repositories = append(manifest.Repositories, *entry)
+	}
+
+	// Objects never change once stored, so linking them is a snapshot
+	count, err := linkLFSObjects(b.lfsDir, filepath.Join(dir, backupLFSDir))
+	if err != nil {
+		return nil, fmt.Errorf("failed to back up LFS objects: %w", err)
+	}
+	if count > 0 {
+		manifest.LFS, manifest.LFSObjects = backupLFSDir, count
+	}
+	return manifest, nil
+}
+
+// backupRepository bundles one repository into the backup set in dir.
+func backupRepository(repoRoot, name, dir string) (*BackupRepo, error) {
+	repo := &models.Repository{Name: name, Path: models.RepoDir(repoRoot, name)}
+	repo.LoadDescription()
+	entry := &BackupRepo{Name: name, Description: repo.Description}
+
+	if head, err := repo.HeadBranch(); err == nil {
+		entry.Head = head
+	}
+	config, err := repo.ServerConfig()
+	if err != nil {
+		return nil, err
+	}
+	if len(config) > 0 {
+		entry.Config = config
+	}
+
+	bundle := filepath.Join(backupReposDir, name+".bundle")
+	if err := os.MkdirAll(filepath.Dir(filepath.Join(dir, bundle)), 0700); err != nil {
+		return nil, err
+	}
+	written, err := repo.Bundle(filepath.Join(dir, bundle))
+	if err != nil {
+		return nil, err
+	}
+	if written {
+		entry.Bundle = bundle
+	}
+	return entry, nil
+}
+
+// Schedule writes a backup set every interval, counted from the most
+// recent one, until ctx is canceled. A backup being written when it is
+// canceled is finished first.
+func (b *Backups) Schedule(ctx context.Context, interval time.Duration) {
+	next := time.Now()
+	if sets, err := ListBackups(b.dir); err == nil && len(sets) > 0 {
+		if last, err := backupTime(sets[len(sets)-1]); err == nil {
+			next = last.Add(interval)
+		}
+	}
+
+	for {
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		if _, err := b.Run(); err != nil {
+			log.Printf("Backup: %v", err)
+		}
+		next = time.Now().Add(interval)
+	}
+}
+
+// prune removes the backup sets beyond the most recent keep, and partial
+// ones left by interrupted backups.
+func (b *Backups) prune() {
+	entries, err := os.ReadDir(b.dir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), backupPrefix) && strings.HasSuffix(entry.Name(), backupPartialSuffix) {
+			os.RemoveAll(filepath.Join(b.dir, entry.Name()))
+		}
+	}
+
+	sets, err := ListBackups(b.dir)
+	if err != nil {
+		return
+	}
+	for len(sets) > b.keep {
+		if err := os.RemoveAll(filepath.Join(b.dir, sets[0])); err != nil {
+			log.Printf("Backup: failed to remove %s: %v", sets[0], err)
+		}
+		sets = sets[1:]
+	}
+}
+
+// ListBackups returns the names of the complete backup sets in dir,
+// oldest first.
+func ListBackups(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var sets []string
+	for _, entry := range entries {
+		if _, err := backupTime(entry.Name()); err == nil && entry.IsDir() {
+			sets = append(sets, entry.Name())
+		}
+	}
+	sort.Strings(sets)
+	return sets, nil
+}
+
+// backupTime returns when the backup set with the given name was taken.
+func backupTime(name string) (time.Time, error) {
+	if !strings.HasPrefix(name, backupPrefix) {
+		return time.Time{}, fmt.Errorf("not a backup set: %s", name)
+	}
+	return time.Parse(backupTimeFormat, strings.TrimPrefix(name, backupPrefix))
+}
+
+// ReadBackupManifest reads the manifest of the backup set in dir.
+func ReadBackupManifest(dir string) (*BackupManifest, error) {
+	data, err := os.ReadFile(filepath.Join(dir, backupManifestFile))
+	if err != nil {
+		return nil, fmt.Errorf("%s is not a backup set: %w", dir, err)
+	}
+	var manifest BackupManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("invalid manifest: %w", err)
+	}
+	if manifest.Version != backupVersion {
+		return nil, fmt.Errorf("unsupported backup version %d", manifest.Version)
+	}
+	return &manifest, nil
+}
+
+func writeManifest(dir string, manifest *BackupManifest) error {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, backupManifestFile), append(data, '\n'), 0600)
+}
+
+// RestoreBackup rebuilds the repositories, the LFS objects and the database
+// from the backup set in dir. The server must not be running. Nothing is
+// overwritten: the repository and LFS directories must be empty and the
+// database must not exist.
+//
+// Parameters:
+//   - dir: The backup set.
+//   - repoRoot: The directory to restore the repositories into.
+//   - lfsDir: The directory to restore the LFS objects into.
+//   - dbPath: Where to restore the database.
+//   - hostKey: Where to restore the SSH host key, unless one exists.
+func RestoreBackup(dir, repoRoot, lfsDir, dbPath, hostKey string) error {
+	manifest, err := ReadBackupManifest(dir)
+	if err != nil {
+		return err
+	}
+
+	if entries, err := os.ReadDir(repoRoot); err == nil && len(entries) > 0 {
+		return fmt.Errorf("%s is not empty; move it away before restoring", repoRoot)
+	}
+	if entries, err := os.ReadDir(lfsDir); manifest.LFS != "" && err == nil && len(entries) > 0 {
+		return fmt.Errorf("%s is not empty; move it away before restoring", lfsDir)
+	}
+	if info, err := os.Stat(dbPath); err == nil && info.Size() > 0 {
+		return fmt.Errorf("%s exists; move it away before restoring", dbPath)
+	}
+
+	for _, entry := range manifest.Repositories {
+		if err := restoreRepository(dir, repoRoot, entry); err != nil {
+			return fmt.Errorf("failed to restore %s: %w", entry.Name, err)
+		}
+	}
+
+	if manifest.LFS != "" {
+		if !validBackupName(manifest.LFS) {
+			return fmt.Errorf("invalid LFS directory in manifest")
+		}
+		if _, err := linkLFSObjects(filepath.Join(dir, manifest.LFS), lfsDir); err != nil {
+			return fmt.Errorf("failed to restore LFS objects: %w", err)
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dbPath), 0755); err != nil {
+		return err
+	}
+	if err := copyFileMode(filepath.Join(dir, manifest.Database), dbPath, 0600); err != nil {
+		return fmt.Errorf("failed to restore the database: %w", err)
+	}
+
+	if manifest.SSHHostKey != "" && hostKey != "" {
+		if _, err := os.Stat(hostKey); os.IsNotExist(err) {
+			if err := os.MkdirAll(filepath.Dir(hostKey), 0755); err != nil {
+				return err
+			}
+			if err := copyFileMode(filepath.Join(dir, manifest.SSHHostKey), hostKey, 0600); err != nil {
+				return fmt.Errorf("failed to restore the SSH host key: %w", err)
+			}
+		}
+	}
+
+	log.Printf("Restore: restored %d repositories, %d LFS objects and the database from %s", len(manifest.Repositories), manifest.LFSObjects, dir)
+	return nil
+}
+
+// restoreRepository creates one repository of a backup set.
+func restoreRepository(dir, repoRoot string, entry BackupRepo) error {
+	if !validBackupName(entry.Name) {
+		return fmt.Errorf("invalid repository name")
+	}
+
+	path := models.RepoDir(repoRoot, entry.Name)
+	if err := os.MkdirAll(path, 0755); err != nil {
+		return err
+	}
+	if _, err := git.PlainInit(path, true); err != nil {
+		return err
+	}
+
+	repo := &models.Repository{Name: entry.Name, Path: path}
+	if entry.Bundle != "" {
+		if err := repo.Unbundle(filepath.Join(dir, entry.Bundle)); err != nil {
+			return err
+		}
+	}
+	if entry.Head != "" {
+		if err := repo.SetDefaultBranch(entry.Head); err != nil && !errors.Is(err, models.ErrBranchNotFound) {
+			return err
+		}
+	}
+	if entry.Description != "" {
+		if err := repo.SetDescription(entry.Description); err != nil {
+			return err
+		}
+	}
+	return repo.SetServerConfig(entry.Config)
+}
+
+// validBackupName reports whether a repository name from a manifest stays
+// inside the repository directory.
+func validBackupName(name string) bool {
+	if name == "" || filepath.IsAbs(name) {
+		return false
+	}
+	for _, part := range strings.Split(name, "/") {
+		if part == "" || part == "." || part == ".." {
+			return false
+		}
+	}
+	return true
+}
+
+// findRepositories returns the full names of the repositories under root,
+// including deleted ones that can still be restored.
+func findRepositories(root string) ([]string, error) {
+	var names []string
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() || path == root || !strings.HasSuffix(d.Name(), ".git") {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		names = append(names, filepath.ToSlash(strings.TrimSuffix(rel, ".git")))
+		return filepath.SkipDir
+	})
+	return names, err
+}
+
+// copyFileMode copies the file at src to dst with the given permissions,
+// replacing dst only once the copy is complete.
+func copyFileMode(src, dst string, perm os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	tmp := dst + ".tmp"
+	out, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, in)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp, dst)
+	}
+	if err != nil {
+		os.Remove(tmp)
+	}
+	return err
+}
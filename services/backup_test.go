@@ -0,0 +1,159 @@
+//services/backup_test.go
+
+package services
+
+import (
+	"SimpleGit/models"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func openTestDB(t *testing.T, path string) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(path), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return db
+}
+
+// TestBackupRestore backs up an installation with a repository, an LFS
+// object, the database and the host key, restores it elsewhere and checks
+// that everything came back.
+func TestBackupRestore(t *testing.T) {
+	log.SetOutput(io.Discard)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	src, dst := t.TempDir(), t.TempDir()
+	repoRoot, lfsDir := filepath.Join(src, "repos"), filepath.Join(src, "lfs")
+	hostKey := filepath.Join(src, "ssh_host_key")
+	if err := os.WriteFile(hostKey, []byte("host key"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	db := openTestDB(t, filepath.Join(src, "githost.db"))
+	if err := db.Exec("CREATE TABLE notes (body TEXT)").Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Exec("INSERT INTO notes VALUES (?)", "kept").Error; err != nil {
+		t.Fatal(err)
+	}
+
+	path := models.RepoDir(repoRoot, "alice/demo")
+	if _, err := git.PlainInit(path, true); err != nil {
+		t.Fatal(err)
+	}
+	repo := &models.Repository{Name: "alice/demo", Path: path}
+	commit, err := repo.CommitFiles(models.CommitFilesOptions{
+		Branch:  "trunk",
+		Message: "Add README",
+		Author:  models.MergeSignature{Name: "Alice", Email: "alice@example.com"},
+		Files:   []models.FileChange{{Path: "README.md", Content: []byte("# Demo\n")}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.SetDefaultBranch("trunk"); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.SetDescription("A demo"); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.SetServerConfig(map[string]string{"archived": "true"}); err != nil {
+		t.Fatal(err)
+	}
+	// An empty repository has nothing to bundle
+	if _, err := git.PlainInit(models.RepoDir(repoRoot, "alice/empty"), true); err != nil {
+		t.Fatal(err)
+	}
+
+	content := "large file\n"
+	sum := sha256.Sum256([]byte(content))
+	oid := hex.EncodeToString(sum[:])
+	store, err := NewLocalLFSStore(lfsDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Put("alice/demo", oid, int64(len(content)), strings.NewReader(content)); err != nil {
+		t.Fatal(err)
+	}
+
+	set, err := NewBackups(db, repoRoot, lfsDir, hostKey, filepath.Join(src, "backups"), 2).Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest, err := ReadBackupManifest(set)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(manifest.Repositories) != 2 || manifest.LFSObjects != 1 || manifest.SSHHostKey == "" {
+		t.Fatalf("manifest = %+v, want 2 repositories, 1 LFS object and the host key", manifest)
+	}
+
+	restoredRoot, restoredLFS := filepath.Join(dst, "repos"), filepath.Join(dst, "lfs")
+	restoredDB, restoredKey := filepath.Join(dst, "githost.db"), filepath.Join(dst, "ssh_host_key")
+	if err := RestoreBackup(set, restoredRoot, restoredLFS, restoredDB, restoredKey); err != nil {
+		t.Fatal(err)
+	}
+
+	restored := &models.Repository{Name: "alice/demo", Path: models.RepoDir(restoredRoot, "alice/demo")}
+	if tip, err := restored.ResolveBranch("trunk"); err != nil || tip != commit {
+		t.Errorf("trunk = %v, %v; want %v", tip, err, commit)
+	}
+	if head, err := restored.HeadBranch(); err != nil || head != "trunk" {
+		t.Errorf("HEAD = %q, %v; want trunk", head, err)
+	}
+	restored.LoadDescription()
+	if restored.Description != "A demo" {
+		t.Errorf("description = %q, want %q", restored.Description, "A demo")
+	}
+	if settings, err := restored.ServerConfig(); err != nil || settings["archived"] != "true" {
+		t.Errorf("settings = %v, %v; want archived", settings, err)
+	}
+	if _, err := os.Stat(filepath.Join(models.RepoDir(restoredRoot, "alice/empty"), "HEAD")); err != nil {
+		t.Errorf("empty repository not restored: %v", err)
+	}
+
+	restoredStore, err := NewLocalLFSStore(restoredLFS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := restoredStore.Open("alice/demo", oid)
+	if err != nil {
+		t.Fatalf("LFS object not restored: %v", err)
+	}
+	data, err := io.ReadAll(f)
+	f.Close()
+	if err != nil || string(data) != content {
+		t.Errorf("LFS object = %q, %v; want %q", data, err, content)
+	}
+
+	var body string
+	if err := openTestDB(t, restoredDB).Raw("SELECT body FROM notes").Scan(&body).Error; err != nil || body != "kept" {
+		t.Errorf("database row = %q, %v; want kept", body, err)
+	}
+	if key, err := os.ReadFile(restoredKey); err != nil || string(key) != "host key" {
+		t.Errorf("host key = %q, %v", key, err)
+	}
+
+	// Restoring never overwrites an installation
+	if err := RestoreBackup(set, restoredRoot, restoredLFS, filepath.Join(dst, "other.db"), ""); err == nil {
+		t.Error("restoring into a non-empty repository directory succeeded")
+	}
+}
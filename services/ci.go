@@ -0,0 +1,419 @@
+//services/ci.go
+
+package services
+
+import (
+	"SimpleGit/models"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// PipelinePath is where a repository declares its CI pipeline.
+const PipelinePath = ".simplegit/ci.yml"
+
+// ciWaitDelay is how long a timed out step gets to exit after being killed
+// before its output pipes are closed.
+const ciWaitDelay = 5 * time.Second
+
+// Pipeline is the parsed contents of a pipeline file.
+//
+// Example:
+//
+//	name: test
+//	branches: [main, "release/*"]
+//	timeout: 10m
+//	env:
+//	  GOFLAGS: -mod=vendor
+//	steps:
+//	  - name: Unit tests
+//	    run: go test ./...
+//	    timeout: 5m
+type Pipeline struct {
+	Name     string            `yaml:"name"`
+	Branches []string          `yaml:"branches"`
+	Timeout  string            `yaml:"timeout"`
+	Env      map[string]string `yaml:"env"`
+	Steps    []PipelineStep    `yaml:"steps"`
+}
+
+// PipelineStep is one shell command of a pipeline.
+type PipelineStep struct {
+	Name    string `yaml:"name"`
+	Run     string `yaml:"run"`
+	Timeout string `yaml:"timeout"`
+}
+
+// ParsePipeline parses and validates a pipeline file.
+func ParsePipeline(data []byte) (*Pipeline, error) {
+	var p Pipeline
+	if err := yaml.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", PipelinePath, err)
+	}
+
+	if len(p.Steps) == 0 {
+		return nil, fmt.Errorf("invalid %s: no steps", PipelinePath)
+	}
+	if p.Name == "" {
+		p.Name = "ci"
+	}
+	if _, err := parseStepTimeout(p.Timeout, time.Minute); err != nil {
+		return nil, fmt.Errorf("invalid %s: timeout: %w", PipelinePath, err)
+	}
+	for i, step := range p.Steps {
+		if strings.TrimSpace(step.Run) == "" {
+			return nil, fmt.Errorf("invalid %s: step %d has nothing to run", PipelinePath, i+1)
+		}
+		if _, err := parseStepTimeout(step.Timeout, time.Minute); err != nil {
+			return nil, fmt.Errorf("invalid %s: step %d timeout: %w", PipelinePath, i+1, err)
+		}
+	}
+	return &p, nil
+}
+
+// parseStepTimeout parses a duration such as "90s" or "10m". A bare
+// number is taken as seconds and an empty value gives def.
+func parseStepTimeout(value string, def time.Duration) (time.Duration, error) {
+	if value == "" {
+		return def, nil
+	}
+	if _, err := strconv.Atoi(value); err == nil {
+		value += "s"
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, err
+	}
+	if d <= 0 {
+		return 0, errors.New("must be positive")
+	}
+	return d, nil
+}
+
+// Matches reports whether the pipeline runs for pushes to branch. A
+// pipeline without a branches list runs for every branch.
+func (p *Pipeline) Matches(branch string) bool {
+	if len(p.Branches) == 0 {
+		return true
+	}
+	for _, pattern := range p.Branches {
+		if ok, _ := path.Match(pattern, branch); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// JobSteps turns the pipeline steps into job steps. Steps without their
+// own timeout use the pipeline's, or defaultTimeout.
+func (p *Pipeline) JobSteps(defaultTimeout time.Duration) []models.CIStep {
+	pipelineTimeout, _ := parseStepTimeout(p.Timeout, defaultTimeout)
+
+	steps := make([]models.CIStep, len(p.Steps))
+	for i, step := range p.Steps {
+		timeout, _ := parseStepTimeout(step.Timeout, pipelineTimeout)
+		name := step.Name
+		if name == "" {
+			name = strings.SplitN(strings.TrimSpace(step.Run), "\n", 2)[0]
+		}
+		steps[i] = models.CIStep{
+			Name:    name,
+			Command: step.Run,
+			Timeout: timeout,
+		}
+	}
+	return steps
+}
+
+// CIRunner runs CI jobs on a fixed number of workers. Each job checks its
+// commit out into a temporary worktree and runs the steps there one after
+// the other as shell subprocesses, writing their output to log files and
+// reporting the outcome as a commit status.
+type CIRunner struct {
+	jobs     *models.CIService
+	statuses *models.CommitStatusService
+	logDir   string
+	queue    chan ciTask
+	ctx      context.Context
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+	stopOnce sync.Once
+}
+
+// ciTask is a queued job together with what is needed to run it.
+type ciTask struct {
+	jobID    string
+	repoPath string
+	env      map[string]string
+}
+
+// NewCIRunner creates the runner and starts its workers.
+//
+// Parameters:
+//   - jobs: Where jobs and steps are stored.
+//   - statuses: Where commit statuses are reported.
+//   - logDir: The directory step logs are written to.
+//   - workers: How many jobs may run at the same time.
+func NewCIRunner(jobs *models.CIService, statuses *models.CommitStatusService, logDir string, workers int) *CIRunner {
+	ctx, cancel := context.WithCancel(context.Background())
+	c := &CIRunner{
+		jobs:     jobs,
+		statuses: statuses,
+		logDir:   logDir,
+		queue:    make(chan ciTask, 256),
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+
+	c.abandonUnfinished()
+
+	for i := 0; i < max(workers, 1); i++ {
+		c.wg.Add(1)
+		go c.worker()
+	}
+	return c
+}
+
+// StatusContext returns the commit status context a pipeline reports as.
+func StatusContext(pipeline string) string {
+	return "ci/" + pipeline
+}
+
+// LogPath returns the log file of a step.
+func (c *CIRunner) LogPath(jobID string, step int) string {
+	return filepath.Join(c.logDir, jobID, fmt.Sprintf("%d.log", step))
+}
+
+// Enqueue reports a new job as pending and queues it.
+//
+// Parameters:
+//   - job: A job created with models.CIService.CreateJob.
+//   - repoPath: The repository to check the commit out from.
+//   - env: Extra environment variables for the steps.
+func (c *CIRunner) Enqueue(job *models.CIJob, repoPath string, env map[string]string) {
+	c.setStatus(job, models.StatusPending, "Queued")
+
+	select {
+	case c.queue <- ciTask{jobID: job.ID, repoPath: repoPath, env: env}:
+	default:
+		log.Printf("CI: queue full, dropping %s#%d", job.RepoName, job.Number)
+		c.finish(job, models.CIError, "The CI queue is full")
+	}
+}
+
+// Stop cancels running steps and waits for the workers to exit.
+func (c *CIRunner) Stop() {
+	c.stopOnce.Do(func() {
+		c.cancel()
+		c.wg.Wait()
+	})
+}
+
+// abandonUnfinished fails jobs left queued or running by a previous
+// process; their environment and worktree are gone.
+func (c *CIRunner) abandonUnfinished() {
+	jobs, err := c.jobs.Unfinished()
+	if err != nil {
+		log.Printf("CI: failed to list unfinished jobs: %v", err)
+		return
+	}
+	for i := range jobs {
+		c.finish(&jobs[i], models.CIError, "Interrupted by a server restart")
+	}
+}
+
+func (c *CIRunner) worker() {
+	defer c.wg.Done()
+	for {
+		select {
+		case <-c.ctx.Done():
+			return
+		case task := <-c.queue:
+			c.run(task)
+		}
+	}
+}
+
+// run executes one job.
+func (c *CIRunner) run(task ciTask) {
+	job, err := c.jobs.GetJobByID(task.jobID)
+	if err != nil {
+		log.Printf("CI: job %s vanished: %v", task.jobID, err)
+		return
+	}
+
+	if err := c.jobs.StartJob(job); err != nil {
+		log.Printf("CI: failed to start %s#%d: %v", job.RepoName, job.Number, err)
+	}
+	c.setStatus(job, models.StatusPending, "Running")
+
+	if err := os.MkdirAll(filepath.Join(c.logDir, job.ID), 0755); err != nil {
+		c.finish(job, models.CIError, fmt.Sprintf("Failed to create log directory: %v", err))
+		return
+	}
+
+	tmp, err := os.MkdirTemp("", "simplegit-ci-")
+	if err != nil {
+		c.finish(job, models.CIError, fmt.Sprintf("Failed to create worktree: %v", err))
+		return
+	}
+	defer os.RemoveAll(tmp)
+
+	worktree := filepath.Join(tmp, "src")
+	home := filepath.Join(tmp, "home")
+	if err := os.Mkdir(home, 0755); err != nil {
+		c.finish(job, models.CIError, fmt.Sprintf("Failed to create worktree: %v", err))
+		return
+	}
+
+	if out, err := exec.Command("git", "-C", task.repoPath, "worktree", "add", "--detach", worktree, job.Commit).CombinedOutput(); err != nil {
+		c.finish(job, models.CIError, fmt.Sprintf("Failed to check out %s: %s", job.Commit, strings.TrimSpace(string(out))))
+		return
+	}
+	defer func() {
+		exec.Command("git", "-C", task.repoPath, "worktree", "remove", "--force", worktree).Run()
+		exec.Command("git", "-C", task.repoPath, "worktree", "prune").Run()
+	}()
+
+	env := []string{
+		"PATH=" + os.Getenv("PATH"),
+		"HOME=" + home,
+		"TMPDIR=" + tmp,
+		"CI=true",
+		"SIMPLEGIT_REPO=" + job.RepoName,
+		"SIMPLEGIT_REF=" + job.Ref,
+		"SIMPLEGIT_COMMIT=" + job.Commit,
+		"SIMPLEGIT_JOB=" + strconv.Itoa(job.Number),
+	}
+	for k, v := range task.env {
+		env = append(env, k+"="+v)
+	}
+
+	status := models.CISuccess
+	for i := range job.Steps {
+		step := &job.Steps[i]
+		if status != models.CISuccess {
+			c.jobs.FinishStep(step, models.CISkipped, 0)
+			continue
+		}
+
+		stepStatus, exitCode, err := c.runStep(step, job.ID, worktree, env)
+		if err != nil {
+			log.Printf("CI: %s#%d step %d: %v", job.RepoName, job.Number, i+1, err)
+		}
+		c.jobs.FinishStep(step, stepStatus, exitCode)
+		if stepStatus != models.CISuccess {
+			status = stepStatus
+		}
+	}
+
+	if c.ctx.Err() != nil {
+		c.finish(job, models.CIError, "Canceled by server shutdown")
+		return
+	}
+
+	msg := ""
+	if status == models.CIFailure {
+		for _, step := range job.Steps {
+			if step.Status == models.CIFailure {
+				msg = fmt.Sprintf("Step %q failed", step.Name)
+				break
+			}
+		}
+	}
+	c.finish(job, status, msg)
+}
+
+// runStep runs one step and returns its status and exit code.
+func (c *CIRunner) runStep(step *models.CIStep, jobID, dir string, env []string) (string, int, error) {
+	logFile, err := os.Create(c.LogPath(jobID, step.Index))
+	if err != nil {
+		return models.CIError, -1, err
+	}
+	defer logFile.Close()
+
+	c.jobs.StartStep(step)
+	fmt.Fprintf(logFile, "$ %s\n", strings.TrimSpace(step.Command))
+
+	ctx, cancel := context.WithTimeout(c.ctx, step.Timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", step.Command)
+	cmd.Dir = dir
+	cmd.Env = env
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	cmd.WaitDelay = ciWaitDelay
+	setProcessGroup(cmd)
+
+	err = cmd.Run()
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		fmt.Fprintf(logFile, "\nStep timed out after %s\n", step.Timeout)
+		return models.CIFailure, -1, nil
+	case c.ctx.Err() != nil:
+		fmt.Fprintf(logFile, "\nStep canceled\n")
+		return models.CIError, -1, nil
+	case err == nil:
+		return models.CISuccess, 0, nil
+	}
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		fmt.Fprintf(logFile, "\nStep failed with exit code %d\n", exitErr.ExitCode())
+		return models.CIFailure, exitErr.ExitCode(), nil
+	}
+	fmt.Fprintf(logFile, "\nFailed to run step: %v\n", err)
+	return models.CIError, -1, err
+}
+
+// finish records the final state of a job and its commit status.
+func (c *CIRunner) finish(job *models.CIJob, status, msg string) {
+	if err := c.jobs.FinishJob(job, status, msg); err != nil {
+		log.Printf("CI: failed to finish %s#%d: %v", job.RepoName, job.Number, err)
+	}
+
+	state, description := models.StatusSuccess, "Passed"
+	switch status {
+	case models.CIFailure:
+		state, description = models.StatusFailure, "Failed"
+	case models.CIError:
+		state, description = models.StatusError, "Errored"
+	}
+	if msg != "" {
+		description += ": " + msg
+	}
+	if job.Duration() > 0 {
+		description += fmt.Sprintf(" in %s", job.Duration())
+	}
+	c.setStatus(job, state, description)
+
+	log.Printf("CI: %s#%d finished: %s", job.RepoName, job.Number, status)
+}
+
+// setStatus reports the job's state as a commit status linking to the
+// job page.
+func (c *CIRunner) setStatus(job *models.CIJob, state, description string) {
+	err := c.statuses.Set(&models.CommitStatus{
+		RepoName:    job.RepoName,
+		Commit:      job.Commit,
+		Context:     StatusContext(job.Pipeline),
+		State:       state,
+		TargetURL:   fmt.Sprintf("/ci/%s/%d", job.RepoName, job.Number),
+		Description: description,
+	})
+	if err != nil {
+		log.Printf("CI: failed to set status of %s@%s: %v", job.RepoName, job.Commit, err)
+	}
+}
@@ -0,0 +1,11 @@
+//services/ci_other.go
+
+//go:build !unix
+
+package services
+
+import "os/exec"
+
+// setProcessGroup is a no-op where process groups are not available; a
+// timeout only kills the shell.
+func setProcessGroup(cmd *exec.Cmd) {}
@@ -0,0 +1,743 @@
+// services/code_search.go
+package services
+
+import (
+	"SimpleGit/models"
+	"fmt"
+	"io"
+	"log"
+	"path"
+	"regexp"
+	"regexp/syntax"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// Limits that keep the code search index and its results bounded.
+const (
+	codeSearchMaxFileBytes  = 1 << 20
+	codeSearchMaxFiles      = 20000
+	codeSearchMaxIndexBytes = 128 << 20
+	codeSearchMaxMatches    = 10
+	codeSearchDefaultLimit  = 50
+	codeSearchMaxLimit      = 200
+	codeSearchDefaultLines  = 2
+	codeSearchMaxLineLength = 500
+
+	// codeSearchPostingBytes estimates the memory a trigram posting of
+	// one file takes in the index maps.
+	codeSearchPostingBytes = 48
+)
+
+// CodeSearchQuery describes a search over the indexed default branches.
+//
+// Parameters:
+//   - Pattern: The text or regular expression to search for.
+//   - Regex: Whether Pattern is a regular expression.
+//   - CaseSensitive: Whether matching is case sensitive.
+//   - Path: Optional; a glob (*.go, cmd/*) or a substring of the file path.
+//   - Repos: Optional; restricts the search to these repositories.
+//   - Context: The number of lines shown around each match.
+//   - Limit: The maximum number of files returned.
+type CodeSearchQuery struct {
+	Pattern       string
+	Regex         bool
+	CaseSensitive bool
+	Path          string
+	Repos         []string
+	Context       int
+	Limit         int
+}
+
+// CodeSearchLine is a line of a result. Ranges holds the byte offsets of
+// the matches within Text and is empty for context lines.
+type CodeSearchLine struct {
+	Number int      `json:"number"`
+	Text   string   `json:"text"`
+	Match  bool     `json:"match"`
+	Ranges [][2]int `json:"ranges,omitempty"`
+}
+
+// CodeSearchHunk is a run of consecutive lines around one or more matches.
+type CodeSearchHunk struct {
+	Lines []CodeSearchLine `json:"lines"`
+}
+
+// CodeSearchResult holds the matches within one file.
+type CodeSearchResult struct {
+	Repo    string           `json:"repo"`
+	Branch  string           `json:"branch"`
+	Commit  string           `json:"commit"`
+	Path    string           `json:"path"`
+	Matches int              `json:"matches"`
+	Hunks   []CodeSearchHunk `json:"hunks"`
+}
+
+// CodeSearchResults is the answer to a CodeSearchQuery.
+type CodeSearchResults struct {
+	Results   []CodeSearchResult `json:"results"`
+	Files     int                `json:"files"`
+	Truncated bool               `json:"truncated"`
+	Indexing  []string           `json:"indexing,omitempty"`
+	Duration  time.Duration      `json:"duration_ns"`
+}
+
+// codeFile is an indexed blob of the default branch. Size is its
+// estimated share of the index memory.
+type codeFile struct {
+	hash    plumbing.Hash
+	content string
+	size    int64
+}
+
+// codeIndex is the trigram index of one repository's default branch.
+// Trigrams are taken from lowercased content so the same index serves
+// case-sensitive and case-insensitive queries. Bytes estimates the memory
+// the index takes; files stop being added once it reaches
+// codeSearchMaxIndexBytes.
+type codeIndex struct {
+	mu     sync.RWMutex
+	branch string
+	commit plumbing.Hash
+	files  map[string]*codeFile
+	grams  map[uint32]map[string]struct{}
+	bytes  int64
+}
+
+// CodeSearchStats is a snapshot of the size of the code search indexes.
+type CodeSearchStats struct {
+	Repositories int   `json:"repositories"`
+	Files        int   `json:"files"`
+	Bytes        int64 `json:"bytes"`
+	MaxRepoBytes int64 `json:"max_repo_bytes"`
+	FullIndexes  int   `json:"full_indexes"`
+	PendingRepos int   `json:"pending_repositories"`
+}
+
+// CodeSearchService maintains the code search indexes and answers queries.
+// Indexes live in memory and are rebuilt in the background on startup;
+// pushes update them incrementally by diffing the old and new trees.
+type CodeSearchService struct {
+	mu       sync.RWMutex
+	indexes  map[string]*codeIndex
+	pending  map[string]bool
+	jobs     chan indexJob
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+// indexJob asks a background indexer to bring a repository up to date.
+type indexJob struct {
+	repoName string
+	repoPath string
+}
+
+// NewCodeSearchService creates the service and starts its indexing worker.
+func NewCodeSearchService() *CodeSearchService {
+	c := &CodeSearchService{
+		indexes: make(map[string]*codeIndex),
+		pending: make(map[string]bool),
+		jobs:    make(chan indexJob, 256),
+		done:    make(chan struct{}),
+	}
+	go c.worker()
+	return c
+}
+
+// Update schedules (re)indexing of a repository's default branch.
+func (c *CodeSearchService) Update(repoName, repoPath string) {
+	c.mu.Lock()
+	if c.pending[repoName] {
+		c.mu.Unlock()
+		return
+	}
+	c.pending[repoName] = true
+	c.mu.Unlock()
+
+	select {
+	case c.jobs <- indexJob{repoName: repoName, repoPath: repoPath}:
+	default:
+		c.mu.Lock()
+		delete(c.pending, repoName)
+		c.mu.Unlock()
+		log.Printf("Code search: queue full, skipping %s", repoName)
+	}
+}
+
+// Forget drops the index of a repository.
+func (c *CodeSearchService) Forget(repoName string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.indexes, repoName)
+}
+
+// Stop stops the indexing worker.
+func (c *CodeSearchService) Stop() {
+	c.stopOnce.Do(func() { close(c.done) })
+}
+
+func (c *CodeSearchService) worker() {
+	for {
+		select {
+		case <-c.done:
+			return
+		case job := <-c.jobs:
+			c.mu.Lock()
+			delete(c.pending, job.repoName)
+			idx := c.indexes[job.repoName]
+			c.mu.Unlock()
+
+			start := time.Now()
+			idx, changed, err := updateCodeIndex(idx, job.repoPath)
+			if err != nil {
+				log.Printf("Code search: failed to index %s: %v", job.repoName, err)
+				continue
+			}
+
+			c.mu.Lock()
+			if idx == nil {
+				delete(c.indexes, job.repoName)
+			} else {
+				c.indexes[job.repoName] = idx
+			}
+			c.mu.Unlock()
+
+			if idx != nil && changed > 0 {
+				log.Printf("Code search: indexed %s@%s (%d files changed) in %v",
+					job.repoName, idx.branch, changed, time.Since(start))
+			}
+		}
+	}
+}
+
+// updateCodeIndex brings idx up to date with the default branch of the
+// repository. When the branch moved, only the files that differ between
+// the indexed and the new tree are re-read. It returns the index to keep
+// (nil for repositories without commits) and the number of changed files.
+func updateCodeIndex(idx *codeIndex, repoPath string) (*codeIndex, int, error) {
+	repo, err := models.OpenGit(repoPath)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	branch, commitHash, err := defaultBranchTip(repo)
+	if err != nil || commitHash.IsZero() {
+		return nil, 0, err
+	}
+
+	commit, err := repo.CommitObject(commitHash)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get commit: %w", err)
+	}
+	tree, err := commit.Tree()
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get tree: %w", err)
+	}
+
+	if idx != nil {
+		idx.mu.RLock()
+		same := idx.commit == commitHash
+		oldBranch, oldCommit := idx.branch, idx.commit
+		idx.mu.RUnlock()
+		if same {
+			return idx, 0, nil
+		}
+
+		if oldBranch == branch {
+			if oldTree, err := commitTree(repo, oldCommit); err == nil {
+				changed, err := idx.applyDiff(oldTree, tree, commitHash)
+				if err == nil {
+					return idx, changed, nil
+				}
+				log.Printf("Code search: incremental update failed, rebuilding: %v", err)
+			}
+		}
+	}
+
+	idx = &codeIndex{
+		branch: branch,
+		commit: commitHash,
+		files:  make(map[string]*codeFile),
+		grams:  make(map[uint32]map[string]struct{}),
+	}
+
+	err = tree.Files().ForEach(func(f *object.File) error {
+		if idx.full() {
+			return io.EOF
+		}
+		if content, ok := readIndexable(f); ok {
+			idx.add(f.Name, f.Hash, content)
+		}
+		return nil
+	})
+	if err != nil && err != io.EOF {
+		return nil, 0, err
+	}
+
+	return idx, len(idx.files), nil
+}
+
+func commitTree(repo *git.Repository, hash plumbing.Hash) (*object.Tree, error) {
+	commit, err := repo.CommitObject(hash)
+	if err != nil {
+		return nil, err
+	}
+	return commit.Tree()
+}
+
+// defaultBranchTip resolves the branch HEAD points to, falling back to the
+// first branch when HEAD is dangling.
+func defaultBranchTip(repo *git.Repository) (string, plumbing.Hash, error) {
+	head, err := repo.Storer.Reference(plumbing.HEAD)
+	if err == nil && head.Type() == plumbing.SymbolicReference {
+		if ref, err := repo.Reference(head.Target(), true); err == nil {
+			return head.Target().Short(), ref.Hash(), nil
+		}
+	}
+
+	branches, err := repo.Branches()
+	if err != nil {
+		return "", plumbing.ZeroHash, err
+	}
+	defer branches.Close()
+
+	ref, err := branches.Next()
+	if err == io.EOF {
+		return "", plumbing.ZeroHash, nil
+	}
+	if err != nil {
+		return "", plumbing.ZeroHash, err
+	}
+	return ref.Name().Short(), ref.Hash(), nil
+}
+
+// readIndexable returns the contents of f if it is a regular text file
+// small enough to index.
+func readIndexable(f *object.File) (string, bool) {
+	if f.Size > codeSearchMaxFileBytes || (f.Mode != filemode.Regular && f.Mode != filemode.Executable) {
+		return "", false
+	}
+	if binary, err := f.IsBinary(); err != nil || binary {
+		return "", false
+	}
+	content, err := f.Contents()
+	if err != nil || !utf8.ValidString(content) {
+		return "", false
+	}
+	return content, true
+}
+
+// applyDiff updates the index with the changes between two trees.
+func (idx *codeIndex) applyDiff(from, to *object.Tree, commit plumbing.Hash) (int, error) {
+	changes, err := object.DiffTree(from, to)
+	if err != nil {
+		return 0, err
+	}
+
+	// Read the new contents before taking the lock so searches keep running
+	type update struct {
+		path    string
+		hash    plumbing.Hash
+		content string
+		remove  string
+	}
+	updates := make([]update, 0, len(changes))
+	for _, change := range changes {
+		u := update{remove: change.From.Name}
+		if change.To.Name != "" {
+			if f, err := to.TreeEntryFile(&change.To.TreeEntry); err == nil {
+				if content, ok := readIndexable(f); ok {
+					u.path, u.hash, u.content = change.To.Name, f.Hash, content
+				}
+			}
+		}
+		updates = append(updates, u)
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	for _, u := range updates {
+		if u.remove != "" {
+			idx.remove(u.remove)
+		}
+		if u.path != "" && !idx.full() {
+			idx.add(u.path, u.hash, u.content)
+		}
+	}
+	idx.commit = commit
+
+	return len(updates), nil
+}
+
+// full reports whether the index holds as many files or bytes as it may.
+func (idx *codeIndex) full() bool {
+	return len(idx.files) >= codeSearchMaxFiles || idx.bytes >= codeSearchMaxIndexBytes
+}
+
+func (idx *codeIndex) add(p string, hash plumbing.Hash, content string) {
+	idx.remove(p)
+	grams := trigrams(strings.ToLower(content))
+	file := &codeFile{hash: hash, content: content}
+	file.size = int64(len(p)+len(content)) + int64(len(grams))*codeSearchPostingBytes
+	idx.files[p] = file
+	idx.bytes += file.size
+	for gram := range grams {
+		paths, ok := idx.grams[gram]
+		if !ok {
+			paths = make(map[string]struct{})
+			idx.grams[gram] = paths
+		}
+		paths[p] = struct{}{}
+	}
+}
+
+func (idx *codeIndex) remove(p string) {
+	file, ok := idx.files[p]
+	if !ok {
+		return
+	}
+	for gram := range trigrams(strings.ToLower(file.content)) {
+		if paths, ok := idx.grams[gram]; ok {
+			delete(paths, p)
+			if len(paths) == 0 {
+				delete(idx.grams, gram)
+			}
+		}
+	}
+	delete(idx.files, p)
+	idx.bytes -= file.size
+}
+
+func trigrams(s string) map[uint32]struct{} {
+	grams := make(map[uint32]struct{})
+	for i := 0; i+3 <= len(s); i++ {
+		grams[uint32(s[i])<<16|uint32(s[i+1])<<8|uint32(s[i+2])] = struct{}{}
+	}
+	return grams
+}
+
+// candidates returns the files that contain every trigram of the given
+// literals, or nil and false when the literals give no filter at all.
+func (idx *codeIndex) candidates(literals []string) (map[string]struct{}, bool) {
+	var result map[string]struct{}
+	filtered := false
+	for _, lit := range literals {
+		for gram := range trigrams(strings.ToLower(lit)) {
+			paths := idx.grams[gram]
+			filtered = true
+			if result == nil {
+				result = make(map[string]struct{}, len(paths))
+				for p := range paths {
+					result[p] = struct{}{}
+				}
+				continue
+			}
+			for p := range result {
+				if _, ok := paths[p]; !ok {
+					delete(result, p)
+				}
+			}
+			if len(result) == 0 {
+				return result, true
+			}
+		}
+	}
+	return result, filtered
+}
+
+// requiredLiterals returns strings that every match of re must contain.
+// Only concatenations are looked into; alternations give no guarantee.
+func requiredLiterals(re *syntax.Regexp) []string {
+	switch re.Op {
+	case syntax.OpLiteral:
+		return []string{string(re.Rune)}
+	case syntax.OpCapture, syntax.OpPlus:
+		return requiredLiterals(re.Sub[0])
+	case syntax.OpRepeat:
+		if re.Min >= 1 {
+			return requiredLiterals(re.Sub[0])
+		}
+	case syntax.OpConcat:
+		var literals []string
+		for _, sub := range re.Sub {
+			literals = append(literals, requiredLiterals(sub)...)
+		}
+		return literals
+	}
+	return nil
+}
+
+// compileQuery turns a query into a line-oriented regexp and the literals
+// used to narrow down the candidate files.
+func compileQuery(q CodeSearchQuery) (*regexp.Regexp, []string, error) {
+	pattern := q.Pattern
+	if !q.Regex {
+		pattern = regexp.QuoteMeta(pattern)
+	}
+
+	flags := syntax.Perl
+	if !q.CaseSensitive {
+		flags |= syntax.FoldCase
+	}
+	parsed, err := syntax.Parse(pattern, flags)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	prefix := "(?m)"
+	if !q.CaseSensitive {
+		prefix = "(?mi)"
+	}
+	re, err := regexp.Compile(prefix + pattern)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return re, requiredLiterals(parsed.Simplify()), nil
+}
+
+// matchPath reports whether a file path passes the path filter. Filters
+// with glob characters are matched against the whole path and the file
+// name; others match as a substring.
+func matchPath(filter, p string) bool {
+	if filter == "" {
+		return true
+	}
+	if strings.ContainsAny(filter, "*?[") {
+		if ok, _ := path.Match(filter, p); ok {
+			return true
+		}
+		ok, _ := path.Match(filter, path.Base(p))
+		return ok
+	}
+	return strings.Contains(p, filter)
+}
+
+// Search runs a query against the indexes of the selected repositories.
+// Repositories whose index is still being built are listed in Indexing.
+func (c *CodeSearchService) Search(q CodeSearchQuery) (*CodeSearchResults, error) {
+	start := time.Now()
+	if strings.TrimSpace(q.Pattern) == "" {
+		return nil, fmt.Errorf("empty search pattern")
+	}
+
+	re, literals, err := compileQuery(q)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern: %w", err)
+	}
+
+	if q.Limit <= 0 {
+		q.Limit = codeSearchDefaultLimit
+	}
+	if q.Limit > codeSearchMaxLimit {
+		q.Limit = codeSearchMaxLimit
+	}
+	if q.Context < 0 {
+		q.Context = codeSearchDefaultLines
+	}
+
+	c.mu.RLock()
+	names := q.Repos
+	if len(names) == 0 {
+		for name := range c.indexes {
+			names = append(names, name)
+		}
+	}
+	indexes := make(map[string]*codeIndex, len(names))
+	for _, name := range names {
+		if idx, ok := c.indexes[name]; ok {
+			indexes[name] = idx
+		}
+	}
+	pending := make(map[string]bool, len(c.pending))
+	for name := range c.pending {
+		pending[name] = true
+	}
+	c.mu.RUnlock()
+
+	results := &CodeSearchResults{Results: []CodeSearchResult{}}
+	sort.Strings(names)
+	for _, name := range names {
+		idx, ok := indexes[name]
+		if !ok {
+			if pending[name] {
+				results.Indexing = append(results.Indexing, name)
+			}
+			continue
+		}
+		if idx.search(name, re, literals, q, results) {
+			results.Truncated = true
+			break
+		}
+	}
+
+	results.Duration = time.Since(start)
+	return results, nil
+}
+
+// search appends the matches in one repository to results and reports
+// whether the result limit was reached.
+func (idx *codeIndex) search(repoName string, re *regexp.Regexp, literals []string, q CodeSearchQuery, results *CodeSearchResults) bool {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	var paths []string
+	if cands, filtered := idx.candidates(literals); filtered {
+		for p := range cands {
+			paths = append(paths, p)
+		}
+	} else {
+		for p := range idx.files {
+			paths = append(paths, p)
+		}
+	}
+	sort.Strings(paths)
+
+	for _, p := range paths {
+		if !matchPath(q.Path, p) {
+			continue
+		}
+		content := idx.files[p].content
+		if !re.MatchString(content) {
+			continue
+		}
+
+		results.Files++
+		if len(results.Results) >= q.Limit {
+			return true
+		}
+
+		result := CodeSearchResult{
+			Repo:   repoName,
+			Branch: idx.branch,
+			Commit: idx.commit.String(),
+			Path:   p,
+		}
+		result.Hunks, result.Matches = matchHunks(content, re, q.Context)
+		results.Results = append(results.Results, result)
+	}
+
+	return false
+}
+
+// matchHunks finds the matching lines of content and groups them with
+// their context lines. Only the first codeSearchMaxMatches lines are
+// returned, but every match is counted.
+func matchHunks(content string, re *regexp.Regexp, context int) ([]CodeSearchHunk, int) {
+	lines := strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+
+	type hit struct {
+		line   int
+		ranges [][2]int
+	}
+	var hits []hit
+	count := 0
+	for i, line := range lines {
+		locs := re.FindAllStringIndex(line, -1)
+		if len(locs) == 0 {
+			continue
+		}
+		count++
+		if len(hits) >= codeSearchMaxMatches {
+			continue
+		}
+		h := hit{line: i}
+		for _, loc := range locs {
+			if loc[0] >= codeSearchMaxLineLength {
+				break
+			}
+			h.ranges = append(h.ranges, [2]int{loc[0], min(loc[1], codeSearchMaxLineLength)})
+		}
+		hits = append(hits, h)
+	}
+
+	var hunks []CodeSearchHunk
+	last := -1
+	for _, h := range hits {
+		from := max(h.line-context, last+1, 0)
+		to := min(h.line+context, len(lines)-1)
+
+		if len(hunks) == 0 || from > last+1 {
+			hunks = append(hunks, CodeSearchHunk{})
+		}
+		hunk := &hunks[len(hunks)-1]
+
+		// A later hit may already be covered as context of the previous one
+		if from > h.line {
+			for i := range hunk.Lines {
+				if hunk.Lines[i].Number == h.line+1 {
+					hunk.Lines[i].Match = true
+					hunk.Lines[i].Ranges = h.ranges
+				}
+			}
+		}
+
+		for n := from; n <= to; n++ {
+			line := CodeSearchLine{Number: n + 1, Text: truncateLine(lines[n])}
+			if n == h.line {
+				line.Match = true
+				line.Ranges = h.ranges
+			}
+			hunk.Lines = append(hunk.Lines, line)
+		}
+		last = max(last, to)
+	}
+
+	return hunks, count
+}
+
+func truncateLine(line string) string {
+	line = strings.TrimRight(line, "\r")
+	if len(line) <= codeSearchMaxLineLength {
+		return line
+	}
+	cut := codeSearchMaxLineLength
+	for cut > 0 && !utf8.RuneStart(line[cut]) {
+		cut--
+	}
+	return line[:cut]
+}
+
+// Status reports the indexed branch and commit of each repository.
+func (c *CodeSearchService) Status() map[string]string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	status := make(map[string]string, len(c.indexes))
+	for name, idx := range c.indexes {
+		idx.mu.RLock()
+		status[name] = idx.branch + "@" + idx.commit.String()[:7]
+		idx.mu.RUnlock()
+	}
+	return status
+}
+
+// Stats returns the size of the indexes.
+func (c *CodeSearchService) Stats() CodeSearchStats {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	stats := CodeSearchStats{
+		Repositories: len(c.indexes),
+		MaxRepoBytes: codeSearchMaxIndexBytes,
+		PendingRepos: len(c.pending),
+	}
+	for _, idx := range c.indexes {
+		idx.mu.RLock()
+		stats.Files += len(idx.files)
+		stats.Bytes += idx.bytes
+		if idx.full() {
+			stats.FullIndexes++
+		}
+		idx.mu.RUnlock()
+	}
+	return stats
+}
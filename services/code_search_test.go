@@ -0,0 +1,102 @@
+//services/code_search_test.go
+
+package services
+
+import "testing"
+
+// searchPaths returns the paths of the files matching q.
+func searchPaths(t *testing.T, c *CodeSearchService, q CodeSearchQuery) []string {
+	t.Helper()
+	results, err := c.Search(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	paths := []string{}
+	for _, result := range results.Results {
+		paths = append(paths, result.Path)
+	}
+	return paths
+}
+
+func TestCodeSearchIndex(t *testing.T) {
+	work, path := newWorkRepo(t)
+	commitWork(t, work, map[string]string{
+		"main.go":        "package main\n\nfunc main() {\n\tServeHTTP()\n}\n",
+		"server/http.go": "package server\n\nfunc ServeHTTP() {}\n",
+		"README.md":      "# Demo\n\nServes http.\n",
+	})
+
+	idx, changed, err := updateCodeIndex(nil, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed != 3 || idx.branch != "main" {
+		t.Fatalf("indexed %d files of %q, want 3 of main", changed, idx.branch)
+	}
+	c := &CodeSearchService{indexes: map[string]*codeIndex{"alice/demo": idx}}
+
+	tests := []struct {
+		name  string
+		query CodeSearchQuery
+		want  []string
+	}{
+		{"case-insensitive", CodeSearchQuery{Pattern: "servehttp"}, []string{"main.go", "server/http.go"}},
+		{"case-sensitive", CodeSearchQuery{Pattern: "http", CaseSensitive: true}, []string{"README.md"}},
+		{"regex", CodeSearchQuery{Pattern: `func \w+\(\) \{\}`, Regex: true}, []string{"server/http.go"}},
+		{"alternation", CodeSearchQuery{Pattern: "Demo|package server", Regex: true}, []string{"README.md", "server/http.go"}},
+		{"path glob", CodeSearchQuery{Pattern: "package", Path: "*.go"}, []string{"main.go", "server/http.go"}},
+		{"path substring", CodeSearchQuery{Pattern: "package", Path: "server/"}, []string{"server/http.go"}},
+		{"no match", CodeSearchQuery{Pattern: "nothing here"}, []string{}},
+		{"other repository", CodeSearchQuery{Pattern: "package", Repos: []string{"bob/demo"}}, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := searchPaths(t, c, tt.query); !equalStrings(got, tt.want) {
+				t.Errorf("Search(%+v) = %v, want %v", tt.query, got, tt.want)
+			}
+		})
+	}
+
+	// A push updates the index in place
+	commitWork(t, work, map[string]string{
+		"server/http.go": "package server\n\nfunc Listen() {}\n",
+		"README.md":      "",
+	})
+	updated, changed, err := updateCodeIndex(idx, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated != idx || changed != 2 {
+		t.Fatalf("update changed %d files (same index: %v), want 2 in place", changed, updated == idx)
+	}
+	if got := searchPaths(t, c, CodeSearchQuery{Pattern: "ServeHTTP"}); !equalStrings(got, []string{"main.go"}) {
+		t.Errorf("after the push ServeHTTP is found in %v, want [main.go]", got)
+	}
+	if got := searchPaths(t, c, CodeSearchQuery{Pattern: "Listen"}); !equalStrings(got, []string{"server/http.go"}) {
+		t.Errorf("after the push Listen is found in %v, want [server/http.go]", got)
+	}
+
+	// The size of an updated index matches that of a rebuilt one
+	rebuilt, _, err := updateCodeIndex(nil, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if idx.bytes != rebuilt.bytes || len(idx.grams) != len(rebuilt.grams) {
+		t.Errorf("updated index has %d bytes and %d trigrams, rebuilt %d and %d", idx.bytes, len(idx.grams), rebuilt.bytes, len(rebuilt.grams))
+	}
+	if stats := c.Stats(); stats.Repositories != 1 || stats.Files != 2 || stats.Bytes != idx.bytes {
+		t.Errorf("Stats() = %+v, want 1 repository, 2 files and %d bytes", stats, idx.bytes)
+	}
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
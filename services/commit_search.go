@@ -0,0 +1,555 @@
+// services/commit_search.go
+package services
+
+import (
+	"SimpleGit/models"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// Limits that keep the commit index and its results bounded.
+const (
+	commitSearchMaxCommits    = 200000
+	commitSearchMaxPaths      = 1000
+	commitSearchDefaultLimit  = 50
+	commitSearchMaxLimit      = 200
+	commitSearchShownPaths    = 5
+	commitSearchMinHashPrefix = 4
+)
+
+// CommitSearchQuery describes a search over commit metadata.
+//
+// Parameters:
+//   - Terms: Words that must all appear in the message, author or email.
+//     Terms of at least four hex digits also match SHA prefixes.
+//   - Author: Optional; a substring of the author name or email.
+//   - Path: Optional; a glob or substring of a path the commit touched.
+//   - Repos: Optional; restricts the search to these repositories.
+//   - Offset, Limit: The page of results to return.
+type CommitSearchQuery struct {
+	Terms  []string
+	Author string
+	Path   string
+	Repos  []string
+	Offset int
+	Limit  int
+}
+
+// CommitSearchResult is a commit matching a CommitSearchQuery. Paths holds
+// the touched paths that matched the path filter, or the first few.
+type CommitSearchResult struct {
+	Repo      string    `json:"repo"`
+	Hash      string    `json:"hash"`
+	ShortHash string    `json:"short_hash"`
+	Summary   string    `json:"summary"`
+	Message   string    `json:"message"`
+	Author    string    `json:"author"`
+	Email     string    `json:"email"`
+	Date      time.Time `json:"date"`
+	Paths     []string  `json:"paths"`
+	MorePaths int       `json:"more_paths"`
+}
+
+// CommitSearchResults is a page of results sorted by date, newest first.
+type CommitSearchResults struct {
+	Results  []CommitSearchResult `json:"results"`
+	Total    int                  `json:"total"`
+	Offset   int                  `json:"offset"`
+	Limit    int                  `json:"limit"`
+	Indexing []string             `json:"indexing,omitempty"`
+}
+
+type commitEntry struct {
+	hash    string
+	message string
+	author  string
+	email   string
+	when    time.Time
+	paths   []string
+}
+
+// commitIndex is the commit metadata of every branch of one repository,
+// with an inverted index from lowercased words to entries. tips holds the
+// branch tips it was built from.
+type commitIndex struct {
+	mu      sync.RWMutex
+	entries []*commitEntry
+	seen    map[plumbing.Hash]bool
+	tokens  map[string][]int
+	sorted  []string
+	tips    map[plumbing.ReferenceName]plumbing.Hash
+}
+
+// CommitSearchService maintains per-repository commit indexes. Indexes
+// are built in the background on startup and extended on push by walking
+// only the commits that are not indexed yet. Pushes that delete or rewrite
+// a branch rebuild the index.
+type CommitSearchService struct {
+	mu       sync.RWMutex
+	indexes  map[string]*commitIndex
+	pending  map[string]bool
+	jobs     chan indexJob
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+// NewCommitSearchService creates the service and starts its indexing worker.
+func NewCommitSearchService() *CommitSearchService {
+	c := &CommitSearchService{
+		indexes: make(map[string]*commitIndex),
+		pending: make(map[string]bool),
+		jobs:    make(chan indexJob, 256),
+		done:    make(chan struct{}),
+	}
+	go c.worker()
+	return c
+}
+
+// Update schedules indexing of the new commits of a repository.
+func (c *CommitSearchService) Update(repoName, repoPath string) {
+	c.mu.Lock()
+	if c.pending[repoName] {
+		c.mu.Unlock()
+		return
+	}
+	c.pending[repoName] = true
+	c.mu.Unlock()
+
+	select {
+	case c.jobs <- indexJob{repoName: repoName, repoPath: repoPath}:
+	default:
+		c.mu.Lock()
+		delete(c.pending, repoName)
+		c.mu.Unlock()
+		log.Printf("Commit search: queue full, skipping %s", repoName)
+	}
+}
+
+// Forget drops the index of a repository.
+func (c *CommitSearchService) Forget(repoName string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.indexes, repoName)
+}
+
+// Stop stops the indexing worker.
+func (c *CommitSearchService) Stop() {
+	c.stopOnce.Do(func() { close(c.done) })
+}
+
+func (c *CommitSearchService) worker() {
+	for {
+		select {
+		case <-c.done:
+			return
+		case job := <-c.jobs:
+			c.mu.Lock()
+			delete(c.pending, job.repoName)
+			idx := c.indexes[job.repoName]
+			c.mu.Unlock()
+
+			start := time.Now()
+			idx, added, err := updateCommitIndex(idx, job.repoPath)
+			if err != nil {
+				log.Printf("Commit search: failed to index %s: %v", job.repoName, err)
+				continue
+			}
+
+			c.mu.Lock()
+			c.indexes[job.repoName] = idx
+			c.mu.Unlock()
+
+			if added > 0 {
+				log.Printf("Commit search: indexed %d commits of %s in %v", added, job.repoName, time.Since(start))
+			}
+		}
+	}
+}
+
+// updateCommitIndex brings idx up to date with the branches of the
+// repository. When branches only moved forward, it walks back from every
+// tip until it reaches commits that are already indexed and adds the new
+// ones. When a branch was deleted or rewritten, commits may have become
+// unreachable, so a new index is built instead. It returns the index to
+// keep and the number of commits added.
+func updateCommitIndex(idx *commitIndex, repoPath string) (*commitIndex, int, error) {
+	repo, err := models.OpenGit(repoPath)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	branches, err := repo.Branches()
+	if err != nil {
+		return nil, 0, err
+	}
+	tips := make(map[plumbing.ReferenceName]plumbing.Hash)
+	err = branches.ForEach(func(ref *plumbing.Reference) error {
+		tips[ref.Name()] = ref.Hash()
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if idx != nil {
+		idx.mu.RLock()
+		oldTips := idx.tips
+		idx.mu.RUnlock()
+		if !fastForwarded(repo, oldTips, tips) {
+			idx = nil
+		}
+	}
+	if idx == nil {
+		idx = &commitIndex{seen: make(map[plumbing.Hash]bool), tokens: make(map[string][]int)}
+	}
+
+	added, err := idx.add(repo, tips)
+	if err != nil {
+		return nil, 0, err
+	}
+	return idx, added, nil
+}
+
+// fastForwarded reports whether every branch of oldTips still exists in
+// tips at a descendant of its old tip, so that no indexed commit can have
+// become unreachable.
+func fastForwarded(repo *git.Repository, oldTips, tips map[plumbing.ReferenceName]plumbing.Hash) bool {
+	for name, old := range oldTips {
+		tip, ok := tips[name]
+		if !ok {
+			return false
+		}
+		if tip == old {
+			continue
+		}
+		oldCommit, err := repo.CommitObject(old)
+		if err != nil {
+			return false
+		}
+		tipCommit, err := repo.CommitObject(tip)
+		if err != nil {
+			return false
+		}
+		if ancestor, err := oldCommit.IsAncestor(tipCommit); err != nil || !ancestor {
+			return false
+		}
+	}
+	return true
+}
+
+// add walks back from tips until it reaches commits that are already
+// indexed and adds the new ones.
+func (idx *commitIndex) add(repo *git.Repository, tips map[plumbing.ReferenceName]plumbing.Hash) (int, error) {
+	var queue []plumbing.Hash
+	visited := make(map[plumbing.Hash]bool)
+	for _, tip := range tips {
+		if !visited[tip] {
+			visited[tip] = true
+			queue = append(queue, tip)
+		}
+	}
+
+	idx.mu.RLock()
+	total := len(idx.entries)
+	idx.mu.RUnlock()
+
+	var entries []*commitEntry
+	var hashes []plumbing.Hash
+	for len(queue) > 0 && total+len(entries) < commitSearchMaxCommits {
+		hash := queue[0]
+		queue = queue[1:]
+
+		idx.mu.RLock()
+		known := idx.seen[hash]
+		idx.mu.RUnlock()
+		if known {
+			continue
+		}
+
+		commit, err := repo.CommitObject(hash)
+		if err != nil {
+			return 0, fmt.Errorf("failed to get commit %s: %w", hash, err)
+		}
+
+		entries = append(entries, newCommitEntry(commit))
+		hashes = append(hashes, hash)
+
+		for _, parent := range commit.ParentHashes {
+			if !visited[parent] {
+				visited[parent] = true
+				queue = append(queue, parent)
+			}
+		}
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.tips = tips
+	if len(entries) == 0 {
+		return 0, nil
+	}
+
+	for i, entry := range entries {
+		id := len(idx.entries)
+		idx.entries = append(idx.entries, entry)
+		idx.seen[hashes[i]] = true
+
+		words := make(map[string]bool)
+		for _, text := range []string{entry.message, entry.author, entry.email} {
+			for _, word := range commitWords(text) {
+				words[word] = true
+			}
+		}
+		for word := range words {
+			idx.tokens[word] = append(idx.tokens[word], id)
+		}
+	}
+
+	idx.sorted = idx.sorted[:0]
+	for word := range idx.tokens {
+		idx.sorted = append(idx.sorted, word)
+	}
+	sort.Strings(idx.sorted)
+
+	return len(entries), nil
+}
+
+func newCommitEntry(commit *object.Commit) *commitEntry {
+	entry := &commitEntry{
+		hash:    commit.Hash.String(),
+		message: strings.TrimSpace(commit.Message),
+		author:  commit.Author.Name,
+		email:   commit.Author.Email,
+		when:    commit.Author.When,
+	}
+
+	tree, err := commit.Tree()
+	if err != nil {
+		return entry
+	}
+
+	// Touched paths are taken against the first parent
+	var parentTree *object.Tree
+	if commit.NumParents() > 0 {
+		if parent, err := commit.Parent(0); err == nil {
+			parentTree, _ = parent.Tree()
+		}
+	}
+
+	if parentTree == nil {
+		tree.Files().ForEach(func(f *object.File) error {
+			if len(entry.paths) >= commitSearchMaxPaths {
+				return fmt.Errorf("too many paths")
+			}
+			entry.paths = append(entry.paths, f.Name)
+			return nil
+		})
+		return entry
+	}
+
+	changes, err := object.DiffTree(parentTree, tree)
+	if err != nil {
+		return entry
+	}
+	for _, change := range changes {
+		if len(entry.paths) >= commitSearchMaxPaths {
+			break
+		}
+		name := change.To.Name
+		if name == "" {
+			name = change.From.Name
+		}
+		entry.paths = append(entry.paths, name)
+	}
+	return entry
+}
+
+// commitWords splits text into lowercased words of letters and digits.
+func commitWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+func isHexPrefix(s string) bool {
+	if len(s) < commitSearchMinHashPrefix || len(s) > 40 {
+		return false
+	}
+	for _, r := range s {
+		if !strings.ContainsRune("0123456789abcdef", r) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchTerm returns the entries containing a word starting with term, and
+// for hex terms the entries whose hash starts with it.
+func (idx *commitIndex) matchTerm(term string) map[int]bool {
+	ids := make(map[int]bool)
+	for n, word := range commitWords(term) {
+		// Multi-word terms such as "foo-bar" need every part
+		part := make(map[int]bool)
+		i := sort.SearchStrings(idx.sorted, word)
+		for ; i < len(idx.sorted) && strings.HasPrefix(idx.sorted[i], word); i++ {
+			for _, id := range idx.tokens[idx.sorted[i]] {
+				part[id] = true
+			}
+		}
+		if n == 0 {
+			ids = part
+			continue
+		}
+		for id := range ids {
+			if !part[id] {
+				delete(ids, id)
+			}
+		}
+	}
+
+	if isHexPrefix(term) {
+		for id, entry := range idx.entries {
+			if strings.HasPrefix(entry.hash, term) {
+				ids[id] = true
+			}
+		}
+	}
+	return ids
+}
+
+// Search runs a query against the selected repositories.
+func (c *CommitSearchService) Search(q CommitSearchQuery) (*CommitSearchResults, error) {
+	if len(q.Terms) == 0 && q.Author == "" && q.Path == "" {
+		return nil, fmt.Errorf("empty search query")
+	}
+	if q.Limit <= 0 {
+		q.Limit = commitSearchDefaultLimit
+	}
+	if q.Limit > commitSearchMaxLimit {
+		q.Limit = commitSearchMaxLimit
+	}
+	if q.Offset < 0 {
+		q.Offset = 0
+	}
+
+	c.mu.RLock()
+	names := q.Repos
+	if len(names) == 0 {
+		for name := range c.indexes {
+			names = append(names, name)
+		}
+	}
+	indexes := make(map[string]*commitIndex, len(names))
+	results := &CommitSearchResults{Results: []CommitSearchResult{}, Offset: q.Offset, Limit: q.Limit}
+	for _, name := range names {
+		if idx, ok := c.indexes[name]; ok {
+			indexes[name] = idx
+		} else if c.pending[name] {
+			results.Indexing = append(results.Indexing, name)
+		}
+	}
+	c.mu.RUnlock()
+
+	var matches []CommitSearchResult
+	for name, idx := range indexes {
+		matches = append(matches, idx.search(name, q)...)
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		if !matches[i].Date.Equal(matches[j].Date) {
+			return matches[i].Date.After(matches[j].Date)
+		}
+		return matches[i].Hash < matches[j].Hash
+	})
+
+	results.Total = len(matches)
+	if q.Offset < len(matches) {
+		end := min(q.Offset+q.Limit, len(matches))
+		results.Results = matches[q.Offset:end]
+	}
+	sort.Strings(results.Indexing)
+
+	return results, nil
+}
+
+func (idx *commitIndex) search(repoName string, q CommitSearchQuery) []CommitSearchResult {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	var ids map[int]bool
+	for _, term := range q.Terms {
+		matched := idx.matchTerm(strings.ToLower(term))
+		if ids == nil {
+			ids = matched
+			continue
+		}
+		for id := range ids {
+			if !matched[id] {
+				delete(ids, id)
+			}
+		}
+	}
+
+	check := func(entry *commitEntry) (CommitSearchResult, bool) {
+		if q.Author != "" {
+			author := strings.ToLower(q.Author)
+			if !strings.Contains(strings.ToLower(entry.author), author) &&
+				!strings.Contains(strings.ToLower(entry.email), author) {
+				return CommitSearchResult{}, false
+			}
+		}
+
+		var paths []string
+		for _, p := range entry.paths {
+			if q.Path == "" || matchPath(q.Path, p) {
+				paths = append(paths, p)
+			}
+		}
+		if q.Path != "" && len(paths) == 0 {
+			return CommitSearchResult{}, false
+		}
+
+		summary, _, _ := strings.Cut(entry.message, "\n")
+		result := CommitSearchResult{
+			Repo:      repoName,
+			Hash:      entry.hash,
+			ShortHash: entry.hash[:7],
+			Summary:   summary,
+			Message:   entry.message,
+			Author:    entry.author,
+			Email:     entry.email,
+			Date:      entry.when,
+			Paths:     paths,
+		}
+		if len(paths) > commitSearchShownPaths {
+			result.Paths = paths[:commitSearchShownPaths]
+			result.MorePaths = len(paths) - commitSearchShownPaths
+		}
+		return result, true
+	}
+
+	var results []CommitSearchResult
+	if ids == nil {
+		for _, entry := range idx.entries {
+			if result, ok := check(entry); ok {
+				results = append(results, result)
+			}
+		}
+		return results
+	}
+	for id := range ids {
+		if result, ok := check(idx.entries[id]); ok {
+			results = append(results, result)
+		}
+	}
+	return results
+}
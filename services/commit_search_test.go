@@ -0,0 +1,84 @@
+//services/commit_search_test.go
+
+package services
+
+import (
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+func TestCommitIndexUpdate(t *testing.T) {
+	work, path := newWorkRepo(t)
+	c1 := commitWork(t, work, map[string]string{"a.txt": "one"})
+	c2 := commitWork(t, work, map[string]string{"a.txt": "two"})
+	repo, err := git.PlainOpen(work)
+	if err != nil {
+		t.Fatal(err)
+	}
+	setBranch := func(name string, hash plumbing.Hash) {
+		t.Helper()
+		ref := plumbing.NewHashReference(plumbing.NewBranchReferenceName(name), hash)
+		if err := repo.Storer.SetReference(ref); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// found reports whether searching the index for a hash finds the commit
+	found := func(idx *commitIndex, hash plumbing.Hash) bool {
+		t.Helper()
+		c := &CommitSearchService{indexes: map[string]*commitIndex{"alice/demo": idx}}
+		results, err := c.Search(CommitSearchQuery{Terms: []string{hash.String()[:12]}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return results.Total == 1
+	}
+
+	idx, added, err := updateCommitIndex(nil, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if added != 2 || !found(idx, c1) || !found(idx, c2) {
+		t.Fatalf("built an index of %d commits, want c1 and c2", added)
+	}
+
+	// A fast-forward extends the index in place
+	c3 := commitWork(t, work, map[string]string{"a.txt": "three"})
+	updated, added, err := updateCommitIndex(idx, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated != idx || added != 1 || !found(idx, c3) {
+		t.Fatalf("fast-forward added %d commits (same index: %v), want c3 in place", added, updated == idx)
+	}
+
+	// A force-push rebuilds; commits still on another branch stay
+	setBranch("feature", c2)
+	setBranch("main", c1)
+	rebuilt, _, err := updateCommitIndex(idx, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rebuilt == idx {
+		t.Fatal("a force-push updated the index in place")
+	}
+	if len(rebuilt.entries) != 2 || !found(rebuilt, c1) || !found(rebuilt, c2) || found(rebuilt, c3) {
+		t.Errorf("after the force-push the index has %d commits, want c1 and c2", len(rebuilt.entries))
+	}
+
+	// So does deleting the branch
+	if err := repo.Storer.RemoveReference(plumbing.NewBranchReferenceName("feature")); err != nil {
+		t.Fatal(err)
+	}
+	idx = rebuilt
+	if rebuilt, _, err = updateCommitIndex(idx, path); err != nil {
+		t.Fatal(err)
+	}
+	if rebuilt == idx {
+		t.Fatal("deleting a branch updated the index in place")
+	}
+	if len(rebuilt.entries) != 1 || !found(rebuilt, c1) || found(rebuilt, c2) {
+		t.Errorf("after deleting the branch the index has %d commits, want c1", len(rebuilt.entries))
+	}
+}
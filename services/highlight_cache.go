@@ -0,0 +1,246 @@
+// services/highlight_cache.go
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"SimpleGit/utils"
+)
+
+// HighlightCache is a content-addressed cache for highlighted files.
+//
+// Entries are keyed by git blob hash, language and highlighter version (see
+// HighlightCacheKey), so different versions of a file can never serve each
+// other's output. A bounded in-memory LRU sits in front of an optional
+// on-disk tier that survives restarts.
+type HighlightCache struct {
+	mem  *utils.LRU[string, HighlightResponse]
+	disk *utils.LRU[string, int64]
+	dir  string
+
+	hits      atomic.Int64
+	diskHits  atomic.Int64
+	misses    atomic.Int64
+	evictions atomic.Int64
+	maxBytes  int64
+}
+
+// HighlightCacheStats is a snapshot of the cache counters.
+type HighlightCacheStats struct {
+	Hits               int64   `json:"hits"`
+	DiskHits           int64   `json:"disk_hits"`
+	Misses             int64   `json:"misses"`
+	Evictions          int64   `json:"evictions"`
+	HitRatio           float64 `json:"hit_ratio"`
+	Entries            int     `json:"entries"`
+	Bytes              int64   `json:"bytes"`
+	MaxBytes           int64   `json:"max_bytes"`
+	DiskEnabled        bool    `json:"disk_enabled"`
+	DiskEntries        int     `json:"disk_entries"`
+	DiskBytes          int64   `json:"disk_bytes"`
+	HighlighterVersion string  `json:"highlighter_version"`
+}
+
+// HighlightCacheKey builds the cache key for a blob highlighted as language.
+func HighlightCacheKey(blobHash, language string) string {
+	return fmt.Sprintf("%s:%s:%s", HighlighterVersion, language, blobHash)
+}
+
+// NewHighlightCache creates a highlight cache.
+//
+// Parameters:
+//   - maxBytes: The memory budget for highlighted output.
+//   - diskDir: Directory for the on-disk tier, or "" to disable it.
+//   - diskMaxBytes: The disk budget; the disk tier is disabled when <= 0.
+func NewHighlightCache(maxBytes int64, diskDir string, diskMaxBytes int64) *HighlightCache {
+	c := &HighlightCache{maxBytes: maxBytes}
+
+	c.mem = utils.NewLRU(maxBytes, func(key string, resp HighlightResponse) int64 {
+		return int64(len(key) + len(resp.Highlighted) + len(resp.DetectedLanguage))
+	})
+	c.mem.OnEvict = func(string, HighlightResponse) {
+		c.evictions.Add(1)
+	}
+
+	if diskDir != "" && diskMaxBytes > 0 {
+		if err := os.MkdirAll(diskDir, 0755); err != nil {
+			log.Printf("Highlight cache: disabling disk tier: %v", err)
+		} else {
+			c.dir = diskDir
+			c.disk = utils.NewLRU(diskMaxBytes, func(_ string, size int64) int64 {
+				return size
+			})
+			c.disk.OnEvict = func(name string, _ int64) {
+				os.Remove(filepath.Join(c.dir, name[:2], name))
+			}
+			c.loadDiskIndex()
+		}
+	}
+
+	return c
+}
+
+// Get returns the cached highlight result for key.
+func (c *HighlightCache) Get(key string) (HighlightResponse, bool) {
+	if resp, ok := c.mem.Get(key); ok {
+		c.hits.Add(1)
+		return resp, true
+	}
+
+	if c.disk != nil {
+		if resp, ok := c.readDisk(key); ok {
+			c.diskHits.Add(1)
+			c.mem.Add(key, resp)
+			return resp, true
+		}
+	}
+
+	c.misses.Add(1)
+	return HighlightResponse{}, false
+}
+
+// Set stores a highlight result under key in every enabled tier.
+func (c *HighlightCache) Set(key string, value HighlightResponse) {
+	c.mem.Add(key, value)
+
+	if c.disk != nil {
+		if err := c.writeDisk(key, value); err != nil {
+			log.Printf("Highlight cache: failed to write disk entry: %v", err)
+		}
+	}
+}
+
+// Stats returns the current cache counters.
+func (c *HighlightCache) Stats() HighlightCacheStats {
+	stats := HighlightCacheStats{
+		Hits:               c.hits.Load(),
+		DiskHits:           c.diskHits.Load(),
+		Misses:             c.misses.Load(),
+		Evictions:          c.evictions.Load(),
+		Entries:            c.mem.Len(),
+		Bytes:              c.mem.Bytes(),
+		MaxBytes:           c.maxBytes,
+		DiskEnabled:        c.disk != nil,
+		HighlighterVersion: HighlighterVersion,
+	}
+
+	if total := stats.Hits + stats.DiskHits + stats.Misses; total > 0 {
+		stats.HitRatio = float64(stats.Hits+stats.DiskHits) / float64(total)
+	}
+
+	if c.disk != nil {
+		stats.DiskEntries = c.disk.Len()
+		stats.DiskBytes = c.disk.Bytes()
+	}
+
+	return stats
+}
+
+func diskName(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func (c *HighlightCache) readDisk(key string) (HighlightResponse, bool) {
+	name := diskName(key)
+	if _, ok := c.disk.Get(name); !ok {
+		return HighlightResponse{}, false
+	}
+
+	data, err := os.ReadFile(filepath.Join(c.dir, name[:2], name))
+	if err != nil {
+		c.disk.Remove(name)
+		return HighlightResponse{}, false
+	}
+
+	var resp HighlightResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		c.disk.Remove(name)
+		return HighlightResponse{}, false
+	}
+
+	return resp, true
+}
+
+func (c *HighlightCache) writeDisk(key string, value HighlightResponse) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	name := diskName(key)
+	dir := filepath.Join(c.dir, name[:2])
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	// Write to a temporary file first so readers never see partial entries
+	tmp, err := os.CreateTemp(dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	tmp.Close()
+
+	if err := os.Rename(tmp.Name(), filepath.Join(dir, name)); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	c.disk.Add(name, int64(len(data)))
+	if _, ok := c.disk.Get(name); !ok {
+		// Entry is larger than the whole disk budget
+		os.Remove(filepath.Join(dir, name))
+	}
+	return nil
+}
+
+// loadDiskIndex rebuilds the disk LRU from the files left by a previous run,
+// oldest first so the most recently written entries survive eviction.
+func (c *HighlightCache) loadDiskIndex() {
+	type diskEntry struct {
+		name    string
+		size    int64
+		modTime time.Time
+	}
+	var entries []diskEntry
+
+	filepath.WalkDir(c.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		if len(d.Name()) != sha256.Size*2 {
+			// Leftover temporary file from an interrupted write
+			os.Remove(path)
+			return nil
+		}
+		entries = append(entries, diskEntry{name: d.Name(), size: info.Size(), modTime: info.ModTime()})
+		return nil
+	})
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].modTime.Before(entries[j].modTime)
+	})
+
+	for _, e := range entries {
+		c.disk.Add(e.name, e.size)
+	}
+}
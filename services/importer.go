@@ -0,0 +1,220 @@
+//services/importer.go
+
+package services
+
+import (
+	"SimpleGit/models"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// importProgressInterval is how often the progress of an import is
+// stored while git reports it.
+const importProgressInterval = 500 * time.Millisecond
+
+// importPercent finds the percentage in a line of git's progress output.
+var importPercent = regexp.MustCompile(`(\d+)%`)
+
+// Importer runs repository imports in the background. Each import fetches
+// the branches and tags of a URL or an uploaded bundle into the empty
+// repository created for it and records git's progress as it goes.
+type Importer struct {
+	imports   *models.ImportService
+	uploadDir string
+	done      func(imp *models.RepoImport)
+	queue     chan importTask
+	ctx       context.Context
+	cancel    context.CancelFunc
+	wg        sync.WaitGroup
+	stopOnce  sync.Once
+}
+
+// importTask is a queued import together with what is needed to run it.
+type importTask struct {
+	importID string
+	repo     *models.Repository
+	source   string
+}
+
+// NewImporter creates the importer and starts its workers.
+//
+// Parameters:
+//   - imports: Where imports are stored.
+//   - uploadDir: The directory uploaded bundles are kept in until imported.
+//   - workers: How many imports may run at the same time.
+//   - done: Called after an import succeeded.
+func NewImporter(imports *models.ImportService, uploadDir string, workers int, done func(imp *models.RepoImport)) (*Importer, error) {
+	if err := os.MkdirAll(uploadDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create upload directory: %w", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	im := &Importer{
+		imports:   imports,
+		uploadDir: uploadDir,
+		done:      done,
+		queue:     make(chan importTask, 64),
+		ctx:       ctx,
+		cancel:    cancel,
+	}
+
+	im.abandonUnfinished()
+
+	for i := 0; i < max(workers, 1); i++ {
+		im.wg.Add(1)
+		go im.worker()
+	}
+	return im, nil
+}
+
+// UploadDir returns the directory uploaded bundles are kept in.
+func (im *Importer) UploadDir() string {
+	return im.uploadDir
+}
+
+// BundlePath returns where the uploaded bundle of an import is kept.
+func (im *Importer) BundlePath(importID string) string {
+	return filepath.Join(im.uploadDir, importID+".bundle")
+}
+
+// Enqueue queues an import.
+//
+// Parameters:
+//   - imp: An import created with models.ImportService.Create.
+//   - repo: The empty repository to import into.
+//   - source: The URL to import from, with credentials if it needs any.
+//     Bundle imports read BundlePath and ignore it.
+func (im *Importer) Enqueue(imp *models.RepoImport, repo *models.Repository, source string) {
+	if imp.Kind == models.ImportFromBundle {
+		source = im.BundlePath(imp.ID)
+	}
+
+	select {
+	case im.queue <- importTask{importID: imp.ID, repo: repo, source: source}:
+	default:
+		log.Printf("Import: queue full, dropping %s", imp.RepoName)
+		im.finish(imp, models.ImportFailure, "The import queue is full")
+	}
+}
+
+// Stop cancels running imports and waits for the workers to exit.
+func (im *Importer) Stop() {
+	im.stopOnce.Do(func() {
+		im.cancel()
+		im.wg.Wait()
+	})
+}
+
+// abandonUnfinished fails imports left queued or running by a previous
+// process; URLs with credentials were only kept in memory.
+func (im *Importer) abandonUnfinished() {
+	imports, err := im.imports.Unfinished()
+	if err != nil {
+		log.Printf("Import: failed to list unfinished imports: %v", err)
+		return
+	}
+	for i := range imports {
+		im.finish(&imports[i], models.ImportFailure, "Interrupted by a server restart")
+	}
+}
+
+func (im *Importer) worker() {
+	defer im.wg.Done()
+	for {
+		select {
+		case <-im.ctx.Done():
+			return
+		case task := <-im.queue:
+			im.run(task)
+		}
+	}
+}
+
+// run executes one import.
+func (im *Importer) run(task importTask) {
+	imp, err := im.imports.Get(task.importID)
+	if err != nil {
+		log.Printf("Import: import %s vanished: %v", task.importID, err)
+		return
+	}
+
+	if err := im.imports.Start(imp); err != nil {
+		log.Printf("Import: failed to start %s: %v", imp.RepoName, err)
+	}
+	log.Printf("Import: importing %s from %s", imp.RepoName, imp.Source)
+
+	progress := &importProgress{imports: im.imports, imp: imp}
+	err = task.repo.Import(im.ctx, task.source, progress)
+	progress.flush()
+
+	switch {
+	case errors.Is(err, context.Canceled):
+		im.finish(imp, models.ImportFailure, "Canceled by server shutdown")
+	case err != nil:
+		im.finish(imp, models.ImportFailure, err.Error())
+	default:
+		im.finish(imp, models.ImportSuccess, "")
+		if im.done != nil {
+			im.done(imp)
+		}
+	}
+}
+
+// finish records the final state of an import and removes its bundle.
+func (im *Importer) finish(imp *models.RepoImport, status, msg string) {
+	if err := im.imports.Finish(imp, status, msg); err != nil {
+		log.Printf("Import: failed to finish %s: %v", imp.RepoName, err)
+	}
+	if imp.Kind == models.ImportFromBundle {
+		if err := os.Remove(im.BundlePath(imp.ID)); err != nil && !os.IsNotExist(err) {
+			log.Printf("Import: failed to remove bundle of %s: %v", imp.RepoName, err)
+		}
+	}
+	log.Printf("Import: %s finished: %s", imp.RepoName, status)
+}
+
+// importProgress stores the lines of git's progress output as the
+// progress of an import, at most every importProgressInterval.
+type importProgress struct {
+	imports *models.ImportService
+	imp     *models.RepoImport
+	line    string
+	percent int
+	stored  time.Time
+}
+
+func (p *importProgress) Write(data []byte) (int, error) {
+	line := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(string(data)), "remote:"))
+	if line == "" {
+		return len(data), nil
+	}
+
+	p.line, p.percent = line, 0
+	if m := importPercent.FindStringSubmatch(line); m != nil {
+		p.percent, _ = strconv.Atoi(m[1])
+	}
+	if time.Since(p.stored) >= importProgressInterval {
+		p.flush()
+	}
+	return len(data), nil
+}
+
+// flush stores the latest line.
+func (p *importProgress) flush() {
+	if p.line == "" || (p.line == p.imp.Progress && p.percent == p.imp.Percent) {
+		return
+	}
+	if err := p.imports.SetProgress(p.imp, p.line, p.percent); err != nil {
+		log.Printf("Import: failed to store progress of %s: %v", p.imp.RepoName, err)
+	}
+	p.stored = time.Now()
+}
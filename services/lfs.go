@@ -0,0 +1,231 @@
+// services/lfs.go
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// lfsPointerVersion is the first line of every Git LFS pointer file.
+const lfsPointerVersion = "version https://git-lfs.github.com/spec/v1"
+
+// lfsPointerMaxSize is the size above which a blob cannot be a pointer.
+const lfsPointerMaxSize = 1024
+
+// ErrLFSObjectNotFound is returned for objects a store does not have.
+var ErrLFSObjectNotFound = errors.New("LFS object not found")
+
+// ErrLFSObjectMismatch is returned when uploaded content does not have the
+// size or SHA-256 it was announced with.
+var ErrLFSObjectMismatch = errors.New("LFS object does not match its oid and size")
+
+var lfsOidPattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// ValidLFSOid reports whether oid is a lowercase hex SHA-256.
+func ValidLFSOid(oid string) bool {
+	return lfsOidPattern.MatchString(oid)
+}
+
+// LFSPointer is the content git stores in place of a file tracked by LFS.
+type LFSPointer struct {
+	Oid  string
+	Size int64
+}
+
+// ParseLFSPointer parses content as an LFS pointer and reports whether it
+// is one.
+func ParseLFSPointer(content []byte) (LFSPointer, bool) {
+	var pointer LFSPointer
+	if len(content) > lfsPointerMaxSize || !bytes.HasPrefix(content, []byte(lfsPointerVersion+"\n")) {
+		return pointer, false
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		key, value, _ := strings.Cut(scanner.Text(), " ")
+		switch key {
+		case "oid":
+			pointer.Oid = strings.TrimPrefix(value, "sha256:")
+		case "size":
+			size, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || size < 0 {
+				return pointer, false
+			}
+			pointer.Size = size
+		}
+	}
+
+	return pointer, ValidLFSOid(pointer.Oid)
+}
+
+// LFSStore stores the LFS objects of repositories. Objects are kept per
+// repository so access to an object follows access to its repository.
+type LFSStore interface {
+	// Size returns the size of an object, or ErrLFSObjectNotFound.
+	Size(repo, oid string) (int64, error)
+	// Open opens an object for reading, or returns ErrLFSObjectNotFound.
+	Open(repo, oid string) (io.ReadSeekCloser, error)
+	// Put stores an object read from r, checking its size and SHA-256.
+	Put(repo, oid string, size int64, r io.Reader) error
+	// DeleteRepo removes every object of a repository.
+	DeleteRepo(repo string) error
+	// CopyRepo gives repository to every object of repository from.
+	CopyRepo(from, to string) error
+	// RenameRepo moves every object of a repository to its new name.
+	RenameRepo(from, to string) error
+}
+
+// LocalLFSStore keeps LFS objects on the local filesystem, laid out like
+// git-lfs does locally: <root>/<repo>/<oid[0:2]>/<oid[2:4]>/<oid>.
+type LocalLFSStore struct {
+	root string
+}
+
+// NewLocalLFSStore creates a store rooted at dir.
+func NewLocalLFSStore(dir string) (*LocalLFSStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create LFS directory: %w", err)
+	}
+	return &LocalLFSStore{root: dir}, nil
+}
+
+func (s *LocalLFSStore) path(repo, oid string) string {
+	return filepath.Join(s.root, repo, oid[0:2], oid[2:4], oid)
+}
+
+// Size returns the size of an object.
+func (s *LocalLFSStore) Size(repo, oid string) (int64, error) {
+	info, err := os.Stat(s.path(repo, oid))
+	if os.IsNotExist(err) {
+		return 0, ErrLFSObjectNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+// Open opens an object for reading.
+func (s *LocalLFSStore) Open(repo, oid string) (io.ReadSeekCloser, error) {
+	f, err := os.Open(s.path(repo, oid))
+	if os.IsNotExist(err) {
+		return nil, ErrLFSObjectNotFound
+	}
+	return f, err
+}
+
+// Put stores an object. It is written to a temporary file first so readers
+// never see a partial object.
+func (s *LocalLFSStore) Put(repo, oid string, size int64, r io.Reader) error {
+	path := s.path(repo, oid)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), oid+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	hash := sha256.New()
+	// Read one byte more than announced to notice oversized uploads
+	n, err := io.Copy(io.MultiWriter(tmp, hash), io.LimitReader(r, size+1))
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if n != size || hex.EncodeToString(hash.Sum(nil)) != oid {
+		return ErrLFSObjectMismatch
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// DeleteRepo removes every object of a repository.
+func (s *LocalLFSStore) DeleteRepo(repo string) error {
+	return os.RemoveAll(filepath.Join(s.root, repo))
+}
+
+// RenameRepo moves every object of a repository to its new name.
+func (s *LocalLFSStore) RenameRepo(from, to string) error {
+	src := filepath.Join(s.root, from)
+	if _, err := os.Stat(src); os.IsNotExist(err) {
+		return nil
+	}
+	dst := filepath.Join(s.root, to)
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	return os.Rename(src, dst)
+}
+
+// CopyRepo gives repository to every object of repository from. Objects
+// are hard-linked where possible since they never change.
+func (s *LocalLFSStore) CopyRepo(from, to string) error {
+	_, err := linkLFSObjects(filepath.Join(s.root, from), filepath.Join(s.root, to))
+	return err
+}
+
+// linkLFSObjects recreates the objects under src in dst, hard-linking them
+// where possible, and returns how many there are. A missing src holds no
+// objects.
+func linkLFSObjects(src, dst string) (int, error) {
+	count := 0
+	err := filepath.WalkDir(src, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		if d.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+		if !ValidLFSOid(d.Name()) {
+			// Leftover temporary file of an interrupted upload
+			return nil
+		}
+		count++
+		if err := os.Link(path, target); err == nil || os.IsExist(err) {
+			return nil
+		}
+		return copyFile(path, target)
+	})
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	return count, err
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
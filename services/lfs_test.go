@@ -0,0 +1,109 @@
+//services/lfs_test.go
+
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func lfsOid(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestValidLFSOid(t *testing.T) {
+	oid := lfsOid("data")
+	tests := []struct {
+		oid  string
+		want bool
+	}{
+		{oid, true},
+		{"", false},
+		{oid[:63], false},
+		{oid + "0", false},
+		{strings.ToUpper(oid), false},
+		{"../../../../" + oid[12:], false},
+		{strings.Repeat("g", 64), false},
+	}
+	for _, tt := range tests {
+		if got := ValidLFSOid(tt.oid); got != tt.want {
+			t.Errorf("ValidLFSOid(%q) = %v, want %v", tt.oid, got, tt.want)
+		}
+	}
+}
+
+func TestParseLFSPointer(t *testing.T) {
+	oid := lfsOid("data")
+	tests := []struct {
+		name    string
+		content string
+		want    LFSPointer
+		ok      bool
+	}{
+		{"pointer", "version https://git-lfs.github.com/spec/v1\noid sha256:" + oid + "\nsize 4\n", LFSPointer{Oid: oid, Size: 4}, true},
+		{"other version", "version https://example.com/v2\noid sha256:" + oid + "\nsize 4\n", LFSPointer{}, false},
+		{"bad oid", "version https://git-lfs.github.com/spec/v1\noid sha256:1234\nsize 4\n", LFSPointer{Oid: "1234", Size: 4}, false},
+		{"negative size", "version https://git-lfs.github.com/spec/v1\noid sha256:" + oid + "\nsize -1\n", LFSPointer{Oid: oid}, false},
+		{"plain file", "package main\n", LFSPointer{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := ParseLFSPointer([]byte(tt.content))
+			if ok != tt.ok || (ok && got != tt.want) {
+				t.Errorf("ParseLFSPointer() = %+v, %v; want %+v, %v", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestLFSPutVerifiesObjects(t *testing.T) {
+	content := "large file\n"
+	oid := lfsOid(content)
+	tests := []struct {
+		name    string
+		oid     string
+		size    int64
+		content string
+		wantErr error
+	}{
+		{"matching", oid, int64(len(content)), content, nil},
+		{"other content", oid, int64(len(content)), "LARGE FILE\n", ErrLFSObjectMismatch},
+		{"short upload", oid, int64(len(content)), content[:4], ErrLFSObjectMismatch},
+		{"long upload", oid, int64(len(content)), content + "more", ErrLFSObjectMismatch},
+		{"size understated", oid, int64(len(content)) - 1, content, ErrLFSObjectMismatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			store, err := NewLocalLFSStore(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := store.Put("alice/demo", tt.oid, tt.size, strings.NewReader(tt.content)); err != tt.wantErr {
+				t.Fatalf("Put() error = %v, want %v", err, tt.wantErr)
+			}
+
+			size, err := store.Size("alice/demo", tt.oid)
+			if tt.wantErr != nil {
+				if err != ErrLFSObjectNotFound {
+					t.Errorf("rejected object is stored: %d, %v", size, err)
+				}
+			} else if err != nil || size != tt.size {
+				t.Errorf("Size() = %d, %v; want %d", size, err, tt.size)
+			}
+
+			// No temporary files are left behind
+			entries, _ := os.ReadDir(filepath.Join(dir, "alice", "demo", oid[0:2], oid[2:4]))
+			for _, entry := range entries {
+				if entry.Name() != oid {
+					t.Errorf("left %s behind", entry.Name())
+				}
+			}
+		})
+	}
+}
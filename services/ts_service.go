@@ -9,6 +9,10 @@ import (
 	"net/http"
 )
 
+// HighlighterVersion identifies the output format of the TS worker. Bump it
+// whenever highlighting changes so cached results are not reused.
+const HighlighterVersion = "hljs-11.1"
+
 type TSService struct {
 	BaseURL string
 }
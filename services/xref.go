@@ -0,0 +1,378 @@
+// services/xref.go
+package services
+
+import (
+	"SimpleGit/models"
+	"fmt"
+	"log"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// Limits that keep indexing of very large repositories bounded.
+const (
+	xrefMaxFiles     = 5000
+	xrefMaxFileBytes = 1 << 20
+	xrefMaxRefs      = 500
+)
+
+// XrefDefinition is a declaration that identifiers can link to.
+//
+// Parameters:
+//   - Kind: function, method, type, constant, variable or field.
+//   - Member: Whether the declaration is reached through a selector (x.Name).
+//   - Package: The directory of the declaring package.
+type XrefDefinition struct {
+	ID       int    `json:"id"`
+	Name     string `json:"name"`
+	Kind     string `json:"kind"`
+	Path     string `json:"path"`
+	Line     int    `json:"line"`
+	Receiver string `json:"receiver,omitempty"`
+	Member   bool   `json:"-"`
+	Package  string `json:"-"`
+}
+
+// XrefReference is a use of a definition.
+type XrefReference struct {
+	Path string `json:"path"`
+	Line int    `json:"line"`
+	Text string `json:"text"`
+}
+
+// XrefLink marks an identifier in a file that resolves to a definition.
+// Col is a 0-based UTF-16 offset into the line so the browser can find it
+// in the rendered text.
+type XrefLink struct {
+	Line  int  `json:"line"`
+	Col   int  `json:"col"`
+	Len   int  `json:"len"`
+	Def   int  `json:"def"`
+	IsDef bool `json:"is_def"`
+}
+
+// XrefIndex holds the cross references of one commit of a repository.
+type XrefIndex struct {
+	Commit      string
+	BuiltAt     time.Time
+	Definitions []XrefDefinition
+	References  map[int][]XrefReference
+	Links       map[string][]XrefLink
+}
+
+// xrefLanguage extracts definitions and resolved identifiers for one
+// language. Indexing happens in two passes so identifiers can be resolved
+// against the definitions of the whole tree.
+type xrefLanguage interface {
+	Match(path string) bool
+	Definitions(path string, content []byte) []XrefDefinition
+	Resolve(path string, content []byte, idx *xrefResolver) []XrefLink
+}
+
+// xrefResolver looks definitions up by name during the second pass.
+type xrefResolver struct {
+	byName map[string][]int
+	defs   []XrefDefinition
+}
+
+var xrefLanguages = []xrefLanguage{goXref{}}
+
+type xrefJob struct {
+	repoName string
+	repoPath string
+	commit   plumbing.Hash
+}
+
+// XrefService builds cross-reference indexes in the background, one per
+// repository branch tip, and keeps them in memory.
+type XrefService struct {
+	mu       sync.RWMutex
+	indexes  map[string]*XrefIndex
+	pending  map[string]bool
+	jobs     chan xrefJob
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+// NewXrefService creates the service and starts its indexing worker.
+func NewXrefService() *XrefService {
+	x := &XrefService{
+		indexes: make(map[string]*XrefIndex),
+		pending: make(map[string]bool),
+		jobs:    make(chan xrefJob, 256),
+		done:    make(chan struct{}),
+	}
+	go x.worker()
+	return x
+}
+
+func xrefKey(repoName, commit string) string {
+	return repoName + "@" + commit
+}
+
+// Index returns the index for a commit if it has been built, and schedules
+// a build otherwise.
+func (x *XrefService) Index(repoName, repoPath, commit string) (*XrefIndex, bool) {
+	x.mu.RLock()
+	idx, ok := x.indexes[xrefKey(repoName, commit)]
+	x.mu.RUnlock()
+	if ok {
+		return idx, true
+	}
+
+	x.schedule(xrefJob{repoName: repoName, repoPath: repoPath, commit: plumbing.NewHash(commit)})
+	return nil, false
+}
+
+// Refresh schedules indexing of every branch tip of a repository and drops
+// indexes of commits that are no longer a tip.
+func (x *XrefService) Refresh(repoName, repoPath string) error {
+	repo, err := models.OpenGit(repoPath)
+	if err != nil {
+		return err
+	}
+
+	branches, err := repo.Branches()
+	if err != nil {
+		return err
+	}
+
+	tips := make(map[string]bool)
+	err = branches.ForEach(func(ref *plumbing.Reference) error {
+		tips[ref.Hash().String()] = true
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	x.mu.Lock()
+	for key := range x.indexes {
+		if commit, ok := strings.CutPrefix(key, repoName+"@"); ok && !tips[commit] {
+			delete(x.indexes, key)
+		}
+	}
+	x.mu.Unlock()
+
+	for commit := range tips {
+		x.schedule(xrefJob{repoName: repoName, repoPath: repoPath, commit: plumbing.NewHash(commit)})
+	}
+	return nil
+}
+
+// Forget drops every index of a repository.
+func (x *XrefService) Forget(repoName string) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	for key := range x.indexes {
+		if strings.HasPrefix(key, repoName+"@") {
+			delete(x.indexes, key)
+		}
+	}
+}
+
+// Stop stops the indexing worker.
+func (x *XrefService) Stop() {
+	x.stopOnce.Do(func() { close(x.done) })
+}
+
+func (x *XrefService) schedule(job xrefJob) {
+	key := xrefKey(job.repoName, job.commit.String())
+
+	x.mu.Lock()
+	if x.pending[key] || x.indexes[key] != nil {
+		x.mu.Unlock()
+		return
+	}
+	x.pending[key] = true
+	x.mu.Unlock()
+
+	select {
+	case x.jobs <- job:
+	default:
+		// Queue is full; the next request for this commit will retry
+		x.mu.Lock()
+		delete(x.pending, key)
+		x.mu.Unlock()
+	}
+}
+
+func (x *XrefService) worker() {
+	for {
+		select {
+		case <-x.done:
+			return
+		case job := <-x.jobs:
+			key := xrefKey(job.repoName, job.commit.String())
+			start := time.Now()
+			idx, err := buildXrefIndex(job.repoPath, job.commit)
+
+			x.mu.Lock()
+			delete(x.pending, key)
+			if err != nil {
+				log.Printf("Xref: failed to index %s: %v", key, err)
+			} else {
+				x.indexes[key] = idx
+			}
+			x.mu.Unlock()
+
+			if err == nil {
+				log.Printf("Xref: indexed %s (%d definitions) in %v", key, len(idx.Definitions), time.Since(start))
+			}
+		}
+	}
+}
+
+// DefinitionReferences returns the uses of a definition.
+func (idx *XrefIndex) DefinitionReferences(id int) []XrefReference {
+	return idx.References[id]
+}
+
+// Definition returns the definition with the given ID.
+func (idx *XrefIndex) Definition(id int) (XrefDefinition, bool) {
+	if id < 0 || id >= len(idx.Definitions) {
+		return XrefDefinition{}, false
+	}
+	return idx.Definitions[id], true
+}
+
+func buildXrefIndex(repoPath string, commitHash plumbing.Hash) (*XrefIndex, error) {
+	repo, err := models.OpenGit(repoPath)
+	if err != nil {
+		return nil, err
+	}
+
+	commit, err := repo.CommitObject(commitHash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get commit: %w", err)
+	}
+
+	tree, err := commit.Tree()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tree: %w", err)
+	}
+
+	type source struct {
+		path    string
+		lang    xrefLanguage
+		content []byte
+	}
+	var sources []source
+
+	err = tree.Files().ForEach(func(f *object.File) error {
+		if len(sources) >= xrefMaxFiles || f.Size > xrefMaxFileBytes {
+			return nil
+		}
+		for _, lang := range xrefLanguages {
+			if !lang.Match(f.Name) {
+				continue
+			}
+			content, err := f.Contents()
+			if err != nil {
+				return nil
+			}
+			sources = append(sources, source{path: f.Name, lang: lang, content: []byte(content)})
+			break
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	resolver := &xrefResolver{byName: make(map[string][]int)}
+	for _, src := range sources {
+		for _, def := range src.lang.Definitions(src.path, src.content) {
+			def.ID = len(resolver.defs)
+			resolver.defs = append(resolver.defs, def)
+			resolver.byName[def.Name] = append(resolver.byName[def.Name], def.ID)
+		}
+	}
+
+	idx := &XrefIndex{
+		Commit:      commitHash.String(),
+		BuiltAt:     time.Now(),
+		Definitions: resolver.defs,
+		References:  make(map[int][]XrefReference),
+		Links:       make(map[string][]XrefLink),
+	}
+
+	for _, src := range sources {
+		links := src.lang.Resolve(src.path, src.content, resolver)
+		if len(links) == 0 {
+			continue
+		}
+		idx.Links[src.path] = links
+
+		lines := strings.Split(string(src.content), "\n")
+		for _, link := range links {
+			if link.IsDef || len(idx.References[link.Def]) >= xrefMaxRefs {
+				continue
+			}
+			text := ""
+			if link.Line-1 < len(lines) {
+				text = strings.TrimSpace(lines[link.Line-1])
+			}
+			idx.References[link.Def] = append(idx.References[link.Def], XrefReference{
+				Path: src.path,
+				Line: link.Line,
+				Text: text,
+			})
+		}
+	}
+
+	return idx, nil
+}
+
+// packageDefs returns the package-level definitions named name declared in
+// the package directory dir.
+func (r *xrefResolver) packageDefs(name, dir string) []int {
+	var ids []int
+	for _, id := range r.byName[name] {
+		if def := r.defs[id]; !def.Member && def.Package == dir {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// importedDefs returns the package-level definitions named name in the
+// package imported as importPath. Packages are matched by directory suffix
+// since the module path is not part of the repository layout.
+func (r *xrefResolver) importedDefs(name, importPath string) []int {
+	var ids []int
+	for _, id := range r.byName[name] {
+		def := r.defs[id]
+		if def.Member || def.Package == "" {
+			continue
+		}
+		if importPath == def.Package || strings.HasSuffix(importPath, "/"+def.Package) {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// memberDefs returns the methods and fields named name.
+func (r *xrefResolver) memberDefs(name string) []int {
+	var ids []int
+	for _, id := range r.byName[name] {
+		if r.defs[id].Member {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+func dirOf(p string) string {
+	dir := path.Dir(p)
+	if dir == "." {
+		return ""
+	}
+	return dir
+}
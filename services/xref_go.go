@@ -0,0 +1,225 @@
+// services/xref_go.go
+package services
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// goXref indexes Go sources with go/parser. Resolution is by name and
+// package directory rather than full type checking: package-level
+// identifiers resolve within their package or through an import, and
+// selectors that are not package-qualified resolve to methods and fields.
+type goXref struct{}
+
+func (goXref) Match(p string) bool {
+	return strings.HasSuffix(p, ".go") && !strings.HasPrefix(p, "vendor/") && !strings.Contains(p, "/vendor/")
+}
+
+func parseGo(content []byte) (*token.FileSet, *ast.File) {
+	fset := token.NewFileSet()
+	file, _ := parser.ParseFile(fset, "", content, parser.SkipObjectResolution)
+	return fset, file
+}
+
+func (goXref) Definitions(p string, content []byte) []XrefDefinition {
+	fset, file := parseGo(content)
+	if file == nil {
+		return nil
+	}
+
+	pkg := dirOf(p)
+	var defs []XrefDefinition
+	add := func(ident *ast.Ident, kind string, member bool) {
+		if ident == nil || ident.Name == "_" {
+			return
+		}
+		defs = append(defs, XrefDefinition{
+			Name:    ident.Name,
+			Kind:    kind,
+			Path:    p,
+			Line:    fset.Position(ident.Pos()).Line,
+			Member:  member,
+			Package: pkg,
+		})
+	}
+
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			if d.Recv == nil || len(d.Recv.List) == 0 {
+				add(d.Name, "function", false)
+				continue
+			}
+			add(d.Name, "method", true)
+			defs[len(defs)-1].Receiver = types.ExprString(d.Recv.List[0].Type)
+
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				switch sp := spec.(type) {
+				case *ast.TypeSpec:
+					add(sp.Name, "type", false)
+					switch t := sp.Type.(type) {
+					case *ast.StructType:
+						for _, field := range t.Fields.List {
+							for _, name := range field.Names {
+								add(name, "field", true)
+							}
+						}
+					case *ast.InterfaceType:
+						for _, method := range t.Methods.List {
+							for _, name := range method.Names {
+								add(name, "method", true)
+							}
+						}
+					}
+				case *ast.ValueSpec:
+					kind := "variable"
+					if d.Tok == token.CONST {
+						kind = "constant"
+					}
+					for _, name := range sp.Names {
+						add(name, kind, false)
+					}
+				}
+			}
+		}
+	}
+
+	return defs
+}
+
+func (goXref) Resolve(p string, content []byte, r *xrefResolver) []XrefLink {
+	fset, file := parseGo(content)
+	if file == nil {
+		return nil
+	}
+
+	pkg := dirOf(p)
+	lines := strings.Split(string(content), "\n")
+
+	// Local names of imported packages
+	imports := make(map[string]string)
+	for _, imp := range file.Imports {
+		importPath, err := strconv.Unquote(imp.Path.Value)
+		if err != nil {
+			continue
+		}
+		name := importPath[strings.LastIndex(importPath, "/")+1:]
+		if imp.Name != nil {
+			name = imp.Name.Name
+		}
+		imports[name] = importPath
+	}
+
+	// Positions of the declaring identifiers in this file
+	declared := make(map[token.Pos]bool)
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			declared[d.Name.Pos()] = true
+		case *ast.GenDecl:
+			ast.Inspect(d, func(n ast.Node) bool {
+				switch sp := n.(type) {
+				case *ast.TypeSpec:
+					declared[sp.Name.Pos()] = true
+				case *ast.ValueSpec:
+					for _, name := range sp.Names {
+						declared[name.Pos()] = true
+					}
+				case *ast.Field:
+					for _, name := range sp.Names {
+						declared[name.Pos()] = true
+					}
+				}
+				return true
+			})
+		}
+	}
+
+	var links []XrefLink
+	link := func(ident *ast.Ident, ids []int) {
+		if len(ids) == 0 {
+			return
+		}
+		// Prefer a definition in this very spot, then in this file
+		best := ids[0]
+		pos := fset.Position(ident.Pos())
+		for _, id := range ids {
+			def := r.defs[id]
+			if def.Path == p && def.Line == pos.Line {
+				best = id
+				break
+			}
+			if def.Path == p && r.defs[best].Path != p {
+				best = id
+			}
+		}
+
+		line := ""
+		if pos.Line-1 < len(lines) {
+			line = lines[pos.Line-1]
+		}
+		col := pos.Column - 1
+		if col > len(line) {
+			col = len(line)
+		}
+		links = append(links, XrefLink{
+			Line:  pos.Line,
+			Col:   len(utf16.Encode([]rune(line[:col]))),
+			Len:   len(utf16.Encode([]rune(ident.Name))),
+			Def:   best,
+			IsDef: declared[ident.Pos()],
+		})
+	}
+
+	// Identifiers used as selectors are handled with their SelectorExpr
+	selectors := map[*ast.Ident]bool{file.Name: true}
+
+	ast.Inspect(file, func(n ast.Node) bool {
+		switch node := n.(type) {
+		case *ast.ImportSpec:
+			return false
+		case *ast.SelectorExpr:
+			selectors[node.Sel] = true
+			if x, ok := node.X.(*ast.Ident); ok {
+				if importPath, ok := imports[x.Name]; ok {
+					link(node.Sel, r.importedDefs(node.Sel.Name, importPath))
+					return true
+				}
+			}
+			link(node.Sel, r.memberDefs(node.Sel.Name))
+		case *ast.Field:
+			// Field and method names in struct and interface types
+			for _, name := range node.Names {
+				if declared[name.Pos()] {
+					selectors[name] = true
+					link(name, r.memberDefs(name.Name))
+				}
+			}
+		case *ast.FuncDecl:
+			if node.Recv != nil && len(node.Recv.List) > 0 {
+				selectors[node.Name] = true
+				link(node.Name, r.memberDefs(node.Name.Name))
+			}
+		case *ast.KeyValueExpr:
+			// Keys in composite literals name struct fields
+			if key, ok := node.Key.(*ast.Ident); ok {
+				selectors[key] = true
+				link(key, r.memberDefs(key.Name))
+			}
+		case *ast.Ident:
+			if !selectors[node] {
+				link(node, r.packageDefs(node.Name, pkg))
+			}
+		}
+		return true
+	})
+
+	return links
+}
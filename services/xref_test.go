@@ -0,0 +1,162 @@
+//services/xref_test.go
+
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// newWorkRepo creates a repository with a working tree whose default
+// branch is main. It returns the working tree and the git directory, which
+// is opened like the bare repositories the server keeps.
+func newWorkRepo(t *testing.T) (string, string) {
+	t.Helper()
+	path := t.TempDir()
+	_, err := git.PlainInitWithOptions(path, &git.PlainInitOptions{
+		InitOptions: git.InitOptions{DefaultBranch: plumbing.NewBranchReferenceName("main")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path, filepath.Join(path, ".git")
+}
+
+// commitWork writes files to a working tree, deleting those with empty
+// content, and commits every change.
+func commitWork(t *testing.T, path string, files map[string]string) plumbing.Hash {
+	t.Helper()
+	for name, content := range files {
+		file := filepath.Join(path, filepath.FromSlash(name))
+		if content == "" {
+			if err := os.Remove(file); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	repo, err := git.PlainOpen(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wt, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := wt.AddWithOptions(&git.AddOptions{All: true}); err != nil {
+		t.Fatal(err)
+	}
+	hash, err := wt.Commit("Change code", &git.CommitOptions{
+		Author: &object.Signature{Name: "Alice", Email: "alice@example.com", When: time.Now()},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hash
+}
+
+func TestBuildXrefIndex(t *testing.T) {
+	work, path := newWorkRepo(t)
+	tip := commitWork(t, work, map[string]string{
+		"greet/greet.go": `package greet
+
+func Hello(name string) string {
+	return "Hello " + name
+}
+
+type Greeter struct {
+	Name string
+}
+
+func (g Greeter) Greet() string {
+	return Hello(g.Name)
+}
+`,
+		"main.go": `package main
+
+import "example.com/demo/greet"
+
+func main() {
+	g := greet.Greeter{Name: "Alice"}
+	println(g.Greet(), greet.Hello("Bob"))
+}
+`,
+	})
+
+	idx, err := buildXrefIndex(path, tip)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if idx.Commit != tip.String() {
+		t.Errorf("index of %s, want %s", idx.Commit, tip)
+	}
+
+	find := func(name string) XrefDefinition {
+		t.Helper()
+		for _, def := range idx.Definitions {
+			if def.Name == name {
+				return def
+			}
+		}
+		t.Fatalf("no definition of %s in %+v", name, idx.Definitions)
+		return XrefDefinition{}
+	}
+
+	hello := find("Hello")
+	if hello.Kind != "function" || hello.Path != "greet/greet.go" || hello.Line != 3 {
+		t.Errorf("Hello is defined as %+v, want a function at greet/greet.go:3", hello)
+	}
+	greetMethod := find("Greet")
+	if greetMethod.Kind != "method" || greetMethod.Receiver != "Greeter" || greetMethod.Line != 11 {
+		t.Errorf("Greet is defined as %+v, want a method of Greeter at line 11", greetMethod)
+	}
+
+	// Hello is called within its package and through the import
+	refs := idx.DefinitionReferences(hello.ID)
+	want := []XrefReference{
+		{Path: "greet/greet.go", Line: 12, Text: "return Hello(g.Name)"},
+		{Path: "main.go", Line: 7, Text: `println(g.Greet(), greet.Hello("Bob"))`},
+	}
+	if len(refs) != len(want) {
+		t.Fatalf("references of Hello = %+v, want %+v", refs, want)
+	}
+	for i := range want {
+		if refs[i] != want[i] {
+			t.Errorf("reference %d of Hello = %+v, want %+v", i, refs[i], want[i])
+		}
+	}
+
+	// Selectors that are not package-qualified resolve to methods
+	if refs := idx.DefinitionReferences(greetMethod.ID); len(refs) != 1 || refs[0].Path != "main.go" || refs[0].Line != 7 {
+		t.Errorf("references of Greet = %+v, want main.go:7", refs)
+	}
+
+	// Definitions link to themselves, uses link to the definition
+	var isDef, isRef bool
+	for _, link := range idx.Links["greet/greet.go"] {
+		if link.Def == hello.ID && link.Line == 3 {
+			isDef = link.IsDef
+		}
+	}
+	for _, link := range idx.Links["main.go"] {
+		if link.Def == hello.ID && link.Line == 7 {
+			isRef = !link.IsDef
+		}
+	}
+	if !isDef || !isRef {
+		t.Errorf("links of Hello: definition %v, reference in main.go %v", isDef, isRef)
+	}
+}
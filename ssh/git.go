@@ -10,10 +10,11 @@ import (
 	"strings"
 	"time"
 
+	"github.com/go-git/go-git/v5/plumbing"
 	"golang.org/x/crypto/ssh"
 )
 
-func (s *Server) handleChannel(channel ssh.Channel, requests <-chan *ssh.Request) {
+func (s *Server) handleChannel(channel ssh.Channel, requests <-chan *ssh.Request, username string) {
 	// Print the absolute path of the repository root
 	absRepoPath, _ := filepath.Abs(s.repoPath)
 	log.Printf("Repository root directory: %s", absRepoPath)
@@ -44,15 +45,32 @@ func (s *Server) handleChannel(channel ssh.Channel, requests <-chan *ssh.Request
 		}
 
 		cmd := parts[0]
+		args := parts[1]
+
+		// git-lfs-authenticate <repo> <operation>
+		var operation string
+		if cmd == "git-lfs-authenticate" {
+			if i := strings.LastIndex(args, " "); i != -1 {
+				args, operation = args[:i], args[i+1:]
+			}
+		}
+
 		// Clean up repository path
-		repoPath := strings.Trim(parts[1], "'\" /")
+		repoPath := strings.Trim(args, "'\" /")
 		repoPath = strings.TrimSuffix(repoPath, ".git")
 
 		log.Printf("Cleaned command: %s, repo path: %s", cmd, repoPath)
 
-		if err := s.handleGitCommand(cmd, repoPath, channel); err != nil {
+		if !s.startCommand() {
+			fmt.Fprintf(channel.Stderr(), "Error: the server is shutting down\n")
+			channel.SendRequest("exit-status", false, []byte{0, 0, 0, 1})
+			return
+		}
+		defer s.commands.Done()
+
+		if err := s.handleGitCommand(cmd, repoPath, operation, username, channel); err != nil {
 			log.Printf("Git command error: %v", err)
-			fmt.Fprintf(channel, "Error: %v\n", err)
+			fmt.Fprintf(channel.Stderr(), "Error: %v\n", err)
 			channel.SendRequest("exit-status", false, []byte{0, 0, 0, 1})
 			return
 		}
@@ -62,53 +80,51 @@ func (s *Server) handleChannel(channel ssh.Channel, requests <-chan *ssh.Request
 	}
 }
 
-func (s *Server) handleGitCommand(cmd, repoPath string, channel ssh.Channel) error {
+func (s *Server) handleGitCommand(cmd, repoPath, operation, username string, channel ssh.Channel) error {
 	log.Printf("Handling git command: %s %s", cmd, repoPath)
 
-	// Try different repository path variations
-	possiblePaths := []string{
-		filepath.Join(s.repoPath, repoPath+".git"), // with .git suffix
-		filepath.Join(s.repoPath, repoPath),        // without .git suffix
+	// Repositories are addressed as <owner>/<name> and stored at
+	// <root>/<owner>/<name>.git; validating both parts also keeps the
+	// path inside the root
+	if _, _, ok := models.SplitRepoName(repoPath); !ok {
+		return fmt.Errorf("invalid repository path: %s (expected <owner>/<repo>)", repoPath)
 	}
-
-	// Print all possible paths being checked
-	for i, path := range possiblePaths {
-		absPath, _ := filepath.Abs(path)
-		log.Printf("Checking path %d: %s", i+1, absPath)
-		if _, err := os.Stat(absPath); !os.IsNotExist(err) {
-			log.Printf("Path exists: %s", absPath)
-		} else {
-			log.Printf("Path does not exist: %s", absPath)
-		}
+	if s.resolveName != nil {
+		repoPath = s.resolveName(repoPath)
 	}
-
-	var fullRepoPath string
-	var exists bool
-	for _, path := range possiblePaths {
-		absPath, _ := filepath.Abs(path)
-		if _, err := os.Stat(absPath); !os.IsNotExist(err) {
-			// Create a temporary Repository object to use EnsureBare
-			repo := &models.Repository{
-				Path: absPath,
-			}
-			if err := repo.EnsureBare(); err != nil {
-				return fmt.Errorf("failed to ensure repository is bare: %w", err)
-			}
-			fullRepoPath = absPath
-			exists = true
-			break
-		}
+	fullRepoPath := models.RepoDir(s.repoPath, repoPath)
+	if _, err := os.Stat(fullRepoPath); err != nil {
+		return fmt.Errorf("repository not found: %s", repoPath)
 	}
 
-	if !exists {
-		return fmt.Errorf("repository not found: %s (checked paths: %v)", repoPath, possiblePaths)
+	// Create a temporary Repository object to use EnsureBare
+	repo := &models.Repository{
+		Path: fullRepoPath,
+	}
+	if err := repo.EnsureBare(); err != nil {
+		return fmt.Errorf("failed to ensure repository is bare: %w", err)
 	}
 
 	log.Printf("Using repository path: %s", fullRepoPath)
 
 	switch cmd {
 	case "git-upload-pack", "git-receive-pack":
+		if s.authorize != nil {
+			if err := s.authorize(username, fullRepoPath, cmd == "git-receive-pack"); err != nil {
+				return err
+			}
+		}
 		return s.executeGitCommand(cmd, fullRepoPath, channel)
+	case "git-lfs-authenticate":
+		if s.lfsAuth == nil {
+			return fmt.Errorf("git LFS is not available")
+		}
+		out, err := s.lfsAuth(username, fullRepoPath, operation)
+		if err != nil {
+			return err
+		}
+		_, err = channel.Write(append(out, '\n'))
+		return err
 	default:
 		return fmt.Errorf("unsupported command: %s", cmd)
 	}
@@ -122,6 +138,9 @@ func (s *Server) executeGitCommand(cmd string, repoPath string, channel ssh.Chan
 	gitCmd.Stdin = channel
 	gitCmd.Stdout = channel
 	gitCmd.Stderr = channel.Stderr()
+	if cmd == "git-receive-pack" && s.receiveEnv != nil {
+		gitCmd.Env = append(os.Environ(), s.receiveEnv(repoPath)...)
+	}
 
 	log.Printf("Full command: %s %v in directory %s", gitCmd.Path, gitCmd.Args, gitCmd.Dir)
 
@@ -129,6 +148,15 @@ func (s *Server) executeGitCommand(cmd string, repoPath string, channel ssh.Chan
 	cwd, _ := os.Getwd()
 	log.Printf("Current working directory: %s", cwd)
 
+	// Remember the refs so the update hook can be told what changed
+	var before map[plumbing.ReferenceName]plumbing.Hash
+	if cmd == "git-receive-pack" {
+		var err error
+		if before, err = models.SnapshotRefs(repoPath); err != nil {
+			log.Printf("Warning: Failed to snapshot refs: %v", err)
+		}
+	}
+
 	if err := gitCmd.Run(); err != nil {
 		log.Printf("Command execution error: %v", err)
 		return fmt.Errorf("git command failed: %w", err)
@@ -149,7 +177,11 @@ func (s *Server) executeGitCommand(cmd string, repoPath string, channel ssh.Chan
 
 		// Notify about repository changes
 		if s.onUpdate != nil {
-			s.onUpdate()
+			after, err := models.SnapshotRefs(repoPath)
+			if err != nil {
+				log.Printf("Warning: Failed to snapshot refs: %v", err)
+			}
+			s.onUpdate(repoPath, models.DiffRefs(before, after))
 			// Wait another moment for the scan to complete
 			time.Sleep(100 * time.Millisecond)
 		}
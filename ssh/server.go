@@ -4,31 +4,51 @@ import (
 	"SimpleGit/config"
 
 	"SimpleGit/models"
+	"context"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
 	"encoding/pem"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net"
 	"os"
 	"path/filepath"
+	"sync"
 
 	"golang.org/x/crypto/ssh"
 )
 
+// ErrServerClosed is returned by ListenAndServe after Shutdown.
+var ErrServerClosed = errors.New("ssh: server closed")
+
 type Server struct {
 	config      *ssh.ServerConfig
 	userService *models.UserService
 	repoPath    string
-	onUpdate    func()
+	onUpdate    func(repoPath string, updates []models.RefUpdate)
+	receiveEnv  func(repoPath string) []string
+	lfsAuth     func(username, repoPath, operation string) ([]byte, error)
+	authorize   func(username, repoPath string, write bool) error
+	resolveName func(name string) string
+
+	// Connections and running commands, so Shutdown can wait for them
+	mu           sync.Mutex
+	listener     net.Listener
+	conns        map[net.Conn]struct{}
+	commands     sync.WaitGroup
+	shuttingDown bool
 }
 
-func NewServer(repoPath string, userService *models.UserService, onUpdate func()) (*Server, error) {
+// NewServer creates the SSH server. onUpdate is called after every push
+// with the repository path and the references the push changed.
+func NewServer(repoPath string, userService *models.UserService, onUpdate func(repoPath string, updates []models.RefUpdate)) (*Server, error) {
 	server := &Server{
 		userService: userService,
 		repoPath:    repoPath,
 		onUpdate:    onUpdate,
+		conns:       make(map[net.Conn]struct{}),
 	}
 
 	// TODO figure out how to default to PublicKeyCallback while allowing KeyboardInteractiveCallback
@@ -53,6 +73,35 @@ func NewServer(repoPath string, userService *models.UserService, onUpdate func()
 	return server, nil
 }
 
+// SetReceiveEnv sets a function returning extra environment for
+// git-receive-pack in a repository, used to enforce branch protection.
+func (s *Server) SetReceiveEnv(fn func(repoPath string) []string) {
+	s.receiveEnv = fn
+}
+
+// SetLFSAuthenticate sets the function answering git-lfs-authenticate. It
+// returns the JSON telling git-lfs where and how to reach the LFS API of
+// the repository at repoPath for operation, download or upload.
+func (s *Server) SetLFSAuthenticate(fn func(username, repoPath, operation string) ([]byte, error)) {
+	s.lfsAuth = fn
+}
+
+// SetAuthorize sets the function deciding whether a user may read, or with
+// write push to, the repository at repoPath. Without one every user with
+// a key may do both.
+func (s *Server) SetAuthorize(fn func(username, repoPath string, write bool) error) {
+	s.authorize = fn
+}
+
+// SetResolveName sets the function returning the current full name of a
+// repository addressed by a former name, so remotes keep working after a
+// rename or transfer.
+func (s *Server) SetResolveName(fn func(name string) string) {
+	s.resolveName = fn
+}
+
+// ListenAndServe accepts connections on addr until Shutdown is called, and
+// then returns ErrServerClosed.
 func (s *Server) ListenAndServe(addr string) error {
 	listener, err := net.Listen("tcp", addr)
 	if err != nil {
@@ -60,17 +109,104 @@ func (s *Server) ListenAndServe(addr string) error {
 	}
 	defer listener.Close()
 
+	s.mu.Lock()
+	if s.shuttingDown {
+		s.mu.Unlock()
+		return ErrServerClosed
+	}
+	s.listener = listener
+	s.mu.Unlock()
+
 	for {
 		conn, err := listener.Accept()
 		if err != nil {
+			if s.closing() {
+				return ErrServerClosed
+			}
 			return fmt.Errorf("failed to accept connection: %w", err)
 		}
 
+		if !s.trackConn(conn) {
+			conn.Close()
+			continue
+		}
 		go s.handleConnection(conn)
 	}
 }
 
+// Shutdown stops accepting connections and commands, waits for the git
+// commands that are running to finish and closes the connections. If ctx
+// ends first the remaining connections are closed, interrupting their
+// commands, and its error is returned.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	s.shuttingDown = true
+	if s.listener != nil {
+		s.listener.Close()
+	}
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.commands.Wait()
+		close(done)
+	}()
+
+	var err error
+	select {
+	case <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	s.mu.Lock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+	return err
+}
+
+// closing reports whether Shutdown was called.
+func (s *Server) closing() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.shuttingDown
+}
+
+// trackConn records an accepted connection until it is closed. It reports
+// false once the server is shutting down.
+func (s *Server) trackConn(conn net.Conn) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.shuttingDown {
+		return false
+	}
+	s.conns[conn] = struct{}{}
+	return true
+}
+
+func (s *Server) forgetConn(conn net.Conn) {
+	s.mu.Lock()
+	delete(s.conns, conn)
+	s.mu.Unlock()
+}
+
+// startCommand records that a command is starting. It reports false once
+// the server is shutting down; the command must then not run. Otherwise
+// the caller calls s.commands.Done when the command has finished.
+func (s *Server) startCommand() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.shuttingDown {
+		return false
+	}
+	s.commands.Add(1)
+	return true
+}
+
 func (s *Server) handleConnection(conn net.Conn) {
+	defer s.forgetConn(conn)
 	defer conn.Close()
 
 	// Perform SSH handshake
@@ -97,7 +233,7 @@ func (s *Server) handleConnection(conn net.Conn) {
 			continue
 		}
 
-		go s.handleChannel(channel, requests)
+		go s.handleChannel(channel, requests, sshConn.Permissions.Extensions["username"])
 	}
 }
 
@@ -0,0 +1,75 @@
+package ssh
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+// TestShutdownWaitsForCommands checks that Shutdown stops the listener and
+// new commands at once but returns only when running commands finished,
+// or when its context ends.
+func TestShutdownWaitsForCommands(t *testing.T) {
+	s := &Server{conns: make(map[net.Conn]struct{})}
+
+	served := make(chan error, 1)
+	go func() { served <- s.ListenAndServe("127.0.0.1:0") }()
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		s.mu.Lock()
+		listening := s.listener != nil
+		s.mu.Unlock()
+		if listening {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the server did not start listening")
+		}
+	}
+
+	if !s.startCommand() {
+		t.Fatal("startCommand() = false before Shutdown")
+	}
+	shutdown := make(chan error, 1)
+	go func() { shutdown <- s.Shutdown(context.Background()) }()
+
+	if err := <-served; !errors.Is(err, ErrServerClosed) {
+		t.Errorf("ListenAndServe() = %v, want %v", err, ErrServerClosed)
+	}
+	if s.startCommand() {
+		t.Error("startCommand() = true during Shutdown")
+	}
+	select {
+	case err := <-shutdown:
+		t.Fatalf("Shutdown() = %v while a command is running", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	s.commands.Done()
+	select {
+	case err := <-shutdown:
+		if err != nil {
+			t.Errorf("Shutdown() = %v, want nil", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Shutdown() did not return after the command finished")
+	}
+}
+
+func TestShutdownDeadline(t *testing.T) {
+	s := &Server{conns: make(map[net.Conn]struct{})}
+	if !s.startCommand() {
+		t.Fatal("startCommand() = false before Shutdown")
+	}
+	defer s.commands.Done()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := s.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Shutdown() = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if err := s.ListenAndServe("127.0.0.1:0"); !errors.Is(err, ErrServerClosed) {
+		t.Errorf("ListenAndServe() after Shutdown = %v, want %v", err, ErrServerClosed)
+	}
+}
@@ -4,17 +4,9 @@ package utils
 
 import (
 	"path/filepath"
-	"regexp"
 	"strings"
 )
 
-type Symbol struct {
-	Name string
-	Type string
-	Icon string
-	Line int
-}
-
 func IsBinaryFile(content []byte) bool {
 	size := len(content)
 	if size > 512 {
@@ -29,84 +21,6 @@ func IsBinaryFile(content []byte) bool {
 	return false
 }
 
-func ParseSymbols(content []byte) []Symbol {
-	var symbols []Symbol
-	lines := strings.Split(string(content), "\n")
-
-	// Common patterns across languages
-	patterns := []struct {
-		regex string
-		typ   string
-		icon  string
-	}{
-		// Functions - catches async, static, public, private, etc.
-		{`^[\s]*(?:async\s+)?(?:static\s+)?(?:public\s+)?(?:private\s+)?(?:protected\s+)?(?:func|function)\s+(?:\([^)]*\)\s+)?(\w+)`, "function", "ƒ"},
-
-		// Arrow functions with explicit name
-		{`^[\s]*(?:export\s+)?(?:const|let|var)\s+(\w+)\s*=\s*(?:async\s+)?\(.*?\)\s*=>`, "function", "ƒ"},
-
-		// Classes/types
-		{`^[\s]*(?:export\s+)?(?:abstract\s+)?(?:class|type)\s+(\w+)`, "class", "◇"},
-
-		// Interfaces
-		{`^[\s]*(?:export\s+)?interface\s+(\w+)`, "interface", "⬡"},
-
-		// Constants
-		{`^[\s]*(?:export\s+)?(?:const|final)\s+(\w+)`, "constant", "□"},
-
-		// Variables
-		{`^[\s]*(?:export\s+)?(?:var|let|private|public|protected)\s+(\w+)`, "variable", "○"},
-		{`^[\s]*(\w+(?:\s*,\s*\w+)*)\s*:=`, "variable", "○"},
-
-		// Methods
-		{`^[\s]*(?:async\s+)?(?:static\s+)?(?:public\s+)?(?:private\s+)?(?:protected\s+)?(?:def|method)\s+(\w+)`, "method", "⌘"},
-
-		// YAML keys (top level)
-		{`^(\w+):(?:\s|$)`, "property", "⚑"},
-
-		// YAML anchors
-		{`^[\s]*&(\w+)\b`, "anchor", "⚓"},
-
-		// JSON properties (with quotes)
-		{`^[\s]*"(\w+)"\s*:`, "property", "⚑"},
-
-		// JSON/YAML nested objects
-		{`^[\s]*"?(\w+)"?\s*:\s*{`, "object", "⬡"},
-
-		// JSON/YAML arrays
-		{`^[\s]*"?(\w+)"?\s*:\s*\[`, "array", "▤"},
-	}
-
-	for lineNum, line := range lines {
-		for _, pattern := range patterns {
-			re := regexp.MustCompile(pattern.regex)
-			if matches := re.FindStringSubmatch(line); matches != nil {
-				if pattern.typ == "variable" && strings.Contains(matches[1], ",") {
-					// Split multiple variable declarations
-					vars := strings.Split(matches[1], ",")
-					for _, v := range vars {
-						symbols = append(symbols, Symbol{
-							Name: strings.TrimSpace(v),
-							Type: pattern.typ,
-							Icon: pattern.icon,
-							Line: lineNum + 1,
-						})
-					}
-				} else {
-					symbols = append(symbols, Symbol{
-						Name: matches[1],
-						Type: pattern.typ,
-						Icon: pattern.icon,
-						Line: lineNum + 1,
-					})
-				}
-			}
-		}
-	}
-
-	return symbols
-}
-
 func GetFileIcon(name string) string {
 	ext := strings.ToLower(filepath.Ext(name))
 	switch ext {
@@ -0,0 +1,134 @@
+//utils/issue_refs.go
+
+package utils
+
+import (
+	"html/template"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gomarkdown/markdown/ast"
+)
+
+// issueRefPattern matches #123 references that are not part of a word,
+// path, URL fragment or HTML entity.
+var issueRefPattern = regexp.MustCompile(`(?:^|[^\w/#&])(#(\d+))\b`)
+
+// closingRefPattern matches "fixes #12" style closing keywords.
+var closingRefPattern = regexp.MustCompile(`(?i)\b(?:close[sd]?|fix(?:e[sd])?|resolve[sd]?)\s*:?\s+#(\d+)\b`)
+
+// issueRefMatches returns the [start, end, number] of every reference in text.
+func issueRefMatches(text string) [][3]int {
+	var matches [][3]int
+	for _, m := range issueRefPattern.FindAllStringSubmatchIndex(text, -1) {
+		n, err := strconv.Atoi(text[m[4]:m[5]])
+		if err != nil || n == 0 {
+			continue
+		}
+		matches = append(matches, [3]int{m[2], m[3], n})
+	}
+	return matches
+}
+
+// IssueReferences returns the issue numbers referenced as #123 in text.
+func IssueReferences(text string) []int {
+	var numbers []int
+	for _, m := range issueRefMatches(text) {
+		numbers = append(numbers, m[2])
+	}
+	return numbers
+}
+
+// ClosingReferences returns the issue numbers a commit message closes
+// with a keyword such as "fixes #12", "closes #3" or "resolved #7".
+func ClosingReferences(message string) []int {
+	var numbers []int
+	seen := make(map[int]bool)
+	for _, m := range closingRefPattern.FindAllStringSubmatch(message, -1) {
+		n, err := strconv.Atoi(m[1])
+		if err != nil || n == 0 || seen[n] {
+			continue
+		}
+		seen[n] = true
+		numbers = append(numbers, n)
+	}
+	return numbers
+}
+
+// LinkIssueReferences escapes text for HTML and turns every #123 reference
+// that resolve returns a URL for into a link.
+func LinkIssueReferences(text string, resolve func(number int) string) template.HTML {
+	var b strings.Builder
+	pos := 0
+	for _, m := range issueRefMatches(text) {
+		url := resolve(m[2])
+		if url == "" {
+			continue
+		}
+		b.WriteString(template.HTMLEscapeString(text[pos:m[0]]))
+		b.WriteString(`<a href="` + template.HTMLEscapeString(url) + `" class="issue-ref">`)
+		b.WriteString(template.HTMLEscapeString(text[m[0]:m[1]]))
+		b.WriteString("</a>")
+		pos = m[1]
+	}
+	b.WriteString(template.HTMLEscapeString(text[pos:]))
+	return template.HTML(b.String())
+}
+
+// linkMarkdownIssueRefs replaces #123 references in the text of a parsed
+// markdown document with links. Text inside links is left alone.
+func linkMarkdownIssueRefs(doc ast.Node, resolve func(number int) string) {
+	var texts []*ast.Text
+	ast.WalkFunc(doc, func(node ast.Node, entering bool) ast.WalkStatus {
+		if !entering {
+			return ast.GoToNext
+		}
+		switch n := node.(type) {
+		case *ast.Link, *ast.Image:
+			return ast.SkipChildren
+		case *ast.Text:
+			texts = append(texts, n)
+		}
+		return ast.GoToNext
+	})
+
+	for _, text := range texts {
+		literal := string(text.Literal)
+		var nodes []ast.Node
+		pos := 0
+		for _, m := range issueRefMatches(literal) {
+			url := resolve(m[2])
+			if url == "" {
+				continue
+			}
+			if m[0] > pos {
+				nodes = append(nodes, &ast.Text{Leaf: ast.Leaf{Literal: []byte(literal[pos:m[0]])}})
+			}
+			link := &ast.Link{Destination: []byte(url)}
+			ast.AppendChild(link, &ast.Text{Leaf: ast.Leaf{Literal: []byte(literal[m[0]:m[1]])}})
+			nodes = append(nodes, link)
+			pos = m[1]
+		}
+		if nodes == nil {
+			continue
+		}
+		if pos < len(literal) {
+			nodes = append(nodes, &ast.Text{Leaf: ast.Leaf{Literal: []byte(literal[pos:])}})
+		}
+
+		parent := text.GetParent()
+		var children []ast.Node
+		for _, child := range parent.GetChildren() {
+			if child != ast.Node(text) {
+				children = append(children, child)
+				continue
+			}
+			for _, node := range nodes {
+				node.SetParent(parent)
+				children = append(children, node)
+			}
+		}
+		parent.SetChildren(children)
+	}
+}
@@ -0,0 +1,72 @@
+//utils/issue_refs_test.go
+
+package utils
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestIssueReferences(t *testing.T) {
+	tests := []struct {
+		text string
+		want []int
+	}{
+		{"see #12 and #3", []int{12, 3}},
+		{"#7 at the start", []int{7}},
+		{"(#5)", []int{5}},
+		{"issue#4, a/#9, http://host/#10, &#38; and ##11", nil},
+		{"#0 is not an issue", nil},
+	}
+	for _, tt := range tests {
+		if got := IssueReferences(tt.text); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("IssueReferences(%q) = %v, want %v", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestClosingReferences(t *testing.T) {
+	tests := []struct {
+		message string
+		want    []int
+	}{
+		{"Fix the parser\n\nFixes #12", []int{12}},
+		{"closes #1, resolved #2 and fix: #3", []int{1, 2, 3}},
+		{"Fixed #4 and fixes #4 again", []int{4}},
+		{"Refers to #5", nil},
+		{"prefixes #6", nil},
+	}
+	for _, tt := range tests {
+		if got := ClosingReferences(tt.message); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("ClosingReferences(%q) = %v, want %v", tt.message, got, tt.want)
+		}
+	}
+}
+
+func TestLinkIssueReferences(t *testing.T) {
+	resolve := func(number int) string {
+		if number == 404 {
+			return ""
+		}
+		return fmt.Sprintf("/issues/alice/demo/%d", number)
+	}
+
+	got := LinkIssueReferences("<b> fixes #1, not #404", resolve)
+	want := `&lt;b&gt; fixes <a href="/issues/alice/demo/1" class="issue-ref">#1</a>, not #404`
+	if string(got) != want {
+		t.Errorf("LinkIssueReferences() = %s, want %s", got, want)
+	}
+
+	html := MarkdownToHTML([]byte("See #2, [#3](https://example.com) and `#4`.\n"), &MarkdownLinks{
+		Repo:   "alice/demo",
+		Branch: "main",
+		Issue:  resolve,
+	})
+	if !strings.Contains(html, `<a href="/issues/alice/demo/2">#2</a>`) {
+		t.Errorf("#2 is not linked in %s", html)
+	}
+	if strings.Contains(html, "/issues/alice/demo/3") || strings.Contains(html, "/issues/alice/demo/4") {
+		t.Errorf("references inside links or code are linked in %s", html)
+	}
+}
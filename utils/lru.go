@@ -0,0 +1,123 @@
+//utils/lru.go
+
+package utils
+
+import (
+	"container/list"
+	"sync"
+)
+
+// LRU is a size-bounded least-recently-used cache.
+//
+// Every entry has a cost in bytes as reported by sizeOf. When the total cost
+// exceeds maxBytes the least recently used entries are evicted until it fits
+// again. OnEvict, when set, is called for every evicted entry while the
+// cache lock is held, so it must not call back into the cache.
+type LRU[K comparable, V any] struct {
+	mu       sync.Mutex
+	maxBytes int64
+	curBytes int64
+	ll       *list.List
+	items    map[K]*list.Element
+	sizeOf   func(K, V) int64
+	OnEvict  func(K, V)
+}
+
+type lruEntry[K comparable, V any] struct {
+	key   K
+	value V
+	size  int64
+}
+
+// NewLRU creates an LRU holding at most maxBytes worth of entries.
+//
+// Parameters:
+//   - maxBytes: The maximum total size of all entries.
+//   - sizeOf: Returns the size of a single entry in bytes.
+func NewLRU[K comparable, V any](maxBytes int64, sizeOf func(K, V) int64) *LRU[K, V] {
+	return &LRU[K, V]{
+		maxBytes: maxBytes,
+		ll:       list.New(),
+		items:    make(map[K]*list.Element),
+		sizeOf:   sizeOf,
+	}
+}
+
+// Get returns the value stored under key and marks it as recently used.
+func (c *LRU[K, V]) Get(key K) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		c.ll.MoveToFront(el)
+		return el.Value.(*lruEntry[K, V]).value, true
+	}
+
+	var zero V
+	return zero, false
+}
+
+// Add stores value under key, evicting older entries if needed. Entries
+// larger than the whole cache are not stored, and any older value under
+// key is dropped so it is not served in their place.
+func (c *LRU[K, V]) Add(key K, value V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	size := c.sizeOf(key, value)
+	if size > c.maxBytes {
+		if el, ok := c.items[key]; ok {
+			c.removeElement(el, false)
+		}
+		return
+	}
+
+	if el, ok := c.items[key]; ok {
+		entry := el.Value.(*lruEntry[K, V])
+		c.curBytes += size - entry.size
+		entry.value = value
+		entry.size = size
+		c.ll.MoveToFront(el)
+	} else {
+		c.items[key] = c.ll.PushFront(&lruEntry[K, V]{key: key, value: value, size: size})
+		c.curBytes += size
+	}
+
+	for c.curBytes > c.maxBytes {
+		c.removeElement(c.ll.Back(), true)
+	}
+}
+
+// Remove deletes key from the cache without calling OnEvict.
+func (c *LRU[K, V]) Remove(key K) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		c.removeElement(el, false)
+	}
+}
+
+// Len returns the number of entries in the cache.
+func (c *LRU[K, V]) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ll.Len()
+}
+
+// Bytes returns the total size of all entries in the cache.
+func (c *LRU[K, V]) Bytes() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.curBytes
+}
+
+func (c *LRU[K, V]) removeElement(el *list.Element, evicted bool) {
+	entry := el.Value.(*lruEntry[K, V])
+	c.ll.Remove(el)
+	delete(c.items, entry.key)
+	c.curBytes -= entry.size
+	if evicted && c.OnEvict != nil {
+		c.OnEvict(entry.key, entry.value)
+	}
+}
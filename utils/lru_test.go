@@ -0,0 +1,81 @@
+//utils/lru_test.go
+
+package utils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func newTestLRU(maxBytes int64) (*LRU[string, string], *[]string) {
+	c := NewLRU(maxBytes, func(_ string, v string) int64 { return int64(len(v)) })
+	evicted := &[]string{}
+	c.OnEvict = func(k string, _ string) { *evicted = append(*evicted, k) }
+	return c, evicted
+}
+
+func TestLRUEvictsLeastRecentlyUsed(t *testing.T) {
+	c, evicted := newTestLRU(10)
+	c.Add("a", "aaaa")
+	c.Add("b", "bbbb")
+	c.Get("a")
+	c.Add("c", "cccc")
+
+	if _, ok := c.Get("b"); ok {
+		t.Error("b is still cached")
+	}
+	for _, k := range []string{"a", "c"} {
+		if _, ok := c.Get(k); !ok {
+			t.Errorf("%s was evicted", k)
+		}
+	}
+	if !reflect.DeepEqual(*evicted, []string{"b"}) {
+		t.Errorf("evicted %v, want [b]", *evicted)
+	}
+	if c.Len() != 2 || c.Bytes() != 8 {
+		t.Errorf("Len() = %d, Bytes() = %d; want 2 and 8", c.Len(), c.Bytes())
+	}
+}
+
+func TestLRUAdd(t *testing.T) {
+	tests := []struct {
+		name      string
+		value     string
+		want      string
+		cached    bool
+		wantBytes int64
+	}{
+		{"replace", "new", "new", true, 7},
+		{"grow past the limit", "0123456789", "0123456789", true, 10},
+		{"larger than the cache", "0123456789a", "", false, 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := newTestLRU(10)
+			c.Add("k", "old")
+			c.Add("other", "oooo")
+			c.Add("k", tt.value)
+
+			got, ok := c.Get("k")
+			if ok != tt.cached || got != tt.want {
+				t.Errorf("Get(k) = %q, %v; want %q, %v", got, ok, tt.want, tt.cached)
+			}
+			if c.Bytes() != tt.wantBytes {
+				t.Errorf("Bytes() = %d, want %d", c.Bytes(), tt.wantBytes)
+			}
+		})
+	}
+}
+
+func TestLRURemove(t *testing.T) {
+	c, evicted := newTestLRU(10)
+	c.Add("a", "aaaa")
+	c.Remove("a")
+	c.Remove("missing")
+	if _, ok := c.Get("a"); ok || c.Len() != 0 || c.Bytes() != 0 {
+		t.Errorf("a is still cached: Len() = %d, Bytes() = %d", c.Len(), c.Bytes())
+	}
+	if len(*evicted) != 0 {
+		t.Errorf("Remove called OnEvict for %v", *evicted)
+	}
+}
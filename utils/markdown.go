@@ -1,21 +1,165 @@
 package utils
 
 import (
+	"net/url"
+	"path"
+	"strings"
+
 	"github.com/gomarkdown/markdown"
+	"github.com/gomarkdown/markdown/ast"
 	"github.com/gomarkdown/markdown/html"
 	"github.com/gomarkdown/markdown/parser"
 )
 
-func markdownToHTML(mdString string) string {
-	md := []byte(mdString)
+// MarkdownLinks describes where a markdown document lives so relative links
+// and images can be rewritten to point into the repository browser.
+//
+// Parameters:
+//   - Repo: The repository name.
+//   - Branch: The branch the document was read from.
+//   - Dir: The directory containing the document, relative to the repo root.
+//   - IsDir: Optional; reports whether a repo path is a directory.
+//   - Issue: Optional; returns the URL of #number references, or "".
+type MarkdownLinks struct {
+	Repo   string
+	Branch string
+	Dir    string
+	IsDir  func(path string) bool
+	Issue  func(number int) string
+}
 
+// IsMarkdownFile reports whether name should be rendered as markdown.
+func IsMarkdownFile(name string) bool {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".md", ".markdown", ".mdown", ".mkd":
+		return true
+	}
+	return false
+}
+
+// MarkdownToHTML renders markdown to HTML that is safe to embed in a page.
+//
+// Raw HTML in the document is dropped and only http(s), mailto and
+// repository-relative links are kept. When links is non-nil, relative link
+// targets are rewritten to /file/ (or /repo/ for directories) and relative
+// image sources to /raw/ URLs, and #123 references are linked if
+// links.Issue knows them.
+func MarkdownToHTML(md []byte, links *MarkdownLinks) string {
 	extensions := parser.CommonExtensions | parser.AutoHeadingIDs
-	parser := parser.NewWithExtensions(extensions)
-	doc := parser.Parse(md)
+	p := parser.NewWithExtensions(extensions)
+	doc := p.Parse(md)
+
+	ast.WalkFunc(doc, func(node ast.Node, entering bool) ast.WalkStatus {
+		if !entering {
+			return ast.GoToNext
+		}
+		switch n := node.(type) {
+		case *ast.Link:
+			n.Destination = []byte(rewriteMarkdownURL(string(n.Destination), links, false))
+		case *ast.Image:
+			n.Destination = []byte(rewriteMarkdownURL(string(n.Destination), links, true))
+		}
+		return ast.GoToNext
+	})
 
-	htmlFlags := html.CommonFlags | html.HrefTargetBlank
+	if links != nil && links.Issue != nil {
+		linkMarkdownIssueRefs(doc, links.Issue)
+	}
+
+	htmlFlags := html.CommonFlags | html.HrefTargetBlank | html.SkipHTML | html.Safelink
 	opts := html.RendererOptions{Flags: htmlFlags}
 	renderer := html.NewRenderer(opts)
+	renderer.IsSafeURLOverride = func(dest []byte) bool {
+		return isSafeMarkdownURL(string(dest))
+	}
 
 	return string(markdown.Render(doc, renderer))
 }
+
+// isSafeMarkdownURL allows fragments, server-relative paths and a small set
+// of schemes. Everything else (javascript:, data:, vbscript:...) is dropped.
+func isSafeMarkdownURL(dest string) bool {
+	if dest == "" {
+		return false
+	}
+	// Browsers read "/\" like "//", the start of another host
+	if strings.HasPrefix(dest, "#") || (strings.HasPrefix(dest, "/") && !strings.HasPrefix(dest, "//") && !strings.HasPrefix(dest, "/\\")) {
+		return true
+	}
+
+	u, err := url.Parse(dest)
+	if err != nil {
+		return false
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "http", "https", "mailto":
+		return true
+	}
+	return false
+}
+
+func rewriteMarkdownURL(dest string, links *MarkdownLinks, image bool) string {
+	dest = strings.TrimSpace(dest)
+	if dest == "" || strings.HasPrefix(dest, "#") {
+		return dest
+	}
+
+	u, err := url.Parse(dest)
+	if err != nil {
+		return "#"
+	}
+
+	// Absolute URLs are left alone if safe and neutralised otherwise
+	if u.Scheme != "" || u.Host != "" {
+		if !isSafeMarkdownURL(dest) {
+			return "#"
+		}
+		return dest
+	}
+
+	if links == nil {
+		return dest
+	}
+
+	target := u.Path
+	if strings.HasPrefix(target, "/") {
+		target = path.Clean(strings.TrimPrefix(target, "/"))
+	} else {
+		target = path.Clean(path.Join(links.Dir, target))
+	}
+	if target == "." {
+		target = ""
+	}
+	if target == ".." || strings.HasPrefix(target, "../") {
+		// Points outside the repository
+		return "#"
+	}
+
+	prefix := "/file/"
+	switch {
+	case image:
+		prefix = "/raw/"
+	case target == "" || (links.IsDir != nil && links.IsDir(target)):
+		prefix = "/repo/"
+	}
+
+	out := prefix + escapePath(links.Repo)
+	if target != "" {
+		out += "/" + escapePath(target)
+	}
+	if links.Branch != "" {
+		out += "?branch=" + url.QueryEscape(links.Branch)
+	}
+	if u.Fragment != "" {
+		out += "#" + url.PathEscape(u.Fragment)
+	}
+	return out
+}
+
+func escapePath(p string) string {
+	parts := strings.Split(p, "/")
+	for i, part := range parts {
+		parts[i] = url.PathEscape(part)
+	}
+	return strings.Join(parts, "/")
+}
@@ -0,0 +1,79 @@
+//utils/markdown_test.go
+
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestIsSafeMarkdownURL(t *testing.T) {
+	tests := []struct {
+		dest string
+		want bool
+	}{
+		{"", false},
+		{"#usage", true},
+		{"/alice/demo", true},
+		{"https://example.com/a?b=c", true},
+		{"HTTP://example.com", true},
+		{"mailto:alice@example.com", true},
+		{"//example.com/evil", false},
+		{`/\example.com/evil`, false},
+		{"javascript:alert(1)", false},
+		{"JavaScript:alert(1)", false},
+		{" javascript:alert(1)", false},
+		{"data:text/html;base64,PHNjcmlwdD4=", false},
+		{"vbscript:msgbox", false},
+		{"ftp://example.com/file", false},
+		{"docs/README.md", false},
+	}
+	for _, tt := range tests {
+		if got := isSafeMarkdownURL(tt.dest); got != tt.want {
+			t.Errorf("isSafeMarkdownURL(%q) = %v, want %v", tt.dest, got, tt.want)
+		}
+	}
+}
+
+func TestRewriteMarkdownURL(t *testing.T) {
+	links := &MarkdownLinks{
+		Repo:   "alice/demo",
+		Branch: "main",
+		Dir:    "docs",
+		IsDir:  func(p string) bool { return p == "docs/guide" },
+	}
+	tests := []struct {
+		dest  string
+		image bool
+		want  string
+	}{
+		{"#usage", false, "#usage"},
+		{"https://example.com", false, "https://example.com"},
+		{"javascript:alert(1)", false, "#"},
+		{"install.md", false, "/file/alice/demo/docs/install.md?branch=main"},
+		{"install.md#linux", false, "/file/alice/demo/docs/install.md?branch=main#linux"},
+		{"guide", false, "/repo/alice/demo/docs/guide?branch=main"},
+		{"../LICENSE", false, "/file/alice/demo/LICENSE?branch=main"},
+		{"/src/main.go", false, "/file/alice/demo/src/main.go?branch=main"},
+		{"..", false, "/repo/alice/demo?branch=main"},
+		{"../../etc/passwd", false, "#"},
+		{"../..notes.md", false, "/file/alice/demo/..notes.md?branch=main"},
+		{"..notes.md", false, "/file/alice/demo/docs/..notes.md?branch=main"},
+		{"logo.png", true, "/raw/alice/demo/docs/logo.png?branch=main"},
+		{"my file.md", false, "/file/alice/demo/docs/my%20file.md?branch=main"},
+	}
+	for _, tt := range tests {
+		if got := rewriteMarkdownURL(tt.dest, links, tt.image); got != tt.want {
+			t.Errorf("rewriteMarkdownURL(%q, image=%v) = %q, want %q", tt.dest, tt.image, got, tt.want)
+		}
+	}
+}
+
+func TestMarkdownToHTMLDropsUnsafeContent(t *testing.T) {
+	out := MarkdownToHTML([]byte(`[x](javascript:alert(1)) <script>alert(1)</script> ![y](data:image/png;base64,AA) [z](/\\example.com)`), nil)
+	for _, bad := range []string{"javascript:", "<script", "data:", "example.com"} {
+		if strings.Contains(out, bad) {
+			t.Errorf("rendered markdown contains %q: %s", bad, out)
+		}
+	}
+}
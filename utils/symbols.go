@@ -0,0 +1,407 @@
+//utils/symbols.go
+
+package utils
+
+import (
+	"bytes"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Symbol is a named declaration found in a source file.
+//
+// Parameters:
+//   - Line, EndLine: The 1-based line range the declaration spans.
+//   - Depth: How many enclosing symbols the declaration is nested in.
+//   - Parent: The name of the innermost enclosing symbol, if any.
+//   - Receiver: The receiver type of a Go method, if any.
+type Symbol struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Icon     string `json:"icon"`
+	Line     int    `json:"line"`
+	EndLine  int    `json:"end_line"`
+	Depth    int    `json:"depth"`
+	Parent   string `json:"parent,omitempty"`
+	Receiver string `json:"receiver,omitempty"`
+}
+
+// SymbolExtractor finds the declarations in a single file.
+type SymbolExtractor interface {
+	Extract(content []byte) ([]Symbol, error)
+}
+
+// Icons used for each symbol type in the outline.
+var symbolIcons = map[string]string{
+	"function":  "ƒ",
+	"method":    "⌘",
+	"class":     "◇",
+	"interface": "⬡",
+	"constant":  "□",
+	"variable":  "○",
+	"field":     "▫",
+	"property":  "⚑",
+	"anchor":    "⚓",
+	"object":    "⬡",
+	"array":     "▤",
+}
+
+var symbolExtractors = map[string]SymbolExtractor{}
+
+// RegisterSymbolExtractor registers ex for files with the given extensions
+// (without the leading dot). Later registrations replace earlier ones.
+func RegisterSymbolExtractor(ex SymbolExtractor, exts ...string) {
+	for _, ext := range exts {
+		symbolExtractors[strings.ToLower(ext)] = ex
+	}
+}
+
+// ParseSymbols extracts the symbols declared in a file, using the extractor
+// registered for its extension and a generic pattern set otherwise. The
+// result is ordered by line with Depth and Parent filled in from the line
+// ranges.
+func ParseSymbols(filename string, content []byte) []Symbol {
+	ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(filename), "."))
+
+	var symbols []Symbol
+	if ex, ok := symbolExtractors[ext]; ok {
+		if found, err := ex.Extract(content); err == nil {
+			symbols = found
+		}
+	}
+	if symbols == nil {
+		symbols, _ = genericSymbols.Extract(content)
+	}
+
+	for i := range symbols {
+		if symbols[i].Icon == "" {
+			symbols[i].Icon = symbolIcons[symbols[i].Type]
+		}
+		if symbols[i].EndLine < symbols[i].Line {
+			symbols[i].EndLine = symbols[i].Line
+		}
+	}
+
+	return nestSymbols(symbols)
+}
+
+// nestSymbols sorts symbols by position and derives nesting from their line
+// ranges: a symbol is a child of the closest earlier symbol whose range
+// contains it.
+func nestSymbols(symbols []Symbol) []Symbol {
+	sort.SliceStable(symbols, func(i, j int) bool {
+		if symbols[i].Line != symbols[j].Line {
+			return symbols[i].Line < symbols[j].Line
+		}
+		return symbols[i].EndLine > symbols[j].EndLine
+	})
+
+	var stack []int
+	for i := range symbols {
+		sym := &symbols[i]
+		for len(stack) > 0 {
+			top := symbols[stack[len(stack)-1]]
+			if top.Line < sym.Line && sym.EndLine <= top.EndLine {
+				break
+			}
+			stack = stack[:len(stack)-1]
+		}
+
+		sym.Depth = len(stack)
+		if len(stack) > 0 {
+			parent := symbols[stack[len(stack)-1]]
+			sym.Parent = parent.Name
+			// Functions declared inside a class body are its methods
+			if sym.Type == "function" && parent.Type == "class" {
+				sym.Type = "method"
+				sym.Icon = symbolIcons["method"]
+			}
+		}
+		if sym.EndLine > sym.Line {
+			stack = append(stack, i)
+		}
+	}
+
+	return symbols
+}
+
+// blockStyle tells a regexExtractor how to find where a declaration ends.
+type blockStyle int
+
+const (
+	blockNone     blockStyle = iota // Declarations span a single line
+	blockBraces                     // Bodies are delimited by { }
+	blockBrackets                   // Bodies are delimited by { } or [ ]
+	blockIndent                     // Bodies are the following, deeper indented lines
+)
+
+type symbolPattern struct {
+	re  *regexp.Regexp
+	typ string
+}
+
+// regexExtractor matches precompiled, line-anchored patterns against every
+// line after comments and string literals have been blanked out, so that
+// declarations inside them are not reported.
+type regexExtractor struct {
+	patterns []symbolPattern
+	blank    func([]byte) []byte
+	block    blockStyle
+}
+
+func newPatterns(defs ...[2]string) []symbolPattern {
+	patterns := make([]symbolPattern, len(defs))
+	for i, def := range defs {
+		patterns[i] = symbolPattern{re: regexp.MustCompile(def[0]), typ: def[1]}
+	}
+	return patterns
+}
+
+func (e *regexExtractor) Extract(content []byte) ([]Symbol, error) {
+	text := content
+	if e.blank != nil {
+		text = e.blank(content)
+	}
+	lines := strings.Split(string(text), "\n")
+
+	var symbols []Symbol
+	for lineNum, line := range lines {
+		for _, pattern := range e.patterns {
+			matches := pattern.re.FindStringSubmatch(line)
+			if matches == nil {
+				continue
+			}
+
+			end := lineNum + 1
+			switch e.block {
+			case blockBraces, blockBrackets:
+				end = braceBlockEnd(lines, lineNum, e.block == blockBrackets)
+			case blockIndent:
+				end = indentBlockEnd(lines, lineNum)
+			}
+
+			// Split multiple variable declarations
+			for _, name := range strings.Split(matches[1], ",") {
+				symbols = append(symbols, Symbol{
+					Name:    strings.TrimSpace(name),
+					Type:    pattern.typ,
+					Line:    lineNum + 1,
+					EndLine: end,
+				})
+			}
+			break
+		}
+	}
+
+	return symbols, nil
+}
+
+// braceBlockEnd returns the 1-based line on which the block opened on line
+// start is closed. The opening brace may also start the following line.
+// Declarations without a body end on their own line. With brackets set,
+// [ ] delimit blocks as well.
+func braceBlockEnd(lines []string, start int, brackets bool) int {
+	depth := 0
+	for i := start; i < len(lines); i++ {
+		if depth == 0 && i > start && (i > start+1 || !strings.HasPrefix(strings.TrimSpace(lines[i]), "{")) {
+			break
+		}
+		for _, c := range lines[i] {
+			switch {
+			case c == '{' || (brackets && c == '['):
+				depth++
+			case c == '}' || (brackets && c == ']'):
+				depth--
+				if depth == 0 {
+					return i + 1
+				}
+			case c == ';' && depth == 0:
+				return start + 1
+			}
+		}
+	}
+	return start + 1
+}
+
+// indentBlockEnd returns the last 1-based line that is indented deeper than
+// line start, ignoring blank lines.
+func indentBlockEnd(lines []string, start int) int {
+	indent := indentOf(lines[start])
+	end := start
+	for i := start + 1; i < len(lines); i++ {
+		if strings.TrimSpace(lines[i]) == "" {
+			continue
+		}
+		if indentOf(lines[i]) <= indent {
+			break
+		}
+		end = i
+	}
+	return end + 1
+}
+
+func indentOf(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " \t"))
+}
+
+// blankCLike blanks out //, /* */ comments and string literals in C-like
+// languages. Newlines are kept so line numbers stay valid.
+func blankCLike(src []byte) []byte {
+	out := bytes.Clone(src)
+	for i := 0; i < len(out); i++ {
+		switch {
+		case out[i] == '/' && i+1 < len(out) && out[i+1] == '/':
+			for ; i < len(out) && out[i] != '\n'; i++ {
+				out[i] = ' '
+			}
+		case out[i] == '/' && i+1 < len(out) && out[i+1] == '*':
+			end := bytes.Index(out[i+2:], []byte("*/"))
+			stop := len(out)
+			if end >= 0 {
+				stop = i + 2 + end + 2
+			}
+			blankRange(out, i, stop)
+			i = stop - 1
+		case out[i] == '`':
+			i = blankQuoted(out, i, '`', true)
+		case out[i] == '"' || out[i] == '\'':
+			i = blankQuoted(out, i, out[i], false)
+		}
+	}
+	return out
+}
+
+// blankHash blanks out # comments, triple-quoted strings and string
+// literals in Python-like languages.
+func blankHash(src []byte) []byte {
+	out := bytes.Clone(src)
+	for i := 0; i < len(out); i++ {
+		switch {
+		case out[i] == '#':
+			for ; i < len(out) && out[i] != '\n'; i++ {
+				out[i] = ' '
+			}
+		case bytes.HasPrefix(out[i:], []byte(`"""`)) || bytes.HasPrefix(out[i:], []byte(`'''`)):
+			delim := out[i : i+3]
+			end := bytes.Index(out[i+3:], delim)
+			stop := len(out)
+			if end >= 0 {
+				stop = i + 3 + end + 3
+			}
+			blankRange(out, i, stop)
+			i = stop - 1
+		case out[i] == '"' || out[i] == '\'':
+			i = blankQuoted(out, i, out[i], false)
+		}
+	}
+	return out
+}
+
+// blankQuoted blanks the string literal starting at out[start] and returns
+// the index of its closing quote. Unless multiline is set, literals end at
+// the end of the line.
+func blankQuoted(out []byte, start int, quote byte, multiline bool) int {
+	i := start + 1
+	for ; i < len(out); i++ {
+		if out[i] == '\\' && quote != '`' {
+			out[i] = ' '
+			i++
+			if i < len(out) && out[i] != '\n' {
+				out[i] = ' '
+			}
+			continue
+		}
+		if out[i] == quote {
+			break
+		}
+		if out[i] == '\n' {
+			if !multiline {
+				return i
+			}
+			continue
+		}
+		out[i] = ' '
+	}
+	return i
+}
+
+func blankRange(out []byte, from, to int) {
+	for i := from; i < to && i < len(out); i++ {
+		if out[i] != '\n' {
+			out[i] = ' '
+		}
+	}
+}
+
+var cLikePatterns = newPatterns(
+	// Functions - catches async, static, public, private, etc.
+	[2]string{`^[\s]*(?:export\s+)?(?:default\s+)?(?:async\s+)?(?:static\s+)?(?:public\s+)?(?:private\s+)?(?:protected\s+)?(?:pub\s+)?(?:func|function|fn)\s+(?:\([^)]*\)\s+)?(\w+)`, "function"},
+	// Arrow functions with explicit name
+	[2]string{`^[\s]*(?:export\s+)?(?:const|let|var)\s+(\w+)\s*=\s*(?:async\s+)?(?:\([^)]*\)|\w+)\s*=>`, "function"},
+	// Classes/types
+	[2]string{`^[\s]*(?:export\s+)?(?:default\s+)?(?:public\s+)?(?:abstract\s+)?(?:final\s+)?(?:pub\s+)?(?:class|struct|enum|type|trait|impl)\s+(\w+)`, "class"},
+	// Interfaces
+	[2]string{`^[\s]*(?:export\s+)?(?:public\s+)?interface\s+(\w+)`, "interface"},
+	// Constants
+	[2]string{`^[\s]*(?:export\s+)?(?:pub\s+)?(?:const|final)\s+(\w+)`, "constant"},
+	// Variables
+	[2]string{`^[\s]*(?:export\s+)?(?:var|let)\s+(\w+)`, "variable"},
+	// Class methods: name(args) {
+	[2]string{`^[\s]+(?:async\s+)?(?:static\s+)?(?:public\s+|private\s+|protected\s+)?(?:get\s+|set\s+)?([A-Za-z_]\w*)\s*\([^;]*\)\s*(?::\s*[^{=;]+)?\{\s*$`, "method"},
+)
+
+var pythonPatterns = newPatterns(
+	[2]string{`^[\s]*class\s+(\w+)`, "class"},
+	[2]string{`^[\s]*(?:async\s+)?def\s+(\w+)`, "function"},
+	[2]string{`^([A-Z][A-Z0-9_]*)\s*=`, "constant"},
+)
+
+var rubyPatterns = newPatterns(
+	[2]string{`^[\s]*(?:class|module)\s+([\w:]+)`, "class"},
+	[2]string{`^[\s]*def\s+(?:self\.)?(\w+[?!]?)`, "method"},
+)
+
+var yamlPatterns = newPatterns(
+	// YAML anchors
+	[2]string{`^[\s]*(?:[\w.-]+:\s*)?&(\w+)\b`, "anchor"},
+	// Keys with nested content
+	[2]string{`^[\s]*([\w.-]+):\s*$`, "object"},
+	// Top level keys
+	[2]string{`^([\w.-]+):\s`, "property"},
+)
+
+var jsonPatterns = newPatterns(
+	// JSON nested objects
+	[2]string{`^[\s]*"([^"]+)"\s*:\s*\{`, "object"},
+	// JSON arrays
+	[2]string{`^[\s]*"([^"]+)"\s*:\s*\[`, "array"},
+	// JSON properties
+	[2]string{`^[\s]*"([^"]+)"\s*:`, "property"},
+)
+
+// genericSymbols is used for files without a registered extractor and
+// recognises the most common declaration forms across languages.
+var genericSymbols = &regexExtractor{
+	patterns: newPatterns(
+		[2]string{`^[\s]*(?:async\s+)?(?:static\s+)?(?:public\s+)?(?:private\s+)?(?:protected\s+)?(?:func|function)\s+(?:\([^)]*\)\s+)?(\w+)`, "function"},
+		[2]string{`^[\s]*(?:export\s+)?(?:const|let|var)\s+(\w+)\s*=\s*(?:async\s+)?\(.*?\)\s*=>`, "function"},
+		[2]string{`^[\s]*(?:export\s+)?(?:abstract\s+)?(?:class|type)\s+(\w+)`, "class"},
+		[2]string{`^[\s]*(?:export\s+)?interface\s+(\w+)`, "interface"},
+		[2]string{`^[\s]*(?:export\s+)?(?:const|final)\s+(\w+)`, "constant"},
+		[2]string{`^[\s]*(?:export\s+)?(?:var|let|private|public|protected)\s+(\w+)`, "variable"},
+		[2]string{`^[\s]*(?:async\s+)?(?:static\s+)?(?:public\s+)?(?:private\s+)?(?:protected\s+)?(?:def|method)\s+(\w+)`, "method"},
+		[2]string{`^(\w+):(?:\s|$)`, "property"},
+	),
+}
+
+func init() {
+	RegisterSymbolExtractor(&regexExtractor{patterns: cLikePatterns, blank: blankCLike, block: blockBraces},
+		"js", "jsx", "mjs", "cjs", "ts", "tsx", "java", "kt", "kts", "scala", "cs", "c", "h", "cpp", "cc", "hpp", "rs", "swift", "php", "dart")
+	RegisterSymbolExtractor(&regexExtractor{patterns: pythonPatterns, blank: blankHash, block: blockIndent}, "py", "pyi")
+	RegisterSymbolExtractor(&regexExtractor{patterns: rubyPatterns, blank: blankHash}, "rb")
+	RegisterSymbolExtractor(&regexExtractor{patterns: yamlPatterns, block: blockIndent}, "yml", "yaml")
+	RegisterSymbolExtractor(&regexExtractor{patterns: jsonPatterns, block: blockBrackets}, "json")
+	RegisterSymbolExtractor(goSymbols{}, "go")
+}
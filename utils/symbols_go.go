@@ -0,0 +1,91 @@
+//utils/symbols_go.go
+
+package utils
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+)
+
+// goSymbols extracts declarations from Go source with go/parser, so only
+// real functions, methods, types, constants and variables are reported.
+// Files that do not parse at all fall back to the C-like patterns.
+type goSymbols struct{}
+
+var goFallback = &regexExtractor{patterns: cLikePatterns, blank: blankCLike, block: blockBraces}
+
+func (goSymbols) Extract(content []byte) ([]Symbol, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", content, parser.SkipObjectResolution)
+	if file == nil || (err != nil && len(file.Decls) == 0) {
+		return goFallback.Extract(content)
+	}
+
+	var symbols []Symbol
+	add := func(name, typ string, from, to token.Pos) {
+		if name == "_" {
+			return
+		}
+		symbols = append(symbols, Symbol{
+			Name:    name,
+			Type:    typ,
+			Line:    fset.Position(from).Line,
+			EndLine: fset.Position(to).Line,
+		})
+	}
+
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			if d.Recv == nil || len(d.Recv.List) == 0 {
+				add(d.Name.Name, "function", d.Pos(), d.End())
+				continue
+			}
+			add(d.Name.Name, "method", d.Pos(), d.End())
+			symbols[len(symbols)-1].Receiver = types.ExprString(d.Recv.List[0].Type)
+
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				switch sp := spec.(type) {
+				case *ast.TypeSpec:
+					goTypeSymbols(sp, add)
+				case *ast.ValueSpec:
+					typ := "variable"
+					if d.Tok == token.CONST {
+						typ = "constant"
+					}
+					for _, name := range sp.Names {
+						add(name.Name, typ, name.Pos(), sp.End())
+					}
+				}
+			}
+		}
+	}
+
+	return symbols, nil
+}
+
+// goTypeSymbols adds a type declaration together with its struct fields or
+// interface methods.
+func goTypeSymbols(spec *ast.TypeSpec, add func(name, typ string, from, to token.Pos)) {
+	switch t := spec.Type.(type) {
+	case *ast.StructType:
+		add(spec.Name.Name, "class", spec.Pos(), spec.End())
+		for _, field := range t.Fields.List {
+			for _, name := range field.Names {
+				add(name.Name, "field", name.Pos(), field.End())
+			}
+		}
+	case *ast.InterfaceType:
+		add(spec.Name.Name, "interface", spec.Pos(), spec.End())
+		for _, method := range t.Methods.List {
+			for _, name := range method.Names {
+				add(name.Name, "method", name.Pos(), method.End())
+			}
+		}
+	default:
+		add(spec.Name.Name, "class", spec.Pos(), spec.End())
+	}
+}